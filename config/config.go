@@ -34,6 +34,32 @@ var (
 	STATUS    = EnvGetter("STATUS", "development")
 	KUAPI     = EnvGetter("KU_API", "xxx.xxx.xxx.xxx/route")
 
+	// Admin two-factor authentication
+	TOTPIssuer = EnvGetter("TOTP_ISSUER", "Tutorium")
+
+	// Meetings: MEETING_PROVIDER is jitsi (default), bigbluebutton or fake.
+	// MEETING_WEBHOOK_SECRET is the bearer token Jitsi and the fake provider
+	// send with presence webhooks; leave it empty if they send none.
+	MeetingProvider      = EnvGetter("MEETING_PROVIDER", "jitsi")
+	MeetingWebhookSecret = EnvGetter("MEETING_WEBHOOK_SECRET", "")
+
+	// Jitsi meetings. Rooms require a JWT signed with JITSI_APP_SECRET
+	// (Prosody token authentication); Jitsi is not used without it.
+	JITSIURL       = EnvGetter("JITSI_URL", "")
+	JITSIAppID     = EnvGetter("JITSI_APP_ID", "tutorium")
+	JITSIAppSecret = EnvGetter("JITSI_APP_SECRET", "")
+
+	// BigBlueButton meetings; BBB_URL is the server's /bigbluebutton/ path.
+	// Set BBB_WEBHOOKS=true once bbb-webhooks posts to /webhooks/meetings.
+	BBBURL      = EnvGetter("BBB_URL", "")
+	BBBSecret   = EnvGetter("BBB_SECRET", "")
+	BBBWebhooks = EnvGetter("BBB_WEBHOOKS", "false")
+
+	// Calendar feeds: CALENDAR_SESSION_URL is the web app's page for a class
+	// session, with {id} standing for its ID. Events carry no link without it;
+	// the API's own /meetings/:id needs a bearer token a calendar cannot send.
+	CalendarSessionURL = EnvGetter("CALENDAR_SESSION_URL", "")
+
 	// MinIO
 	MINIOEndpoint  = EnvGetter("MINIO_ENDPOINT", "localhost:9000")
 	MINIOAccessKey = EnvGetter("MINIO_ACCESS_KEY", "minioadmin")
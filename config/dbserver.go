@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -17,13 +18,15 @@ type Config struct {
 }
 
 func (c *Config) DBUrl() string {
-	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Asia/Bangkok",
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
 		c.DBHost, c.DBUser, c.DBPassword, c.DBName, c.DBPort)
 }
 
 func ConnectDB(cfg *Config) (*gorm.DB, error) {
 	dbUrl := cfg.DBUrl()
-	dbConfig := &gorm.Config{}
+	// Timestamps are stored and read back in UTC; users' timezones only
+	// apply when times are rendered or interpreted as wall-clock times.
+	dbConfig := &gorm.Config{NowFunc: func() time.Time { return time.Now().UTC() }}
 
 	modes := map[string]logger.LogLevel{
 		"silent": logger.Silent,
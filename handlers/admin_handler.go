@@ -14,7 +14,7 @@ import (
 func AdminRoutes(app *fiber.App) {
 	admin := app.Group("/admins", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware())
 
-	admin.Post("/", CreateAdmin)
+	admin.Post("/", middlewares.AdminRequired(), middlewares.StepUpRequired(middlewares.StepUpMaxAge), CreateAdmin)
 	admin.Get("/", GetAdmins)
 	admin.Get("/:id", GetAdmin)
 	// admin.Put("/admin/:id", UpdateAdmin) No application logic for updating admin
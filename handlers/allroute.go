@@ -11,7 +11,11 @@ func AllRoutes(app *fiber.App) {
 	ClassCategoryRoutes(app)
 	ClassRoutes(app)
 	ClassSessionRoutes(app)
+	ClassSessionSeriesRoutes(app)
 	EnrollmentRoutes(app)
+	AvailabilityRoutes(app)
+	CalendarRoutes(app)
+	AttendanceRoutes(app)
 	LearnerRoutes(app)
 	NotificationRoutes(app)
 	ReportRoutes(app)
@@ -19,6 +23,12 @@ func AllRoutes(app *fiber.App) {
 	TeacherRoutes(app)
 	UserRoutes(app)
 	LoginRoutes(app)
+	TwoFactorRoutes(app)
+	APIKeyRoutes(app)
+	ModerationRoutes(app)
+	BanAppealRoutes(app)
 	PaymentRoutes(app)
 	MeetingRoutes(app)
+	StorageRoutes(app)
+	UploadRoutes(app)
 }
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func APIKeyRoutes(app *fiber.App) {
+	apiKey := app.Group("/api_keys", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware(), middlewares.AdminRequired())
+
+	apiKey.Post("/", middlewares.StepUpRequired(middlewares.StepUpMaxAge), CreateAPIKey)
+	apiKey.Get("/", GetAPIKeys)
+	apiKey.Get("/:id", GetAPIKey)
+	apiKey.Delete("/:id", RevokeAPIKey)
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateAPIKey godoc
+//
+//	@Summary		Create an API key
+//	@Description	Creates a scoped API key owned by the calling admin. The raw key is returned only once; send it in the X-API-Key header.
+//	@Tags			APIKeys
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			api_key	body		models.CreateAPIKeyRequestDoc	true	"API key payload"
+//	@Success		201		{object}	models.CreateAPIKeyResponseDoc
+//	@Failure		400		{object}	map[string]string	"Invalid input"
+//	@Failure		401		{object}	map[string]string	"Unauthorized"
+//	@Failure		500		{object}	map[string]string	"Server error"
+//	@Router			/api_keys [post]
+func CreateAPIKey(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	raw, key, err := services.CreateAPIKey(db, user.ID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKeyRequest) {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"key":     raw,
+		"api_key": key,
+	})
+}
+
+// GetAPIKeys godoc
+//
+//	@Summary		List API keys
+//	@Description	Lists all API keys, including revoked and expired ones
+//	@Tags			APIKeys
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{array}		models.APIKeyDoc
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/api_keys [get]
+func GetAPIKeys(c *fiber.Ctx) error {
+	keys := []models.APIKey{}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	if err := db.Order("id desc").Find(&keys).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).JSON(keys)
+}
+
+func findAPIKey(db *gorm.DB, id int, key *models.APIKey) error {
+	return db.First(key, "id = ?", id).Error
+}
+
+// GetAPIKey godoc
+//
+//	@Summary		Get API key by ID
+//	@Description	Retrieves the metadata of a single API key
+//	@Tags			APIKeys
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"API key ID"
+//	@Success		200	{object}	models.APIKeyDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		404	{string}	string	"API key not found"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/api_keys/{id} [get]
+func GetAPIKey(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+
+	var key models.APIKey
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	err = findAPIKey(db, id, &key)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("api key not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).JSON(key)
+}
+
+// RevokeAPIKey godoc
+//
+//	@Summary		Revoke an API key
+//	@Description	Marks an API key as revoked; it is rejected immediately but kept for auditing
+//	@Tags			APIKeys
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"API key ID"
+//	@Success		200	{object}	models.APIKeyDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		404	{string}	string	"API key not found"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/api_keys/{id} [delete]
+func RevokeAPIKey(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+
+	var key models.APIKey
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	err = findAPIKey(db, id, &key)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("api key not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		if err := db.Model(&key).Update("revoked_at", now).Error; err != nil {
+			return c.Status(500).JSON(err.Error())
+		}
+		key.RevokedAt = &now
+	}
+
+	return c.Status(200).JSON(key)
+}
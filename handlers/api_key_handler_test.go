@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+/* ------------------ CreateAPIKey ------------------ */
+
+// 201
+func TestCreateAPIKey_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+	ExpInsertReturningID("api_keys", 1)(mock)
+
+	expires := time.Now().Add(30 * 24 * time.Hour)
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/api_keys/",
+		Body:        jsonBody(CreateAPIKeyRequest{Name: "LMS sync", Scopes: []string{"class_sessions:read"}, ExpiresAt: &expires}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	var out struct {
+		Key    string        `json:"key"`
+		APIKey models.APIKey `json:"api_key"`
+	}
+	if err := json.Unmarshal(readBody(t, resp.Body), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.HasPrefix(out.Key, "tut_"+out.APIKey.Prefix+"_") {
+		t.Fatalf("key %q does not carry prefix %q", out.Key, out.APIKey.Prefix)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestCreateAPIKey_InvalidScope(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/api_keys/",
+		Body:        jsonBody(CreateAPIKeyRequest{Name: "bad", Scopes: []string{"api_keys:write"}}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 500
+func TestCreateAPIKey_DBError(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+	ExpInsertError("api_keys", fmt.Errorf("insert failed"))(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/api_keys/",
+		Body:        jsonBody(CreateAPIKeyRequest{Name: "Recording sync", Scopes: []string{"uploads:write", "storage:read"}}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusInternalServerError)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 401
+func TestCreateAPIKey_Unauthenticated(t *testing.T) {
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			*payload = jsonBody(CreateAPIKeyRequest{Name: "LMS sync", Scopes: []string{"class_sessions:read"}})
+		},
+		http.StatusUnauthorized,
+		http.MethodPost,
+		"/api_keys/",
+	)
+}
+
+/* ------------------ GetAPIKeys ------------------ */
+
+// 200
+func TestGetAPIKeys_OK(t *testing.T) {
+	userID := uint(42)
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, true, false, false)(mock)
+			ExpListRows("api_keys", []string{"id", "prefix"}, []any{1, "3f9a1c2b"}, []any{2, "0b1c2d3e"})(mock)
+			*uID = userID
+		},
+		http.StatusOK,
+		http.MethodGet,
+		"/api_keys/",
+	)
+}
+
+/* ------------------ GetAPIKey ------------------ */
+
+// 404
+func TestGetAPIKey_NotFound(t *testing.T) {
+	userID := uint(42)
+	keyID := uint(9)
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, true, false, false)(mock)
+			ExpSelectByIDEmpty("api_keys", keyID)(mock)
+			*uID = userID
+		},
+		http.StatusNotFound,
+		http.MethodGet,
+		fmt.Sprintf("/api_keys/%d", keyID),
+	)
+}
+
+/* ------------------ RevokeAPIKey ------------------ */
+
+// 200
+func TestRevokeAPIKey_OK(t *testing.T) {
+	userID := uint(42)
+	keyID := uint(3)
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, true, false, false)(mock)
+			ExpSelectByIDFound("api_keys", keyID, []string{"id", "prefix"}, []any{keyID, "3f9a1c2b"})(mock)
+			ExpUpdateOK("api_keys")(mock)
+			*uID = userID
+		},
+		http.StatusOK,
+		http.MethodDelete,
+		fmt.Sprintf("/api_keys/%d", keyID),
+	)
+}
+
+// 400
+func TestRevokeAPIKey_BadID(t *testing.T) {
+	userID := uint(42)
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, true, false, false)(mock)
+			*uID = userID
+		},
+		http.StatusBadRequest,
+		http.MethodDelete,
+		"/api_keys/abc",
+	)
+}
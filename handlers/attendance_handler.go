@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func AttendanceRoutes(app *fiber.App) {
+	attendance := app.Group("/attendance", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware())
+	attendance.Get("/sessions/:id", GetSessionAttendance)
+	attendance.Get("/learners/:id", GetLearnerAttendance)
+	attendance.Post("/sessions/:id/check-in", middlewares.LearnerRequired(), middlewares.BanMiddleware(models.BanScopeLearning), CheckInToSession)
+	attendance.Post("/sessions/:id/code", middlewares.TeacherRequired(), middlewares.BanMiddleware(models.BanScopeTeaching), IssueCheckInCode)
+	attendance.Post("/sessions/:id/roll-call", middlewares.TeacherRequired(), middlewares.BanMiddleware(models.BanScopeTeaching), SubmitRollCall)
+}
+
+type RollCallEntry struct {
+	LearnerID uint   `json:"learner_id"`
+	Status    string `json:"status"`
+}
+
+type RollCallRequest struct {
+	Entries []RollCallEntry `json:"entries"`
+}
+
+type CheckInRequest struct {
+	Code string `json:"code"`
+}
+
+// loadSessionForCaller fetches class session :id with its Class and the
+// caller, writing the error response itself when either is missing.
+func loadSessionForCaller(c *fiber.Ctx, db *gorm.DB, session *models.ClassSession) (*models.User, error) {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return nil, c.Status(401).JSON("unauthorized")
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return nil, c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+
+	err = db.Preload("Class").First(session, "id = ?", id).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, c.Status(404).JSON("class session not found")
+	case err != nil:
+		return nil, c.Status(500).JSON(err.Error())
+	}
+	return user, nil
+}
+
+// attendanceErrorStatus maps attendance service errors to HTTP status codes.
+func attendanceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidAttendanceStatus):
+		return 400
+	case errors.Is(err, services.ErrInvalidCheckInCode), errors.Is(err, services.ErrNotSessionTeacher), errors.Is(err, services.ErrNotEnrolled):
+		return 403
+	case errors.Is(err, services.ErrCheckInClosed):
+		return 409
+	case errors.Is(err, services.ErrCheckInLocked):
+		return 429
+	default:
+		return 500
+	}
+}
+
+// GetSessionAttendance godoc
+//
+//	@Summary		Attendance summary for a class session
+//	@Description	Counts present, late, absent and unrecorded learners for a session and lists every attendance record. Only the session's teacher or an admin may view it.
+//	@Tags			Attendance
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"ClassSession ID"
+//	@Success		200	{object}	models.SessionAttendanceSummaryDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		403	{string}	string	"Not allowed"
+//	@Failure		404	{string}	string	"Class session not found"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/attendance/sessions/{id} [get]
+func GetSessionAttendance(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var session models.ClassSession
+	user, err := loadSessionForCaller(c, db, &session)
+	if user == nil {
+		return err
+	}
+	if user.Admin == nil && !services.IsSessionTeacher(user, &session) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+
+	summary, err := services.SessionAttendance(db, session.ID)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(summary)
+}
+
+// GetLearnerAttendance godoc
+//
+//	@Summary		Attendance summary for a learner
+//	@Description	Counts attended sessions and no-shows across a learner's finished enrollments. Learners may view their own summary; admins may view anyone's.
+//	@Tags			Attendance
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Learner ID"
+//	@Success		200	{object}	models.LearnerAttendanceSummaryDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		403	{string}	string	"Not allowed"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/attendance/learners/{id} [get]
+func GetLearnerAttendance(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON("unauthorized")
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	if user.Admin == nil && (user.Learner == nil || user.Learner.ID != uint(id)) {
+		return c.Status(403).JSON("you can only view your own attendance")
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	summary, err := services.LearnerAttendance(db, uint(id), time.Now())
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(summary)
+}
+
+// IssueCheckInCode godoc
+//
+//	@Summary		Show the check-in code for a class session
+//	@Description	Returns the six-digit code learners enter to check in and records the teacher as present. Each code is accepted for 5 minutes; once expires_at passes, call again for a new one. Codes are only available from 15 minutes before the session starts until it finishes.
+//	@Tags			Attendance
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"ClassSession ID"
+//	@Success		200	{object}	models.CheckInCodeDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		403	{string}	string	"Not the session's teacher"
+//	@Failure		404	{string}	string	"Class session not found"
+//	@Failure		409	{string}	string	"Session not running"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/attendance/sessions/{id}/code [post]
+func IssueCheckInCode(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var session models.ClassSession
+	user, err := loadSessionForCaller(c, db, &session)
+	if user == nil {
+		return err
+	}
+	if !services.IsSessionTeacher(user, &session) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+
+	now := time.Now()
+	code, expires, err := services.IssueCheckInCode(db, &session, now)
+	if err != nil {
+		return c.Status(attendanceErrorStatus(err)).JSON(err.Error())
+	}
+	if err := services.RecordTeacherCheckIn(db, &session, now); err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(fiber.Map{"code": code, "expires_at": expires})
+}
+
+// CheckInToSession godoc
+//
+//	@Summary		Check in to a class session
+//	@Description	Records the calling learner as present (or late, more than 10 minutes after the start) using the code the teacher currently shows during class. After 5 wrong or expired codes in a row the learner must wait 15 minutes before trying again.
+//	@Tags			Attendance
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"ClassSession ID"
+//	@Param			check_in	body		models.CheckInDoc	true	"Check-in code"
+//	@Success		200			{object}	models.AttendanceDoc
+//	@Failure		400			{string}	string	"Invalid input"
+//	@Failure		403			{string}	string	"Wrong code or not enrolled"
+//	@Failure		404			{string}	string	"Class session not found"
+//	@Failure		409			{string}	string	"Session not running"
+//	@Failure		429			{string}	string	"Too many wrong codes"
+//	@Failure		500			{string}	string	"Server error"
+//	@Router			/attendance/sessions/{id}/check-in [post]
+func CheckInToSession(c *fiber.Ctx) error {
+	var req CheckInRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var session models.ClassSession
+	user, err := loadSessionForCaller(c, db, &session)
+	if user == nil {
+		return err
+	}
+	if user.Learner == nil {
+		return c.Status(403).JSON("only learners can check in")
+	}
+
+	record, err := services.CheckIn(db, &session, user.Learner.ID, req.Code, time.Now())
+	if err != nil {
+		return c.Status(attendanceErrorStatus(err)).JSON(err.Error())
+	}
+	return c.Status(200).JSON(record)
+}
+
+// SubmitRollCall godoc
+//
+//	@Summary		Submit a roll call for a class session
+//	@Description	Records each listed learner as present, late or absent, all or none of them. Roll call is open from shortly before the session starts until a day after it ends, except for cancelled sessions. A teacher's roll call overrides self check-ins and meeting joins, and marking a learner present clears a no-show, lowering their no-show count and removing the flag it caused.
+//	@Tags			Attendance
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int					true	"ClassSession ID"
+//	@Param			roll_call	body		models.RollCallDoc	true	"Attendance entries"
+//	@Success		200			{array}		models.AttendanceDoc
+//	@Failure		400			{string}	string	"Invalid input"
+//	@Failure		403			{string}	string	"Not the session's teacher or learner not enrolled"
+//	@Failure		404			{string}	string	"Class session not found"
+//	@Failure		409			{string}	string	"Roll call not open"
+//	@Failure		500			{string}	string	"Server error"
+//	@Router			/attendance/sessions/{id}/roll-call [post]
+func SubmitRollCall(c *fiber.Ctx) error {
+	var req RollCallRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	if len(req.Entries) == 0 {
+		return c.Status(400).JSON("entries must not be empty")
+	}
+	for _, e := range req.Entries {
+		if !models.ValidAttendanceStatus(e.Status) {
+			return c.Status(400).JSON(services.ErrInvalidAttendanceStatus.Error())
+		}
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var session models.ClassSession
+	user, err := loadSessionForCaller(c, db, &session)
+	if user == nil {
+		return err
+	}
+	if !services.IsSessionTeacher(user, &session) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+	now := time.Now()
+	switch {
+	case models.NormalizeSessionStatus(session.ClassStatus) == models.SessionStatusCancelled:
+		return c.Status(409).JSON("there is no roll call for a cancelled session")
+	case now.Before(session.ClassStart.Add(-services.AttendanceWindowLead)):
+		return c.Status(409).JSON("roll call opens shortly before the session starts")
+	case now.After(session.ClassFinish.Add(models.AttendanceCheckLookback)):
+		return c.Status(409).JSON("roll call closes a day after the session ends")
+	}
+
+	// The roll call is saved as a whole: one learner who cannot be recorded
+	// leaves the others as they were.
+	records := make([]models.Attendance, 0, len(req.Entries))
+	var failed uint
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, e := range req.Entries {
+			record, err := services.RecordAttendance(tx, session.ID, e.LearnerID, e.Status, models.AttendanceSourceRollCall, &user.ID, now)
+			if err != nil {
+				failed = e.LearnerID
+				return err
+			}
+			records = append(records, *record)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(attendanceErrorStatus(err)).JSON(fiber.Map{"error": err.Error(), "learner_id": failed})
+	}
+	return c.Status(200).JSON(records)
+}
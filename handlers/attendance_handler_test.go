@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+)
+
+func sessionTeacherUser(userID, teacherID uint) *models.User {
+	u := &models.User{StudentID: "b6600000005", Teacher: &models.Teacher{UserID: userID}}
+	u.ID = userID
+	u.Teacher.ID = teacherID
+	return u
+}
+
+func enrolledLearnerUser(userID, learnerID uint) *models.User {
+	u := &models.User{StudentID: "b6600000006", Learner: &models.Learner{UserID: userID}}
+	u.ID = userID
+	u.Learner.ID = learnerID
+	return u
+}
+
+// expSessionWithClass expects the class session :id lookup with its Class
+// preloaded. A non-empty code was issued a minute ago.
+func expSessionWithClass(mock sqlmock.Sqlmock, sessionID, teacherID uint, start time.Time, code string) {
+	var issuedAt *time.Time
+	if code != "" {
+		at := time.Now().Add(-time.Minute)
+		issuedAt = &at
+	}
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "class_start", "class_finish", "check_in_code", "check_in_code_issued_at"}).
+			AddRow(sessionID, 12, start, start.Add(2*time.Hour), code, issuedAt))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, teacherID))
+}
+
+// expNoShowCorrection expects RecordAttendance to put a no_show enrollment
+// back to active; corrected is how many rows it finds to correct.
+func expNoShowCorrection(mock sqlmock.Sqlmock, corrected int64) {
+	mock.ExpectExec(`UPDATE "enrollments" SET "enrollment_status"=\$1,"updated_at"=\$2 WHERE \(learner_id = \$3 AND class_session_id = \$4 AND enrollment_status = \$5\)`).
+		WithArgs(models.EnrollmentStatusActive, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), models.EnrollmentStatusNoShow).
+		WillReturnResult(sqlmock.NewResult(0, corrected))
+}
+
+/* ------------------ SubmitRollCall ------------------ */
+
+// 200
+func TestSubmitRollCall_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	expSessionWithClass(mock, 3, 30, time.Now().Add(-30*time.Minute), "")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments" WHERE \(learner_id = \$1 AND class_session_id = \$2 AND enrollment_status IN \(\$3,\$4\)\)`).
+		WithArgs(9, 3, models.EnrollmentStatusActive, models.EnrollmentStatusNoShow).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "attendances" WHERE \(class_session_id = \$1 AND learner_id = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO "attendances"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expNoShowCorrection(mock, 0)
+	mock.ExpectExec(`UPDATE "enrollments" SET "attended_at"=\$1,"updated_at"=\$2 WHERE \(learner_id = \$3 AND class_session_id = \$4\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/attendance/sessions/3/roll-call",
+		Body:        jsonBody(RollCallRequest{Entries: []RollCallEntry{{LearnerID: 9, Status: models.AttendancePresent}}}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200: marking a no-show present takes back the no-show and the flag it caused.
+func TestSubmitRollCall_CorrectsNoShow(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	expSessionWithClass(mock, 3, 30, time.Now().Add(-30*time.Minute), "")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "attendances" WHERE \(class_session_id = \$1 AND learner_id = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO "attendances"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expNoShowCorrection(mock, 1)
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "flag_threshold", "no_show_flag_threshold"}).AddRow(1, 3, 3))
+	mock.ExpectQuery(`SELECT \* FROM "learners" WHERE "learners"\."id" = \$1 .* FOR UPDATE`).
+		WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "flag_count", "no_show_count"}).AddRow(9, 7, 2, 3))
+	mock.ExpectExec(`UPDATE "learners" SET "flag_count"=\$1,"no_show_count"=\$2,"updated_at"=\$3 WHERE`).
+		WithArgs(1, 2, sqlmock.AnyArg(), 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "enrollments" SET "attended_at"=\$1,"updated_at"=\$2 WHERE \(learner_id = \$3 AND class_session_id = \$4\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/attendance/sessions/3/roll-call",
+		Body:        jsonBody(RollCallRequest{Entries: []RollCallEntry{{LearnerID: 9, Status: models.AttendancePresent}}}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestSubmitRollCall_NotSessionTeacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(6, 31))
+	expSessionWithClass(mock, 3, 30, time.Now(), "")
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/attendance/sessions/3/roll-call",
+		Body:        jsonBody(RollCallRequest{Entries: []RollCallEntry{{LearnerID: 9, Status: models.AttendanceAbsent}}}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestSubmitRollCall_InvalidStatus(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/attendance/sessions/3/roll-call",
+		Body:        jsonBody(RollCallRequest{Entries: []RollCallEntry{{LearnerID: 9, Status: "sleeping"}}}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403: a learner who is not enrolled fails the whole roll call.
+func TestSubmitRollCall_AllOrNothing(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	expSessionWithClass(mock, 3, 30, time.Now().Add(-30*time.Minute), "")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments"`).
+		WithArgs(9, 3, models.EnrollmentStatusActive, models.EnrollmentStatusNoShow).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "attendances" WHERE \(class_session_id = \$1 AND learner_id = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO "attendances"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`UPDATE "enrollments" SET "attended_at"=\$1`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments"`).
+		WithArgs(10, 3, models.EnrollmentStatusActive, models.EnrollmentStatusNoShow).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/attendance/sessions/3/roll-call",
+		Body: jsonBody(RollCallRequest{Entries: []RollCallEntry{
+			{LearnerID: 9, Status: models.AttendanceAbsent},
+			{LearnerID: 10, Status: models.AttendanceAbsent},
+		}}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	var body map[string]any
+	if err := json.Unmarshal(readBody(t, resp.Body), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body["learner_id"] != float64(10) {
+		t.Fatalf("expected the failing learner in the response, got %v", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestSubmitRollCall_LongAfterSession(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	expSessionWithClass(mock, 3, 30, time.Now().Add(-3*24*time.Hour), "")
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/attendance/sessions/3/roll-call",
+		Body:        jsonBody(RollCallRequest{Entries: []RollCallEntry{{LearnerID: 9, Status: models.AttendancePresent}}}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestSubmitRollCall_CancelledSession(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Now().Add(-30 * time.Minute)
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "class_start", "class_finish", "class_status"}).
+			AddRow(3, 12, start, start.Add(2*time.Hour), models.SessionStatusCancelled))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/attendance/sessions/3/roll-call",
+		Body:        jsonBody(RollCallRequest{Entries: []RollCallEntry{{LearnerID: 9, Status: models.AttendancePresent}}}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ IssueCheckInCode ------------------ */
+
+// 200: a code older than CheckInCodeLifetime is replaced.
+func TestIssueCheckInCode_RotatesExpiredCode(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Now().Add(-30 * time.Minute)
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "class_start", "class_finish", "check_in_code", "check_in_code_issued_at", "teacher_checked_in_at"}).
+			AddRow(3, 12, start, start.Add(2*time.Hour), "482913", time.Now().Add(-6*time.Minute), start))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "class_sessions" SET "check_in_code"=\$1,"check_in_code_issued_at"=\$2,"updated_at"=\$3 WHERE \(id = \$4 AND check_in_code = \$5\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 3, "482913").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/attendance/sessions/3/code"})
+	wantStatus(t, resp, http.StatusOK)
+
+	var body struct {
+		Code      string    `json:"code"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(readBody(t, resp.Body), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Code) != 6 || body.ExpiresAt.After(time.Now().Add(services.CheckInCodeLifetime)) {
+		t.Fatalf("unexpected code %+v", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ CheckInToSession ------------------ */
+
+// expCheckInEnrollment expects learner 9's enrollment in session 3 to be
+// locked for a check-in.
+func expCheckInEnrollment(mock sqlmock.Sqlmock, failures int, lockedUntil *time.Time) {
+	mock.ExpectQuery(`SELECT \* FROM "enrollments" WHERE \(learner_id = \$1 AND class_session_id = \$2 AND enrollment_status IN \(\$3,\$4\)\) .*FOR UPDATE`).
+		WithArgs(9, 3, models.EnrollmentStatusActive, models.EnrollmentStatusNoShow, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "learner_id", "class_session_id", "enrollment_status", "check_in_failures", "check_in_locked_until"}).
+			AddRow(4, 9, 3, models.EnrollmentStatusActive, failures, lockedUntil))
+}
+
+// 403
+func TestCheckInToSession_WrongCode(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	expSessionWithClass(mock, 3, 30, time.Now().Add(-5*time.Minute), "482913")
+	mock.ExpectBegin()
+	expCheckInEnrollment(mock, 1, nil)
+	mock.ExpectExec(`UPDATE "enrollments" SET "check_in_failures"=\$1,"updated_at"=\$2 WHERE`).
+		WithArgs(2, sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/attendance/sessions/3/check-in",
+		Body:        jsonBody(CheckInRequest{Code: "000000"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403: the fifth wrong code in a row locks the learner out.
+func TestCheckInToSession_LocksAfterRepeatedFailures(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	expSessionWithClass(mock, 3, 30, time.Now().Add(-5*time.Minute), "482913")
+	mock.ExpectBegin()
+	expCheckInEnrollment(mock, 4, nil)
+	mock.ExpectExec(`UPDATE "enrollments" SET "check_in_failures"=\$1,"check_in_locked_until"=\$2,"updated_at"=\$3 WHERE`).
+		WithArgs(0, sqlmock.AnyArg(), sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/attendance/sessions/3/check-in",
+		Body:        jsonBody(CheckInRequest{Code: "000000"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 429: even the right code is refused while locked out.
+func TestCheckInToSession_LockedOut(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	expSessionWithClass(mock, 3, 30, time.Now().Add(-5*time.Minute), "482913")
+	lockedUntil := time.Now().Add(10 * time.Minute)
+	mock.ExpectBegin()
+	expCheckInEnrollment(mock, 0, &lockedUntil)
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/attendance/sessions/3/check-in",
+		Body:        jsonBody(CheckInRequest{Code: "482913"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusTooManyRequests)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestCheckInToSession_NotRunning(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	expSessionWithClass(mock, 3, 30, time.Now().Add(24*time.Hour), "482913")
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/attendance/sessions/3/check-in",
+		Body:        jsonBody(CheckInRequest{Code: "482913"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ GetLearnerAttendance ------------------ */
+
+// 403
+func TestGetLearnerAttendance_OtherLearner(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodGet,
+		Path:   "/attendance/learners/10",
+	})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
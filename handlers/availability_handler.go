@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func AvailabilityRoutes(app *fiber.App) {
+	availability := app.Group("/availability", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware())
+	availability.Get("/teachers/:id", GetTeacherAvailability)
+	availability.Get("/classes/:id/slots", GetBookableSlots)
+	availability.Post("/classes/:id/book", middlewares.LearnerRequired(), middlewares.BanMiddleware(models.BanScopeLearning), BookPrivateLesson)
+
+	availabilityProtected := availability.Group("/", middlewares.TeacherRequired(), middlewares.BanMiddleware(models.BanScopeTeaching))
+	availabilityProtected.Post("/windows", CreateAvailabilityWindow)
+	availabilityProtected.Delete("/windows/:id", DeleteAvailabilityWindow)
+	availabilityProtected.Post("/exceptions", CreateAvailabilityException)
+	availabilityProtected.Delete("/exceptions/:id", DeleteAvailabilityException)
+}
+
+// availabilityErrorStatus maps availability and booking errors to HTTP status codes.
+func availabilityErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidTimezone), errors.Is(err, services.ErrInvalidAvailabilityWindow),
+		errors.Is(err, services.ErrInvalidException), errors.Is(err, services.ErrInvalidSlotRange),
+		errors.Is(err, services.ErrNotBookable):
+		return 400
+	case errors.Is(err, services.ErrInsufficientBalance):
+		return 402
+	case errors.Is(err, services.ErrSlotUnavailable):
+		return 409
+	default:
+		return 500
+	}
+}
+
+// loadBookableClass fetches class :id, writing the error response itself when it is missing.
+func loadBookableClass(c *fiber.Ctx, db *gorm.DB, class *models.Class) (bool, error) {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return false, c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	err = db.First(class, "id = ?", id).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, c.Status(404).JSON("class not found")
+	case err != nil:
+		return false, c.Status(500).JSON(err.Error())
+	}
+	return true, nil
+}
+
+// GetTeacherAvailability godoc
+//
+//	@Summary		Get a teacher's availability
+//	@Description	Lists the teacher's weekly private-lesson windows and the exceptions that have not ended yet
+//	@Tags			Availability
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Teacher ID"
+//	@Success		200	{object}	models.TeacherAvailabilityResponseDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/availability/teachers/{id} [get]
+func GetTeacherAvailability(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	resp := models.TeacherAvailabilityResponse{TeacherID: uint(id)}
+	if err := db.Where("teacher_id = ?", id).Order("weekday, start_time").Find(&resp.Windows).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	if err := db.Where("teacher_id = ? AND end_at > ?", id, time.Now()).Order("start_at").Find(&resp.Exceptions).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(resp)
+}
+
+// CreateAvailabilityWindow godoc
+//
+//	@Summary		Add a weekly availability window
+//	@Description	Adds a weekly window, in wall-clock time of the given IANA timezone (default: the teacher's own timezone), in which learners can book private lessons with the calling teacher
+//	@Tags			Availability
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			window	body		models.TeacherAvailabilityDoc	true	"Weekly window"
+//	@Success		201		{object}	models.TeacherAvailabilityDoc
+//	@Failure		400		{string}	string	"Invalid input"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/availability/windows [post]
+func CreateAvailabilityWindow(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok || user.Teacher == nil {
+		return c.Status(403).JSON("teacher access required")
+	}
+
+	var window models.TeacherAvailability
+	if err := c.BodyParser(&window); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	window.ID = 0
+	window.TeacherID = user.Teacher.ID
+	if window.Timezone == "" {
+		window.Timezone = services.UserLocation(user).String()
+	}
+	if err := services.ValidateAvailabilityWindow(&window); err != nil {
+		return c.Status(availabilityErrorStatus(err)).JSON(err.Error())
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	if err := db.Omit("Teacher").Create(&window).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(201).JSON(window)
+}
+
+// DeleteAvailabilityWindow godoc
+//
+//	@Summary		Remove a weekly availability window
+//	@Description	Removes one of the calling teacher's windows. Lessons already booked are kept.
+//	@Tags			Availability
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int		true	"Window ID"
+//	@Success		200	{string}	string	"Successfully deleted availability window"
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		404	{string}	string	"Availability window not found"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/availability/windows/{id} [delete]
+func DeleteAvailabilityWindow(c *fiber.Ctx) error {
+	return deleteTeacherOwned(c, &models.TeacherAvailability{}, "availability window")
+}
+
+// CreateAvailabilityException godoc
+//
+//	@Summary		Block out time
+//	@Description	Blocks out a time range, e.g. a holiday, so no private lessons can be booked in it
+//	@Tags			Availability
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			exception	body		models.AvailabilityExceptionDoc	true	"Blocked time range"
+//	@Success		201			{object}	models.AvailabilityExceptionDoc
+//	@Failure		400			{string}	string	"Invalid input"
+//	@Failure		500			{string}	string	"Server error"
+//	@Router			/availability/exceptions [post]
+func CreateAvailabilityException(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok || user.Teacher == nil {
+		return c.Status(403).JSON("teacher access required")
+	}
+
+	var exception models.AvailabilityException
+	if err := c.BodyParser(&exception); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	exception.ID = 0
+	exception.TeacherID = user.Teacher.ID
+	if err := services.ValidateAvailabilityException(&exception); err != nil {
+		return c.Status(availabilityErrorStatus(err)).JSON(err.Error())
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	if err := db.Omit("Teacher").Create(&exception).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(201).JSON(exception)
+}
+
+// DeleteAvailabilityException godoc
+//
+//	@Summary		Remove blocked time
+//	@Description	Removes one of the calling teacher's availability exceptions
+//	@Tags			Availability
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int		true	"Exception ID"
+//	@Success		200	{string}	string	"Successfully deleted availability exception"
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		404	{string}	string	"Availability exception not found"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/availability/exceptions/{id} [delete]
+func DeleteAvailabilityException(c *fiber.Ctx) error {
+	return deleteTeacherOwned(c, &models.AvailabilityException{}, "availability exception")
+}
+
+// deleteTeacherOwned deletes record :id when it belongs to the calling teacher.
+func deleteTeacherOwned(c *fiber.Ctx, record interface{}, name string) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok || user.Teacher == nil {
+		return c.Status(403).JSON("teacher access required")
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	res := db.Where("id = ? AND teacher_id = ?", id, user.Teacher.ID).Delete(record)
+	if res.Error != nil {
+		return c.Status(500).JSON(res.Error.Error())
+	}
+	if res.RowsAffected == 0 {
+		return c.Status(404).JSON(name + " not found")
+	}
+	return c.Status(200).JSON("Successfully deleted " + name)
+}
+
+// GetBookableSlots godoc
+//
+//	@Summary		List bookable private-lesson slots
+//	@Description	Computes the free private-lesson slots of a class from its teacher's weekly windows, minus exceptions and sessions the teacher already teaches. Slots are returned in the requested timezone.
+//	@Tags			Availability
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id		path		int		true	"Class ID"
+//	@Param			from	query		string	false	"First day (YYYY-MM-DD in tz) or instant (RFC 3339); defaults to now"
+//	@Param			days	query		int		false	"Number of days to cover (default 7, max 31)"
+//	@Param			tz		query		string	false	"IANA timezone to return slots in (default: the caller's timezone)"
+//	@Success		200		{array}		models.BookableSlotDoc
+//	@Failure		400		{string}	string	"Invalid input or class does not offer private lessons"
+//	@Failure		404		{string}	string	"Class not found"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/availability/classes/{id}/slots [get]
+func GetBookableSlots(c *fiber.Ctx) error {
+	loc, err := services.LoadTimezone(c.Query("tz"))
+	if err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	if user, ok := c.Locals("currentUser").(*models.User); ok && c.Query("tz") == "" {
+		loc = services.UserLocation(user)
+	}
+
+	now := time.Now()
+	from := now
+	if raw := c.Query("from"); raw != "" {
+		if from, err = time.ParseInLocation("2006-01-02", raw, loc); err != nil {
+			if from, err = time.Parse(time.RFC3339, raw); err != nil {
+				return c.Status(400).JSON("from must be YYYY-MM-DD or RFC 3339")
+			}
+		}
+	}
+	days := c.QueryInt("days", 7)
+	if days < 1 || days > services.MaxSlotRangeDays {
+		return c.Status(400).JSON(services.ErrInvalidSlotRange.Error())
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	var class models.Class
+	if ok, err := loadBookableClass(c, db, &class); !ok {
+		return err
+	}
+
+	slots, err := services.BookableSlots(db, &class, from, from.AddDate(0, 0, days), loc, now)
+	if err != nil {
+		return c.Status(availabilityErrorStatus(err)).JSON(err.Error())
+	}
+	return c.Status(200).JSON(slots)
+}
+
+// BookPrivateLesson godoc
+//
+//	@Summary		Book a private lesson
+//	@Description	Books one of the class's bookable slots for the calling learner. The lesson price is paid from the learner's balance and a one-learner class session with its enrollment is created in the same transaction.
+//	@Tags			Availability
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int									true	"Class ID"
+//	@Param			booking	body		models.BookPrivateLessonRequestDoc	true	"Slot start"
+//	@Success		201		{object}	models.PrivateLessonBookingDoc
+//	@Failure		400		{string}	string	"Invalid input or class does not offer private lessons"
+//	@Failure		402		{string}	string	"Insufficient balance"
+//	@Failure		404		{string}	string	"Class not found"
+//	@Failure		409		{object}	models.ScheduleConflictErrorDoc	"Slot taken or overlaps another of the learner's sessions"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/availability/classes/{id}/book [post]
+func BookPrivateLesson(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok || user.Learner == nil {
+		return c.Status(403).JSON("learner access required")
+	}
+
+	var req models.BookPrivateLessonRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	if req.ClassStart.IsZero() {
+		return c.Status(400).JSON("class_start is required")
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	var class models.Class
+	if ok, err := loadBookableClass(c, db, &class); !ok {
+		return err
+	}
+
+	room, err := newMeetingRoom(c, class.ClassName, req.ClassStart, req.ClassStart.Add(time.Duration(class.PrivateLessonMinutes)*time.Minute))
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	booking, err := services.BookPrivateLesson(db, &class, user.Learner, req.ClassStart, req.Description, room, time.Now())
+	if err != nil {
+		discardMeetingRooms(c, room)
+		if errors.Is(err, services.ErrScheduleConflict) {
+			return scheduleConflictResponse(c, err)
+		}
+		return c.Status(availabilityErrorStatus(err)).JSON(err.Error())
+	}
+	return c.Status(201).JSON(booking)
+}
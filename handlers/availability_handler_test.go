@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+// bangkok matches Asia/Bangkok, which has no DST. The tests book on
+// Tuesday 2030-01-01.
+var bangkok = time.FixedZone("ICT", 7*60*60)
+
+// expBookableClass expects class 12 of teacher 30, offering 60-minute lessons for 500.
+func expBookableClass(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id", "class_name", "private_lesson_minutes", "private_lesson_price"}).
+			AddRow(12, 30, "Calculus I", 60, 500.0))
+}
+
+// expTuesdayWindow expects teacher 30's Tuesday 18:00-20:00 Bangkok window,
+// no exceptions and the given one-hour sessions already on their schedule.
+func expTuesdayWindow(mock sqlmock.Sqlmock, busy ...time.Time) {
+	mock.ExpectQuery(`SELECT \* FROM "teacher_availabilities" WHERE teacher_id = \$1`).
+		WithArgs(30).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id", "weekday", "start_time", "end_time", "timezone"}).
+			AddRow(1, 30, 2, "18:00", "20:00", "Asia/Bangkok"))
+	mock.ExpectQuery(`SELECT \* FROM "availability_exceptions" WHERE \(teacher_id = \$1 AND start_at < \$2 AND end_at > \$3\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	rows := sqlmock.NewRows([]string{"class_session_id", "class_id", "class_name", "class_start", "class_finish"})
+	for i, start := range busy {
+		rows.AddRow(40+i, 13, "Group class", start, start.Add(time.Hour))
+	}
+	mock.ExpectQuery(`SELECT class_sessions\.id AS class_session_id.* AND classes\.teacher_id = \$6`).
+		WillReturnRows(rows)
+}
+
+/* ------------------ GetBookableSlots ------------------ */
+
+// 200
+func TestGetBookableSlots_SkipsBusyTimeInLearnerTimezone(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	expBookableClass(mock)
+	expTuesdayWindow(mock, time.Date(2030, 1, 1, 18, 0, 0, 0, bangkok))
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodGet,
+		Path:   "/availability/classes/12/slots?from=2030-01-01&days=7&tz=America/New_York",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	var slots []models.BookableSlot
+	if err := json.Unmarshal(readBody(t, resp.Body), &slots); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(slots) != 1 {
+		t.Fatalf("got %d slots, want 1: %+v", len(slots), slots)
+	}
+	// 19:00 in Bangkok is 07:00 in New York.
+	if got := slots[0].Start.Format(time.RFC3339); got != "2030-01-01T07:00:00-05:00" {
+		t.Fatalf("slot start = %s", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200: a London 09:00-10:00 Sunday window on the day the UK moves to BST
+// starts at 08:00 UTC, not 09:00. Without tz the caller's own timezone is used.
+func TestGetBookableSlots_WindowOnDSTChangeDay(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	learner := enrolledLearnerUser(7, 9)
+	learner.Timezone = "Europe/London"
+	app := setupAppAsUser(gdb, learner)
+	expBookableClass(mock)
+	mock.ExpectQuery(`SELECT \* FROM "teacher_availabilities" WHERE teacher_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id", "weekday", "start_time", "end_time", "timezone"}).
+			AddRow(1, 30, 0, "09:00", "10:00", "Europe/London"))
+	mock.ExpectQuery(`SELECT \* FROM "availability_exceptions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT class_sessions\.id AS class_session_id`).
+		WillReturnRows(sqlmock.NewRows([]string{"class_session_id"}))
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodGet,
+		Path:   "/availability/classes/12/slots?from=2030-03-30&days=2",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	var slots []models.BookableSlot
+	if err := json.Unmarshal(readBody(t, resp.Body), &slots); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(slots) != 1 {
+		t.Fatalf("got %d slots, want 1: %+v", len(slots), slots)
+	}
+	if got := slots[0].Start.Format(time.RFC3339); got != "2030-03-31T09:00:00+01:00" {
+		t.Fatalf("slot start = %s", got)
+	}
+	if got := slots[0].Start.UTC().Hour(); got != 8 {
+		t.Fatalf("slot starts at %02d:00 UTC, want 08:00", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestGetBookableSlots_InvalidTimezone(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/availability/classes/12/slots?tz=Mars/Olympus"})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ BookPrivateLesson ------------------ */
+
+// 201
+func TestBookPrivateLesson_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	start := time.Date(2030, 1, 1, 19, 0, 0, 0, bangkok)
+	expBookableClass(mock)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "teachers" WHERE "teachers"\."id" = \$1 .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(30, 5))
+	expTuesdayWindow(mock)
+	expLearnerScheduleFree(mock)
+	mock.ExpectExec(`UPDATE "users" SET "balance"=balance - \$1,"updated_at"=\$2 WHERE \(id = \$3 AND balance >= \$4\)`).
+		WithArgs(500.0, sqlmock.AnyArg(), 7, 500.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "class_sessions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(50))
+	mock.ExpectQuery(`INSERT INTO "enrollments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(60))
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	expUserTimezones(mock, 5, "Asia/Bangkok")
+	expNotice(mock, 5, "booked for Tue 1 Jan 2030 19:00 +07:00 (Asia/Bangkok)")
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/availability/classes/12/book",
+		Body:        jsonBody(models.BookPrivateLessonRequest{ClassStart: start}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	var booking models.PrivateLessonBooking
+	if err := json.Unmarshal(readBody(t, resp.Body), &booking); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if booking.ClassSession.LearnerLimit != 1 || !booking.ClassSession.ClassFinish.Equal(start.Add(time.Hour)) {
+		t.Fatalf("unexpected session: %+v", booking.ClassSession)
+	}
+	if booking.Enrollment.ClassSessionID != 50 || booking.Enrollment.LearnerID != 9 {
+		t.Fatalf("unexpected enrollment: %+v", booking.Enrollment)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 402
+func TestBookPrivateLesson_InsufficientBalance(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	expBookableClass(mock)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "teachers"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(30, 5))
+	expTuesdayWindow(mock)
+	expLearnerScheduleFree(mock)
+	mock.ExpectExec(`UPDATE "users" SET "balance"=balance - \$1`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/availability/classes/12/book",
+		Body:        jsonBody(models.BookPrivateLessonRequest{ClassStart: time.Date(2030, 1, 1, 18, 0, 0, 0, bangkok)}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusPaymentRequired)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestBookPrivateLesson_SlotNotOffered(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	expBookableClass(mock)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "teachers"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(30, 5))
+	expTuesdayWindow(mock)
+	mock.ExpectRollback()
+
+	// Lessons start on the hour within the window, so 18:30 is not a slot.
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/availability/classes/12/book",
+		Body:        jsonBody(models.BookPrivateLessonRequest{ClassStart: time.Date(2030, 1, 1, 18, 30, 0, 0, bangkok)}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ CreateAvailabilityWindow ------------------ */
+
+// 400
+func TestCreateAvailabilityWindow_EndBeforeStart(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/availability/windows",
+		Body:        jsonBody(models.TeacherAvailability{Weekday: 2, StartTime: "20:00", EndTime: "18:00"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
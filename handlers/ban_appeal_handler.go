@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/a2n2k3p4/tutorium-backend/storage"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// maxAppealEvidence caps the number of images attached to one appeal.
+const maxAppealEvidence = 5
+
+// BanAppealRoutes deliberately skips BanMiddleware so banned users can still
+// see their bans and appeal them.
+func BanAppealRoutes(app *fiber.App) {
+	appeal := app.Group("/appeals", middlewares.ProtectedMiddleware())
+
+	appeal.Get("/bans", GetMyActiveBans)
+	appeal.Get("/me", GetMyBanAppeals)
+	appeal.Post("/", CreateBanAppeal)
+
+	appealAdmin := appeal.Group("/", middlewares.AdminRequired())
+	appealAdmin.Get("/", GetBanAppeals)
+	appealAdmin.Get("/:id", GetBanAppeal)
+	appealAdmin.Post("/:id/review", ReviewBanAppeal)
+}
+
+type CreateBanAppealRequest struct {
+	BanRole    string   `json:"ban_role"`
+	BanID      uint     `json:"ban_id"`
+	AppealText string   `json:"appeal_text"`
+	Evidence   []string `json:"evidence"`
+}
+
+type ReviewBanAppealRequest struct {
+	Decision   string     `json:"decision"`
+	ReviewNote string     `json:"review_note"`
+	NewBanEnd  *time.Time `json:"new_ban_end,omitempty"`
+}
+
+// banAppealErrorStatus maps service errors to HTTP status codes.
+func banAppealErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidBanRole),
+		errors.Is(err, services.ErrInvalidAppealDecision),
+		errors.Is(err, services.ErrBanEndNotShorter):
+		return 400
+	case errors.Is(err, services.ErrBanNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		return 404
+	case errors.Is(err, services.ErrBanAppealExists), errors.Is(err, services.ErrBanAppealReviewed):
+		return 409
+	default:
+		return 500
+	}
+}
+
+// GetMyActiveBans godoc
+//
+//	@Summary		List my active bans
+//	@Description	Lists the bans currently in force against the caller's learner and teacher roles, so one can be appealed
+//	@Tags			BanAppeals
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{array}		models.ActiveBanDoc
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		500	{object}	map[string]string	"Server error"
+//	@Router			/appeals/bans [get]
+func GetMyActiveBans(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	bans, err := services.ActiveBansForUser(db, user, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(200).JSON(bans)
+}
+
+// GetMyBanAppeals godoc
+//
+//	@Summary		List my ban appeals
+//	@Description	Lists the caller's appeals with their review status
+//	@Tags			BanAppeals
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{array}		models.BanAppealDoc
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		500	{object}	map[string]string	"Server error"
+//	@Router			/appeals/me [get]
+func GetMyBanAppeals(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	appeals := []models.BanAppeal{}
+	if err := db.Where("user_id = ?", user.ID).Order("id desc").Find(&appeals).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(200).JSON(appeals)
+}
+
+// CreateBanAppeal godoc
+//
+//	@Summary		Appeal a ban
+//	@Description	Submits one appeal for an active ban on the caller's account, with optional base64 evidence images (max 5). Reachable while banned.
+//	@Tags			BanAppeals
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			appeal	body		models.CreateBanAppealDoc	true	"Appeal payload"
+//	@Success		201		{object}	models.BanAppealDoc
+//	@Failure		400		{object}	map[string]string	"Invalid input"
+//	@Failure		401		{object}	map[string]string	"Unauthorized"
+//	@Failure		404		{object}	map[string]string	"No matching active ban"
+//	@Failure		409		{object}	map[string]string	"Ban already appealed"
+//	@Failure		500		{object}	map[string]string	"Server error"
+//	@Router			/appeals [post]
+func CreateBanAppeal(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req CreateBanAppealRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	req.AppealText = strings.TrimSpace(req.AppealText)
+	if req.AppealText == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "appeal_text is required"})
+	}
+	if len(req.AppealText) > 1000 {
+		return c.Status(400).JSON(fiber.Map{"error": "appeal_text must be at most 1000 characters"})
+	}
+	if len(req.Evidence) > maxAppealEvidence {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("at most %d evidence images are allowed", maxAppealEvidence)})
+	}
+
+	images := make([][]byte, 0, len(req.Evidence))
+	for i, e := range req.Evidence {
+		b, err := storage.DecodeBase64Image(e)
+		if err == nil {
+			err = validateImageBytes(b)
+		}
+		if err == nil && len(b) == 0 {
+			err = errors.New("empty file")
+		}
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("invalid evidence image %d: %v", i+1, err)})
+		}
+		images = append(images, b)
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	banEnd, err := services.FindAppealableBan(db, user, req.BanRole, req.BanID, time.Now())
+	if err != nil {
+		return c.Status(banAppealErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	keys := []string{}
+	if len(images) > 0 {
+		up, ok := c.Locals("minio").(storage.Uploader)
+		if !ok {
+			return c.Status(500).JSON(fiber.Map{"error": "storage not available"})
+		}
+		for _, b := range images {
+			filename := storage.GenerateFilename(http.DetectContentType(b[:min(512, len(b))]))
+			key, err := up.UploadBytes(c.Context(), "appeals", filename, b)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	appeal := models.BanAppeal{
+		UserID:         user.ID,
+		BanRole:        req.BanRole,
+		BanID:          req.BanID,
+		AppealText:     req.AppealText,
+		EvidenceURLs:   keys,
+		Status:         "pending",
+		OriginalBanEnd: banEnd,
+	}
+	if err := db.Create(&appeal).Error; err != nil {
+		if store, ok := c.Locals("minio").(storage.ObjectStore); ok {
+			for _, key := range keys {
+				_ = store.RemoveObject(c.Context(), key)
+			}
+		}
+		// Another request appealed the same ban after FindAppealableBan.
+		if services.IsUniqueViolation(err, "idx_ban_appeal_ban") {
+			return c.Status(409).JSON(fiber.Map{"error": services.ErrBanAppealExists.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var admins []models.Admin
+	db.Find(&admins)
+	for _, admin := range admins {
+		services.CreateNotification(
+			db,
+			admin.UserID,
+			"system",
+			fmt.Sprintf("A new ban appeal (ID: %d) has been submitted and requires your review.", appeal.ID),
+		)
+	}
+
+	return c.Status(201).JSON(appeal)
+}
+
+// presignAppealEvidence swaps stored object keys for short-lived URLs.
+func presignAppealEvidence(c *fiber.Ctx, appeal *models.BanAppeal) {
+	mc, ok := c.Locals("minio").(*storage.Client)
+	if !ok {
+		return
+	}
+	urls := make([]string, 0, len(appeal.EvidenceURLs))
+	for _, key := range appeal.EvidenceURLs {
+		if u, err := mc.PresignedGetObject(c.Context(), key, 15*time.Minute); err == nil {
+			urls = append(urls, u)
+		}
+	}
+	appeal.EvidenceURLs = urls
+}
+
+// GetBanAppeals godoc
+//
+//	@Summary		List ban appeals
+//	@Description	Lists ban appeals, oldest first, optionally filtered by status (pending, accepted, rejected)
+//	@Tags			BanAppeals
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			status	query		string	false	"Filter by status"
+//	@Success		200		{array}		models.BanAppealDoc
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/appeals [get]
+func GetBanAppeals(c *fiber.Ctx) error {
+	appeals := []models.BanAppeal{}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	q := db.Order("id asc")
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if err := q.Find(&appeals).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	for i := range appeals {
+		presignAppealEvidence(c, &appeals[i])
+	}
+	return c.Status(200).JSON(appeals)
+}
+
+func findBanAppeal(db *gorm.DB, id int, appeal *models.BanAppeal) error {
+	return db.First(appeal, "id = ?", id).Error
+}
+
+// GetBanAppeal godoc
+//
+//	@Summary		Get ban appeal by ID
+//	@Description	Retrieves a single ban appeal with presigned evidence URLs
+//	@Tags			BanAppeals
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Appeal ID"
+//	@Success		200	{object}	models.BanAppealDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		404	{string}	string	"Appeal not found"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/appeals/{id} [get]
+func GetBanAppeal(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+
+	var appeal models.BanAppeal
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	err = findBanAppeal(db, id, &appeal)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("appeal not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	presignAppealEvidence(c, &appeal)
+	return c.Status(200).JSON(appeal)
+}
+
+// ReviewBanAppeal godoc
+//
+//	@Summary		Accept or reject a ban appeal
+//	@Description	Accepting lifts the ban immediately, or shortens it to new_ban_end when given. The user is notified of the decision.
+//	@Tags			BanAppeals
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int							true	"Appeal ID"
+//	@Param			review	body		models.ReviewBanAppealDoc	true	"Review decision"
+//	@Success		200		{object}	models.BanAppealDoc
+//	@Failure		400		{object}	map[string]string	"Invalid input"
+//	@Failure		404		{object}	map[string]string	"Appeal not found"
+//	@Failure		409		{object}	map[string]string	"Appeal already reviewed"
+//	@Failure		500		{object}	map[string]string	"Server error"
+//	@Router			/appeals/{id}/review [post]
+func ReviewBanAppeal(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+
+	var req ReviewBanAppealRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var reviewerID *uint
+	if user, ok := c.Locals("currentUser").(*models.User); ok {
+		reviewerID = &user.ID
+	}
+
+	appeal, err := services.ReviewBanAppeal(db, uint(id), reviewerID, req.Decision, req.ReviewNote, req.NewBanEnd)
+	if err != nil {
+		return c.Status(banAppealErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(200).JSON(appeal)
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func bannedLearnerUser(userID, learnerID uint) *models.User {
+	u := &models.User{StudentID: "b6600000002", Learner: &models.Learner{UserID: userID}}
+	u.ID = userID
+	u.Learner.ID = learnerID
+	return u
+}
+
+/* ------------------ CreateBanAppeal ------------------ */
+
+// 201
+func TestCreateBanAppeal_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, bannedLearnerUser(7, 3))
+	banEnd := time.Now().Add(48 * time.Hour)
+
+	mock.ExpectQuery(`SELECT \* FROM "ban_details_learners" WHERE \(id = \$1 AND learner_id = \$2 AND ban_end > \$3\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "learner_id", "ban_end"}).AddRow(12, 3, banEnd))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "ban_appeals" WHERE \(ban_role = \$1 AND ban_id = \$2\)`).
+		WithArgs("learner", 12).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	ExpInsertReturningID("ban_appeals", 1)(mock)
+	ExpPreloadCanEmpty("admins", []string{"id", "user_id"})(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/appeals",
+		Body:        jsonBody(CreateBanAppealRequest{BanRole: "learner", BanID: 12, AppealText: "Wrong person was reported."}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 404
+func TestCreateBanAppeal_NoActiveBan(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, bannedLearnerUser(7, 3))
+
+	mock.ExpectQuery(`SELECT \* FROM "ban_details_learners" WHERE \(id = \$1 AND learner_id = \$2 AND ban_end > \$3\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/appeals",
+		Body:        jsonBody(CreateBanAppealRequest{BanRole: "learner", BanID: 12, AppealText: "Please review."}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusNotFound)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestCreateBanAppeal_AlreadyAppealed(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, bannedLearnerUser(7, 3))
+
+	mock.ExpectQuery(`SELECT \* FROM "ban_details_learners"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "learner_id", "ban_end"}).AddRow(12, 3, time.Now().Add(time.Hour)))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "ban_appeals"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/appeals",
+		Body:        jsonBody(CreateBanAppealRequest{BanRole: "learner", BanID: 12, AppealText: "Please review."}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409 (a concurrent appeal was inserted after the count)
+func TestCreateBanAppeal_AppealedMeanwhile(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{}
+	app := setupAppWithUploader(gdb, bannedLearnerUser(7, 3), up)
+
+	mock.ExpectQuery(`SELECT \* FROM "ban_details_learners"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "learner_id", "ban_end"}).AddRow(12, 3, time.Now().Add(time.Hour)))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "ban_appeals"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "ban_appeals"`).
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "idx_ban_appeal_ban"})
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/appeals",
+		Body: jsonBody(CreateBanAppealRequest{
+			BanRole: "learner", BanID: 12, AppealText: "Please review.", Evidence: []string{tinyPNGRawBase64},
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if len(up.removed) != 1 || up.removed[0] != "appeals/"+up.lastFilename {
+		t.Fatalf("uploaded evidence not removed: %v", up.removed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestCreateBanAppeal_MissingText(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, bannedLearnerUser(7, 3))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/appeals",
+		Body:        jsonBody(CreateBanAppealRequest{BanRole: "learner", BanID: 12}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ ReviewBanAppeal ------------------ */
+
+// 200
+func TestReviewBanAppeal_Accept(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "ban_appeals" WHERE "ban_appeals"\."id" = \$1 .*FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "ban_role", "ban_id", "status", "original_ban_end"}).
+			AddRow(4, 7, "learner", 12, "pending", time.Now().Add(48*time.Hour)))
+	mock.ExpectExec(`UPDATE "ban_details_learners" SET "ban_end"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "ban_appeals" SET`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	ExpInsertReturningID("notifications", 1)(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/appeals/4/review",
+		Body:        jsonBody(ReviewBanAppealRequest{Decision: "accept", ReviewNote: "Mistaken identity"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestReviewBanAppeal_AlreadyReviewed(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "ban_appeals" WHERE "ban_appeals"\."id" = \$1 .*FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(4, "rejected"))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/appeals/4/review",
+		Body:        jsonBody(ReviewBanAppealRequest{Decision: "accept"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestReviewBanAppeal_InvalidDecision(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/appeals/4/review",
+		Body:        jsonBody(ReviewBanAppealRequest{Decision: "maybe"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -39,6 +39,9 @@ func CreateBanLearner(c *fiber.Ctx) error {
 	if err := c.BodyParser(&banlearner); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
+	if banlearner.BanScope != "" && !models.ValidBanScope("learner", banlearner.BanScope) {
+		return c.Status(400).JSON("ban_scope must be learning or platform")
+	}
 	db, err := middlewares.GetDB(c)
 	if err != nil {
 		return c.Status(500).JSON(err.Error())
@@ -155,6 +158,9 @@ func UpdateBanLearner(c *fiber.Ctx) error {
 	if err := c.BodyParser(&banlearner_update); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
+	if banlearner_update.BanScope != "" && !models.ValidBanScope("learner", banlearner_update.BanScope) {
+		return c.Status(400).JSON("ban_scope must be learning or platform")
+	}
 
 	if err := db.Model(&banlearner).Omit(clause.Associations).Updates(banlearner_update).Error; err != nil {
 		return c.Status(500).JSON(err.Error())
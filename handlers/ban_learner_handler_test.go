@@ -57,6 +57,29 @@ func TestCreateBanLearner_BadRequest(t *testing.T) {
 	)
 }
 
+// 400 (a scope that only applies to the other role)
+func TestCreateBanLearner_ScopeForOtherRole(t *testing.T) {
+	userID := uint(42)
+	now := time.Now()
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, true, false, false)(mock)
+
+			*payload = jsonBody(models.BanDetailsLearner{
+				LearnerID:      50,
+				BanStart:       now,
+				BanEnd:         now.Add(2 * time.Hour),
+				BanDescription: "spamming",
+				BanScope:       models.BanScopeTeaching,
+			})
+			*uID = userID
+		},
+		http.StatusBadRequest,
+		http.MethodPost,
+		"/banlearners/",
+	)
+}
+
 // 500
 func TestCreateBanLearner_DBError(t *testing.T) {
 	table := "ban_details_learners"
@@ -39,6 +39,9 @@ func CreateBanTeacher(c *fiber.Ctx) error {
 	if err := c.BodyParser(&banteacher); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
+	if banteacher.BanScope != "" && !models.ValidBanScope("teacher", banteacher.BanScope) {
+		return c.Status(400).JSON("ban_scope must be teaching or platform")
+	}
 	db, err := middlewares.GetDB(c)
 	if err != nil {
 		return c.Status(500).JSON(err.Error())
@@ -155,6 +158,9 @@ func UpdateBanTeacher(c *fiber.Ctx) error {
 	if err := c.BodyParser(&banteacher_update); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
+	if banteacher_update.BanScope != "" && !models.ValidBanScope("teacher", banteacher_update.BanScope) {
+		return c.Status(400).JSON("ban_scope must be teaching or platform")
+	}
 
 	if err := db.Model(&banteacher).Omit(clause.Associations).Updates(banteacher_update).Error; err != nil {
 		return c.Status(500).JSON(err.Error())
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/config"
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func CalendarRoutes(app *fiber.App) {
+	// Calendar apps cannot log in, so the secret token in the URL is the
+	// credential. Registered before the protected group so it skips it.
+	app.Get("/calendar/:token.ics", GetCalendarFeed)
+
+	calendar := app.Group("/calendar", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware())
+	calendar.Post("/token", RotateCalendarToken)
+	calendar.Delete("/token", RevokeCalendarToken)
+	calendar.Get("/sessions/:id.ics", GetClassSessionICS)
+}
+
+func sendICS(c *fiber.Ctx, body string) error {
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	return c.Status(200).SendString(body)
+}
+
+// GetCalendarFeed godoc
+//
+//	@Summary		Subscribe to a user's schedule
+//	@Description	iCalendar feed of the sessions the token's owner teaches or is enrolled in, including the last 90 days. Cancelled sessions and refunded enrollments stay in the feed with STATUS:CANCELLED and a higher SEQUENCE so calendar apps remove them. Meeting links are personal and left out; when CALENDAR_SESSION_URL is set, each event links to the session's page in the web app instead.
+//	@Tags			Calendar
+//	@Produce		text/calendar
+//	@Param			token	path		string	true	"Feed token"
+//	@Success		200		{string}	string	"iCalendar document"
+//	@Failure		404		{string}	string	"Unknown token"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/calendar/{token}.ics [get]
+func GetCalendarFeed(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	now := time.Now()
+	user, err := services.AuthenticateCalendarToken(db, c.Params("token"), now)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCalendarToken) {
+			return c.Status(404).JSON(err.Error())
+		}
+		return c.Status(500).JSON(err.Error())
+	}
+
+	events, err := services.UserCalendarEvents(db, user, config.CalendarSessionURL(), now)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return sendICS(c, services.BuildCalendar("Tutorium", services.UserLocation(user), events, now))
+}
+
+// RotateCalendarToken godoc
+//
+//	@Summary		Create or rotate the calendar feed token
+//	@Description	Issues a new secret feed URL for the caller. Any previous URL stops working. The token is only shown in this response.
+//	@Tags			Calendar
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		201	{object}	models.CalendarFeedResponseDoc
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/calendar/token [post]
+func RotateCalendarToken(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON("unauthorized")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	token, err := services.RotateCalendarToken(db, user.ID, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(201).JSON(models.CalendarFeedResponse{
+		Token:   token,
+		FeedURL: fmt.Sprintf("%s/calendar/%s.ics", c.BaseURL(), token),
+	})
+}
+
+// RevokeCalendarToken godoc
+//
+//	@Summary		Revoke the calendar feed token
+//	@Description	Disables the caller's feed URL
+//	@Tags			Calendar
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{string}	string	"Calendar feed revoked"
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/calendar/token [delete]
+func RevokeCalendarToken(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON("unauthorized")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	if err := services.RevokeCalendarToken(db, user.ID); err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON("Calendar feed revoked")
+}
+
+// GetClassSessionICS godoc
+//
+//	@Summary		Download a class session as .ics
+//	@Description	Single-event iCalendar file for adding one session to a calendar
+//	@Tags			Calendar
+//	@Security		BearerAuth
+//	@Produce		text/calendar
+//	@Param			id	path		int		true	"ClassSession ID"
+//	@Success		200	{string}	string	"iCalendar document"
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		404	{string}	string	"Class session not found"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/calendar/sessions/{id}.ics [get]
+func GetClassSessionICS(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var session models.ClassSession
+	err = findClassSession(db, id, &session)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("class session not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	now := time.Now()
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="class-session-%d.ics"`, session.ID))
+	return sendICS(c, services.BuildCalendar(session.Class.ClassName, services.ClassLocation(&session.Class), []services.CalendarEvent{services.SessionCalendarEvent(&session, false, config.CalendarSessionURL())}, now))
+}
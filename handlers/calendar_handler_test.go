@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/config"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+)
+
+/* ------------------ GetCalendarFeed ------------------ */
+
+// 200
+func TestGetCalendarFeed_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	prev := config.CalendarSessionURL
+	config.CalendarSessionURL = func() string { return "https://app.example.com/sessions/{id}" }
+	t.Cleanup(func() { config.CalendarSessionURL = prev })
+
+	// The feed is public; the token is the credential.
+	app := setupAppAsUser(gdb, nil)
+	start := time.Date(2030, 1, 1, 11, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT \* FROM "calendar_feeds" WHERE token_hash = \$1`).
+		WithArgs(services.HashAPIKey("secret-token"), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "token_hash"}).AddRow(1, 7, services.HashAPIKey("secret-token")))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "timezone"}).AddRow(7, "America/New_York"))
+	mock.ExpectQuery(`SELECT \* FROM "learners" WHERE "learners"\."user_id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(9, 7))
+	mock.ExpectQuery(`SELECT \* FROM "teachers" WHERE "teachers"\."user_id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "calendar_feeds" SET "last_accessed_at"=\$1 WHERE`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery(`SELECT "enrollments"\."id".* FROM "enrollments" JOIN class_sessions ON class_sessions\.id = enrollments\.class_session_id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "learner_id", "class_session_id", "enrollment_status"}).
+			AddRow(1, 9, 3, models.EnrollmentStatusActive).
+			AddRow(2, 9, 4, models.EnrollmentStatusRefunded))
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE "class_sessions"\."id" IN \(\$1,\$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "description", "class_start", "class_finish", "class_status", "meeting_url", "calendar_sequence"}).
+			AddRow(3, 12, "Limits; continuity, and more", start, start.Add(2*time.Hour), models.SessionStatusScheduled, "https://meet.jit.si/abc", 1).
+			AddRow(4, 12, "", start.Add(24*time.Hour), start.Add(26*time.Hour), models.SessionStatusCancelled, "https://meet.jit.si/def", 1))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_name"}).AddRow(12, "Calculus I"))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/calendar/secret-token.ics"})
+	wantStatus(t, resp, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Fatalf("content type = %q", ct)
+	}
+
+	// Unfold long lines before matching.
+	body := strings.ReplaceAll(string(readBody(t, resp.Body)), "\r\n ", "")
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-TIMEZONE:America/New_York\r\n",
+		"UID:class-session-3@tutorium\r\nDTSTAMP:",
+		"DTSTART:20300101T110000Z\r\nDTEND:20300101T130000Z\r\n",
+		"SEQUENCE:1\r\nSTATUS:CONFIRMED\r\nSUMMARY:Calculus I\r\n",
+		"DESCRIPTION:Limits\\; continuity\\, and more\\n\\nOpen in Tutorium: https://app.example.com/sessions/3\r\n",
+		"URL:https://app.example.com/sessions/3\r\n",
+		"DESCRIPTION:Open in Tutorium: https://app.example.com/sessions/4\r\n",
+		"UID:class-session-4@tutorium\r\n",
+		"SEQUENCE:2\r\nSTATUS:CANCELLED\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("feed missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "meet.jit.si") || strings.Contains(body, "/meetings/") {
+		t.Fatalf("feed leaks the meeting room:\n%s", body)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 404
+func TestGetCalendarFeed_UnknownToken(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, nil)
+	mock.ExpectQuery(`SELECT \* FROM "calendar_feeds" WHERE token_hash = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/calendar/revoked.ics"})
+	wantStatus(t, resp, http.StatusNotFound)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ RotateCalendarToken ------------------ */
+
+// 201
+func TestRotateCalendarToken_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "calendar_feeds" .* ON CONFLICT \("user_id"\) DO UPDATE SET`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/calendar/token"})
+	wantStatus(t, resp, http.StatusCreated)
+	if body := string(readBody(t, resp.Body)); !strings.Contains(body, `.ics"`) {
+		t.Fatalf("missing feed url: %s", body)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ GetClassSessionICS ------------------ */
+
+// 200
+func TestGetClassSessionICS_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	expSessionWithClass(mock, 3, 30, time.Date(2030, 1, 1, 11, 0, 0, 0, time.UTC), "")
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/calendar/sessions/3.ics"})
+	wantStatus(t, resp, http.StatusOK)
+	if cd := resp.Header.Get("Content-Disposition"); !strings.Contains(cd, "class-session-3.ics") {
+		t.Fatalf("content disposition = %q", cd)
+	}
+	// Without CALENDAR_SESSION_URL the event carries no link.
+	if body := string(readBody(t, resp.Body)); !strings.Contains(body, "DTSTART:20300101T110000Z\r\n") || strings.Contains(body, "URL:") {
+		t.Fatalf("unexpected body:\n%s", body)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
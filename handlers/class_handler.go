@@ -11,6 +11,7 @@ import (
 
 	"github.com/a2n2k3p4/tutorium-backend/middlewares"
 	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
 	"github.com/a2n2k3p4/tutorium-backend/storage"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -23,7 +24,7 @@ func ClassRoutes(app *fiber.App) {
 	class.Get("/:id", GetClass)
 	class.Get("/:id/average_rating", GetClassAverageRating)
 
-	classProtected := class.Group("/", middlewares.TeacherRequired())
+	classProtected := class.Group("/", middlewares.TeacherRequired(), middlewares.BanMiddleware(models.BanScopeTeaching))
 	classProtected.Post("/", CreateClass)
 	classProtected.Put("/:id", UpdateClass)
 	classProtected.Delete("/:id", DeleteClass)
@@ -52,6 +53,10 @@ func CreateClass(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	if err := services.ValidateTimezone(class.Timezone); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
 	if err := processBannerPicture(c, &class); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
@@ -469,6 +474,10 @@ func UpdateClass(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	if err := services.ValidateTimezone(class_update.Timezone); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
 	if err := processBannerPicture(c, &class_update); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
@@ -3,29 +3,44 @@ package handlers
 import (
 	"errors"
 	"strings"
+	"time"
 
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
 	"github.com/a2n2k3p4/tutorium-backend/middlewares"
 	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// errLearnersEnrolled rejects moving a session whose learners have not agreed
+// to the new time.
+var errLearnersEnrolled = errors.New("learners are enrolled; propose the new time with POST /class_sessions/:id/reschedule")
+
 func ClassSessionRoutes(app *fiber.App) {
 	classSession := app.Group("/class_sessions", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware())
 	classSession.Get("/", GetClassSessions)
 	classSession.Get("/:id", GetClassSession)
-
-	classSessionProtected := classSession.Group("/", middlewares.TeacherRequired())
+	classSession.Get("/:id/history", GetClassSessionHistory)
+	classSession.Get("/:id/reschedules", GetClassSessionReschedules)
+	classSession.Post("/:id/reschedule/respond", middlewares.LearnerRequired(), middlewares.BanMiddleware(models.BanScopeLearning), RespondToReschedule)
+	classSession.Get("/:id/assets", GetSessionAssets)
+	classSession.Post("/:id/assets", middlewares.TeacherRequired(), middlewares.BanMiddleware(models.BanScopeTeaching), UploadSessionAsset)
+	classSession.Delete("/:id/assets/:assetId", middlewares.TeacherRequired(), middlewares.BanMiddleware(models.BanScopeTeaching), DeleteSessionAsset)
+
+	classSessionProtected := classSession.Group("/", middlewares.TeacherRequired(), middlewares.BanMiddleware(models.BanScopeTeaching))
 	classSessionProtected.Post("/", CreateClassSession)
 	classSessionProtected.Put("/:id", UpdateClassSession)
 	classSessionProtected.Delete("/:id", DeleteClassSession)
+	classSessionProtected.Post("/:id/reschedule", ProposeReschedule)
+	classSessionProtected.Post("/:id/cancel", CancelClassSession)
 }
 
 // CreateClassSession godoc
 //
 //	@Summary		Create a new class session
-//	@Description	CreateClassSession creates a new ClassSession record
+//	@Description	CreateClassSession creates a new ClassSession record in a class the caller teaches. It is rejected when it overlaps another session taught by the same teacher.
 //	@Tags			ClassSessions
 //	@Security		BearerAuth
 //	@Accept			json
@@ -33,6 +48,10 @@ func ClassSessionRoutes(app *fiber.App) {
 //	@Param			create_class_session_request	body		models.CreateClassSessionRequestDoc	true	"CreateClassSessionRequest payload"
 //	@Success		201								{object}	models.ClassSessionDoc
 //	@Failure		400								{string}	string	"Invalid input"
+//	@Failure		401								{string}	string	"Unauthorized"
+//	@Failure		403								{string}	string	"Not the class's teacher"
+//	@Failure		404								{string}	string	"Class not found"
+//	@Failure		409								{object}	models.ScheduleConflictErrorDoc	"Overlaps another of the teacher's sessions"
 //	@Failure		500								{string}	string	"Server error"
 //	@Router			/class_sessions [post]
 func CreateClassSession(c *fiber.Ctx) error {
@@ -47,21 +66,53 @@ func CreateClassSession(c *fiber.Ctx) error {
 		return c.Status(500).JSON(err.Error())
 	}
 
-	// Autogenerate meeting URL for new class session using UUID v8 + SHA-256
-	meeting_url := NewMeetingHandler()
-
 	var class_session models.ClassSession
 	copy_class_session_content(&class_session, &class_session_request)
+	class_session.ClassStatus = models.NormalizeSessionStatus(class_session.ClassStatus)
+	if class_session.ClassStatus != models.SessionStatusScheduled && class_session.ClassStatus != models.SessionStatusOpen {
+		return c.Status(400).JSON("class_status must be scheduled or open for a new session")
+	}
 
-	link, err := GenerateMeetingLink(meeting_url.BaseURL)
-	if err != nil {
-		return c.Status(500).JSON(err.Error())
+	if !class_session.ClassFinish.After(class_session.ClassStart) {
+		return c.Status(400).JSON(services.ErrInvalidTimeRange.Error())
 	}
-	class_session.MeetingUrl = link
 
-	if err := db.Create(&class_session).Error; err != nil {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON("unauthorized")
+	}
+	var class models.Class
+	err = db.First(&class, "id = ?", class_session.ClassID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("class not found")
+	case err != nil:
 		return c.Status(500).JSON(err.Error())
 	}
+	if !services.IsClassTeacher(user, &class) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+
+	var room *meeting.Room
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := services.CheckTeacherSchedule(tx, class_session.ClassID, class_session.ClassStart, class_session.ClassFinish); err != nil {
+			return err
+		}
+		// Every session gets its own room on the configured meeting provider,
+		// opened only once the slot is known to be free.
+		created, err := newMeetingRoom(c, class_session.Description, class_session.ClassStart, class_session.ClassFinish)
+		if err != nil {
+			return err
+		}
+		room = &created
+		services.AttachMeetingRoom(&class_session, created)
+		return tx.Create(&class_session).Error
+	}); err != nil {
+		if room != nil {
+			discardMeetingRooms(c, *room)
+		}
+		return scheduleConflictResponse(c, err)
+	}
 
 	return c.Status(201).JSON(class_session)
 }
@@ -160,7 +211,7 @@ func GetClassSession(c *fiber.Ctx) error {
 // UpdateClassSession godoc
 //
 //	@Summary		Update an existing class session
-//	@Description	UpdateClassSession updates a ClassSession record by its ID
+//	@Description	UpdateClassSession updates a ClassSession record by its ID. class_start and class_finish can only be changed here while nobody is enrolled; otherwise use the reschedule flow, and the new time must not overlap another session taught by the same teacher. class_status may only be changed to scheduled, open or cancelled, following the session lifecycle; in_progress, completed and teacher_absent are set by the scheduler.
 //	@Tags			ClassSessions
 //	@Security		BearerAuth
 //	@Accept			json
@@ -169,7 +220,10 @@ func GetClassSession(c *fiber.Ctx) error {
 //	@Param			class_session	body		models.ClassSessionDoc	true	"Updated ClassSession payload"
 //	@Success		200				{object}	models.ClassSessionDoc
 //	@Failure		400				{string}	string	"Invalid input"
+//	@Failure		401				{string}	string	"Unauthorized"
+//	@Failure		403				{string}	string	"Not the session's teacher"
 //	@Failure		404				{string}	string	"ClassSession not found"
+//	@Failure		409				{object}	map[string]string	"Invalid status transition, learners enrolled or schedule conflict"
 //	@Failure		500				{string}	string	"Server error"
 //	@Router			/class_sessions/{id} [put]
 func UpdateClassSession(c *fiber.Ctx) error {
@@ -192,14 +246,121 @@ func UpdateClassSession(c *fiber.Ctx) error {
 	case err != nil:
 		return c.Status(500).JSON(err.Error())
 	}
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON("unauthorized")
+	}
+	if !services.IsSessionTeacher(user, &class_session) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
 
 	var class_session_update models.ClassSession
 	if err := c.BodyParser(&class_session_update); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
+	// A session can only be moved to another class the caller also teaches.
+	if class_session_update.ClassID != 0 && class_session_update.ClassID != class_session.ClassID {
+		var target models.Class
+		err := db.First(&target, "id = ?", class_session_update.ClassID).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(404).JSON("class not found")
+		case err != nil:
+			return c.Status(500).JSON(err.Error())
+		}
+		if !services.IsClassTeacher(user, &target) {
+			return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+		}
+	}
 
-	if err := db.Model(&class_session).Omit(clause.Associations).Updates(class_session_update).Error; err != nil {
-		return c.Status(500).JSON(err.Error())
+	// Moving a session with learners needs their consent; see ProposeReschedule.
+	timeChanged := (!class_session_update.ClassStart.IsZero() && !class_session_update.ClassStart.Equal(class_session.ClassStart)) ||
+		(!class_session_update.ClassFinish.IsZero() && !class_session_update.ClassFinish.Equal(class_session.ClassFinish))
+	moved := timeChanged || (class_session_update.ClassID != 0 && class_session_update.ClassID != class_session.ClassID)
+	classID, start, finish := class_session.ClassID, class_session.ClassStart, class_session.ClassFinish
+	if class_session_update.ClassID != 0 {
+		classID = class_session_update.ClassID
+	}
+	if !class_session_update.ClassStart.IsZero() {
+		start = class_session_update.ClassStart
+	}
+	if !class_session_update.ClassFinish.IsZero() {
+		finish = class_session_update.ClassFinish
+	}
+	if moved && !finish.After(start) {
+		return c.Status(400).JSON(services.ErrInvalidTimeRange.Error())
+	}
+
+	// Status changes go through the lifecycle so they are validated and emit events.
+	status := models.NormalizeSessionStatus(class_session_update.ClassStatus)
+	changeStatus := class_session_update.ClassStatus != "" && status != models.NormalizeSessionStatus(class_session.ClassStatus)
+	if changeStatus {
+		if !services.IsManualSessionStatus(status) {
+			return c.Status(400).JSON(services.ErrSessionStatusAutomatic.Error())
+		}
+		if !models.CanTransitionSession(class_session.ClassStatus, status) {
+			return c.Status(409).JSON(fiber.Map{"error": services.ErrInvalidSessionTransition.Error(), "class_status": class_session.ClassStatus})
+		}
+	}
+
+	// Calendar clients only pick up a moved event when its SEQUENCE grows.
+	class_session_update.CalendarSequence = class_session.CalendarSequence
+	if timeChanged {
+		class_session_update.CalendarSequence++
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if moved {
+			if err := services.CheckTeacherSchedule(tx, classID, start, finish, class_session.ID); err != nil {
+				return err
+			}
+		}
+		if timeChanged {
+			// The session lock keeps the count valid until the new time commits.
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+				First(&models.ClassSession{}, class_session.ID).Error; err != nil {
+				return err
+			}
+			var enrolled int64
+			if err := tx.Model(&models.Enrollment{}).
+				Where("class_session_id = ? AND enrollment_status = ?", class_session.ID, models.EnrollmentStatusActive).
+				Count(&enrolled).Error; err != nil {
+				return err
+			}
+			if enrolled > 0 {
+				return errLearnersEnrolled
+			}
+		}
+		// Series membership and the meeting room are managed by the server.
+		if err := tx.Model(&class_session).Omit("class_status", "check_in_code", "teacher_checked_in_at", "series_id", "meeting_provider", clause.Associations).Updates(class_session_update).Error; err != nil {
+			return err
+		}
+		// A moved session is reminded again at its new time.
+		if timeChanged && class_session.ReminderSentAt != nil {
+			return tx.Model(&class_session).UpdateColumn("reminder_sent_at", nil).Error
+		}
+		return nil
+	}); err != nil {
+		if errors.Is(err, errLearnersEnrolled) {
+			return c.Status(409).JSON(err.Error())
+		}
+		return scheduleConflictResponse(c, err)
+	}
+
+	if changeStatus && status == models.SessionStatusCancelled {
+		if _, err := services.CancelClassSession(db, &class_session, class_session.Class.TeacherID, &user.ID, "", time.Now()); err != nil {
+			if errors.Is(err, services.ErrSessionStatusChanged) {
+				return c.Status(409).JSON(err.Error())
+			}
+			return c.Status(500).JSON(err.Error())
+		}
+	} else if changeStatus {
+		if err := services.TransitionClassSession(db, &class_session, status); err != nil {
+			if errors.Is(err, services.ErrSessionStatusChanged) {
+				return c.Status(409).JSON(err.Error())
+			}
+			return c.Status(500).JSON(err.Error())
+		}
 	}
 
 	return c.Status(200).JSON(class_session)
@@ -209,14 +370,17 @@ func UpdateClassSession(c *fiber.Ctx) error {
 // DeleteClassSession godoc
 //
 //	@Summary		Delete a class session by ID
-//	@Description	DeleteClassSession removes a ClassSession record by its ID
+//	@Description	DeleteClassSession removes a ClassSession record by its ID. Only the session's teacher may delete it. Sessions with enrolled learners must be cancelled instead.
 //	@Tags			ClassSessions
 //	@Security		BearerAuth
 //	@Produce		json
 //	@Param			id	path		int		true	"ClassSession ID"
 //	@Success		200	{string}	string	"Successfully deleted class session"
 //	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Failure		403	{string}	string	"Not the session's teacher"
 //	@Failure		404	{string}	string	"ClassSession not found"
+//	@Failure		409	{string}	string	"Learners are enrolled"
 //	@Failure		500	{string}	string	"Server error"
 //	@Router			/class_sessions/{id} [delete]
 func DeleteClassSession(c *fiber.Ctx) error {
@@ -240,12 +404,45 @@ func DeleteClassSession(c *fiber.Ctx) error {
 		return c.Status(500).JSON(err.Error())
 	}
 
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON("unauthorized")
+	}
+	if !services.IsSessionTeacher(user, &class_session) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+
+	// Deleting cascades to enrollments, so learners holding a seat must be
+	// refunded through CancelClassSession first.
+	var enrolled int64
+	if err := db.Model(&models.Enrollment{}).
+		Where("class_session_id = ? AND enrollment_status = ?", class_session.ID, models.EnrollmentStatusActive).
+		Count(&enrolled).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	if enrolled > 0 {
+		return c.Status(409).JSON("learners are enrolled; cancel the session with POST /class_sessions/:id/cancel instead")
+	}
+
 	if err = db.Delete(&class_session).Error; err != nil {
 		return c.Status(500).JSON(err.Error())
 	}
 	return c.Status(200).JSON("Successfully deleted class session")
 }
 
+// scheduleConflictResponse answers a failed schedule check, listing the
+// conflicting sessions on 409.
+func scheduleConflictResponse(c *fiber.Ctx, err error) error {
+	var conflict *services.ScheduleConflictError
+	switch {
+	case errors.As(err, &conflict):
+		return c.Status(409).JSON(fiber.Map{"error": conflict.Error(), "conflicts": conflict.Conflicts})
+	case errors.Is(err, services.ErrInvalidTimeRange):
+		return c.Status(400).JSON(err.Error())
+	}
+	return c.Status(500).JSON(err.Error())
+}
+
 func copy_class_session_content(dest *models.ClassSession, src *models.CreateClassSessionRequest) {
 	dest.ClassID = src.ClassID
 	dest.Description = src.Description
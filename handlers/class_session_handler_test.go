@@ -7,41 +7,82 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
 	"github.com/a2n2k3p4/tutorium-backend/models"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
+func expNoScheduleConflicts(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT class_sessions\.id AS class_session_id`).
+		WillReturnRows(sqlmock.NewRows([]string{"class_session_id"}))
+}
+
+// expTeacherScheduleFree expects CheckTeacherSchedule to lock the class's
+// teacher and find nothing in the way.
+func expTeacherScheduleFree(mock sqlmock.Sqlmock) {
+	expTeacherLock(mock)
+	expNoScheduleConflicts(mock)
+}
+
+func expTeacherLock(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "teachers" WHERE id = \(SELECT teacher_id FROM classes WHERE id = \$1\) AND "teachers"\."deleted_at" IS NULL FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(30))
+}
+
+// expLearnerScheduleFree expects CheckLearnerSchedule to lock the learner
+// and find nothing in the way.
+func expLearnerScheduleFree(mock sqlmock.Sqlmock) {
+	expLearnerLock(mock)
+	mock.ExpectQuery(`SELECT class_sessions\.id AS class_session_id.* JOIN enrollments`).
+		WillReturnRows(sqlmock.NewRows([]string{"class_session_id"}))
+}
+
+func expLearnerLock(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "learners" WHERE id = \$1 AND "learners"\."deleted_at" IS NULL FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+}
+
 /* ------------------ CreateClassSession ------------------ */
 
+// expOwnClass expects the class lookup for a new session taught by teacher 30.
+func expOwnClass(mock sqlmock.Sqlmock, classID uint) {
+	ExpSelectByIDFound("classes", classID, []string{"id", "teacher_id"}, []any{classID, 30})(mock)
+}
+
+func newSessionPayload(classID uint) []byte {
+	return jsonBody(models.ClassSession{
+		ClassID:            classID,
+		Description:        "Lorem Ipsum",
+		LearnerLimit:       40,
+		EnrollmentDeadline: time.Now().Add(72 * time.Hour),
+		ClassStart:         time.Now().Add(108 * time.Hour),
+		ClassFinish:        time.Now().Add(110 * time.Hour),
+		ClassStatus:        "Available",
+		MeetingUrl:         "https://meet.jit.si/KUtutorium-test",
+	})
+}
+
 // 201
 func TestCreateClassSession_OK(t *testing.T) {
-	table := "class_sessions"
-	userID := uint(42)
-	classID := uint(50)
-
-	RunInDifferentStatus(t,
-		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
-			ExpAuthUser(userID, false, true, false)(mock)
-			ExpInsertReturningID(table, 1)(mock)
-
-			req := jsonBody(models.ClassSession{
-				ClassID:            classID,
-				Description:        "Lorem Ipsum",
-				LearnerLimit:       40,
-				EnrollmentDeadline: time.Now().Add(72 * time.Hour),
-				ClassStart:         time.Now().Add(108 * time.Hour),
-				ClassFinish:        time.Now().Add(110 * time.Hour),
-				ClassStatus:        "Available",
-				MeetingUrl:         "https://meet.jit.si/KUtutorium-test",
-			})
-			*payload = req
-			*uID = userID
-		},
-		http.StatusCreated,
-		http.MethodPost,
-		"/class_sessions/",
-	)
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	expOwnClass(mock, 50)
+	mock.ExpectBegin()
+	expTeacherScheduleFree(mock)
+	mock.ExpectQuery(`INSERT INTO "class_sessions".*RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/class_sessions/", Body: newSessionPayload(50), ContentType: "application/json"})
+	wantStatus(t, resp, http.StatusCreated)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
 }
 
 // 400
@@ -59,31 +100,107 @@ func TestCreateClassSession_BadRequest(t *testing.T) {
 	)
 }
 
-// 500
+// 403
+func TestCreateClassSession_NotClassTeacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(6, 31))
+	expOwnClass(mock, 50)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/class_sessions/", Body: newSessionPayload(50), ContentType: "application/json"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 500: the room opened for the session is closed again.
 func TestCreateClassSession_DBError(t *testing.T) {
-	table := "class_sessions"
-	userID := uint(42)
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	fake := meeting.NewFake("")
+	app := fiber.New()
+	app.Use(middlewares.DBMiddleware(gdb))
+	app.Use(middlewares.MeetingMiddleware(fake))
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("currentUser", sessionTeacherUser(5, 30))
+		return c.Next()
+	})
+	app.Post("/class_sessions/", CreateClassSession)
+	expOwnClass(mock, 50)
+	mock.ExpectBegin()
+	expTeacherScheduleFree(mock)
+	mock.ExpectQuery(`INSERT INTO "class_sessions".*RETURNING "id"`).
+		WillReturnError(fmt.Errorf("db insert failed"))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/class_sessions/", Body: newSessionPayload(50), ContentType: "application/json"})
+	wantStatus(t, resp, http.StatusInternalServerError)
+	if !fake.Ended("room-1") {
+		t.Fatalf("room of the unsaved session was left open")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestCreateClassSession_TeacherScheduleConflict(t *testing.T) {
 	classID := uint(50)
+	start := time.Now().Add(108 * time.Hour)
+
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	expOwnClass(mock, classID)
+	mock.ExpectBegin()
+	expTeacherLock(mock)
+	mock.ExpectQuery(`SELECT class_sessions\.id AS class_session_id.* JOIN classes ON classes\.id = class_sessions\.class_id WHERE \(class_sessions\.id NOT IN \(\$1\) AND class_sessions\.class_status NOT IN \(\$2,\$3\)\) AND \(class_sessions\.class_start < \$4 AND class_sessions\.class_finish > \$5\) AND classes\.teacher_id = \(SELECT teacher_id FROM classes WHERE id = \$6\)`).
+		WithArgs(0, models.SessionStatusCancelled, models.SessionStatusTeacherAbsent, sqlmock.AnyArg(), sqlmock.AnyArg(), classID).
+		WillReturnRows(sqlmock.NewRows([]string{"class_session_id", "class_id", "class_name", "class_start", "class_finish"}).
+			AddRow(7, 51, "Physics", start.Add(-time.Hour), start.Add(time.Hour)))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/class_sessions/",
+		Body: jsonBody(models.ClassSession{
+			ClassID:     classID,
+			ClassStart:  start,
+			ClassFinish: start.Add(2 * time.Hour),
+			ClassStatus: models.SessionStatusScheduled,
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestCreateClassSession_FinishBeforeStart(t *testing.T) {
+	userID := uint(42)
+	start := time.Now().Add(108 * time.Hour)
 
 	RunInDifferentStatus(t,
 		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
 			ExpAuthUser(userID, false, true, false)(mock)
-			ExpInsertError(table, fmt.Errorf("db insert failed"))(mock)
-
-			req := jsonBody(models.ClassSession{
-				ClassID:            classID,
-				Description:        "Lorem Ipsum",
-				LearnerLimit:       40,
-				EnrollmentDeadline: time.Now().Add(72 * time.Hour),
-				ClassStart:         time.Now().Add(108 * time.Hour),
-				ClassFinish:        time.Now().Add(110 * time.Hour),
-				ClassStatus:        "Available",
-				MeetingUrl:         "https://meet.jit.si/KUtutorium-test",
+			*payload = jsonBody(models.ClassSession{
+				ClassID:     50,
+				ClassStart:  start,
+				ClassFinish: start.Add(-time.Hour),
+				ClassStatus: models.SessionStatusScheduled,
 			})
-			*payload = req
 			*uID = userID
 		},
-		http.StatusInternalServerError,
+		http.StatusBadRequest,
 		http.MethodPost,
 		"/class_sessions/",
 	)
@@ -196,41 +313,110 @@ func TestGetClassSession_BadRequest(t *testing.T) {
 
 /* ------------------ UpdateClassSession ------------------ */
 
+// expSessionUnenrolled expects a session being moved to be locked and found
+// to have no active enrollments.
+func expSessionUnenrolled(mock sqlmock.Sqlmock, sessionID uint) {
+	mock.ExpectQuery(`SELECT "id" FROM "class_sessions" WHERE "class_sessions"\."id" = \$1 .*FOR UPDATE`).
+		WithArgs(sessionID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(sessionID))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments" WHERE \(class_session_id = \$1 AND enrollment_status = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+}
+
 // 200
 func TestUpdateClassSession_OK(t *testing.T) {
-	table := "class_sessions"
-	userID := uint(42)
 	classID := uint(50)
 	classSessionID := uint(1)
 
-	RunInDifferentStatus(t,
-		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
-			ExpAuthUser(userID, false, true, false)(mock)
-			ExpSelectByIDFound(table, classSessionID,
-				[]string{"id", "class_id", "description", "learner_limit", "enrollment_deadline", "class_start", "class_finish", "class_status", "class_url"},
-				[]any{classSessionID, classID, "Lorem", 40, time.Now().Add(72 * time.Hour), time.Now().Add(108 * time.Hour), time.Now().Add(110 * time.Hour), "pending", "https://meet.jit.si/KUtutorium-test"},
-			)(mock)
-
-			ExpPreloadField("classes", []string{"id"}, []any{classID})(mock)
-			ExpUpdateOK(table)(mock)
-
-			req := jsonBody(models.ClassSession{
-				ClassID:            classID,
-				Description:        "Lorem Ipsum",
-				LearnerLimit:       40,
-				EnrollmentDeadline: time.Now().Add(72 * time.Hour),
-				ClassStart:         time.Now().Add(108 * time.Hour),
-				ClassFinish:        time.Now().Add(110 * time.Hour),
-				ClassStatus:        "Available",
-				MeetingUrl:         "https://meet.jit.si/KUtutorium-test",
-			})
-			*payload = req
-			*uID = userID
-		},
-		http.StatusOK,
-		http.MethodPut,
-		fmt.Sprintf("/class_sessions/%d", classSessionID),
-	)
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+	mock.MatchExpectationsInOrder(false)
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	ExpSelectByIDFound("class_sessions", classSessionID,
+		[]string{"id", "class_id", "description", "learner_limit", "enrollment_deadline", "class_start", "class_finish", "class_status", "class_url"},
+		[]any{classSessionID, classID, "Lorem", 40, time.Now().Add(72 * time.Hour), time.Now().Add(108 * time.Hour), time.Now().Add(110 * time.Hour), "pending", "https://meet.jit.si/KUtutorium-test"},
+	)(mock)
+	ExpPreloadField("classes", []string{"id", "teacher_id"}, []any{classID, 30})(mock)
+	mock.ExpectBegin()
+	expTeacherScheduleFree(mock)
+	expSessionUnenrolled(mock, classSessionID)
+	mock.ExpectExec(`UPDATE "class_sessions" SET .* WHERE "class_sessions"\."deleted_at" IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// pending (scheduled) -> open goes through the lifecycle
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "class_sessions" SET "class_status"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND class_status = \$4\)`).
+		WithArgs(models.SessionStatusOpen, sqlmock.AnyArg(), classSessionID, "pending").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	ExpInsertReturningID("session_histories", 1)(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPut,
+		Path:   fmt.Sprintf("/class_sessions/%d", classSessionID),
+		Body: jsonBody(models.ClassSession{
+			ClassID:            classID,
+			Description:        "Lorem Ipsum",
+			LearnerLimit:       40,
+			EnrollmentDeadline: time.Now().Add(72 * time.Hour),
+			ClassStart:         time.Now().Add(108 * time.Hour),
+			ClassFinish:        time.Now().Add(110 * time.Hour),
+			ClassStatus:        "Available",
+			MeetingUrl:         "https://meet.jit.si/KUtutorium-test",
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestUpdateClassSession_NotSessionTeacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(6, 31))
+	ExpSelectByIDFound("class_sessions", 1, []string{"id", "class_id", "class_status"}, []any{1, 50, models.SessionStatusScheduled})(mock)
+	ExpPreloadField("classes", []string{"id", "teacher_id"}, []any{50, 30})(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/class_sessions/1",
+		Body:        jsonBody(map[string]any{"class_status": models.SessionStatusCancelled}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestUpdateClassSession_MoveToOtherTeachersClass(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	ExpSelectByIDFound("class_sessions", 1, []string{"id", "class_id", "class_status"}, []any{1, 50, models.SessionStatusScheduled})(mock)
+	ExpPreloadField("classes", []string{"id", "teacher_id"}, []any{50, 30})(mock)
+	ExpSelectByIDFound("classes", 60, []string{"id", "teacher_id"}, []any{60, 31})(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/class_sessions/1",
+		Body:        jsonBody(map[string]any{"class_id": 60}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
 }
 
 // 404
@@ -253,34 +439,42 @@ func TestUpdateClassSession_NotFound(t *testing.T) {
 
 // 500
 func TestUpdateClassSession_DBError(t *testing.T) {
-	table := "class_sessions"
-	userID := uint(42)
 	classID := uint(50)
 	classSessionID := uint(1)
 
-	RunInDifferentStatus(t,
-		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
-			ExpAuthUser(userID, false, true, false)(mock)
-			ExpSelectByIDFound(table, classSessionID, []string{"id"}, []any{classSessionID})(mock)
-			ExpUpdateError(table, fmt.Errorf("update failed"))(mock)
-
-			req := jsonBody(models.ClassSession{
-				ClassID:            classID,
-				Description:        "Lorem Ipsum",
-				LearnerLimit:       40,
-				EnrollmentDeadline: time.Now().Add(72 * time.Hour),
-				ClassStart:         time.Now().Add(108 * time.Hour),
-				ClassFinish:        time.Now().Add(110 * time.Hour),
-				ClassStatus:        "Available",
-				MeetingUrl:         "https://meet.jit.si/KUtutorium-test",
-			})
-			*payload = req
-			*uID = userID
-		},
-		http.StatusInternalServerError,
-		http.MethodPut,
-		fmt.Sprintf("/class_sessions/%d", classSessionID),
-	)
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+	mock.MatchExpectationsInOrder(false)
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	ExpSelectByIDFound("class_sessions", classSessionID, []string{"id", "class_id"}, []any{classSessionID, classID})(mock)
+	ExpPreloadField("classes", []string{"id", "teacher_id"}, []any{classID, 30})(mock)
+	mock.ExpectBegin()
+	expTeacherScheduleFree(mock)
+	expSessionUnenrolled(mock, classSessionID)
+	mock.ExpectExec(`UPDATE "class_sessions" SET .* WHERE "class_sessions"\."deleted_at" IS NULL`).
+		WillReturnError(fmt.Errorf("update failed"))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPut,
+		Path:   fmt.Sprintf("/class_sessions/%d", classSessionID),
+		Body: jsonBody(models.ClassSession{
+			ClassID:            classID,
+			Description:        "Lorem Ipsum",
+			LearnerLimit:       40,
+			EnrollmentDeadline: time.Now().Add(72 * time.Hour),
+			ClassStart:         time.Now().Add(108 * time.Hour),
+			ClassFinish:        time.Now().Add(110 * time.Hour),
+			MeetingUrl:         "https://meet.jit.si/KUtutorium-test",
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusInternalServerError)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
 }
 
 // 400
@@ -298,25 +492,92 @@ func TestUpdateClassSession_BadRequest(t *testing.T) {
 	)
 }
 
+// 409
+func TestUpdateClassSession_InvalidTransition(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	ExpSelectByIDFound("class_sessions", 1, []string{"id", "class_id", "class_status"}, []any{1, 50, models.SessionStatusCompleted})(mock)
+	ExpPreloadField("classes", []string{"id", "teacher_id"}, []any{50, 30})(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/class_sessions/1",
+		Body:        jsonBody(map[string]any{"class_status": models.SessionStatusOpen}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestUpdateClassSession_AutomaticStatus(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	ExpSelectByIDFound("class_sessions", 1, []string{"id", "class_id", "class_status"}, []any{1, 50, models.SessionStatusOpen})(mock)
+	ExpPreloadField("classes", []string{"id", "teacher_id"}, []any{50, 30})(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/class_sessions/1",
+		Body:        jsonBody(map[string]any{"class_status": models.SessionStatusCompleted}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 /* ------------------ DeleteClassSession ------------------ */
 
+// expOwnSession expects session :id to be loaded with its Class, taught by
+// teacher 30.
+func expOwnSession(mock sqlmock.Sqlmock, sessionID uint) {
+	ExpSelectByIDFound("class_sessions", sessionID, []string{"id", "class_id"}, []any{sessionID, 50})(mock)
+	ExpPreloadField("classes", []string{"id", "teacher_id"}, []any{50, 30})(mock)
+}
+
 // 200
 func TestDeleteClassSession_OK_SoftDelete(t *testing.T) {
-	table := "class_sessions"
-	userID := uint(42)
-	classSessionID := uint(5)
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
 
-	RunInDifferentStatus(t,
-		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
-			ExpAuthUser(userID, false, true, false)(mock)
-			ExpSelectByIDFound(table, classSessionID, []string{"id"}, []any{classSessionID})(mock)
-			ExpSoftDeleteOK(table)(mock)
-			*uID = userID
-		},
-		http.StatusOK,
-		http.MethodDelete,
-		fmt.Sprintf("/class_sessions/%d", classSessionID),
-	)
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	expOwnSession(mock, 5)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments" WHERE \(class_session_id = \$1 AND enrollment_status = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	ExpSoftDeleteOK("class_sessions")(mock)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodDelete, Path: "/class_sessions/5"})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestDeleteClassSession_NotSessionTeacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(6, 31))
+	expOwnSession(mock, 5)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodDelete, Path: "/class_sessions/5"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
 }
 
 // 404
@@ -339,21 +600,39 @@ func TestDeleteClassSession_NotFound(t *testing.T) {
 
 // 500
 func TestDeleteClassSession_DBError(t *testing.T) {
-	table := "class_sessions"
-	userID := uint(42)
-	classSessionID := uint(5)
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
 
-	RunInDifferentStatus(t,
-		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
-			ExpAuthUser(userID, false, true, false)(mock)
-			ExpSelectByIDFound(table, classSessionID, []string{"id"}, []any{classSessionID})(mock)
-			ExpSoftDeleteError(table, fmt.Errorf("update failed"))(mock)
-			*uID = userID
-		},
-		http.StatusInternalServerError,
-		http.MethodDelete,
-		fmt.Sprintf("/class_sessions/%d", classSessionID),
-	)
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	expOwnSession(mock, 5)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments" WHERE \(class_session_id = \$1 AND enrollment_status = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	ExpSoftDeleteError("class_sessions", fmt.Errorf("update failed"))(mock)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodDelete, Path: "/class_sessions/5"})
+	wantStatus(t, resp, http.StatusInternalServerError)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestDeleteClassSession_LearnersEnrolled(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	expOwnSession(mock, 5)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments" WHERE \(class_session_id = \$1 AND enrollment_status = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodDelete, Path: "/class_sessions/5"})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
 }
 
 // 400
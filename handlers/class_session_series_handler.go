@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func ClassSessionSeriesRoutes(app *fiber.App) {
+	series := app.Group("/class_session_series", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware())
+	series.Get("/:id", GetClassSessionSeries)
+
+	seriesProtected := series.Group("/", middlewares.TeacherRequired(), middlewares.BanMiddleware(models.BanScopeTeaching))
+	seriesProtected.Post("/", CreateClassSessionSeries)
+	seriesProtected.Put("/:id/sessions/:session_id", UpdateSeriesOccurrence)
+	seriesProtected.Post("/:id/cancel", CancelClassSessionSeries)
+}
+
+// seriesErrorStatus maps series service errors to HTTP status codes.
+func seriesErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidRRule), errors.Is(err, services.ErrInvalidSeriesSpan), errors.Is(err, services.ErrNotSeriesSession):
+		return 400
+	case errors.Is(err, services.ErrSeriesCancelled), errors.Is(err, services.ErrOccurrenceStarted), errors.Is(err, services.ErrOccurrenceEnrolled):
+		return 409
+	default:
+		return 500
+	}
+}
+
+// loadSeriesForTeacher fetches series :id with its Class and checks that the
+// caller teaches it, writing the error response itself on failure.
+func loadSeriesForTeacher(c *fiber.Ctx, db *gorm.DB, series *models.ClassSessionSeries) (bool, error) {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return false, c.Status(401).JSON("unauthorized")
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return false, c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+
+	err = db.Preload("Class").First(series, "id = ?", id).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, c.Status(404).JSON("class session series not found")
+	case err != nil:
+		return false, c.Status(500).JSON(err.Error())
+	}
+	if !services.IsClassTeacher(user, &series.Class) {
+		return false, c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+	return true, nil
+}
+
+// CreateClassSessionSeries godoc
+//
+//	@Summary		Create a recurring class session series
+//	@Description	Creates one ClassSession per occurrence of an iCalendar RRULE (FREQ=DAILY or WEEKLY with INTERVAL, BYDAY, COUNT or UNTIL), each with its own meeting link. Dates in excluded_dates (YYYY-MM-DD) are skipped but still count towards COUNT, as in RFC 5545. Occurrences keep first_start's wall-clock time in the class timezone across DST changes, and excluded dates are dates in that timezone. Each occurrence's enrollment deadline is enrollment_lead_hours before it starts. The series is refused if any occurrence overlaps another of the teacher's sessions.
+//	@Tags			ClassSessionSeries
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			series	body		models.CreateClassSessionSeriesRequestDoc	true	"Series payload"
+//	@Success		201		{object}	models.ClassSessionSeriesDoc
+//	@Failure		400		{string}	string	"Invalid input or recurrence rule"
+//	@Failure		403		{string}	string	"Not the class's teacher"
+//	@Failure		404		{string}	string	"Class not found"
+//	@Failure		409		{object}	models.ScheduleConflictErrorDoc	"An occurrence overlaps another of the teacher's sessions"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/class_session_series [post]
+func CreateClassSessionSeries(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON("unauthorized")
+	}
+
+	var req models.CreateClassSessionSeriesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	if req.DurationMinutes <= 0 || req.FirstStart.IsZero() {
+		return c.Status(400).JSON("first_start and a positive duration_minutes are required")
+	}
+	status := models.NormalizeSessionStatus(req.ClassStatus)
+	if status != models.SessionStatusScheduled && status != models.SessionStatusOpen {
+		return c.Status(400).JSON("class_status must be scheduled or open for a new session")
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var class models.Class
+	err = db.First(&class, "id = ?", req.ClassID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("class not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+	if !services.IsClassTeacher(user, &class) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+
+	series := models.ClassSessionSeries{
+		ClassID:             req.ClassID,
+		RRule:               req.RRule,
+		ExcludedDates:       req.ExcludedDates,
+		FirstStart:          req.FirstStart,
+		DurationMinutes:     req.DurationMinutes,
+		EnrollmentLeadHours: req.EnrollmentLeadHours,
+		Description:         req.Description,
+		Price:               req.Price,
+		LearnerLimit:        req.LearnerLimit,
+		Status:              models.SeriesStatusActive,
+	}
+	sessions, err := services.ExpandSeries(&series, status, services.ClassLocation(&class))
+	if err != nil {
+		return c.Status(seriesErrorStatus(err)).JSON(err.Error())
+	}
+
+	var rooms []meeting.Room
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		for _, session := range sessions {
+			if err := services.CheckTeacherSchedule(tx, class.ID, session.ClassStart, session.ClassFinish); err != nil {
+				return err
+			}
+		}
+		// Rooms are only opened once every occurrence is known to fit.
+		for i := range sessions {
+			room, err := newMeetingRoom(c, class.ClassName, sessions[i].ClassStart, sessions[i].ClassFinish)
+			if err != nil {
+				return err
+			}
+			rooms = append(rooms, room)
+			services.AttachMeetingRoom(&sessions[i], room)
+		}
+		series.Sessions = sessions
+		return tx.Create(&series).Error
+	}); err != nil {
+		discardMeetingRooms(c, rooms...)
+		return scheduleConflictResponse(c, err)
+	}
+	return c.Status(201).JSON(series)
+}
+
+// GetClassSessionSeries godoc
+//
+//	@Summary		Get a class session series
+//	@Description	Returns a series with its occurrences in start order
+//	@Tags			ClassSessionSeries
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Series ID"
+//	@Success		200	{object}	models.ClassSessionSeriesDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		404	{string}	string	"Series not found"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/class_session_series/{id} [get]
+func GetClassSessionSeries(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var series models.ClassSessionSeries
+	err = db.Preload("Sessions", func(tx *gorm.DB) *gorm.DB { return tx.Order("class_start") }).
+		First(&series, "id = ?", id).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("class session series not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(series)
+}
+
+// UpdateSeriesOccurrence godoc
+//
+//	@Summary		Edit an occurrence of a series
+//	@Description	Edits one occurrence (scope=this, the default) or it and every later upcoming occurrence (scope=future). A new class_start/class_finish moves later occurrences by the same amount; occurrences with enrolled learners must be moved through the reschedule flow instead, and moved occurrences may not overlap the teacher's other sessions.
+//	@Tags			ClassSessionSeries
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int										true	"Series ID"
+//	@Param			session_id	path		int										true	"ClassSession ID of the occurrence"
+//	@Param			scope		query		string									false	"this or future"
+//	@Param			update		body		models.UpdateSeriesOccurrenceRequestDoc	true	"Fields to change"
+//	@Success		200			{array}		models.ClassSessionDoc
+//	@Failure		400			{string}	string	"Invalid input"
+//	@Failure		403			{string}	string	"Not the class's teacher"
+//	@Failure		404			{string}	string	"Series or occurrence not found"
+//	@Failure		409			{string}	string	"Series cancelled, occurrence already started, learners enrolled or teacher schedule conflict"
+//	@Failure		500			{string}	string	"Server error"
+//	@Router			/class_session_series/{id}/sessions/{session_id} [put]
+func UpdateSeriesOccurrence(c *fiber.Ctx) error {
+	scope := c.Query("scope", services.SeriesScopeThis)
+	if scope != services.SeriesScopeThis && scope != services.SeriesScopeFuture {
+		return c.Status(400).JSON("scope must be this or future")
+	}
+	sessionID, err := c.ParamsInt("session_id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :session_id is an integer")
+	}
+
+	var req models.UpdateSeriesOccurrenceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var series models.ClassSessionSeries
+	if ok, err := loadSeriesForTeacher(c, db, &series); !ok {
+		return err
+	}
+
+	var occurrence models.ClassSession
+	err = db.First(&occurrence, "id = ?", sessionID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("class_session not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	updated, err := services.UpdateSeriesOccurrence(db, &series, &occurrence, scope, req)
+	if err != nil {
+		if errors.Is(err, services.ErrScheduleConflict) {
+			return scheduleConflictResponse(c, err)
+		}
+		return c.Status(seriesErrorStatus(err)).JSON(err.Error())
+	}
+	return c.Status(200).JSON(updated)
+}
+
+// CancelClassSessionSeries godoc
+//
+//	@Summary		Cancel a class session series
+//	@Description	Cancels every occurrence that has not started yet, refunding enrolled learners as for a single cancellation, and marks the series cancelled
+//	@Tags			ClassSessionSeries
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Series ID"
+//	@Success		200	{array}		models.ClassSessionDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		403	{string}	string	"Not the class's teacher"
+//	@Failure		404	{string}	string	"Series not found"
+//	@Failure		409	{string}	string	"Series already cancelled"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/class_session_series/{id}/cancel [post]
+func CancelClassSessionSeries(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var series models.ClassSessionSeries
+	if ok, err := loadSeriesForTeacher(c, db, &series); !ok {
+		return err
+	}
+
+	cancelled, err := services.CancelSeries(db, &series, currentUserID(c), time.Now())
+	if err != nil {
+		return c.Status(seriesErrorStatus(err)).JSON(err.Error())
+	}
+	return c.Status(200).JSON(cancelled)
+}
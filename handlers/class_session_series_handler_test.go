@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+/* ------------------ CreateClassSessionSeries ------------------ */
+
+// 201
+func TestCreateClassSessionSeries_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+	mock.ExpectBegin()
+	for range 4 {
+		expTeacherScheduleFree(mock)
+	}
+	mock.ExpectQuery(`INSERT INTO "class_session_series"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectQuery(`INSERT INTO "class_sessions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3).AddRow(4))
+	mock.ExpectCommit()
+
+	// Monday 1 Sep 2025; Mon/Wed for 5 occurrences with Wed 3 Sep excluded.
+	firstStart := time.Date(2025, 9, 1, 18, 0, 0, 0, time.FixedZone("ICT", 7*3600))
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/class_session_series",
+		Body: jsonBody(models.CreateClassSessionSeriesRequest{
+			ClassID:             12,
+			LearnerLimit:        5,
+			FirstStart:          firstStart,
+			DurationMinutes:     90,
+			EnrollmentLeadHours: 24,
+			RRule:               "RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=5",
+			ExcludedDates:       []string{"2025-09-03"},
+			ClassStatus:         models.SessionStatusOpen,
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	var got models.ClassSessionSeries
+	if err := json.Unmarshal(readBody(t, resp.Body), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []string{"2025-09-01", "2025-09-08", "2025-09-10", "2025-09-15"}
+	if len(got.Sessions) != len(want) {
+		t.Fatalf("sessions = %d, want %d", len(got.Sessions), len(want))
+	}
+	for i, s := range got.Sessions {
+		if d := s.ClassStart.In(firstStart.Location()).Format("2006-01-02"); d != want[i] {
+			t.Errorf("session %d starts %s, want %s", i, d, want[i])
+		}
+		if s.ClassFinish.Sub(s.ClassStart) != 90*time.Minute {
+			t.Errorf("session %d lasts %s, want 90m", i, s.ClassFinish.Sub(s.ClassStart))
+		}
+		if s.MeetingProvider == "" {
+			t.Errorf("session %d has no meeting room", i)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 201: occurrences keep 18:00 London time when the UK moves to BST on
+// 2030-03-31, so their UTC start moves an hour earlier.
+func TestCreateClassSessionSeries_KeepsWallClockAcrossDST(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id", "timezone"}).AddRow(12, 30, "Europe/London"))
+	mock.ExpectBegin()
+	for range 3 {
+		expTeacherScheduleFree(mock)
+	}
+	mock.ExpectQuery(`INSERT INTO "class_session_series"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectQuery(`INSERT INTO "class_sessions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/class_session_series",
+		Body: jsonBody(models.CreateClassSessionSeriesRequest{
+			ClassID:         12,
+			LearnerLimit:    5,
+			FirstStart:      time.Date(2030, 3, 26, 18, 0, 0, 0, time.UTC), // Tuesday, GMT
+			DurationMinutes: 60,
+			RRule:           "FREQ=WEEKLY;COUNT=3",
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	var got models.ClassSessionSeries
+	if err := json.Unmarshal(readBody(t, resp.Body), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []string{"2030-03-26T18:00:00Z", "2030-04-02T17:00:00Z", "2030-04-09T17:00:00Z"}
+	if len(got.Sessions) != len(want) {
+		t.Fatalf("sessions = %d, want %d", len(got.Sessions), len(want))
+	}
+	for i, s := range got.Sessions {
+		if d := s.ClassStart.UTC().Format(time.RFC3339); d != want[i] {
+			t.Errorf("session %d starts %s, want %s", i, d, want[i])
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+// 409
+func TestCreateClassSessionSeries_TeacherScheduleConflict(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	firstStart := time.Now().Add(48 * time.Hour)
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+	mock.ExpectBegin()
+	expTeacherScheduleFree(mock)
+	expTeacherLock(mock)
+	mock.ExpectQuery(`SELECT class_sessions\.id AS class_session_id`).
+		WillReturnRows(sqlmock.NewRows([]string{"class_session_id", "class_id", "class_name", "class_start", "class_finish"}).
+			AddRow(9, 13, "Physics", firstStart.Add(7*24*time.Hour), firstStart.Add(7*24*time.Hour+time.Hour)))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/class_session_series",
+		Body: jsonBody(models.CreateClassSessionSeriesRequest{
+			ClassID:         12,
+			FirstStart:      firstStart,
+			DurationMinutes: 60,
+			RRule:           "FREQ=WEEKLY;COUNT=3",
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreateClassSessionSeries_UnboundedRule(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/class_session_series",
+		Body: jsonBody(models.CreateClassSessionSeriesRequest{
+			ClassID:         12,
+			FirstStart:      time.Now().Add(24 * time.Hour),
+			DurationMinutes: 60,
+			RRule:           "FREQ=WEEKLY;BYDAY=MO",
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestCreateClassSessionSeries_NotClassTeacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(6, 31))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/class_session_series",
+		Body: jsonBody(models.CreateClassSessionSeriesRequest{
+			ClassID:         12,
+			FirstStart:      time.Now().Add(24 * time.Hour),
+			DurationMinutes: 60,
+			RRule:           "FREQ=DAILY;COUNT=3",
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ UpdateSeriesOccurrence ------------------ */
+
+// 200
+func TestUpdateSeriesOccurrence_Future(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Now().Add(48 * time.Hour)
+
+	mock.ExpectQuery(`SELECT \* FROM "class_session_series" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "status", "duration_minutes"}).AddRow(4, 12, models.SeriesStatusActive, 90))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "series_id", "class_status", "class_start", "class_finish"}).
+			AddRow(2, 4, models.SessionStatusOpen, start, start.Add(90*time.Minute)))
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE \(series_id = \$1 AND class_start >= \$2 AND class_status IN \(\$3,\$4\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "series_id", "class_status", "class_start", "class_finish"}).
+			AddRow(2, 4, models.SessionStatusOpen, start, start.Add(90*time.Minute)).
+			AddRow(3, 4, models.SessionStatusOpen, start.Add(7*24*time.Hour), start.Add(7*24*time.Hour+90*time.Minute)))
+	// Not moved: calendar events and reminders are left alone.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "class_sessions" SET "class_finish"=\$1,"class_start"=\$2,"description"=\$3,"enrollment_deadline"=\$4,"learner_limit"=\$5,"price"=\$6,"updated_at"=\$7 WHERE id = \$8`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "class_sessions" SET "class_finish"=\$1,"class_start"=\$2,"description"=\$3,"enrollment_deadline"=\$4,"learner_limit"=\$5,"price"=\$6,"updated_at"=\$7 WHERE id = \$8`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "class_session_series" SET "description"=\$1,"updated_at"=\$2`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	desc := "Moved to the evening"
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/class_session_series/4/sessions/2?scope=future",
+		Body:        jsonBody(models.UpdateSeriesOccurrenceRequest{Description: &desc}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200 (a moved occurrence gets a new calendar sequence and reminder)
+func TestUpdateSeriesOccurrence_Move(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Now().Add(48 * time.Hour)
+
+	mock.ExpectQuery(`SELECT \* FROM "class_session_series" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "status", "duration_minutes"}).AddRow(4, 12, models.SeriesStatusActive, 90))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "series_id", "class_status", "class_start", "class_finish"}).
+			AddRow(2, 4, models.SessionStatusOpen, start, start.Add(90*time.Minute)))
+	mock.ExpectBegin()
+	expTeacherScheduleFree(mock)
+	mock.ExpectQuery(`SELECT "id" FROM "class_sessions" WHERE id IN \(\$1\) AND "class_sessions"\."deleted_at" IS NULL FOR UPDATE`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments" WHERE \(class_session_id IN \(\$1\) AND enrollment_status = \$2\)`).
+		WithArgs(2, models.EnrollmentStatusActive).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`UPDATE "class_sessions" SET "calendar_sequence"=calendar_sequence \+ 1,"class_finish"=\$1,"class_start"=\$2,"description"=\$3,"enrollment_deadline"=\$4,"learner_limit"=\$5,"price"=\$6,"reminder_sent_at"=\$7,"updated_at"=\$8 WHERE id = \$9`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	movedStart, movedFinish := start.Add(2*time.Hour), start.Add(2*time.Hour+90*time.Minute)
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/class_session_series/4/sessions/2",
+		Body:        jsonBody(models.UpdateSeriesOccurrenceRequest{ClassStart: &movedStart, ClassFinish: &movedFinish}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestUpdateSeriesOccurrence_MoveWithLearners(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Now().Add(48 * time.Hour)
+
+	mock.ExpectQuery(`SELECT \* FROM "class_session_series" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "status", "duration_minutes"}).AddRow(4, 12, models.SeriesStatusActive, 90))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "series_id", "class_status", "class_start", "class_finish"}).
+			AddRow(2, 4, models.SessionStatusOpen, start, start.Add(90*time.Minute)))
+	mock.ExpectBegin()
+	expTeacherScheduleFree(mock)
+	mock.ExpectQuery(`SELECT "id" FROM "class_sessions" WHERE id IN \(\$1\) AND "class_sessions"\."deleted_at" IS NULL FOR UPDATE`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments" WHERE \(class_session_id IN \(\$1\) AND enrollment_status = \$2\)`).
+		WithArgs(2, models.EnrollmentStatusActive).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	movedStart, movedFinish := start.Add(2*time.Hour), start.Add(2*time.Hour+90*time.Minute)
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/class_session_series/4/sessions/2",
+		Body:        jsonBody(models.UpdateSeriesOccurrenceRequest{ClassStart: &movedStart, ClassFinish: &movedFinish}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestUpdateSeriesOccurrence_MoveIntoConflict(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Now().Add(48 * time.Hour)
+
+	mock.ExpectQuery(`SELECT \* FROM "class_session_series" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "status", "duration_minutes"}).AddRow(4, 12, models.SeriesStatusActive, 90))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "series_id", "class_status", "class_start", "class_finish"}).
+			AddRow(2, 4, models.SessionStatusOpen, start, start.Add(90*time.Minute)))
+	mock.ExpectBegin()
+	expTeacherLock(mock)
+	mock.ExpectQuery(`SELECT class_sessions\.id AS class_session_id.* WHERE \(class_sessions\.id NOT IN \(\$1\) AND`).
+		WithArgs(2, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 12).
+		WillReturnRows(sqlmock.NewRows([]string{"class_session_id", "class_id", "class_name", "class_start", "class_finish"}).
+			AddRow(9, 13, "Physics", start.Add(2*time.Hour), start.Add(3*time.Hour)))
+	mock.ExpectRollback()
+
+	movedStart, movedFinish := start.Add(2*time.Hour), start.Add(2*time.Hour+90*time.Minute)
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/class_session_series/4/sessions/2",
+		Body:        jsonBody(models.UpdateSeriesOccurrenceRequest{ClassStart: &movedStart, ClassFinish: &movedFinish}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ CancelClassSessionSeries ------------------ */
+
+// 200
+func TestCancelClassSessionSeries_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	mock.ExpectQuery(`SELECT \* FROM "class_session_series" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "status"}).AddRow(4, 12, models.SeriesStatusActive))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE \(series_id = \$1 AND class_status IN \(\$2,\$3\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "series_id", "class_status", "class_start"}).
+			AddRow(7, 4, models.SessionStatusOpen, time.Now().Add(7*24*time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "class_sessions" SET "class_status"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND class_status = \$4\)`).
+		WithArgs(models.SessionStatusCancelled, sqlmock.AnyArg(), 7, models.SessionStatusOpen).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "enrollments" WHERE \(class_session_id = \$1 AND enrollment_status = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "class_session_series" SET "status"=\$1`).
+		WithArgs(models.SeriesStatusCancelled, sqlmock.AnyArg(), 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/class_session_series/4/cancel"})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -6,13 +6,14 @@ import (
 
 	"github.com/a2n2k3p4/tutorium-backend/middlewares"
 	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 func EnrollmentRoutes(app *fiber.App) {
-	enrollment := app.Group("/enrollments", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware(), middlewares.LearnerRequired())
+	enrollment := app.Group("/enrollments", middlewares.ProtectedMiddleware(), middlewares.LearnerRequired(), middlewares.BanMiddleware(models.BanScopeLearning))
 
 	enrollment.Post("/", CreateEnrollment)
 	enrollment.Get("/", GetEnrollments)
@@ -24,7 +25,7 @@ func EnrollmentRoutes(app *fiber.App) {
 // CreateEnrollment godoc
 //
 //	@Summary		Create a new enrollment
-//	@Description	CreateEnrollment creates a new Enrollment record
+//	@Description	CreateEnrollment creates a new Enrollment record. It is rejected when the session overlaps another session the learner is enrolled in. A learner joining a session with a pending reschedule is asked to accept or decline it like everyone else.
 //	@Tags			Enrollments
 //	@Security		BearerAuth
 //	@Accept			json
@@ -32,6 +33,8 @@ func EnrollmentRoutes(app *fiber.App) {
 //	@Param			enrollment	body		models.EnrollmentDoc	true	"Enrollment payload"
 //	@Success		201			{object}	models.EnrollmentDoc
 //	@Failure		400			{string}	string	"Invalid input"
+//	@Failure		404			{string}	string	"Class session not found"
+//	@Failure		409			{object}	models.ScheduleConflictErrorDoc	"Overlaps another of the learner's sessions"
 //	@Failure		500			{string}	string	"Server error"
 //	@Router			/enrollments [post]
 func CreateEnrollment(c *fiber.Ctx) error {
@@ -40,14 +43,41 @@ func CreateEnrollment(c *fiber.Ctx) error {
 	if err := c.BodyParser(&enrollment); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
+	// Enrolling here charges nothing; only paid bookings record AmountPaid.
+	// Status, attendance and refunds are owned by their own flows.
+	enrollment.AmountPaid = 0
+	enrollment.EnrollmentStatus = models.EnrollmentStatusActive
+	enrollment.AttendedAt = nil
+	enrollment.RefundAmount = 0
+	enrollment.RefundedAt = nil
 	db, err := middlewares.GetDB(c)
 	if err != nil {
 		return c.Status(500).JSON(err.Error())
 	}
 
-	if err := db.Create(&enrollment).Error; err != nil {
-		return c.Status(500).JSON(err.Error())
+	var reschedule *models.SessionReschedule
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var session models.ClassSession
+		if err := lockEnrollmentSession(tx, enrollment.ClassSessionID, &session); err != nil {
+			return err
+		}
+		if err := services.CheckLearnerSchedule(tx, enrollment.LearnerID, &session); err != nil {
+			return err
+		}
+		if err := tx.Create(&enrollment).Error; err != nil {
+			return err
+		}
+		var err error
+		reschedule, err = services.AddPendingRescheduleResponse(tx, session.ID, enrollment.LearnerID)
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON("class_session not found")
 	}
+	if err != nil {
+		return scheduleConflictResponse(c, err)
+	}
+	services.NotifyPendingReschedule(db, reschedule, enrollment.LearnerID)
 
 	return c.Status(201).JSON(enrollment)
 }
@@ -155,6 +185,12 @@ func GetEnrollments(c *fiber.Ctx) error {
 	return c.Status(200).JSON(responses)
 }
 
+// lockEnrollmentSession loads the session a learner is joining with a shared
+// lock, so its time cannot change until the enrollment commits.
+func lockEnrollmentSession(tx *gorm.DB, id uint, session *models.ClassSession) error {
+	return tx.Clauses(clause.Locking{Strength: "SHARE"}).First(session, "id = ?", id).Error
+}
+
 func findEnrollment(db *gorm.DB, id int, enrollment *models.Enrollment) error {
 	return db.Preload("Learner").Preload("ClassSession").First(enrollment, "id = ?", id).Error
 }
@@ -199,7 +235,7 @@ func GetEnrollment(c *fiber.Ctx) error {
 // UpdateEnrollment godoc
 //
 //	@Summary		Update an existing enrollment
-//	@Description	UpdateEnrollment updates an Enrollment record by its ID
+//	@Description	UpdateEnrollment updates an Enrollment record by its ID. Moving it to another learner or session is rejected when the session overlaps another of that learner's enrollments.
 //	@Tags			Enrollments
 //	@Security		BearerAuth
 //	@Accept			json
@@ -209,6 +245,7 @@ func GetEnrollment(c *fiber.Ctx) error {
 //	@Success		200			{object}	models.EnrollmentDoc
 //	@Failure		400			{string}	string	"Invalid input"
 //	@Failure		404			{string}	string	"Enrollment not found"
+//	@Failure		409			{object}	models.ScheduleConflictErrorDoc	"Overlaps another of the learner's sessions"
 //	@Failure		500			{string}	string	"Server error"
 //	@Router			/enrollments/{id} [put]
 func UpdateEnrollment(c *fiber.Ctx) error {
@@ -237,11 +274,42 @@ func UpdateEnrollment(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
-	if err := db.Model(&enrollment).
-		Omit(clause.Associations).
-		Updates(enrollment_update).Error; err != nil {
-		return c.Status(500).JSON(err.Error())
+	// Moving the enrollment to another learner or session is checked against
+	// the schedule of the learner it ends up with.
+	learnerID, sessionID := enrollment.LearnerID, enrollment.ClassSessionID
+	if enrollment_update.LearnerID != 0 {
+		learnerID = enrollment_update.LearnerID
+	}
+	if enrollment_update.ClassSessionID != 0 {
+		sessionID = enrollment_update.ClassSessionID
+	}
+
+	var reschedule *models.SessionReschedule
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if learnerID != enrollment.LearnerID || sessionID != enrollment.ClassSessionID {
+			var session models.ClassSession
+			if err := lockEnrollmentSession(tx, sessionID, &session); err != nil {
+				return err
+			}
+			if err := services.CheckLearnerSchedule(tx, learnerID, &session); err != nil {
+				return err
+			}
+			var err error
+			if reschedule, err = services.AddPendingRescheduleResponse(tx, sessionID, learnerID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&enrollment).
+			Omit(clause.Associations, "amount_paid", "enrollment_status", "attended_at", "refund_amount", "refunded_at").
+			Updates(enrollment_update).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(404).JSON("class_session not found")
+	}
+	if err != nil {
+		return scheduleConflictResponse(c, err)
 	}
+	services.NotifyPendingReschedule(db, reschedule, learnerID)
 
 	return c.Status(200).JSON(enrollment)
 
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/a2n2k3p4/tutorium-backend/models"
@@ -23,7 +25,13 @@ func TestCreateEnrollment_OK(t *testing.T) {
 	RunInDifferentStatus(t,
 		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
 			ExpAuthUser(userID, false, false, true)(mock)
-			ExpInsertReturningID(table, 1)(mock)
+			mock.ExpectBegin()
+			ExpSelectByIDFound("class_sessions", classSessionID, []string{"id"}, []any{classSessionID})(mock)
+			expLearnerScheduleFree(mock)
+			mock.ExpectQuery(`INSERT INTO "` + table + `".*RETURNING "id"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			expNoPendingReschedule(mock)
+			mock.ExpectCommit()
 
 			req := jsonBody(models.Enrollment{
 				LearnerID:        learnerID,
@@ -39,6 +47,90 @@ func TestCreateEnrollment_OK(t *testing.T) {
 	)
 }
 
+// 201 (joining during a pending reschedule adds a response row and asks the learner)
+func TestCreateEnrollment_JoinsPendingReschedule(t *testing.T) {
+	userID := uint(42)
+	learnerID := uint(5)
+	classSessionID := uint(10)
+	oldStart := time.Date(2030, 3, 8, 23, 0, 0, 0, time.UTC)
+	newStart := time.Date(2030, 3, 11, 22, 0, 0, 0, time.UTC)
+
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, false, false, true)(mock)
+			mock.ExpectBegin()
+			ExpSelectByIDFound("class_sessions", classSessionID, []string{"id"}, []any{classSessionID})(mock)
+			expLearnerScheduleFree(mock)
+			mock.ExpectQuery(`INSERT INTO "enrollments".*RETURNING "id"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectQuery(`SELECT \* FROM "session_reschedules" WHERE \(class_session_id = \$1 AND status = \$2\)`).
+				WithArgs(classSessionID, models.RescheduleStatusPending, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "class_session_id", "old_start", "new_start", "response_deadline", "status"}).
+					AddRow(2, classSessionID, oldStart, newStart, oldStart.Add(-24*time.Hour), models.RescheduleStatusPending))
+			mock.ExpectQuery(`INSERT INTO "reschedule_responses" .* ON CONFLICT \("reschedule_id","learner_id"\) DO UPDATE SET`).
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 2, learnerID, models.RescheduleDecisionPending, sqlmock.AnyArg(), models.RescheduleDecisionPending, nil).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectCommit()
+			mock.ExpectQuery(`SELECT "user_id" FROM "learners" WHERE id = \$1`).
+				WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(userID))
+			expUserTimezones(mock, userID, "America/New_York")
+			expNotice(mock, userID, "Your class on Fri 8 Mar 2030 18:00 -05:00 (America/New_York)", "move to Mon 11 Mar 2030 18:00 -04:00")
+
+			*payload = jsonBody(models.Enrollment{LearnerID: learnerID, ClassSessionID: classSessionID})
+			*uID = userID
+		},
+		http.StatusCreated,
+		http.MethodPost,
+		"/enrollments/",
+	)
+}
+
+// 201
+func TestCreateEnrollment_IgnoresServerOwnedFields(t *testing.T) {
+	t.Setenv("STATUS", "development")
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+	mock.MatchExpectationsInOrder(false)
+
+	app := setupApp(gdb)
+	mock.ExpectBegin()
+	ExpSelectByIDFound("class_sessions", 10, []string{"id"}, []any{10})(mock)
+	expLearnerScheduleFree(mock)
+	mock.ExpectQuery(`INSERT INTO "enrollments".*RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expNoPendingReschedule(mock)
+	mock.ExpectCommit()
+
+	attended := time.Now()
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/enrollments/",
+		Body: jsonBody(models.Enrollment{
+			LearnerID:        5,
+			ClassSessionID:   10,
+			EnrollmentStatus: models.EnrollmentStatusRefunded,
+			AttendedAt:       &attended,
+			AmountPaid:       100,
+			RefundAmount:     100,
+			RefundedAt:       &attended,
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	var got models.Enrollment
+	if err := json.Unmarshal(readBody(t, resp.Body), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.EnrollmentStatus != models.EnrollmentStatusActive || got.AttendedAt != nil || got.AmountPaid != 0 || got.RefundAmount != 0 || got.RefundedAt != nil {
+		t.Fatalf("server-owned fields were taken from the request: %+v", got)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 // 400
 func TestCreateEnrollment_BadRequest(t *testing.T) {
 	userID := uint(42)
@@ -64,7 +156,12 @@ func TestCreateEnrollment_DBError(t *testing.T) {
 	RunInDifferentStatus(t,
 		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
 			ExpAuthUser(userID, false, false, true)(mock)
-			ExpInsertError(table, fmt.Errorf("db insert failed"))(mock)
+			mock.ExpectBegin()
+			ExpSelectByIDFound("class_sessions", classSessionID, []string{"id"}, []any{classSessionID})(mock)
+			expLearnerScheduleFree(mock)
+			mock.ExpectQuery(`INSERT INTO "` + table + `".*RETURNING "id"`).
+				WillReturnError(fmt.Errorf("db insert failed"))
+			mock.ExpectRollback()
 			req := jsonBody(models.Enrollment{
 				LearnerID:        learnerID,
 				ClassSessionID:   classSessionID,
@@ -79,6 +176,41 @@ func TestCreateEnrollment_DBError(t *testing.T) {
 	)
 }
 
+// 409
+func TestCreateEnrollment_LearnerScheduleConflict(t *testing.T) {
+	userID := uint(42)
+	learnerID := uint(5)
+	classSessionID := uint(10)
+	start := time.Now().Add(48 * time.Hour)
+
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, false, false, true)(mock)
+			mock.ExpectBegin()
+			ExpSelectByIDFound("class_sessions", classSessionID,
+				[]string{"id", "class_start", "class_finish"},
+				[]any{classSessionID, start, start.Add(2 * time.Hour)},
+			)(mock)
+			expLearnerLock(mock)
+			mock.ExpectQuery(`SELECT class_sessions\.id AS class_session_id.* JOIN enrollments ON enrollments\.class_session_id = class_sessions\.id .*enrollments\.learner_id = \$6 AND enrollments\.enrollment_status = \$7`).
+				WithArgs(classSessionID, models.SessionStatusCancelled, models.SessionStatusTeacherAbsent, sqlmock.AnyArg(), sqlmock.AnyArg(), learnerID, models.EnrollmentStatusActive).
+				WillReturnRows(sqlmock.NewRows([]string{"class_session_id", "class_id", "class_name", "class_start", "class_finish"}).
+					AddRow(8, 13, "Chemistry", start.Add(time.Hour), start.Add(3*time.Hour)))
+			mock.ExpectRollback()
+
+			*payload = jsonBody(models.Enrollment{
+				LearnerID:        learnerID,
+				ClassSessionID:   classSessionID,
+				EnrollmentStatus: models.EnrollmentStatusActive,
+			})
+			*uID = userID
+		},
+		http.StatusConflict,
+		http.MethodPost,
+		"/enrollments/",
+	)
+}
+
 /* ------------------ GetEnrollments ------------------ */
 
 // 200
@@ -222,6 +354,44 @@ func TestUpdateEnrollment_OK(t *testing.T) {
 	)
 }
 
+// 409 (the session stays, but it overlaps the new learner's schedule)
+func TestUpdateEnrollment_NewLearnerScheduleConflict(t *testing.T) {
+	userID := uint(42)
+	enrollmentID := uint(1)
+	classSessionID := uint(10)
+	newLearnerID := uint(6)
+	start := time.Now().Add(48 * time.Hour)
+
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, false, false, true)(mock)
+			ExpSelectByIDFound("enrollments", enrollmentID,
+				[]string{"id", "learner_id", "class_session_id"},
+				[]any{enrollmentID, 5, classSessionID},
+			)(mock)
+			ExpPreloadField("learners", []string{"id"}, []any{5})(mock)
+			ExpPreloadField("class_sessions", []string{"id"}, []any{classSessionID})(mock)
+			mock.ExpectBegin()
+			ExpSelectByIDFound("class_sessions", classSessionID,
+				[]string{"id", "class_start", "class_finish"},
+				[]any{classSessionID, start, start.Add(2 * time.Hour)},
+			)(mock)
+			expLearnerLock(mock)
+			mock.ExpectQuery(`SELECT class_sessions\.id AS class_session_id.* JOIN enrollments ON enrollments\.class_session_id = class_sessions\.id .*enrollments\.learner_id = \$6 AND enrollments\.enrollment_status = \$7`).
+				WithArgs(classSessionID, models.SessionStatusCancelled, models.SessionStatusTeacherAbsent, sqlmock.AnyArg(), sqlmock.AnyArg(), newLearnerID, models.EnrollmentStatusActive).
+				WillReturnRows(sqlmock.NewRows([]string{"class_session_id", "class_id", "class_name", "class_start", "class_finish"}).
+					AddRow(8, 13, "Chemistry", start.Add(time.Hour), start.Add(3*time.Hour)))
+			mock.ExpectRollback()
+
+			*payload = jsonBody(map[string]any{"learner_id": newLearnerID})
+			*uID = userID
+		},
+		http.StatusConflict,
+		http.MethodPut,
+		fmt.Sprintf("/enrollments/%d", enrollmentID),
+	)
+}
+
 // 404
 func TestUpdateEnrollment_NotFound(t *testing.T) {
 	table := "enrollments"
@@ -252,7 +422,13 @@ func TestUpdateEnrollment_DBError(t *testing.T) {
 		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
 			ExpAuthUser(userID, false, false, true)(mock)
 			ExpSelectByIDFound(table, enrollmentID, []string{"id"}, []any{enrollmentID})(mock)
-			ExpUpdateError(table, fmt.Errorf("update failed"))(mock)
+			mock.ExpectBegin()
+			ExpSelectByIDFound("class_sessions", classSessionID, []string{"id"}, []any{classSessionID})(mock)
+			expLearnerScheduleFree(mock)
+			expNoPendingReschedule(mock)
+			mock.ExpectExec(`UPDATE "` + table + `" SET .* WHERE "` + table + `"\."deleted_at" IS NULL`).
+				WillReturnError(fmt.Errorf("update failed"))
+			mock.ExpectRollback()
 
 			req := jsonBody(models.Enrollment{
 				LearnerID:        learnerID,
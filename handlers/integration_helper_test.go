@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/a2n2k3p4/tutorium-backend/config"
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
 	"github.com/a2n2k3p4/tutorium-backend/middlewares"
 	"github.com/a2n2k3p4/tutorium-backend/models"
 	"github.com/gofiber/fiber/v2"
@@ -79,6 +80,7 @@ func TestMain(m *testing.M) {
 	integApp = fiber.New()
 	integApp.Use(middlewares.DBMiddleware(integDB))
 	integApp.Use(middlewares.MinioMiddleware(dummyUploader{}))
+	integApp.Use(middlewares.MeetingMiddleware(meeting.NewFake("")))
 	integApp.Use(func(c *fiber.Ctx) error {
 		c.Locals("omise", nil)
 		return c.Next()
@@ -103,6 +105,14 @@ func (dummyUploader) UploadBytes(ctx context.Context, folder, filename string, b
 	return fmt.Sprintf("stub://%s/%s", folder, filename), nil
 }
 
+func (dummyUploader) PutObject(ctx context.Context, objectName string, r io.Reader, size int64, contentType string) error {
+	return nil
+}
+
+func (dummyUploader) RemoveObject(ctx context.Context, objectName string) error {
+	return nil
+}
+
 /* ------------------ API Request Helper ------------------ */
 func newJSONRequest(t *testing.T, method, target string, payload any) *http.Request {
 	t.Helper()
@@ -156,6 +156,20 @@ func generateJWT(user models.User) (string, error) {
 	return token.SignedString(secret)
 }
 
+// generateMFAJWT issues a token that records a successful second-factor check at verifiedAt.
+func generateMFAJWT(user models.User, verifiedAt time.Time) (string, error) {
+	secret := middlewares.Secret()
+
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"mfa":     true,
+		"mfa_at":  verifiedAt.Unix(),
+		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(secret)
+}
+
 // validateImageBytes checks size and MIME type of the image bytes.
 func validateImageBytes(b []byte) error {
 	const MaxProfileImageBytes = 2 * 1024 * 1024
@@ -1,119 +1,376 @@
 package handlers
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"log"
-	"os"
+	"strings"
+	"time"
 
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
 	"github.com/a2n2k3p4/tutorium-backend/middlewares"
 	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/a2n2k3p4/tutorium-backend/storage"
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 	"gorm.io/gorm"
 )
 
 func MeetingRoutes(app *fiber.App) {
-	meetingurl := NewMeetingHandler()
-	meeting := app.Group("/meetings", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware(), middlewares.TeacherRequired(), middlewares.LearnerRequired())
-	meeting.Get("/:id", meetingurl.GetMeetingLink)
-}
+	app.Post("/webhooks/meetings", MeetingWebhook)
 
-var BASE_JITSI_URL string
+	meetings := app.Group("/meetings", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware())
+	meetings.Get("/:id", GetMeetingLink)
+	meetings.Get("/:id/accesses", GetMeetingAccesses)
+	meetings.Get("/:id/participants", GetMeetingParticipants)
+	meetings.Get("/:id/presence", GetMeetingPresence)
+	meetings.Post("/:id/end", EndMeeting)
+}
 
-func init() {
-	err := godotenv.Load(".env")
+// newMeetingRoom opens a room on the configured meeting provider for a
+// session about to be created.
+func newMeetingRoom(c *fiber.Ctx, title string, start, finish time.Time) (meeting.Room, error) {
+	provider, err := middlewares.GetMeetingProvider(c)
 	if err != nil {
-		log.Println("Warning: could not load .env file")
+		return meeting.Room{}, err
 	}
-	BASE_JITSI_URL = os.Getenv("JITSI_URL")
+	return provider.CreateRoom(c.Context(), meeting.RoomSpec{Title: title, Start: start, Finish: finish})
 }
 
-// Create baseURL variable type
-type MeetingURL struct {
-	BaseURL string `json:"base_url"`
+// discardMeetingRooms closes rooms opened for sessions that were not saved
+// after all. Providers without an end call, like Jitsi, keep no state for
+// an unused room, so only other failures are logged.
+func discardMeetingRooms(c *fiber.Ctx, rooms ...meeting.Room) {
+	provider, err := middlewares.GetMeetingProvider(c)
+	if err != nil {
+		return
+	}
+	for _, room := range rooms {
+		if err := provider.EndMeeting(c.Context(), room.ID); err != nil && !errors.Is(err, meeting.ErrUnsupported) {
+			log.Printf("meeting: failed to close unused room %q: %v", room.ID, err)
+		}
+	}
 }
 
-func NewMeetingHandler() *MeetingURL {
-	return &MeetingURL{BaseURL: BASE_JITSI_URL}
+// meetingErrorStatus maps meeting errors onto HTTP statuses.
+func meetingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNotMeetingParticipant), errors.Is(err, services.ErrMeetingBanned):
+		return 403
+	case errors.Is(err, services.ErrNoMeetingRoom):
+		return 404
+	case errors.Is(err, services.ErrMeetingClosed):
+		return 410
+	case errors.Is(err, services.ErrMeetingProviderMismatch):
+		return 409
+	case errors.Is(err, meeting.ErrUnsupported):
+		return 501
+	default:
+		return 500
+	}
 }
 
-// GenerateMeetingLink generates a meeting link using UUID v8 hashed with SHA-256
-func GenerateMeetingLink(baseURL string) (string, error) {
-	// Generate UUID v8
-	uuidBytes := make([]byte, 16)
-	_, err := rand.Read(uuidBytes)
+// loadMeetingSession loads the :id session with its class for the meeting
+// endpoints, writing the error response when it returns false.
+func loadMeetingSession(c *fiber.Ctx, db *gorm.DB, session *models.ClassSession) (bool, error) {
+	id, err := c.ParamsInt("id")
 	if err != nil {
-		return "", err
+		return false, c.Status(400).JSON(fiber.Map{"error": "invalid class session ID"})
 	}
+	err = findClassSession(db, id, session)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return false, c.Status(404).JSON(fiber.Map{"error": "class session not found"})
+	case err != nil:
+		return false, c.Status(500).JSON(err.Error())
+	}
+	return true, nil
+}
 
-	// Set version to 8 (bits 4-7 of byte 6)
-	uuidBytes[6] = (uuidBytes[6] & 0x0f) | 0x80
-	// Set variant to RFC4122 (bits 6-7 of byte 8)
-	uuidBytes[8] = (uuidBytes[8] & 0x3f) | 0x80
+// GetMeetingLink godoc
+//
+//	@Summary		Get meeting link by ClassSession ID
+//	@Description	Retrieves the caller's personal link into a ClassSession's meeting on the configured provider (Jitsi, BigBlueButton). Only the session's teacher, who joins as moderator, and learners with an active enrollment get a link, and only from 15 minutes before the session until 15 minutes after it ends. On Jitsi with token authentication the link carries a JWT naming the room, the caller's name and avatar, valid for that window. Every link handed out is logged. Unless the provider reports presence through webhooks, fetching the link while the session runs also counts as attending.
+//	@Tags			Meetings
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int							true	"ClassSession ID"
+//	@Success		200	{object}	models.MeetingJoinInfoDoc	"Join details"
+//	@Failure		400	{object}	map[string]string			"Invalid class session ID"
+//	@Failure		401	{object}	map[string]string			"Unauthorized"
+//	@Failure		403	{object}	map[string]string			"Not the session's teacher or an enrolled learner, or banned"
+//	@Failure		404	{object}	map[string]string			"Class session not found or meeting not created"
+//	@Failure		410	{object}	map[string]string			"Meeting has ended or session was cancelled"
+//	@Failure		425	{object}	map[string]string			"Meeting not open yet"
+//	@Failure		500	{string}	string						"Server error"
+//	@Router			/meetings/{id} [get]
+func GetMeetingLink(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
 
-	uuidV8, err := uuid.FromBytes(uuidBytes)
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	provider, err := middlewares.GetMeetingProvider(c)
 	if err != nil {
-		return "", err
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var classSession models.ClassSession
+	if ok, err := loadMeetingSession(c, db, &classSession); !ok {
+		return err
 	}
 
-	// Hash UUID with SHA-256
-	hash := sha256.Sum256([]byte(uuidV8.String()))
-	hashedString := hex.EncodeToString(hash[:])
+	now := time.Now()
+	role, err := services.AuthorizeMeetingAccess(db, &classSession, user, now)
+	if errors.Is(err, services.ErrMeetingNotOpen) {
+		opensAt, _ := services.MeetingTokenWindow(&classSession)
+		return c.Status(425).JSON(fiber.Map{"error": err.Error(), "opens_at": opensAt})
+	}
+	if err != nil {
+		return c.Status(meetingErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	// Return full meeting URL
-	return baseURL + "/" + hashedString, nil
+	_, expires := services.MeetingTokenWindow(&classSession)
+	info, err := services.JoinSessionMeeting(c.Context(), provider, &classSession, user, meetingAvatarURL(c, user, expires.Sub(now)), now)
+	if err != nil {
+		return c.Status(meetingErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := services.RecordMeetingAccess(db, &classSession, user, role, c.IP(), !meeting.TracksPresence(provider), now); err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.JSON(info)
 }
 
-// GetMeetingLink godoc
+// GetMeetingAccesses godoc
 //
-//	@Summary		Get meeting link by ClassSession ID
-//	@Description	Retrieves the meeting link associated with a given ClassSession ID.
+//	@Summary		List who fetched a session's meeting link
+//	@Description	Returns the log of join links handed out for a ClassSession's meeting, oldest first. Only the session's teacher may read it.
 //	@Tags			Meetings
 //	@Security		BearerAuth
 //	@Produce		json
-//	@Param			id	path		int					true	"ClassSession ID"
-//	@Success		200	{object}	map[string]string	"meeting_link"
+//	@Param			id	path		int		true	"ClassSession ID"
+//	@Success		200	{array}		models.MeetingAccessDoc
 //	@Failure		400	{object}	map[string]string	"Invalid class session ID"
 //	@Failure		401	{object}	map[string]string	"Unauthorized"
-//	@Failure		404	{object}	map[string]string	"Class session not found or meeting not created"
+//	@Failure		403	{string}	string				"Not the session's teacher"
+//	@Failure		404	{object}	map[string]string	"Class session not found"
 //	@Failure		500	{string}	string				"Server error"
-//	@Router			/meetings/{id} [get]
-func (h *MeetingURL) GetMeetingLink(c *fiber.Ctx) error {
-	_, ok := c.Locals("currentUser").(*models.User)
+//	@Router			/meetings/{id}/accesses [get]
+func GetMeetingAccesses(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
 	if !ok {
 		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
 	}
-
 	db, err := middlewares.GetDB(c)
 	if err != nil {
 		return c.Status(500).JSON(err.Error())
 	}
 
-	// ------- Get class session ID from params -------
-	id, err := c.ParamsInt("id")
+	var classSession models.ClassSession
+	if ok, err := loadMeetingSession(c, db, &classSession); !ok {
+		return err
+	}
+	if !services.IsSessionTeacher(user, &classSession) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+	accesses, err := services.SessionMeetingAccesses(db, classSession.ID)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "invalid class session ID"})
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(accesses)
+}
+
+// GetMeetingPresence godoc
+//
+//	@Summary		Time each participant spent in a session's meeting
+//	@Description	Totals, per participant, the time in the ClassSession's room reported by the meeting provider's join and leave webhooks. Only the session's teacher may ask.
+//	@Tags			Meetings
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int		true	"ClassSession ID"
+//	@Success		200	{array}		models.ParticipantPresenceDoc
+//	@Failure		400	{object}	map[string]string	"Invalid class session ID"
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		403	{string}	string				"Not the session's teacher"
+//	@Failure		404	{object}	map[string]string	"Class session not found"
+//	@Failure		500	{string}	string				"Server error"
+//	@Router			/meetings/{id}/presence [get]
+func GetMeetingPresence(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
 	}
 
 	var classSession models.ClassSession
+	if ok, err := loadMeetingSession(c, db, &classSession); !ok {
+		return err
+	}
+	if !services.IsSessionTeacher(user, &classSession) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+	presence, err := services.SessionPresence(db, &classSession, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(presence)
+}
+
+// MeetingWebhook godoc
+//
+//	@Summary		Meeting provider presence webhook
+//	@Description	Receives participant join and leave events from the configured meeting provider: Jitsi's mod_event_sync_component (bearer MEETING_WEBHOOK_SECRET), bbb-webhooks (checksum signed with BBB_SECRET) or the fake provider. Joins drive teacher check-in, and with it absence detection, and learner attendance. Events for unknown rooms or users are ignored.
+//	@Tags			Meetings
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	map[string]int		"Number of events received"
+//	@Failure		400	{object}	map[string]string	"Unreadable payload"
+//	@Failure		401	{object}	map[string]string	"Bad signature"
+//	@Failure		404	{object}	map[string]string	"Webhooks not enabled for the provider"
+//	@Failure		500	{string}	string				"Server error"
+//	@Router			/webhooks/meetings [post]
+func MeetingWebhook(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	provider, err := middlewares.GetMeetingProvider(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	receiver, ok := provider.(meeting.WebhookReceiver)
+	if !ok || !receiver.ReceivesWebhooks() {
+		return c.Status(404).JSON(fiber.Map{"error": "meeting webhooks are not enabled"})
+	}
 
-	err = db.First(&classSession, "id = ?", id).Error
+	events, err := receiver.ParseWebhook(meeting.Webhook{
+		URL:           c.BaseURL() + c.OriginalURL(),
+		Authorization: c.Get(fiber.HeaderAuthorization),
+		ContentType:   c.Get(fiber.HeaderContentType),
+		Body:          c.Body(),
+	})
 	switch {
-	case errors.Is(err, gorm.ErrRecordNotFound):
-		return c.Status(404).JSON(fiber.Map{"error": "class session not found"})
+	case errors.Is(err, meeting.ErrWebhookUnauthorized):
+		return c.Status(401).JSON(fiber.Map{"error": err.Error()})
 	case err != nil:
-		return c.Status(500).JSON(err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	link := classSession.MeetingUrl
-	if link == "" {
-		return c.Status(404).JSON(fiber.Map{"error": "no meeting was created for your class session yet"})
+	for _, ev := range events {
+		err := services.RecordPresenceEvent(db, provider.Name(), ev)
+		if errors.Is(err, services.ErrUnknownMeetingRoom) || errors.Is(err, services.ErrNotMeetingParticipant) {
+			log.Printf("meeting webhook: ignoring %s of user %q in room %q: %v", ev.Kind, ev.UserID, ev.RoomID, err)
+			continue
+		}
+		if err != nil {
+			return c.Status(500).JSON(err.Error())
+		}
 	}
-	return c.JSON(fiber.Map{
-		"meeting_link": link,
+	return c.Status(200).JSON(fiber.Map{"received": len(events)})
+}
+
+// GetMeetingParticipants godoc
+//
+//	@Summary		List who is in a session's meeting
+//	@Description	Asks the meeting provider who is in the ClassSession's room right now. Only the session's teacher may ask. Jitsi needs Prosody's mod_muc_size for this.
+//	@Tags			Meetings
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int		true	"ClassSession ID"
+//	@Success		200	{array}		models.MeetingAttendeeDoc
+//	@Failure		400	{object}	map[string]string	"Invalid class session ID"
+//	@Failure		403	{string}	string				"Not the session's teacher"
+//	@Failure		404	{object}	map[string]string	"Class session not found or meeting not created"
+//	@Failure		501	{object}	map[string]string	"Provider cannot list participants"
+//	@Failure		500	{string}	string				"Server error"
+//	@Router			/meetings/{id}/participants [get]
+func GetMeetingParticipants(c *fiber.Ctx) error {
+	return withTeacherMeeting(c, func(provider meeting.Provider, roomID string) error {
+		attendees, err := provider.Participants(c.Context(), roomID)
+		if err != nil {
+			return c.Status(meetingErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(200).JSON(attendees)
 	})
 }
+
+// EndMeeting godoc
+//
+//	@Summary		End a session's meeting
+//	@Description	Closes the ClassSession's room for everyone in it. Only the session's teacher may end it. Jitsi has no API for this, so Jitsi teachers end the meeting from the moderator controls.
+//	@Tags			Meetings
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int		true	"ClassSession ID"
+//	@Success		200	{string}	string				"Meeting ended"
+//	@Failure		400	{object}	map[string]string	"Invalid class session ID"
+//	@Failure		403	{string}	string				"Not the session's teacher"
+//	@Failure		404	{object}	map[string]string	"Class session not found or meeting not created"
+//	@Failure		501	{object}	map[string]string	"Provider cannot end meetings"
+//	@Failure		500	{string}	string				"Server error"
+//	@Router			/meetings/{id}/end [post]
+func EndMeeting(c *fiber.Ctx) error {
+	return withTeacherMeeting(c, func(provider meeting.Provider, roomID string) error {
+		if err := provider.EndMeeting(c.Context(), roomID); err != nil {
+			return c.Status(meetingErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(200).JSON("Meeting ended")
+	})
+}
+
+// withTeacherMeeting loads the :id session, checks the caller teaches it and
+// runs fn with the session's room.
+func withTeacherMeeting(c *fiber.Ctx, fn func(provider meeting.Provider, roomID string) error) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	provider, err := middlewares.GetMeetingProvider(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var classSession models.ClassSession
+	if ok, err := loadMeetingSession(c, db, &classSession); !ok {
+		return err
+	}
+	if !services.IsSessionTeacher(user, &classSession) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+	roomID, err := services.SessionMeetingRoom(provider, &classSession)
+	if err != nil {
+		return c.Status(meetingErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return fn(provider, roomID)
+}
+
+// meetingAvatarURL makes user's profile picture loadable by the meeting
+// clients for ttl. Stored object keys are presigned; URLs pass through.
+func meetingAvatarURL(c *fiber.Ctx, user *models.User, ttl time.Duration) string {
+	pic := user.ProfilePictureURL
+	if pic == "" || strings.HasPrefix(pic, "http") {
+		return pic
+	}
+	ps, ok := c.Locals("minio").(storage.Presigner)
+	if !ok {
+		return ""
+	}
+	if ttl < 15*time.Minute {
+		ttl = 15 * time.Minute
+	}
+	u, err := ps.PresignedGetObject(c.Context(), pic, ttl)
+	if err != nil {
+		return ""
+	}
+	return u
+}
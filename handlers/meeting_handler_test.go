@@ -0,0 +1,733 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+var testJitsi = &meeting.Jitsi{BaseURL: "https://meet.example.com", AppID: "tutorium", Secret: "jitsi-secret"}
+
+// setupMeetingApp serves the meeting endpoints on provider as user.
+func setupMeetingApp(gdb *gorm.DB, user *models.User, provider meeting.Provider) *fiber.App {
+	app := fiber.New()
+	app.Use(middlewares.DBMiddleware(gdb))
+	app.Use(middlewares.MeetingMiddleware(provider))
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("currentUser", user)
+		return c.Next()
+	})
+	app.Get("/meetings/:id", GetMeetingLink)
+	app.Get("/meetings/:id/accesses", GetMeetingAccesses)
+	app.Get("/meetings/:id/participants", GetMeetingParticipants)
+	app.Get("/meetings/:id/presence", GetMeetingPresence)
+	app.Post("/meetings/:id/end", EndMeeting)
+	app.Post("/webhooks/meetings", MeetingWebhook)
+	return app
+}
+
+// expMeetingSession expects session 3 of class 12 (teacher 30) whose room
+// abc123 is hosted on provider.
+func expMeetingSession(mock sqlmock.Sqlmock, start time.Time, provider string) {
+	expMeetingSessionStatus(mock, start, provider, models.SessionStatusScheduled)
+}
+
+func expMeetingSessionStatus(mock sqlmock.Sqlmock, start time.Time, provider, status string) {
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "class_start", "class_finish", "class_status", "meeting_url", "meeting_provider", "meeting_room_id"}).
+			AddRow(3, 12, start, start.Add(2*time.Hour), status, "https://meet.example.com/abc123", provider, "abc123"))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+}
+
+// expMeetingEnrollment expects the check that learnerID is actively enrolled in session 3.
+func expMeetingEnrollment(mock sqlmock.Sqlmock, learnerID uint, enrolled bool) {
+	count := 0
+	if enrolled {
+		count = 1
+	}
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments" WHERE \(learner_id = \$1 AND class_session_id = \$2 AND enrollment_status = \$3\)`).
+		WithArgs(learnerID, 3, models.EnrollmentStatusActive).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+}
+
+// expMeetingBan expects the active ban check for a teacher (teaching scope)
+// or learner (learning scope) joining session 3.
+func expMeetingBan(mock sqlmock.Sqlmock, table, column string, id uint, scope string, banned bool) {
+	count := 0
+	if banned {
+		count = 1
+	}
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "`+table+`" WHERE \(`+column+` = \$1 AND ban_end > \$2 AND ban_scope IN \(\$3,\$4\)\)`).
+		WithArgs(id, sqlmock.AnyArg(), models.BanScopePlatform, scope).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+}
+
+func expNoTeacherMeetingBan(mock sqlmock.Sqlmock, teacherID uint) {
+	expMeetingBan(mock, "ban_details_teachers", "teacher_id", teacherID, models.BanScopeTeaching, false)
+}
+
+func expNoLearnerMeetingBan(mock sqlmock.Sqlmock, learnerID uint) {
+	expMeetingBan(mock, "ban_details_learners", "learner_id", learnerID, models.BanScopeLearning, false)
+}
+
+// expMeetingAccessLogged expects userID's access to session 3 to be logged in role.
+func expMeetingAccessLogged(mock sqlmock.Sqlmock, userID uint, role string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "meeting_accesses" .*RETURNING "id"`).
+		WithArgs(sqlmock.AnyArg(), 3, userID, role, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+}
+
+// finishedMeetingStart starts a session that ended five minutes ago: its
+// links still work but no longer count as attending.
+func finishedMeetingStart() time.Time {
+	return time.Now().Add(-2*time.Hour - 5*time.Minute)
+}
+
+func decodeJoinInfo(t *testing.T, resp *http.Response) (models.MeetingJoinInfo, jwt.MapClaims) {
+	t.Helper()
+	var info models.MeetingJoinInfo
+	if err := json.Unmarshal(readBody(t, resp.Body), &info); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	claims := jwt.MapClaims{}
+	// nbf lies in the future, so only the signature is checked here.
+	_, err := jwt.ParseWithClaims(info.JWT, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(testJitsi.Secret), nil
+	}, jwt.WithoutClaimsValidation())
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	return info, claims
+}
+
+/* ------------------ GetMeetingLink ------------------ */
+
+// 200
+func TestGetMeetingLink_TeacherGetsModeratorToken(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	teacher := sessionTeacherUser(5, 30)
+	teacher.FirstName, teacher.LastName = "Ada", "Lovelace"
+	app := setupMeetingApp(gdb, teacher, testJitsi)
+	start := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	expMeetingSession(mock, start, meeting.ProviderJitsi)
+	expNoTeacherMeetingBan(mock, 30)
+	expMeetingAccessLogged(mock, 5, models.MeetingRoleTeacher)
+	// Joining inside the attendance window checks the teacher in.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "class_sessions" SET "teacher_checked_in_at"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND teacher_checked_in_at IS NULL\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/meetings/3"})
+	wantStatus(t, resp, http.StatusOK)
+
+	info, claims := decodeJoinInfo(t, resp)
+	if !info.Moderator || !strings.HasSuffix(info.MeetingLink, "?jwt="+info.JWT) {
+		t.Fatalf("unexpected join info: %+v", info)
+	}
+	if claims["room"] != "abc123" || claims["sub"] != "meet.example.com" || claims["moderator"] != true {
+		t.Fatalf("unexpected claims: %v", claims)
+	}
+	user := claims["context"].(map[string]interface{})["user"].(map[string]interface{})
+	if user["name"] != "Ada Lovelace" || user["moderator"] != true {
+		t.Fatalf("unexpected user claim: %v", user)
+	}
+	if nbf := int64(claims["nbf"].(float64)); nbf != start.Add(-15*time.Minute).Unix() {
+		t.Fatalf("nbf = %d", nbf)
+	}
+	if exp := int64(claims["exp"].(float64)); exp != start.Add(2*time.Hour+15*time.Minute).Unix() {
+		t.Fatalf("exp = %d", exp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200
+func TestGetMeetingLink_LearnerIsNotModerator(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	learner := enrolledLearnerUser(7, 9)
+	learner.ProfilePictureURL = "https://cdn.example.com/me.png"
+	app := setupMeetingApp(gdb, learner, testJitsi)
+	expMeetingSession(mock, finishedMeetingStart(), meeting.ProviderJitsi)
+	expMeetingEnrollment(mock, 9, true)
+	expNoLearnerMeetingBan(mock, 9)
+	expMeetingAccessLogged(mock, 7, models.MeetingRoleLearner)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/meetings/3"})
+	wantStatus(t, resp, http.StatusOK)
+
+	info, claims := decodeJoinInfo(t, resp)
+	if info.Moderator || claims["moderator"] != false {
+		t.Fatalf("learner must not moderate: %+v %v", info, claims)
+	}
+	user := claims["context"].(map[string]interface{})["user"].(map[string]interface{})
+	if user["avatar"] != "https://cdn.example.com/me.png" || user["id"] != "7" {
+		t.Fatalf("unexpected user claim: %v", user)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 410
+func TestGetMeetingLink_Ended(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupMeetingApp(gdb, enrolledLearnerUser(7, 9), testJitsi)
+	expMeetingSession(mock, time.Now().Add(-3*time.Hour), meeting.ProviderJitsi)
+	expMeetingEnrollment(mock, 9, true)
+	expNoLearnerMeetingBan(mock, 9)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/meetings/3"})
+	wantStatus(t, resp, http.StatusGone)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestGetMeetingLink_ProviderMismatch(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupMeetingApp(gdb, enrolledLearnerUser(7, 9), testJitsi)
+	expMeetingSession(mock, finishedMeetingStart(), meeting.ProviderBigBlueButton)
+	expMeetingEnrollment(mock, 9, true)
+	expNoLearnerMeetingBan(mock, 9)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/meetings/3"})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestGetMeetingLink_LearnerNotEnrolled(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupMeetingApp(gdb, enrolledLearnerUser(7, 9), testJitsi)
+	expMeetingSession(mock, time.Now().Add(10*time.Minute), meeting.ProviderJitsi)
+	expMeetingEnrollment(mock, 9, false)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/meetings/3"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestGetMeetingLink_OtherTeacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupMeetingApp(gdb, sessionTeacherUser(6, 31), testJitsi)
+	expMeetingSession(mock, time.Now().Add(10*time.Minute), meeting.ProviderJitsi)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/meetings/3"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestGetMeetingLink_TeacherBannedFromTeaching(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupMeetingApp(gdb, sessionTeacherUser(5, 30), testJitsi)
+	expMeetingSession(mock, time.Now().Add(10*time.Minute), meeting.ProviderJitsi)
+	expMeetingBan(mock, "ban_details_teachers", "teacher_id", 30, models.BanScopeTeaching, true)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/meetings/3"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestGetMeetingLink_LearnerBannedFromLearning(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupMeetingApp(gdb, enrolledLearnerUser(7, 9), testJitsi)
+	expMeetingSession(mock, time.Now().Add(10*time.Minute), meeting.ProviderJitsi)
+	expMeetingEnrollment(mock, 9, true)
+	expMeetingBan(mock, "ban_details_learners", "learner_id", 9, models.BanScopeLearning, true)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/meetings/3"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 425
+func TestGetMeetingLink_TooEarly(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupMeetingApp(gdb, enrolledLearnerUser(7, 9), testJitsi)
+	start := time.Now().Add(time.Hour).Truncate(time.Second)
+	expMeetingSession(mock, start, meeting.ProviderJitsi)
+	expMeetingEnrollment(mock, 9, true)
+	expNoLearnerMeetingBan(mock, 9)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/meetings/3"})
+	wantStatus(t, resp, http.StatusTooEarly)
+
+	var body struct {
+		OpensAt time.Time `json:"opens_at"`
+	}
+	if err := json.Unmarshal(readBody(t, resp.Body), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !body.OpensAt.Equal(start.Add(-15 * time.Minute)) {
+		t.Fatalf("opens_at = %v", body.OpensAt)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 410
+func TestGetMeetingLink_Cancelled(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupMeetingApp(gdb, sessionTeacherUser(5, 30), testJitsi)
+	expMeetingSessionStatus(mock, time.Now().Add(10*time.Minute), meeting.ProviderJitsi, models.SessionStatusCancelled)
+	expNoTeacherMeetingBan(mock, 30)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/meetings/3"})
+	wantStatus(t, resp, http.StatusGone)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ GetMeetingAccesses ------------------ */
+
+// 200
+func TestGetMeetingAccesses_Teacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	at := time.Date(2030, 1, 1, 10, 50, 0, 0, time.UTC)
+	expMeetingSession(mock, at.Add(10*time.Minute), meeting.ProviderJitsi)
+	mock.ExpectQuery(`SELECT \* FROM "meeting_accesses" WHERE class_session_id = \$1 ORDER BY created_at, id`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "class_session_id", "user_id", "role", "ip"}).
+			AddRow(1, at, 3, 5, models.MeetingRoleTeacher, "203.0.113.7").
+			AddRow(2, at.Add(time.Minute), 3, 7, models.MeetingRoleLearner, "198.51.100.2"))
+
+	resp := runHTTP(t, setupMeetingApp(gdb, sessionTeacherUser(5, 30), testJitsi), httpInput{Method: http.MethodGet, Path: "/meetings/3/accesses"})
+	wantStatus(t, resp, http.StatusOK)
+
+	var accesses []models.MeetingAccess
+	if err := json.Unmarshal(readBody(t, resp.Body), &accesses); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(accesses) != 2 || accesses[1].UserID != 7 || accesses[1].Role != models.MeetingRoleLearner {
+		t.Fatalf("unexpected accesses: %+v", accesses)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestGetMeetingAccesses_Learner(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	expMeetingSession(mock, time.Now(), meeting.ProviderJitsi)
+
+	resp := runHTTP(t, setupMeetingApp(gdb, enrolledLearnerUser(7, 9), testJitsi), httpInput{Method: http.MethodGet, Path: "/meetings/3/accesses"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ GetMeetingParticipants / EndMeeting ------------------ */
+
+// 200
+func TestGetMeetingParticipants_ListsJoinedUsers(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	fake := meeting.NewFake("")
+	learner := enrolledLearnerUser(7, 9)
+	learner.FirstName = "Grace"
+	expMeetingSession(mock, finishedMeetingStart(), meeting.ProviderFake)
+	expMeetingEnrollment(mock, 9, true)
+	expNoLearnerMeetingBan(mock, 9)
+	expMeetingAccessLogged(mock, 7, models.MeetingRoleLearner)
+	resp := runHTTP(t, setupMeetingApp(gdb, learner, fake), httpInput{Method: http.MethodGet, Path: "/meetings/3"})
+	wantStatus(t, resp, http.StatusOK)
+
+	expMeetingSession(mock, time.Now().Add(time.Hour), meeting.ProviderFake)
+	resp = runHTTP(t, setupMeetingApp(gdb, sessionTeacherUser(5, 30), fake), httpInput{Method: http.MethodGet, Path: "/meetings/3/participants"})
+	wantStatus(t, resp, http.StatusOK)
+
+	var attendees []meeting.Attendee
+	if err := json.Unmarshal(readBody(t, resp.Body), &attendees); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(attendees) != 1 || attendees[0].ID != "7" || attendees[0].Name != "Grace" || attendees[0].Moderator {
+		t.Fatalf("unexpected attendees: %+v", attendees)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200
+func TestEndMeeting_Teacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	fake := meeting.NewFake("")
+	expMeetingSession(mock, time.Now().Add(time.Hour), meeting.ProviderFake)
+
+	resp := runHTTP(t, setupMeetingApp(gdb, sessionTeacherUser(5, 30), fake), httpInput{Method: http.MethodPost, Path: "/meetings/3/end"})
+	wantStatus(t, resp, http.StatusOK)
+	if !fake.Ended("abc123") {
+		t.Fatalf("room abc123 was not ended")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestEndMeeting_NotTeacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	fake := meeting.NewFake("")
+	expMeetingSession(mock, time.Now().Add(time.Hour), meeting.ProviderFake)
+
+	resp := runHTTP(t, setupMeetingApp(gdb, enrolledLearnerUser(7, 9), fake), httpInput{Method: http.MethodPost, Path: "/meetings/3/end"})
+	wantStatus(t, resp, http.StatusForbidden)
+	if fake.Ended("abc123") {
+		t.Fatalf("a learner ended the meeting")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 501
+func TestEndMeeting_JitsiUnsupported(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	expMeetingSession(mock, time.Now().Add(time.Hour), meeting.ProviderJitsi)
+
+	resp := runHTTP(t, setupMeetingApp(gdb, sessionTeacherUser(5, 30), testJitsi), httpInput{Method: http.MethodPost, Path: "/meetings/3/end"})
+	wantStatus(t, resp, http.StatusNotImplemented)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ GetMeetingPresence ------------------ */
+
+// 200
+func TestGetMeetingPresence_TotalsTimeInRoom(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	at := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+	left := func(m int) *time.Time { t := at(m); return &t }
+	expMeetingSession(mock, start, meeting.ProviderFake)
+	mock.ExpectQuery(`SELECT \* FROM "meeting_presences" WHERE class_session_id = \$1 ORDER BY user_id, joined_at`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_session_id", "user_id", "role", "joined_at", "left_at"}).
+			AddRow(1, 3, 5, models.MeetingRoleTeacher, at(-5), left(120)).
+			// Two devices: 0-30 and 20-45 count as 45 minutes.
+			AddRow(2, 3, 7, models.MeetingRoleLearner, at(0), left(30)).
+			AddRow(3, 3, 7, models.MeetingRoleLearner, at(20), left(45)).
+			// The leave event never came: counted until the meeting closed.
+			AddRow(4, 3, 8, models.MeetingRoleLearner, at(60), nil))
+
+	resp := runHTTP(t, setupMeetingApp(gdb, sessionTeacherUser(5, 30), meeting.NewFake("")), httpInput{Method: http.MethodGet, Path: "/meetings/3/presence"})
+	wantStatus(t, resp, http.StatusOK)
+
+	var got []models.ParticipantPresence
+	if err := json.Unmarshal(readBody(t, resp.Body), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := map[uint]int64{5: 125 * 60, 7: 45 * 60, 8: 75 * 60}
+	if len(got) != len(want) {
+		t.Fatalf("presence = %+v", got)
+	}
+	for _, p := range got {
+		if p.Seconds != want[p.UserID] || p.InRoom {
+			t.Errorf("user %d: %d seconds (in room %v), want %d", p.UserID, p.Seconds, p.InRoom, want[p.UserID])
+		}
+	}
+	if !got[1].LastLeftAt.Equal(at(45)) || !got[1].FirstJoinedAt.Equal(at(0)) {
+		t.Fatalf("unexpected learner stay bounds: %+v", got[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestGetMeetingPresence_Learner(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	expMeetingSession(mock, time.Now(), meeting.ProviderFake)
+
+	resp := runHTTP(t, setupMeetingApp(gdb, enrolledLearnerUser(7, 9), meeting.NewFake("")), httpInput{Method: http.MethodGet, Path: "/meetings/3/presence"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ MeetingWebhook ------------------ */
+
+const testWebhookSecret = "hook-secret"
+
+func webhookFake() *meeting.Fake {
+	fake := meeting.NewFake("")
+	fake.WebhookSecret = testWebhookSecret
+	return fake
+}
+
+func postPresence(t *testing.T, app *fiber.App, auth, kind, userID string, at time.Time) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/meetings", bytes.NewReader(jsonBody(map[string]interface{}{
+		"event": kind, "room": "abc123", "user_id": userID, "at": at,
+	})))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", auth)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	return resp
+}
+
+// expPresenceRoom expects the webhook's lookup of room abc123, hosting
+// session 3 of class 12 taught by teacher 30 (user 5).
+func expPresenceRoom(mock sqlmock.Sqlmock, start time.Time) {
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE \(meeting_provider = \$1 AND meeting_room_id = \$2\)`).
+		WithArgs(meeting.ProviderFake, "abc123", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "class_start", "class_finish", "meeting_provider", "meeting_room_id"}).
+			AddRow(3, 12, start, start.Add(2*time.Hour), meeting.ProviderFake, "abc123"))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+	mock.ExpectQuery(`SELECT "id","user_id" FROM "teachers" WHERE "teachers"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(30, 5))
+}
+
+// expOpenStay expects the lookup of userID's open stay, returning one that
+// began at joined when it is not zero.
+func expOpenStay(mock sqlmock.Sqlmock, userID uint, joined time.Time) {
+	rows := sqlmock.NewRows([]string{"id", "class_session_id", "user_id", "role", "joined_at"})
+	if !joined.IsZero() {
+		rows.AddRow(4, 3, userID, models.MeetingRoleLearner, joined)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "meeting_presences" WHERE class_session_id = \$1 AND user_id = \$2 AND left_at IS NULL ORDER BY joined_at DESC`).
+		WithArgs(3, userID, 1).
+		WillReturnRows(rows)
+}
+
+// 200
+func TestMeetingWebhook_TeacherJoinChecksIn(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	start := time.Now().Add(5 * time.Minute)
+	joined := time.Now().UTC().Truncate(time.Second)
+	expPresenceRoom(mock, start)
+	expOpenStay(mock, 5, time.Time{})
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "meeting_presences" WHERE class_session_id = \$1 AND user_id = \$2`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "meeting_presences" .*RETURNING "id"`).
+		WithArgs(3, 5, models.MeetingRoleTeacher, sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "class_sessions" SET "teacher_checked_in_at"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND teacher_checked_in_at IS NULL\)`).
+		WithArgs(joined, sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	app := setupMeetingApp(gdb, nil, webhookFake())
+	resp := postPresence(t, app, "Bearer "+testWebhookSecret, meeting.EventJoin, "5", joined)
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200
+func TestMeetingWebhook_LearnerJoinsLate(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	start := time.Now().Add(-20 * time.Minute)
+	expPresenceRoom(mock, start)
+	mock.ExpectQuery(`SELECT "id" FROM "learners" WHERE user_id = \$1`).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments" WHERE \(learner_id = \$1 AND class_session_id = \$2 AND enrollment_status IN \(\$3,\$4\)\)`).
+		WithArgs(9, 3, models.EnrollmentStatusActive, models.EnrollmentStatusNoShow).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	expOpenStay(mock, 7, time.Time{})
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "meeting_presences"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "meeting_presences"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	// Joining 20 minutes in is past LateAfter.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "attendances" WHERE \(class_session_id = \$1 AND learner_id = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO "attendances"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 3, 9, models.AttendanceLate, models.AttendanceSourceMeetingJoin, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	expNoShowCorrection(mock, 0)
+	mock.ExpectExec(`UPDATE "enrollments" SET "attended_at"=\$1,"updated_at"=\$2 WHERE \(learner_id = \$3 AND class_session_id = \$4\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	app := setupMeetingApp(gdb, nil, webhookFake())
+	resp := postPresence(t, app, "Bearer "+testWebhookSecret, meeting.EventJoin, "7", time.Now())
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200
+func TestMeetingWebhook_LeaveClosesStay(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	start := time.Now().Add(-time.Hour)
+	left := time.Now().UTC().Truncate(time.Second)
+	expPresenceRoom(mock, start)
+	mock.ExpectQuery(`SELECT "id" FROM "learners" WHERE user_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	expOpenStay(mock, 7, start)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "meeting_presences" SET "left_at"=\$1 WHERE "id" = \$2`).
+		WithArgs(left, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	app := setupMeetingApp(gdb, nil, webhookFake())
+	resp := postPresence(t, app, "Bearer "+testWebhookSecret, meeting.EventLeave, "7", left)
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200: rooms no session uses are acknowledged so the provider stops retrying.
+func TestMeetingWebhook_UnknownRoomIgnored(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE \(meeting_provider = \$1 AND meeting_room_id = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	app := setupMeetingApp(gdb, nil, webhookFake())
+	resp := postPresence(t, app, "Bearer "+testWebhookSecret, meeting.EventJoin, "7", time.Now())
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 401
+func TestMeetingWebhook_BadSecret(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupMeetingApp(gdb, nil, webhookFake())
+	resp := postPresence(t, app, "Bearer wrong", meeting.EventJoin, "7", time.Now())
+	wantStatus(t, resp, http.StatusUnauthorized)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 404
+func TestMeetingWebhook_NotEnabled(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupMeetingApp(gdb, nil, meeting.NewFake(""))
+	resp := postPresence(t, app, "Bearer ", meeting.EventJoin, "7", time.Now())
+	wantStatus(t, resp, http.StatusNotFound)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
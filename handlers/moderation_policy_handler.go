@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+func ModerationRoutes(app *fiber.App) {
+	moderation := app.Group("/moderation", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware(), middlewares.AdminRequired())
+
+	moderation.Get("/policy", GetModerationPolicy)
+	moderation.Put("/policy", UpdateModerationPolicy)
+}
+
+// GetModerationPolicy godoc
+//
+//	@Summary		Get the moderation policy
+//	@Description	Returns the flag threshold, ban duration, reason weights and decay settings currently enforced
+//	@Tags			Moderation
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	models.ModerationPolicyDoc
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/moderation/policy [get]
+func GetModerationPolicy(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	policy, err := services.GetModerationPolicy(db)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).JSON(policy)
+}
+
+// UpdateModerationPolicy godoc
+//
+//	@Summary		Update the moderation policy
+//	@Description	Updates the moderation policy; fields left out keep their current values, and ban_escalation_hours or reason_flag_weights given replace the stored ones. Takes effect for the next flag, report decision or scheduler run.
+//	@Tags			Moderation
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			policy	body		models.ModerationPolicyDoc	true	"Moderation policy"
+//	@Success		200		{object}	models.ModerationPolicyDoc
+//	@Failure		400		{string}	string	"Invalid input"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/moderation/policy [put]
+func UpdateModerationPolicy(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	// Fields left out of the body keep their current values.
+	policy, err := services.GetModerationPolicy(db)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	var req models.UpdateModerationPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	req.ApplyTo(&policy)
+
+	if err := services.ValidateModerationPolicy(policy); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	var updatedBy *uint
+	if user, ok := c.Locals("currentUser").(*models.User); ok {
+		updatedBy = &user.ID
+	}
+
+	if err := services.SaveModerationPolicy(db, &policy, updatedBy); err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).JSON(policy)
+}
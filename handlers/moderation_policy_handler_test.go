@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+/* ------------------ GetModerationPolicy ------------------ */
+
+// 200 (defaults when nothing is persisted yet)
+func TestGetModerationPolicy_Defaults(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupApp(gdb)
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies" WHERE "moderation_policies"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/moderation/policy"})
+	wantStatus(t, resp, http.StatusOK)
+
+	var policy models.ModerationPolicy
+	if err := json.Unmarshal(readBody(t, resp.Body), &policy); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if policy.FlagThreshold != 3 || policy.BanDurationHours != 168 || policy.FlagsForReason("harassment") != 2 {
+		t.Fatalf("unexpected default policy: %+v", policy)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 500
+func TestGetModerationPolicy_DBError(t *testing.T) {
+	userID := uint(42)
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, true, false, false)(mock)
+			ExpListError("moderation_policies", fmt.Errorf("select failed"))(mock)
+			*uID = userID
+		},
+		http.StatusInternalServerError,
+		http.MethodGet,
+		"/moderation/policy",
+	)
+}
+
+/* ------------------ UpdateModerationPolicy ------------------ */
+
+// 200
+func TestUpdateModerationPolicy_OK(t *testing.T) {
+	userID := uint(42)
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, true, false, false)(mock)
+			ExpPreloadCanEmpty("moderation_policies", []string{"id"})(mock)
+			ExpPreloadCanEmpty("moderation_policies", []string{"id"})(mock)
+			ExpInsertReturningID("moderation_policies", 1)(mock)
+
+			*payload = jsonBody(map[string]any{
+				"flag_threshold":          4,
+				"ban_duration_hours":      72,
+				"absence_auto_flag_limit": 2,
+				"false_report_flags":      1,
+				"flag_decay_days":         30,
+				"ban_escalation_hours":    []int{72, 720, 0},
+				"reason_flag_weights":     map[string]int{"harassment": 3},
+			})
+			*uID = userID
+		},
+		http.StatusOK,
+		http.MethodPut,
+		"/moderation/policy",
+	)
+}
+
+// 200 (a partial body keeps the stored values)
+func TestUpdateModerationPolicy_Partial(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+	stored := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "flag_threshold", "ban_duration_hours", "no_show_flag_threshold", "reason_flag_weights"}).
+			AddRow(1, 3, 168, 5, `{"harassment":2}`)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies" WHERE "moderation_policies"\."id" = \$1`).WillReturnRows(stored())
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies" WHERE "moderation_policies"\."id" = \$1`).WillReturnRows(stored())
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "moderation_policies" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/moderation/policy",
+		Body:        jsonBody(map[string]any{"flag_threshold": 4}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	var policy models.ModerationPolicy
+	if err := json.Unmarshal(readBody(t, resp.Body), &policy); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if policy.FlagThreshold != 4 || policy.BanDurationHours != 168 || policy.NoShowFlagThreshold != 5 || policy.FlagsForReason("harassment") != 2 {
+		t.Fatalf("stored values were not kept: %+v", policy)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestUpdateModerationPolicy_Invalid(t *testing.T) {
+	userID := uint(42)
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, true, false, false)(mock)
+			ExpPreloadCanEmpty("moderation_policies", []string{"id"})(mock)
+
+			*payload = jsonBody(map[string]any{
+				"flag_threshold":     0,
+				"ban_duration_hours": 72,
+			})
+			*uID = userID
+		},
+		http.StatusBadRequest,
+		http.MethodPut,
+		"/moderation/policy",
+	)
+}
+
+// 400
+func TestUpdateModerationPolicy_NegativeEscalation(t *testing.T) {
+	userID := uint(42)
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, true, false, false)(mock)
+			ExpPreloadCanEmpty("moderation_policies", []string{"id"})(mock)
+
+			*payload = jsonBody(map[string]any{
+				"flag_threshold":       3,
+				"ban_duration_hours":   72,
+				"ban_escalation_hours": []int{72, -1},
+			})
+			*uID = userID
+		},
+		http.StatusBadRequest,
+		http.MethodPut,
+		"/moderation/policy",
+	)
+}
+
+func TestUpdateModerationPolicy_NegativeNoShowThreshold(t *testing.T) {
+	userID := uint(42)
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, true, false, false)(mock)
+			ExpPreloadCanEmpty("moderation_policies", []string{"id"})(mock)
+
+			*payload = jsonBody(map[string]any{
+				"flag_threshold":         3,
+				"ban_duration_hours":     72,
+				"no_show_flag_threshold": -1,
+			})
+			*uID = userID
+		},
+		http.StatusBadRequest,
+		http.MethodPut,
+		"/moderation/policy",
+	)
+}
+
+// 400
+func TestUpdateModerationPolicy_NegativeLateCancelHours(t *testing.T) {
+	userID := uint(42)
+	RunInDifferentStatus(t,
+		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
+			ExpAuthUser(userID, true, false, false)(mock)
+			ExpPreloadCanEmpty("moderation_policies", []string{"id"})(mock)
+
+			*payload = jsonBody(map[string]any{
+				"flag_threshold":     3,
+				"ban_duration_hours": 72,
+				"late_cancel_hours":  -1,
+			})
+			*uID = userID
+		},
+		http.StatusBadRequest,
+		http.MethodPut,
+		"/moderation/policy",
+	)
+}
+
+// 200 (a DeletedAt in the body does not soft-delete the policy)
+func TestUpdateModerationPolicy_IgnoresDeletedAt(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+	stored := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "flag_threshold", "ban_duration_hours"}).AddRow(1, 3, 168)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies" WHERE "moderation_policies"\."id" = \$1`).WillReturnRows(stored())
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies" WHERE "moderation_policies"\."id" = \$1`).WillReturnRows(stored())
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "moderation_policies" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/moderation/policy",
+		Body:        jsonBody(map[string]any{"DeletedAt": "2026-01-01T00:00:00Z", "flag_threshold": 4}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	var policy models.ModerationPolicy
+	if err := json.Unmarshal(readBody(t, resp.Body), &policy); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if policy.DeletedAt.Valid || policy.FlagThreshold != 4 {
+		t.Fatalf("DeletedAt was taken from the body: %+v", policy)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200 (reason_flag_weights replaces the stored map)
+func TestUpdateModerationPolicy_ReplacesReasonWeights(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+	stored := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "flag_threshold", "ban_duration_hours", "reason_flag_weights"}).
+			AddRow(1, 3, 168, `{"harassment":2,"spam":1}`)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies" WHERE "moderation_policies"\."id" = \$1`).WillReturnRows(stored())
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies" WHERE "moderation_policies"\."id" = \$1`).WillReturnRows(stored())
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "moderation_policies" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/moderation/policy",
+		Body:        jsonBody(map[string]any{"reason_flag_weights": map[string]int{"harassment": 3}}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	var policy models.ModerationPolicy
+	if err := json.Unmarshal(readBody(t, resp.Body), &policy); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if policy.FlagsForReason("harassment") != 3 || policy.FlagsForReason("spam") != 0 || policy.FlagThreshold != 3 {
+		t.Fatalf("reason weights were merged instead of replaced: %+v", policy.ReasonFlagWeights.Data())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
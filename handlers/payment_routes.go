@@ -61,8 +61,8 @@ func PaymentRoutes(app *fiber.App) {
 		return h.GetTransaction(c)
 	})
 
-	// Refund
-	app.Post("/payments/transactions/:id/refund", func(c *fiber.Ctx) error {
+	// Refund (admin only, with a fresh second-factor check)
+	app.Post("/payments/transactions/:id/refund", middlewares.ProtectedMiddleware(), middlewares.AdminRequired(), middlewares.StepUpRequired(middlewares.StepUpMaxAge), func(c *fiber.Ctx) error {
 		db, err := middlewares.GetDB(c)
 		if err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "db not available"})
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/a2n2k3p4/tutorium-backend/storage"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type ReportDisclosureRequest struct {
+	EvidenceDisclosed bool `json:"evidence_disclosed"`
+}
+
+// loadReportForCaller fetches report :id and the caller, writing the error
+// response itself when either is missing.
+func loadReportForCaller(c *fiber.Ctx, db *gorm.DB, report *models.Report) (*models.User, error) {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return nil, c.Status(401).JSON("unauthorized")
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return nil, c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+
+	err = db.First(report, "id = ?", id).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, c.Status(404).JSON("report not found")
+	case err != nil:
+		return nil, c.Status(500).JSON(err.Error())
+	}
+	return user, nil
+}
+
+// UploadReportAttachment godoc
+//
+//	@Summary		Attach evidence to a report
+//	@Description	Uploads one evidence file (multipart field "file") to an open report. Images (5 MB), video clips (50 MB), audio clips (20 MB) and plain-text chat exports (2 MB) are accepted; the type is sniffed from the content. Request bodies are limited to 4 MB, so larger files go straight to storage through POST /uploads with purpose report_attachment. Only the reporter or a moderator may upload, up to 10 files per report.
+//	@Tags			Reports
+//	@Security		BearerAuth
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			id		path		int		true	"Report ID"
+//	@Param			file	formData	file	true	"Evidence file"
+//	@Param			kind	formData	string	false	"image, video, audio or chat_export (detected when omitted)"
+//	@Success		201		{object}	models.ReportAttachmentDoc
+//	@Failure		400		{string}	string	"Invalid file"
+//	@Failure		403		{string}	string	"Not allowed"
+//	@Failure		404		{string}	string	"Report not found"
+//	@Failure		409		{string}	string	"Report closed or attachment limit reached"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/reports/{id}/attachments [post]
+func UploadReportAttachment(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var report models.Report
+	user, err := loadReportForCaller(c, db, &report)
+	if user == nil {
+		return err
+	}
+	if user.ID != report.ReportUserID && !middlewares.IsVerifiedAdmin(c) {
+		return c.Status(403).JSON("only the reporter or a moderator can add evidence")
+	}
+	if err := services.CheckReportOpenForEvidence(db, &report); err != nil {
+		return c.Status(reportAttachmentErrorStatus(err)).JSON(err.Error())
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON("file is required")
+	}
+	if fh.Size > services.ReportAttachmentRules["video"].MaxBytes {
+		return c.Status(400).JSON("file too large")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	kind, contentType, err := services.ClassifyReportAttachment(b, c.FormValue("kind"))
+	if err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	up, ok := c.Locals("minio").(storage.Uploader)
+	if !ok {
+		return c.Status(500).JSON("storage not available")
+	}
+	key, err := up.UploadBytes(c.Context(), "reports/attachments", storage.GenerateFilename(contentType), b)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	attachment := models.ReportAttachment{
+		ReportID:       report.ID,
+		UploaderUserID: user.ID,
+		Kind:           kind,
+		FileName:       filepath.Base(fh.Filename),
+		ContentType:    contentType,
+		SizeBytes:      int64(len(b)),
+		ObjectKey:      key,
+	}
+	if err := services.AddReportAttachment(db, &attachment); err != nil {
+		if store, ok := c.Locals("minio").(storage.ObjectStore); ok {
+			_ = store.RemoveObject(c.Context(), key)
+		}
+		return c.Status(reportAttachmentErrorStatus(err)).JSON(err.Error())
+	}
+	return c.Status(201).JSON(attachment)
+}
+
+// reportAttachmentErrorStatus maps report evidence errors onto HTTP statuses.
+func reportAttachmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrReportClosed), errors.Is(err, services.ErrAttachmentLimit):
+		return 409
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return 404
+	default:
+		return 500
+	}
+}
+
+// GetReportAttachments godoc
+//
+//	@Summary		List report evidence
+//	@Description	Lists a report's attachments with presigned read URLs valid for 15 minutes. Visible to moderators signed in with two-factor authentication and the reporter, and to the reported user once a moderator has disclosed the evidence.
+//	@Tags			Reports
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Report ID"
+//	@Success		200	{array}		models.ReportAttachmentDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		403	{string}	string	"Not allowed"
+//	@Failure		404	{string}	string	"Report not found"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/reports/{id}/attachments [get]
+func GetReportAttachments(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var report models.Report
+	user, err := loadReportForCaller(c, db, &report)
+	if user == nil {
+		return err
+	}
+	if !services.CanViewReportEvidence(user, &report, middlewares.IsVerifiedAdmin(c)) {
+		return c.Status(403).JSON("you are not allowed to view this evidence")
+	}
+
+	attachments := []models.ReportAttachment{}
+	if err := db.Where("report_id = ?", report.ID).Order("id asc").Find(&attachments).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	if ps, ok := c.Locals("minio").(storage.Presigner); ok {
+		for i := range attachments {
+			if u, err := ps.PresignedGetObject(c.Context(), attachments[i].ObjectKey, 15*time.Minute); err == nil {
+				attachments[i].URL = u
+			}
+		}
+	}
+	return c.Status(200).JSON(attachments)
+}
+
+// SetReportDisclosure godoc
+//
+//	@Summary		Disclose report evidence to the reported user
+//	@Description	Controls whether the reported user may read the report's attachments
+//	@Tags			Reports
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int							true	"Report ID"
+//	@Param			disclosure	body		models.ReportDisclosureDoc	true	"Disclosure flag"
+//	@Success		200			{object}	models.ReportDoc
+//	@Failure		400			{string}	string	"Invalid input"
+//	@Failure		404			{string}	string	"Report not found"
+//	@Failure		500			{string}	string	"Server error"
+//	@Router			/reports/{id}/disclosure [put]
+func SetReportDisclosure(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+
+	var report models.Report
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+
+	var req ReportDisclosureRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	err = db.First(&report, "id = ?", id).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("report not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	if err := db.Model(&report).Update("evidence_disclosed", req.EvidenceDisclosed).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(report)
+}
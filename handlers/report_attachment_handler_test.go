@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func setupAppWithUploader(gdb *gorm.DB, user *models.User, up *fakeUploader) *fiber.App {
+	app := fiber.New()
+	app.Use(middlewares.DBMiddleware(gdb))
+	app.Use(middlewares.MinioMiddleware(up))
+	app.Use(middlewares.MeetingMiddleware(meeting.NewFake("")))
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("currentUser", user)
+		return c.Next()
+	})
+	AllRoutes(app)
+	return app
+}
+
+func multipartFile(t *testing.T, field, filename string, data []byte) ([]byte, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, err := w.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatalf("form file: %v", err)
+	}
+	fw.Write(data)
+	w.Close()
+	return buf.Bytes(), w.FormDataContentType()
+}
+
+func reporterUser(id uint) *models.User {
+	u := &models.User{StudentID: "b6600000004"}
+	u.ID = id
+	return u
+}
+
+func expReportRow(mock sqlmock.Sqlmock, status string, disclosed bool) {
+	mock.ExpectQuery(`SELECT \* FROM "reports" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "report_user_id", "reported_user_id", "report_status", "evidence_disclosed"}).
+			AddRow(1, 42, 50, status, disclosed))
+}
+
+// expReportLocked expects AddReportAttachment to lock report 1 and find
+// count attachments on it.
+func expReportLocked(mock sqlmock.Sqlmock, count int) {
+	mock.ExpectQuery(`SELECT \* FROM "reports" WHERE "reports"\."id" = \$1 .*FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "report_user_id", "report_status"}).
+			AddRow(1, 42, models.ReportStatusSubmitted))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "report_attachments" WHERE report_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+}
+
+/* ------------------ UploadReportAttachment ------------------ */
+
+// 201
+func TestUploadReportAttachment_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{}
+	app := setupAppWithUploader(gdb, reporterUser(42), up)
+
+	expReportRow(mock, models.ReportStatusSubmitted, false)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "report_attachments" WHERE report_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectBegin()
+	expReportLocked(mock, 0)
+	mock.ExpectQuery(`INSERT INTO "report_attachments".*RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	png, _ := base64.StdEncoding.DecodeString(tinyPNGRawBase64)
+	body, ct := multipartFile(t, "file", "screenshot.png", png)
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/reports/1/attachments", Body: body, ContentType: ct})
+	wantStatus(t, resp, http.StatusCreated)
+
+	if up.lastBucket != "reports/attachments" || !strings.HasSuffix(up.lastFilename, ".png") {
+		t.Fatalf("unexpected upload target %q/%q", up.lastBucket, up.lastFilename)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409 (a concurrent upload took the last slot after the first count)
+func TestUploadReportAttachment_LimitReachedMeanwhile(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{}
+	app := setupAppWithUploader(gdb, reporterUser(42), up)
+
+	expReportRow(mock, models.ReportStatusSubmitted, false)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "report_attachments" WHERE report_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(services.MaxReportAttachments - 1))
+	mock.ExpectBegin()
+	expReportLocked(mock, services.MaxReportAttachments)
+	mock.ExpectRollback()
+
+	png, _ := base64.StdEncoding.DecodeString(tinyPNGRawBase64)
+	body, ct := multipartFile(t, "file", "screenshot.png", png)
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/reports/1/attachments", Body: body, ContentType: ct})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if len(up.removed) != 1 || up.removed[0] != up.lastBucket+"/"+up.lastFilename {
+		t.Fatalf("stored file must be removed, got %v", up.removed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestUploadReportAttachment_UnsupportedType(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{}
+	app := setupAppWithUploader(gdb, reporterUser(42), up)
+
+	expReportRow(mock, models.ReportStatusSubmitted, false)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "report_attachments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	body, ct := multipartFile(t, "file", "archive.zip", []byte("PK\x03\x04 not really a zip"))
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/reports/1/attachments", Body: body, ContentType: ct})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if up.lastData != nil {
+		t.Fatalf("rejected file must not be uploaded")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400 (HTML chat exports would be served as a page)
+func TestUploadReportAttachment_HTMLChatExport(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{}
+	app := setupAppWithUploader(gdb, reporterUser(42), up)
+
+	expReportRow(mock, models.ReportStatusSubmitted, false)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "report_attachments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	body, ct := multipartFile(t, "file", "chat.html", []byte("<html><body><script>alert(1)</script></body></html>"))
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/reports/1/attachments", Body: body, ContentType: ct})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if up.lastData != nil {
+		t.Fatalf("rejected file must not be uploaded")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestUploadReportAttachment_NotReporter(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, reporterUser(50), &fakeUploader{})
+
+	expReportRow(mock, models.ReportStatusSubmitted, true)
+
+	body, ct := multipartFile(t, "file", "note.txt", []byte("hello"))
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/reports/1/attachments", Body: body, ContentType: ct})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ GetReportAttachments ------------------ */
+
+// 403 (reported user before disclosure)
+func TestGetReportAttachments_NotDisclosed(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, reporterUser(50))
+
+	expReportRow(mock, models.ReportStatusUnderReview, false)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/reports/1/attachments"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200 (reported user after disclosure)
+func TestGetReportAttachments_Disclosed(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, reporterUser(50))
+
+	expReportRow(mock, models.ReportStatusUnderReview, true)
+	mock.ExpectQuery(`SELECT \* FROM "report_attachments" WHERE report_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "report_id", "kind", "object_key"}).AddRow(1, 1, "image", "reports/attachments/1.png"))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/reports/1/attachments"})
+	wantStatus(t, resp, http.StatusOK)
+
+	if strings.Contains(string(readBody(t, resp.Body)), "reports/attachments/1.png") {
+		t.Fatalf("object key must not be exposed")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403 (an admin whose token skipped the second factor is no moderator)
+func TestGetReportAttachments_AdminWithoutMFA(t *testing.T) {
+	inProduction(t)
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+	mock.MatchExpectationsInOrder(false)
+
+	app := setupApp(gdb)
+
+	expAuthUserRows(mock, 7, true, false, false)
+	expReportRow(mock, models.ReportStatusUnderReview, true)
+
+	uID := uint(7)
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/reports/1/attachments", UserID: &uID})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -20,26 +20,38 @@ import (
 func ReportRoutes(app *fiber.App) {
 	report := app.Group("/reports", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware())
 	report.Post("/", CreateReport)
+	report.Post("/:id/attachments", UploadReportAttachment)
+	report.Get("/:id/attachments", GetReportAttachments)
 
 	reportAdmin := report.Group("/", middlewares.AdminRequired())
 	reportAdmin.Get("/", GetReports)
+	reportAdmin.Get("/queue", GetModerationQueue)
+	reportAdmin.Get("/cases", GetReportCases)
+	reportAdmin.Get("/cases/:id", GetReportCase)
 	reportAdmin.Get("/:id", GetReport)
 	reportAdmin.Put("/:id", UpdateReport)
 	reportAdmin.Delete("/:id", DeleteReport)
+	reportAdmin.Post("/:id/transition", TransitionReport)
+	reportAdmin.Post("/:id/assign", AssignReport)
+	reportAdmin.Put("/:id/disclosure", SetReportDisclosure)
+	reportAdmin.Get("/:id/notes", GetReportNotes)
+	reportAdmin.Post("/:id/notes", CreateReportNote)
 }
 
 // CreateReport godoc
 //
 //	@Summary		Create a new report
-//	@Description	CreateReport creates a new Report record
+//	@Description	CreateReport files a Report from the caller and groups it into the case for the same reported user and session. Duplicates (same reporter, reported user, session and reason) and reporters over the daily limit are rejected.
 //	@Tags			Reports
 //	@Security		BearerAuth
 //	@Accept			json
 //	@Produce		json
 //	@Param			report	body		models.ReportDoc	true	"Report payload"
 //	@Success		201		{object}	models.ReportDoc
-//	@Failure		400		{string}	string	"Invalid input"
-//	@Failure		500		{string}	string	"Server error"
+//	@Failure		400		{string}	string				"Invalid input"
+//	@Failure		409		{object}	map[string]string	"Duplicate report"
+//	@Failure		429		{string}	string				"Too many reports"
+//	@Failure		500		{string}	string				"Server error"
 //	@Router			/reports [post]
 func CreateReport(c *fiber.Ctx) error {
 	var report models.Report
@@ -57,7 +69,28 @@ func CreateReport(c *fiber.Ctx) error {
 		return c.Status(500).JSON(err.Error())
 	}
 
-	if err := db.Create(&report).Error; err != nil {
+	policy, err := services.GetModerationPolicy(db)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	if reporter, ok := c.Locals("currentUser").(*models.User); ok {
+		report.ReportUserID = reporter.ID
+	}
+	now := time.Now()
+	report.ReportDate = now
+	services.StampNewReport(&policy, &report, now)
+
+	existing, err := services.FileReport(db, &policy, &report, now)
+	switch {
+	case errors.Is(err, services.ErrDuplicateReport):
+		body := fiber.Map{"error": err.Error()}
+		if existing != nil {
+			body["report_id"] = existing.ID
+		}
+		return c.Status(409).JSON(body)
+	case errors.Is(err, services.ErrReportRateLimited):
+		return c.Status(429).JSON(err.Error())
+	case err != nil:
 		return c.Status(500).JSON(err.Error())
 	}
 
@@ -216,60 +249,25 @@ func UpdateReport(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
-	if report.ReportStatus == "pending" && report_updated.ReportStatus == "resolve" {
-		flags := 0
-		switch report.ReportReason {
-		case "teacher_absent", "poor_teaching", "not_teaching":
-			flags = 1
-		case "fake_review", "disruption", "disrespected", "harassment", "bullying":
-			flags = 2
-		}
+	// The picture is checked before the status changes, so an invalid one
+	// rejects the whole update instead of leaving the report transitioned.
+	picture := report_updated.ReportPictureURL
+	if err := processReportPicture(c, &report_updated); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
 
-		if flags > 0 {
-			reason := fmt.Sprintf("Flagged from resolved report ID %d: %s", report.ID, report.ReportDescription)
-			switch report.ReportType {
-			case "learner": // Learner reported a Teacher
-				var teacher models.Teacher
-				if err := db.Where("user_id = ?", report.ReportedUserID).First(&teacher).Error; err == nil {
-					desc := fmt.Sprintf("An admin has reviewed report #%d and issued a warning with %d flag(s).", report.ID, flags)
-					services.CreateNotification(db, teacher.UserID, "system", desc)
-					services.ApplyTeacherFlags(db, teacher.ID, flags, reason)
-				}
-			case "teacher": // Teacher reported a Learner
-				var learner models.Learner
-				if err := db.Where("user_id = ?", report.ReportedUserID).First(&learner).Error; err == nil {
-					desc := fmt.Sprintf("An admin has reviewed report #%d and issued a warning with %d flag(s).", report.ID, flags)
-					services.CreateNotification(db, learner.UserID, "system", desc)
-					services.ApplyLearnerFlags(db, learner.ID, flags, reason)
-				}
-			}
-		} // False Report
-	} else if report.ReportStatus == "pending" && report_updated.ReportStatus == "reject" {
-		reason := fmt.Sprintf("Flagged for submitting a false report (ID: %d)", report.ID)
-		switch report.ReportType {
-		case "learner": // The reporter was a Learner
-			var learner models.Learner
-			if err := db.Where("user_id = ?", report.ReportUserID).First(&learner).Error; err == nil {
-				desc := fmt.Sprintf("Report #%d was found to be false. You have received 1 flag as a warning.", report.ID)
-				services.CreateNotification(db, learner.UserID, "system", desc)
-				services.ApplyLearnerFlags(db, learner.ID, 1, reason)
-			}
-		case "teacher": // The reporter was a Teacher
-			var teacher models.Teacher
-			if err := db.Where("user_id = ?", report.ReportUserID).First(&teacher).Error; err == nil {
-				desc := fmt.Sprintf("Report #%d was found to be false. You have received 1 flag as a warning.", report.ID)
-				services.CreateNotification(db, teacher.UserID, "system", desc)
-				services.ApplyTeacherFlags(db, teacher.ID, 1, reason)
+	if to := models.NormalizeReportStatus(report_updated.ReportStatus); to != "" && to != models.NormalizeReportStatus(report.ReportStatus) {
+		if err := services.TransitionReport(db, &report, to, report_updated.ReportResult, currentUserID(c)); err != nil {
+			if report_updated.ReportPictureURL != picture {
+				_ = c.Locals("minio").(*storage.Client).RemoveObject(c.Context(), report_updated.ReportPictureURL)
 			}
+			return c.Status(reportErrorStatus(err)).JSON(err.Error())
 		}
 	}
 
-	if err := processReportPicture(c, &report_updated); err != nil {
-		return c.Status(400).JSON(err.Error())
-	}
-
+	// Lifecycle fields only change through TransitionReport and AssignReport.
 	if err := db.Model(&report).
-		Omit(clause.Associations).
+		Omit(reportLifecycleColumns...).
 		Updates(report_updated).Error; err != nil {
 		return c.Status(500).JSON(err.Error())
 	}
@@ -317,6 +315,357 @@ func DeleteReport(c *fiber.Ctx) error {
 	return c.Status(200).JSON("Successfully deleted Report")
 }
 
+// reportLifecycleColumns are omitted from UpdateReport's generic update.
+var reportLifecycleColumns = []string{
+	clause.Associations,
+	"report_status", "severity", "assigned_moderator_id", "case_id", "evidence_disclosed",
+	"triage_due_at", "resolution_due_at", "triaged_at", "escalated_at", "closed_at",
+}
+
+type ReportTransitionRequest struct {
+	ReportStatus string `json:"report_status"`
+	ReportResult string `json:"report_result"`
+	Note         string `json:"note"`
+}
+
+type ReportAssignRequest struct {
+	ModeratorUserID uint `json:"moderator_user_id"`
+}
+
+type ReportNoteRequest struct {
+	Body string `json:"body"`
+}
+
+// currentUserID returns the caller's user ID, or nil when no user is attached.
+func currentUserID(c *fiber.Ctx) *uint {
+	if user, ok := c.Locals("currentUser").(*models.User); ok {
+		return &user.ID
+	}
+	return nil
+}
+
+// reportErrorStatus maps report service errors to HTTP status codes.
+func reportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidReportTransition), errors.Is(err, services.ErrNotModerator):
+		return 400
+	case errors.Is(err, services.ErrReportClosed), errors.Is(err, services.ErrReportStatusChanged):
+		return 409
+	default:
+		return 500
+	}
+}
+
+// GetModerationQueue godoc
+//
+//	@Summary		Moderation queue
+//	@Description	Lists open reports (submitted, triaged, under_review, escalated), most severe first and then oldest first. Filter with status, or assigned=me / assigned=unassigned.
+//	@Tags			Reports
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			status		query		string	false	"Only this open status"
+//	@Param			assigned	query		string	false	"me or unassigned"
+//	@Success		200			{array}		models.ReportDoc
+//	@Failure		400			{string}	string	"Invalid filter"
+//	@Failure		500			{string}	string	"Server error"
+//	@Router			/reports/queue [get]
+func GetModerationQueue(c *fiber.Ctx) error {
+	reports := []models.Report{}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	q := db.Preload("Reporter").Preload("Reported").
+		Where("report_status IN ?", models.OpenReportStatuses)
+	if status := c.Query("status"); status != "" {
+		q = q.Where("report_status = ?", status)
+	}
+	switch c.Query("assigned") {
+	case "":
+	case "me":
+		uid := currentUserID(c)
+		if uid == nil {
+			return c.Status(400).JSON("assigned=me requires an authenticated user")
+		}
+		q = q.Where("assigned_moderator_id = ?", *uid)
+	case "unassigned":
+		q = q.Where("assigned_moderator_id IS NULL")
+	default:
+		return c.Status(400).JSON("assigned must be me or unassigned")
+	}
+
+	if err := q.Order("severity desc").Order("report_date asc").Find(&reports).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(reports)
+}
+
+// GetReportCases godoc
+//
+//	@Summary		List open report cases
+//	@Description	Lists cases that still contain at least one open report, heaviest first and then oldest first
+//	@Tags			Reports
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{array}		models.ReportCaseDoc
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/reports/cases [get]
+func GetReportCases(c *fiber.Ctx) error {
+	cases := []models.ReportCase{}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	open := db.Model(&models.Report{}).Select("case_id").Where("report_status IN ?", models.OpenReportStatuses)
+	if err := db.Where("id IN (?)", open).
+		Order("weight_score desc").Order("created_at asc").
+		Find(&cases).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(cases)
+}
+
+// GetReportCase godoc
+//
+//	@Summary		Get report case by ID
+//	@Description	Retrieves a case with all of its reports
+//	@Tags			Reports
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Case ID"
+//	@Success		200	{object}	models.ReportCaseDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		404	{string}	string	"Case not found"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/reports/cases/{id} [get]
+func GetReportCase(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+
+	var reportCase models.ReportCase
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	err = db.Preload("Reports", func(db *gorm.DB) *gorm.DB { return db.Order("report_date asc") }).
+		First(&reportCase, "id = ?", id).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("case not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	return c.Status(200).JSON(reportCase)
+}
+
+// TransitionReport godoc
+//
+//	@Summary		Move a report through its lifecycle
+//	@Description	Validates and applies a status transition (submitted → triaged → under_review → resolved/rejected, with escalated as a side branch). Closing a report applies the moderation policy's flags exactly once; an optional note is stored as an internal note.
+//	@Tags			Reports
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int							true	"Report ID"
+//	@Param			transition	body		models.ReportTransitionDoc	true	"Target status"
+//	@Success		200			{object}	models.ReportDoc
+//	@Failure		400			{string}	string	"Invalid transition"
+//	@Failure		404			{string}	string	"Report not found"
+//	@Failure		500			{string}	string	"Server error"
+//	@Router			/reports/{id}/transition [post]
+func TransitionReport(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+
+	var report models.Report
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+
+	var req ReportTransitionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	err = findReport(db, id, &report)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("report not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	actor := currentUserID(c)
+	if err := services.TransitionReport(db, &report, req.ReportStatus, req.ReportResult, actor); err != nil {
+		return c.Status(reportErrorStatus(err)).JSON(err.Error())
+	}
+
+	if note := strings.TrimSpace(req.Note); note != "" && actor != nil {
+		if err := db.Create(&models.ReportNote{ReportID: report.ID, AuthorUserID: *actor, Body: note}).Error; err != nil {
+			return c.Status(500).JSON(err.Error())
+		}
+	}
+
+	return c.Status(200).JSON(report)
+}
+
+// AssignReport godoc
+//
+//	@Summary		Assign a report to a moderator
+//	@Description	Assigns an open report to an admin; defaults to the caller when moderator_user_id is omitted
+//	@Tags			Reports
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Report ID"
+//	@Param			assignment	body		models.ReportAssignDoc	false	"Moderator"
+//	@Success		200			{object}	models.ReportDoc
+//	@Failure		400			{string}	string	"Invalid moderator"
+//	@Failure		404			{string}	string	"Report not found"
+//	@Failure		409			{string}	string	"Report already closed"
+//	@Failure		500			{string}	string	"Server error"
+//	@Router			/reports/{id}/assign [post]
+func AssignReport(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+
+	var report models.Report
+
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+
+	var req ReportAssignRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(err.Error())
+		}
+	}
+	if req.ModeratorUserID == 0 {
+		uid := currentUserID(c)
+		if uid == nil {
+			return c.Status(400).JSON("moderator_user_id is required")
+		}
+		req.ModeratorUserID = *uid
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	err = findReport(db, id, &report)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("report not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	if err := services.AssignReport(db, &report, req.ModeratorUserID); err != nil {
+		return c.Status(reportErrorStatus(err)).JSON(err.Error())
+	}
+
+	return c.Status(200).JSON(report)
+}
+
+// GetReportNotes godoc
+//
+//	@Summary		List internal notes on a report
+//	@Description	Internal moderator notes, oldest first
+//	@Tags			Reports
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Report ID"
+//	@Success		200	{array}		models.ReportNoteDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/reports/{id}/notes [get]
+func GetReportNotes(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	notes := []models.ReportNote{}
+	if err := db.Where("report_id = ?", id).Order("id asc").Find(&notes).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(notes)
+}
+
+// CreateReportNote godoc
+//
+//	@Summary		Add an internal note to a report
+//	@Description	Adds a moderator-only note; notes are never shown to the reporter or the reported user
+//	@Tags			Reports
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Report ID"
+//	@Param			note	body		models.ReportNoteDoc	true	"Note"
+//	@Success		201		{object}	models.ReportNoteDoc
+//	@Failure		400		{string}	string	"Invalid input"
+//	@Failure		401		{string}	string	"Unauthorized"
+//	@Failure		404		{string}	string	"Report not found"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/reports/{id}/notes [post]
+func CreateReportNote(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+
+	var req ReportNoteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Body == "" || len(req.Body) > 1000 {
+		return c.Status(400).JSON("body must be between 1 and 1000 characters")
+	}
+
+	author := currentUserID(c)
+	if author == nil {
+		return c.Status(401).JSON("unauthorized")
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var report models.Report
+	err = db.First(&report, "id = ?", id).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("report not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	note := models.ReportNote{ReportID: report.ID, AuthorUserID: *author, Body: req.Body}
+	if err := db.Create(&note).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(201).JSON(note)
+}
+
 func processReportPicture(c *fiber.Ctx, report *models.Report) error {
 	if report.ReportPictureURL != "" && !strings.HasPrefix(report.ReportPictureURL, "http") {
 		b, err := storage.DecodeBase64Image(report.ReportPictureURL)
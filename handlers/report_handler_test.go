@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"testing"
@@ -9,21 +10,51 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/a2n2k3p4/tutorium-backend/models"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
 /* ------------------ CreateReport ------------------ */
 
+// expReportIntakeChecks expects the reporter lock, the daily rate-limit count
+// and the duplicate lookup, which all run in the filing transaction.
+func expReportIntakeChecks(mock sqlmock.Sqlmock, recent int) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id" FROM "users" WHERE "users"\."id" = \$1 .*FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "reports" WHERE \(report_user_id = \$1 AND report_date > \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(recent))
+	if recent < 10 {
+		mock.ExpectQuery(`SELECT \* FROM "reports" WHERE \(report_user_id = \$1 AND reported_user_id = \$2 AND class_session_id = \$3 AND report_reason = \$4\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	} else {
+		mock.ExpectRollback()
+	}
+}
+
 // 201
 func TestCreateReport_OK(t *testing.T) {
-	table := "reports"
 	userID := uint(42)
 	userReportedID := uint(50)
 
 	RunInDifferentStatus(t,
 		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
 			ExpAuthUser(userID, false, false, true)(mock)
-			ExpInsertReturningID(table, 1)(mock)
+			ExpPreloadCanEmpty("moderation_policies", []string{"id"})(mock)
+			expReportIntakeChecks(mock, 0)
+			mock.ExpectQuery(`SELECT \* FROM "report_cases" WHERE \(reported_user_id = \$1 AND class_session_id = \$2\).*FOR UPDATE`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			mock.ExpectQuery(`INSERT INTO "report_cases" .*ON CONFLICT \("reported_user_id","class_session_id"\) DO NOTHING RETURNING "id"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectQuery(`SELECT \* FROM "report_cases" WHERE \(reported_user_id = \$1 AND class_session_id = \$2\).*FOR UPDATE`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectQuery(`INSERT INTO "reports".*RETURNING "id"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectQuery(`SELECT COUNT\(DISTINCT\("report_user_id"\)\) FROM "reports" WHERE case_id = \$1`).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectExec(`UPDATE "report_cases" SET "report_count"=report_count \+ 1,"reporter_count"=\$1,"weight_score"=weight_score \+ \$2`).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
 
 			req := jsonBody(models.Report{
 				ReportUserID:      userID,
@@ -59,14 +90,18 @@ func TestCreateReport_BadRequest(t *testing.T) {
 
 // 500
 func TestCreateReport_DBError(t *testing.T) {
-	table := "reports"
 	userID := uint(42)
 	userReportedID := uint(50)
 
 	RunInDifferentStatus(t,
 		func(t *testing.T, mock sqlmock.Sqlmock, gdb *gorm.DB, app *fiber.App, payload *[]byte, uID *uint) {
 			ExpAuthUser(userID, false, false, true)(mock)
-			ExpInsertError(table, fmt.Errorf("db insert failed"))(mock)
+			ExpPreloadCanEmpty("moderation_policies", []string{"id"})(mock)
+			expReportIntakeChecks(mock, 0)
+			mock.ExpectQuery(`SELECT \* FROM "report_cases"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectQuery(`INSERT INTO "reports"`).WillReturnError(fmt.Errorf("db insert failed"))
+			mock.ExpectRollback()
 
 			req := jsonBody(models.Report{
 				ReportUserID:      userID,
@@ -364,3 +399,270 @@ func TestDeleteReport_BadRequest(t *testing.T) {
 		"/reports/not-an-int",
 	)
 }
+
+/* ------------------ Report lifecycle ------------------ */
+
+func expFindReport(mock sqlmock.Sqlmock, reportID uint, status string) {
+	ExpSelectByIDFound("reports", reportID,
+		[]string{"id", "report_user_id", "reported_user_id", "report_type", "report_reason", "report_status"},
+		[]any{reportID, 42, 50, "learner", "poor_teaching", status},
+	)(mock)
+	ExpPreloadField("users", []string{"id"}, []any{50})(mock)
+	ExpPreloadField("users", []string{"id"}, []any{42})(mock)
+}
+
+// 200
+func TestTransitionReport_Triage(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	expFindReport(mock, 1, models.ReportStatusSubmitted)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "reports" SET .* WHERE report_status = \$\d+ AND "reports"\."deleted_at" IS NULL AND "id" = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/reports/1/transition",
+		Body:        jsonBody(ReportTransitionRequest{ReportStatus: models.ReportStatusTriaged}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409 (another moderator moved the report first)
+func TestTransitionReport_ConcurrentChange(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	expFindReport(mock, 1, models.ReportStatusUnderReview)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "reports" SET .* WHERE report_status = \$\d+ AND "reports"\."deleted_at" IS NULL AND "id" = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/reports/1/transition",
+		Body:        jsonBody(ReportTransitionRequest{ReportStatus: models.ReportStatusResolved}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400 (closed reports are terminal, so flags cannot be re-applied)
+func TestTransitionReport_FromClosed(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	expFindReport(mock, 1, models.ReportStatusResolved)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/reports/1/transition",
+		Body:        jsonBody(ReportTransitionRequest{ReportStatus: models.ReportStatusRejected}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400 (UpdateReport goes through the same validation)
+func TestUpdateReport_InvalidTransition(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	expFindReport(mock, 1, models.ReportStatusRejected)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/reports/1",
+		Body:        jsonBody(map[string]any{"report_status": "submitted"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400 (an invalid picture rejects the update before the status changes)
+func TestUpdateReport_InvalidPictureKeepsStatus(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	expFindReport(mock, 1, models.ReportStatusSubmitted)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/reports/1",
+		Body:        jsonBody(map[string]any{"report_status": "triaged", "report_picture": "not-an-image"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200
+func TestGetModerationQueue_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	mock.ExpectQuery(`SELECT \* FROM "reports" WHERE report_status IN \(\$1,\$2,\$3,\$4\) AND assigned_moderator_id IS NULL AND "reports"\."deleted_at" IS NULL ORDER BY severity desc,report_date asc`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/reports/queue?assigned=unassigned"})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 201
+func TestCreateReportNote_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	mock.ExpectQuery(`SELECT \* FROM "reports" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	ExpInsertReturningID("report_notes", 1)(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/reports/1/notes",
+		Body:        jsonBody(ReportNoteRequest{Body: "Asked the reporter for the chat log"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestCreateReport_Duplicate(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	reporter := &models.User{StudentID: "b6600000003"}
+	reporter.ID = 42
+	app := setupAppAsUser(gdb, reporter)
+
+	ExpPreloadCanEmpty("moderation_policies", []string{"id"})(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "id" FROM "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "reports"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "reports" WHERE \(report_user_id = \$1 AND reported_user_id = \$2 AND class_session_id = \$3 AND report_reason = \$4\)`).
+		WithArgs(uint(42), uint(50), uint(20), "harassment", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/reports/",
+		Body:        jsonBody(models.Report{ReportUserID: 7, ReportedUserID: 50, ClassSessionID: 20, ReportType: "learner", ReportReason: "harassment"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409 (a concurrent submission got past the lookup; the unique index catches it)
+func TestCreateReport_DuplicateCaughtByIndex(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	reporter := &models.User{StudentID: "b6600000003"}
+	reporter.ID = 42
+	app := setupAppAsUser(gdb, reporter)
+
+	ExpPreloadCanEmpty("moderation_policies", []string{"id"})(mock)
+	expReportIntakeChecks(mock, 0)
+	mock.ExpectQuery(`SELECT \* FROM "report_cases"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO "reports"`).
+		WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "idx_report_reporter_subject"})
+	mock.ExpectRollback()
+	mock.ExpectQuery(`SELECT \* FROM "reports" WHERE \(report_user_id = \$1 AND reported_user_id = \$2 AND class_session_id = \$3 AND report_reason = \$4\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/reports/",
+		Body:        jsonBody(models.Report{ReportedUserID: 50, ClassSessionID: 20, ReportType: "learner", ReportReason: "harassment"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	var body struct {
+		ReportID uint `json:"report_id"`
+	}
+	if err := json.Unmarshal(readBody(t, resp.Body), &body); err != nil || body.ReportID != 9 {
+		t.Fatalf("want the existing report 9, got %+v (%v)", body, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 429
+func TestCreateReport_RateLimited(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	reporter := &models.User{StudentID: "b6600000003"}
+	reporter.ID = 42
+	app := setupAppAsUser(gdb, reporter)
+
+	ExpPreloadCanEmpty("moderation_policies", []string{"id"})(mock)
+	expReportIntakeChecks(mock, 10)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/reports/",
+		Body:        jsonBody(models.Report{ReportedUserID: 50, ClassSessionID: 20, ReportType: "learner", ReportReason: "harassment"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusTooManyRequests)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ProposeRescheduleRequest struct {
+	ClassStart       time.Time `json:"class_start"`
+	ClassFinish      time.Time `json:"class_finish"`
+	ResponseDeadline time.Time `json:"response_deadline"`
+	Reason           string    `json:"reason"`
+}
+
+type RescheduleDecisionRequest struct {
+	Decision string `json:"decision"`
+}
+
+// rescheduleErrorStatus maps reschedule service errors to HTTP status codes.
+func rescheduleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidRescheduleTimes), errors.Is(err, services.ErrInvalidRescheduleDeadlines), errors.Is(err, services.ErrInvalidRescheduleDecision),
+		errors.Is(err, services.ErrSessionReasonTooLong):
+		return 400
+	case errors.Is(err, services.ErrNotEnrolled):
+		return 403
+	case errors.Is(err, services.ErrNoPendingReschedule):
+		return 404
+	case errors.Is(err, services.ErrSessionNotUpcoming), errors.Is(err, services.ErrReschedulePending),
+		errors.Is(err, services.ErrRescheduleDeadlinePassed), errors.Is(err, services.ErrRescheduleAlreadyAnswered), errors.Is(err, services.ErrAlreadyRefunded):
+		return 409
+	default:
+		return 500
+	}
+}
+
+// ProposeReschedule godoc
+//
+//	@Summary		Propose a new time for a class session
+//	@Description	Notifies every enrolled learner, who can accept or decline until response_deadline. Declining refunds the session price and frees the seat. The session moves once the deadline passes, or immediately when nobody is enrolled. The new time may not overlap another of the teacher's sessions; if it does by the time the deadline passes, the proposal is withdrawn.
+//	@Tags			ClassSessions
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int							true	"ClassSession ID"
+//	@Param			reschedule	body		models.ProposeRescheduleDoc	true	"New time and response deadline"
+//	@Success		201			{object}	models.SessionRescheduleDoc
+//	@Failure		400			{string}	string	"Invalid times or reason too long"
+//	@Failure		403			{string}	string	"Not the session's teacher"
+//	@Failure		404			{string}	string	"Class session not found"
+//	@Failure		409			{string}	string	"Session started, a reschedule is already pending or teacher schedule conflict"
+//	@Failure		500			{string}	string	"Server error"
+//	@Router			/class_sessions/{id}/reschedule [post]
+func ProposeReschedule(c *fiber.Ctx) error {
+	var req ProposeRescheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var session models.ClassSession
+	user, err := loadSessionForCaller(c, db, &session)
+	if user == nil {
+		return err
+	}
+	if !services.IsSessionTeacher(user, &session) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+
+	reschedule, err := services.ProposeReschedule(db, &session, user.ID, req.ClassStart, req.ClassFinish, req.ResponseDeadline, req.Reason, time.Now())
+	if err != nil {
+		if errors.Is(err, services.ErrScheduleConflict) {
+			return scheduleConflictResponse(c, err)
+		}
+		return c.Status(rescheduleErrorStatus(err)).JSON(err.Error())
+	}
+	return c.Status(201).JSON(reschedule)
+}
+
+// RespondToReschedule godoc
+//
+//	@Summary		Accept or decline a proposed reschedule
+//	@Description	Records the calling learner's decision before the response deadline. Declining refunds the session price to the learner's balance and frees the seat.
+//	@Tags			ClassSessions
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int								true	"ClassSession ID"
+//	@Param			decision	body		models.RescheduleDecisionDoc	true	"accept or decline"
+//	@Success		200			{object}	models.RescheduleResponseDoc
+//	@Failure		400			{string}	string	"Invalid decision"
+//	@Failure		403			{string}	string	"Not enrolled"
+//	@Failure		404			{string}	string	"No pending reschedule"
+//	@Failure		409			{string}	string	"Already answered or deadline passed"
+//	@Failure		500			{string}	string	"Server error"
+//	@Router			/class_sessions/{id}/reschedule/respond [post]
+func RespondToReschedule(c *fiber.Ctx) error {
+	var req RescheduleDecisionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var session models.ClassSession
+	user, err := loadSessionForCaller(c, db, &session)
+	if user == nil {
+		return err
+	}
+	if user.Learner == nil {
+		return c.Status(403).JSON(services.ErrNotEnrolled.Error())
+	}
+
+	response, err := services.RespondToReschedule(db, &session, user.Learner.ID, req.Decision, time.Now())
+	if err != nil {
+		return c.Status(rescheduleErrorStatus(err)).JSON(err.Error())
+	}
+	return c.Status(200).JSON(response)
+}
+
+// GetClassSessionReschedules godoc
+//
+//	@Summary		List reschedules of a class session
+//	@Description	Returns every reschedule proposed for a session, newest first, with learner responses
+//	@Tags			ClassSessions
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"ClassSession ID"
+//	@Success		200	{array}		models.SessionRescheduleDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/class_sessions/{id}/reschedules [get]
+func GetClassSessionReschedules(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	reschedules := []models.SessionReschedule{}
+	if err := db.Preload("Responses").Where("class_session_id = ?", id).Order("id desc").Find(&reschedules).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(reschedules)
+}
+
+// GetClassSessionHistory godoc
+//
+//	@Summary		Get the history of a class session
+//	@Description	Returns status changes, reschedules, learner responses and refunds for a session in order
+//	@Tags			ClassSessions
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"ClassSession ID"
+//	@Success		200	{array}		models.SessionHistoryDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/class_sessions/{id}/history [get]
+func GetClassSessionHistory(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	history := []models.SessionHistory{}
+	if err := db.Where("class_session_id = ?", id).Order("id").Find(&history).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(history)
+}
+
+type CancelClassSessionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CancelClassSession godoc
+//
+//	@Summary		Cancel a class session
+//	@Description	Sets the session to cancelled, refunds the price to every enrolled learner and notifies them. Cancelling within the moderation policy's late_cancel_hours of the start flags the teacher.
+//	@Tags			ClassSessions
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int								true	"ClassSession ID"
+//	@Param			cancel	body		models.CancelClassSessionDoc	false	"Optional reason shown to learners"
+//	@Success		200		{object}	models.CancellationResultDoc
+//	@Failure		400		{string}	string	"Invalid ID or reason too long"
+//	@Failure		403		{string}	string	"Not the session's teacher"
+//	@Failure		404		{string}	string	"Class session not found"
+//	@Failure		409		{string}	string	"Session already started, finished or cancelled"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/class_sessions/{id}/cancel [post]
+func CancelClassSession(c *fiber.Ctx) error {
+	var req CancelClassSessionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(err.Error())
+		}
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var session models.ClassSession
+	user, err := loadSessionForCaller(c, db, &session)
+	if user == nil {
+		return err
+	}
+	if !services.IsSessionTeacher(user, &session) {
+		return c.Status(403).JSON(services.ErrNotSessionTeacher.Error())
+	}
+
+	result, err := services.CancelClassSession(db, &session, session.Class.TeacherID, &user.ID, req.Reason, time.Now())
+	if err != nil {
+		if errors.Is(err, services.ErrSessionReasonTooLong) {
+			return c.Status(400).JSON(err.Error())
+		}
+		if errors.Is(err, services.ErrSessionNotUpcoming) || errors.Is(err, services.ErrSessionStatusChanged) {
+			return c.Status(409).JSON(err.Error())
+		}
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(result)
+}
@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+)
+
+// expUserTimezones expects the timezone lookup made before notifying users;
+// pairs are user ID then IANA name.
+func expUserTimezones(mock sqlmock.Sqlmock, pairs ...interface{}) {
+	rows := sqlmock.NewRows([]string{"id", "timezone"})
+	for i := 0; i+1 < len(pairs); i += 2 {
+		rows.AddRow(pairs[i], pairs[i+1])
+	}
+	mock.ExpectQuery(`SELECT "id","timezone" FROM "users" WHERE id IN`).WillReturnRows(rows)
+}
+
+// containsAll matches a string argument holding every substring.
+type containsAll []string
+
+func (c containsAll) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	for _, want := range c {
+		if !strings.Contains(s, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// expNotice expects a notification to userID whose description holds want.
+func expNotice(mock sqlmock.Sqlmock, userID uint, want ...string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "notifications"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), userID, sqlmock.AnyArg(), sqlmock.AnyArg(), containsAll(want), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+}
+
+// expSessionLock expects the FOR UPDATE lock ProposeReschedule takes on the
+// session before counting its enrollments.
+func expSessionLock(mock sqlmock.Sqlmock, sessionID uint) {
+	mock.ExpectQuery(`SELECT "id" FROM "class_sessions" WHERE "class_sessions"\."id" = \$1 .*FOR UPDATE`).
+		WithArgs(sessionID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(sessionID))
+}
+
+// expNoPendingReschedule expects the pending reschedule lookup made after an
+// enrollment is written, finding none.
+func expNoPendingReschedule(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "session_reschedules" WHERE \(class_session_id = \$1 AND status = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+/* ------------------ ProposeReschedule ------------------ */
+
+// 201
+func TestProposeReschedule_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Now().Add(72 * time.Hour)
+	expSessionWithClass(mock, 3, 30, start, "")
+
+	mock.ExpectBegin()
+	expSessionLock(mock, 3)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "session_reschedules" WHERE \(class_session_id = \$1 AND status = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expTeacherScheduleFree(mock)
+	mock.ExpectQuery(`SELECT "learner_id" FROM "enrollments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"learner_id"}).AddRow(9))
+	mock.ExpectQuery(`INSERT INTO "session_reschedules"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`INSERT INTO "reschedule_responses"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`SELECT "learners"\."user_id" FROM "enrollments" JOIN learners`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	expUserTimezones(mock, 7, "Asia/Bangkok")
+	ExpInsertReturningID("notifications", 1)(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/class_sessions/3/reschedule",
+		Body: jsonBody(ProposeRescheduleRequest{
+			ClassStart:       start.Add(24 * time.Hour),
+			ClassFinish:      start.Add(26 * time.Hour),
+			ResponseDeadline: time.Now().Add(24 * time.Hour),
+			Reason:           "Teacher is travelling",
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 201: the notice shows each time with the learner's offset, which differs
+// on either side of the US DST change on 2030-03-10.
+func TestProposeReschedule_NoticeInLearnerTimezoneAcrossDST(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Date(2030, 3, 8, 23, 0, 0, 0, time.UTC)     // Fri 18:00 EST
+	newStart := time.Date(2030, 3, 11, 22, 0, 0, 0, time.UTC) // Mon 18:00 EDT
+	expSessionWithClass(mock, 3, 30, start, "")
+
+	mock.ExpectBegin()
+	expSessionLock(mock, 3)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "session_reschedules"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expTeacherScheduleFree(mock)
+	mock.ExpectQuery(`SELECT "learner_id" FROM "enrollments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"learner_id"}).AddRow(9))
+	mock.ExpectQuery(`INSERT INTO "session_reschedules"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`INSERT INTO "reschedule_responses"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`SELECT "learners"\."user_id" FROM "enrollments" JOIN learners`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	expUserTimezones(mock, 7, "America/New_York")
+	expNotice(mock, 7,
+		"Your class on Fri 8 Mar 2030 18:00 -05:00 (America/New_York)",
+		"move to Mon 11 Mar 2030 18:00 -04:00 (America/New_York)")
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/class_sessions/3/reschedule",
+		Body: jsonBody(ProposeRescheduleRequest{
+			ClassStart:       newStart,
+			ClassFinish:      newStart.Add(2 * time.Hour),
+			ResponseDeadline: start.Add(-24 * time.Hour),
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestProposeReschedule_TeacherScheduleConflict(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Now().Add(72 * time.Hour)
+	expSessionWithClass(mock, 3, 30, start, "")
+
+	mock.ExpectBegin()
+	expSessionLock(mock, 3)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "session_reschedules" WHERE \(class_session_id = \$1 AND status = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expTeacherLock(mock)
+	mock.ExpectQuery(`SELECT class_sessions\.id AS class_session_id.* WHERE \(class_sessions\.id NOT IN \(\$1\) AND`).
+		WithArgs(3, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"class_session_id", "class_id", "class_name", "class_start", "class_finish"}).
+			AddRow(8, 13, "Physics", start.Add(24*time.Hour), start.Add(25*time.Hour)))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/class_sessions/3/reschedule",
+		Body: jsonBody(ProposeRescheduleRequest{
+			ClassStart:       start.Add(24 * time.Hour),
+			ClassFinish:      start.Add(26 * time.Hour),
+			ResponseDeadline: time.Now().Add(24 * time.Hour),
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestProposeReschedule_DeadlineAfterStart(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Now().Add(72 * time.Hour)
+	expSessionWithClass(mock, 3, 30, start, "")
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/class_sessions/3/reschedule",
+		Body: jsonBody(ProposeRescheduleRequest{
+			ClassStart:       start.Add(24 * time.Hour),
+			ClassFinish:      start.Add(26 * time.Hour),
+			ResponseDeadline: start.Add(time.Hour),
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestProposeReschedule_ReasonTooLong(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Now().Add(72 * time.Hour)
+	expSessionWithClass(mock, 3, 30, start, "")
+
+	resp := runHTTP(t, app, httpInput{
+		Method: http.MethodPost,
+		Path:   "/class_sessions/3/reschedule",
+		Body: jsonBody(ProposeRescheduleRequest{
+			ClassStart:       start.Add(24 * time.Hour),
+			ClassFinish:      start.Add(26 * time.Hour),
+			ResponseDeadline: start.Add(-time.Hour),
+			Reason:           strings.Repeat("x", services.MaxSessionReasonLength+1),
+		}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ RespondToReschedule ------------------ */
+
+// 200
+func TestRespondToReschedule_DeclineRefunds(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "price", "class_start"}).AddRow(3, 12, 450.0, time.Now().Add(72*time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "session_reschedules" WHERE \(class_session_id = \$1 AND status = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_session_id", "status", "response_deadline"}).
+			AddRow(2, 3, models.RescheduleStatusPending, time.Now().Add(time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "reschedule_responses" WHERE \(reschedule_id = \$1 AND learner_id = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reschedule_id", "learner_id", "decision"}).
+			AddRow(1, 2, 9, models.RescheduleDecisionPending))
+	mock.ExpectExec(`UPDATE "reschedule_responses" SET "decision"=\$1`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	// Booked for 400; the price was raised to 450 afterwards.
+	mock.ExpectQuery(`SELECT \* FROM "enrollments" WHERE \(learner_id = \$1 AND class_session_id = \$2 AND enrollment_status = \$3\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "learner_id", "class_session_id", "enrollment_status", "amount_paid"}).
+			AddRow(11, 9, 3, models.EnrollmentStatusActive, 400.0))
+	mock.ExpectExec(`UPDATE "enrollments" SET "enrollment_status"=\$1,"refund_amount"=\$2,"refunded_at"=\$3`).
+		WithArgs(models.EnrollmentStatusRefunded, 400.0, sqlmock.AnyArg(), sqlmock.AnyArg(), 11, models.EnrollmentStatusRefunded).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "learners" WHERE "learners"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(9, 7))
+	mock.ExpectExec(`UPDATE "users" SET "balance"=balance \+ \$1`).
+		WithArgs(400.0, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+	expUserTimezones(mock, 7, "Asia/Bangkok")
+	ExpInsertReturningID("notifications", 1)(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/class_sessions/3/reschedule/respond",
+		Body:        jsonBody(RescheduleDecisionRequest{Decision: "decline"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200 (free enrollment: seat released, nothing credited)
+func TestRespondToReschedule_DeclineUnpaid(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "price", "class_start"}).AddRow(3, 12, 450.0, time.Now().Add(72*time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "session_reschedules"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_session_id", "status", "response_deadline"}).
+			AddRow(2, 3, models.RescheduleStatusPending, time.Now().Add(time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "reschedule_responses"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reschedule_id", "learner_id", "decision"}).
+			AddRow(1, 2, 9, models.RescheduleDecisionPending))
+	mock.ExpectExec(`UPDATE "reschedule_responses"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "enrollments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "learner_id", "class_session_id", "enrollment_status"}).
+			AddRow(11, 9, 3, models.EnrollmentStatusActive))
+	mock.ExpectExec(`UPDATE "enrollments" SET "enrollment_status"=\$1,"refund_amount"=\$2`).
+		WithArgs(models.EnrollmentStatusRefunded, 0.0, sqlmock.AnyArg(), sqlmock.AnyArg(), 11, models.EnrollmentStatusRefunded).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "learners"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(9, 7))
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+	expUserTimezones(mock, 7, "Asia/Bangkok")
+	ExpInsertReturningID("notifications", 1)(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/class_sessions/3/reschedule/respond",
+		Body:        jsonBody(RescheduleDecisionRequest{Decision: "decline"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 404
+func TestRespondToReschedule_NonePending(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+	expSessionWithClass(mock, 3, 30, time.Now().Add(72*time.Hour), "")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "session_reschedules"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/class_sessions/3/reschedule/respond",
+		Body:        jsonBody(RescheduleDecisionRequest{Decision: "accept"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusNotFound)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ UpdateClassSession time guard ------------------ */
+
+// 409
+func TestUpdateClassSession_TimeChangeWithLearners(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	start := time.Now().Add(72 * time.Hour)
+	ExpSelectByIDFound("class_sessions", 3, []string{"id", "class_id", "class_start", "class_finish", "class_status"}, []any{3, 12, start, start.Add(2 * time.Hour), models.SessionStatusOpen})(mock)
+	ExpPreloadField("classes", []string{"id", "teacher_id"}, []any{12, 30})(mock)
+	// The count is taken inside the transaction, under the session lock.
+	mock.ExpectBegin()
+	expTeacherScheduleFree(mock)
+	mock.ExpectQuery(`SELECT "id" FROM "class_sessions" WHERE "class_sessions"\."id" = \$1 .*FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/class_sessions/3",
+		Body:        jsonBody(map[string]any{"class_start": start.Add(24 * time.Hour), "class_finish": start.Add(26 * time.Hour)}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ CancelClassSession ------------------ */
+
+// 200
+func TestCancelClassSession_LateCancelRefundsAndFlags(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "price", "class_status", "class_start"}).
+			AddRow(3, 12, 450.0, models.SessionStatusScheduled, time.Now().Add(2*time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "late_cancel_hours", "late_cancel_flags", "flag_threshold"}).AddRow(1, 24, 1, 3))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "class_sessions" SET "class_status"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND class_status = \$4\)`).
+		WithArgs(models.SessionStatusCancelled, sqlmock.AnyArg(), 3, models.SessionStatusScheduled).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "enrollments" WHERE \(class_session_id = \$1 AND enrollment_status = \$2\)`).
+		WithArgs(3, models.EnrollmentStatusActive).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "learner_id", "class_session_id", "enrollment_status", "amount_paid"}).
+			AddRow(11, 9, 3, models.EnrollmentStatusActive, 400.0))
+	mock.ExpectExec(`UPDATE "enrollments" SET "enrollment_status"=\$1,"refund_amount"=\$2,"refunded_at"=\$3`).
+		WithArgs(models.EnrollmentStatusRefunded, 400.0, sqlmock.AnyArg(), sqlmock.AnyArg(), 11, models.EnrollmentStatusRefunded).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "learners" WHERE "learners"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(9, 7))
+	mock.ExpectExec(`UPDATE "users" SET "balance"=balance \+ \$1`).
+		WithArgs(400.0, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+	expUserTimezones(mock, 7, "Asia/Bangkok")
+	ExpInsertReturningID("notifications", 1)(mock)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "teachers" WHERE "teachers"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "flag_count"}).AddRow(30, 5, 0))
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "late_cancel_hours", "late_cancel_flags", "flag_threshold"}).AddRow(1, 24, 1, 3))
+	mock.ExpectExec(`UPDATE "teachers" SET .*"flag_count"=\$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "teachers" WHERE "teachers"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(30, 5))
+	expUserTimezones(mock, 5, "Asia/Bangkok")
+	ExpInsertReturningID("notifications", 2)(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/class_sessions/3/cancel",
+		Body:        jsonBody(CancelClassSessionRequest{Reason: "Teacher is ill"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	if body := string(readBody(t, resp.Body)); !strings.Contains(body, `"refund_total":400`) {
+		t.Fatalf("refund total must be what was paid, not the session price: %s", body)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestCancelClassSession_AlreadyCompleted(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "class_status", "class_start"}).
+			AddRow(3, 12, models.SessionStatusCompleted, time.Now().Add(-3*time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 30))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/class_sessions/3/cancel"})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestCancelClassSession_ReasonTooLong(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(5, 30))
+	expSessionWithClass(mock, 3, 30, time.Now().Add(72*time.Hour), "")
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/class_sessions/3/cancel",
+		Body:        jsonBody(CancelClassSessionRequest{Reason: strings.Repeat("x", services.MaxSessionReasonLength+1)}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestCancelClassSession_NotTeacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, sessionTeacherUser(6, 31))
+	expSessionWithClass(mock, 3, 30, time.Now().Add(72*time.Hour), "")
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/class_sessions/3/cancel"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -15,7 +15,7 @@ func ReviewRoutes(app *fiber.App) {
 	review.Get("/", GetReviews)
 	review.Get("/:id", GetReview)
 
-	reviewLearner := review.Group("/", middlewares.LearnerRequired())
+	reviewLearner := review.Group("/", middlewares.LearnerRequired(), middlewares.BanMiddleware(models.BanScopeLearning))
 	reviewLearner.Post("/", CreateReview)
 	reviewLearner.Put("/:id", UpdateReview)
 	reviewLearner.Delete("/:id", DeleteReview)
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/a2n2k3p4/tutorium-backend/storage"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// UploadSessionAsset godoc
+//
+//	@Summary		Share a recording or material with a class session
+//	@Description	Uploads one file (multipart field "file") for the session's learners. Slides (pdf or office documents) and worksheets (pdf, office documents, text or images) are accepted; the type is sniffed from the content. Request bodies are limited to 4 MB, so larger files go straight to storage through POST /uploads with purpose session_asset, as do recordings (mp4, webm, mp3, wav or ogg, up to 2 GiB), which this endpoint rejects. Recordings are kept for the storage policy's retention period after the session ends. Only the session's teacher may upload, within their storage quota.
+//	@Tags			ClassSessions
+//	@Security		BearerAuth
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			id		path		int		true	"Class session ID"
+//	@Param			file	formData	file	true	"Recording or material"
+//	@Param			kind	formData	string	false	"slides or worksheet (detected when omitted)"
+//	@Param			title	formData	string	false	"Title shown to learners"
+//	@Success		201		{object}	models.SessionAssetDoc
+//	@Failure		400		{string}	string	"Invalid file"
+//	@Failure		403		{string}	string	"Not the session's teacher"
+//	@Failure		404		{string}	string	"Class session not found"
+//	@Failure		409		{string}	string	"Storage quota exceeded"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/class_sessions/{id}/assets [post]
+func UploadSessionAsset(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var session models.ClassSession
+	user, err := loadSessionForCaller(c, db, &session)
+	if user == nil {
+		return err
+	}
+	if !services.IsSessionTeacher(user, &session) {
+		return c.Status(403).JSON("only the session's teacher can share recordings and materials")
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON("file is required")
+	}
+	if fh.Size > services.MaxSessionAssetBytes {
+		return c.Status(400).JSON("file too large")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return c.Status(400).JSON(err.Error())
+	}
+	head = head[:n]
+
+	kind, contentType, err := services.ClassifySessionAsset(head, fh.Size, c.FormValue("kind"))
+	if err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	if kind == models.SessionAssetRecording {
+		return c.Status(400).JSON("upload recordings through POST /uploads with purpose session_asset")
+	}
+	if err := services.CheckStorageQuota(db, user.Teacher, fh.Size); err != nil {
+		if errors.Is(err, services.ErrStorageQuotaExceeded) {
+			return c.Status(409).JSON(err.Error())
+		}
+		return c.Status(500).JSON(err.Error())
+	}
+
+	store, ok := c.Locals("minio").(storage.ObjectStore)
+	if !ok {
+		return c.Status(500).JSON("storage not available")
+	}
+	key := fmt.Sprintf("sessions/%d/assets/%s", session.ID, storage.GenerateFilename(contentType))
+	if err := store.PutObject(c.Context(), key, io.MultiReader(bytes.NewReader(head), f), fh.Size, contentType); err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	asset := models.SessionAsset{
+		ClassSessionID: session.ID,
+		TeacherID:      user.Teacher.ID,
+		Kind:           kind,
+		Title:          c.FormValue("title"),
+		FileName:       filepath.Base(fh.Filename),
+		ContentType:    contentType,
+		SizeBytes:      fh.Size,
+		ObjectKey:      key,
+	}
+	if err := services.CreateSessionAsset(db, &asset, &session, time.Now()); err != nil {
+		_ = store.RemoveObject(c.Context(), key)
+		if errors.Is(err, services.ErrStorageQuotaExceeded) {
+			return c.Status(409).JSON(err.Error())
+		}
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(201).JSON(asset)
+}
+
+// GetSessionAssets godoc
+//
+//	@Summary		List a class session's recordings and materials
+//	@Description	Lists the session's unexpired assets with presigned read URLs valid for 15 minutes. Visible to the session's teacher and its enrolled learners only.
+//	@Tags			ClassSessions
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Class session ID"
+//	@Success		200	{array}		models.SessionAssetDoc
+//	@Failure		400	{string}	string	"Invalid ID"
+//	@Failure		403	{string}	string	"Not the teacher or an enrolled learner"
+//	@Failure		404	{string}	string	"Class session not found"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/class_sessions/{id}/assets [get]
+func GetSessionAssets(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var session models.ClassSession
+	user, err := loadSessionForCaller(c, db, &session)
+	if user == nil {
+		return err
+	}
+	allowed, err := services.CanViewSessionAssets(db, user, &session)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	if !allowed {
+		return c.Status(403).JSON("only the session's teacher and enrolled learners can view its recordings and materials")
+	}
+
+	assets := []models.SessionAsset{}
+	if err := db.Where("class_session_id = ? AND (expires_at IS NULL OR expires_at > ?)", session.ID, time.Now()).
+		Order("id asc").Find(&assets).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	if ps, ok := c.Locals("minio").(storage.Presigner); ok {
+		for i := range assets {
+			if u, err := ps.PresignedGetObject(c.Context(), assets[i].ObjectKey, 15*time.Minute); err == nil {
+				assets[i].URL = u
+			}
+		}
+	}
+	return c.Status(200).JSON(assets)
+}
+
+// DeleteSessionAsset godoc
+//
+//	@Summary		Delete a recording or material
+//	@Description	Removes the asset from storage, freeing its space in the teacher's quota. Only the session's teacher may delete it.
+//	@Tags			ClassSessions
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id		path		int	true	"Class session ID"
+//	@Param			assetId	path		int	true	"Asset ID"
+//	@Success		200		{string}	string	"Successfully deleted session asset"
+//	@Failure		400		{string}	string	"Invalid ID"
+//	@Failure		403		{string}	string	"Not the session's teacher"
+//	@Failure		404		{string}	string	"Asset not found"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/class_sessions/{id}/assets/{assetId} [delete]
+func DeleteSessionAsset(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var session models.ClassSession
+	user, err := loadSessionForCaller(c, db, &session)
+	if user == nil {
+		return err
+	}
+	if !services.IsSessionTeacher(user, &session) {
+		return c.Status(403).JSON("only the session's teacher can delete its recordings and materials")
+	}
+	assetID, err := c.ParamsInt("assetId")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :assetId is an integer")
+	}
+
+	var asset models.SessionAsset
+	err = db.Where("class_session_id = ?", session.ID).First(&asset, assetID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("session asset not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	store, ok := c.Locals("minio").(storage.ObjectStore)
+	if !ok {
+		return c.Status(500).JSON("storage not available")
+	}
+	if err := services.DeleteSessionAsset(c.Context(), db, store, &asset); err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON("Successfully deleted session asset")
+}
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+var tinyPDF = []byte("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n")
+
+// expStoragePolicy expects the storage policy lookup, answering with
+// quotaBytes or with no row (the defaults) when it is zero.
+func expStoragePolicy(mock sqlmock.Sqlmock, quotaBytes int64) {
+	rows := sqlmock.NewRows([]string{"id", "recording_retention_days", "material_retention_days", "teacher_quota_bytes"})
+	if quotaBytes > 0 {
+		rows.AddRow(1, 90, 0, quotaBytes)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "storage_policies" WHERE "storage_policies"\."id" = \$1`).WillReturnRows(rows)
+}
+
+func expStorageUsed(mock sqlmock.Sqlmock, teacherID uint, used int64) {
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(size_bytes\), 0\) FROM "session_assets" WHERE teacher_id = \$1`).
+		WithArgs(teacherID).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(used))
+}
+
+/* ------------------ UploadSessionAsset ------------------ */
+
+// 201
+func TestUploadSessionAsset_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{}
+	app := setupAppWithUploader(gdb, sessionTeacherUser(5, 30), up)
+
+	expSessionWithClass(mock, 3, 30, time.Now().Add(-time.Hour), "")
+	expStoragePolicy(mock, 0)
+	expStorageUsed(mock, 30, 0)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "teachers" WHERE "teachers"\."id" = \$1 .* FOR UPDATE`).
+		WithArgs(30, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(30, 5))
+	expStoragePolicy(mock, 0)
+	expStorageUsed(mock, 30, 0)
+	mock.ExpectQuery(`INSERT INTO "session_assets"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	body, ct := multipartFile(t, "file", "week3-slides.pdf", tinyPDF)
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/class_sessions/3/assets", Body: body, ContentType: ct})
+	wantStatus(t, resp, http.StatusCreated)
+
+	if !strings.HasPrefix(up.lastObject, "sessions/3/assets/") || !strings.HasSuffix(up.lastObject, ".pdf") {
+		t.Fatalf("unexpected object key %q", up.lastObject)
+	}
+	if string(up.lastData) != string(tinyPDF) {
+		t.Fatalf("uploaded bytes differ from the file")
+	}
+	got := string(readBody(t, resp.Body))
+	if !strings.Contains(got, `"kind":"slides"`) || strings.Contains(got, up.lastObject) {
+		t.Fatalf("unexpected response %s", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestUploadSessionAsset_NotSessionTeacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{}
+	app := setupAppWithUploader(gdb, sessionTeacherUser(6, 31), up)
+
+	expSessionWithClass(mock, 3, 30, time.Now(), "")
+
+	body, ct := multipartFile(t, "file", "slides.pdf", tinyPDF)
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/class_sessions/3/assets", Body: body, ContentType: ct})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if up.lastData != nil {
+		t.Fatalf("rejected file must not be uploaded")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestUploadSessionAsset_QuotaExceeded(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{}
+	app := setupAppWithUploader(gdb, sessionTeacherUser(5, 30), up)
+
+	expSessionWithClass(mock, 3, 30, time.Now(), "")
+	expStoragePolicy(mock, 1000)
+	expStorageUsed(mock, 30, 990)
+
+	body, ct := multipartFile(t, "file", "slides.pdf", tinyPDF)
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/class_sessions/3/assets", Body: body, ContentType: ct})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if up.lastData != nil {
+		t.Fatalf("file over quota must not be uploaded")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestUploadSessionAsset_WrongKind(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, sessionTeacherUser(5, 30), &fakeUploader{})
+
+	expSessionWithClass(mock, 3, 30, time.Now(), "")
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	w.WriteField("kind", models.SessionAssetRecording)
+	fw, _ := w.CreateFormFile("file", "slides.pdf")
+	fw.Write(tinyPDF)
+	w.Close()
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/class_sessions/3/assets", Body: buf.Bytes(), ContentType: w.FormDataContentType()})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400 (recordings go through direct uploads)
+func TestUploadSessionAsset_Recording(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{}
+	app := setupAppWithUploader(gdb, sessionTeacherUser(5, 30), up)
+
+	expSessionWithClass(mock, 3, 30, time.Now(), "")
+
+	mp3 := append([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), make([]byte, 64)...)
+	body, ct := multipartFile(t, "file", "week3.mp3", mp3)
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/class_sessions/3/assets", Body: body, ContentType: ct})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if up.lastData != nil {
+		t.Fatalf("recordings must not be uploaded through this endpoint")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ GetSessionAssets ------------------ */
+
+// 200 (enrolled learner)
+func TestGetSessionAssets_EnrolledLearner(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+
+	expSessionWithClass(mock, 3, 30, time.Now().Add(-time.Hour), "")
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments" WHERE \(learner_id = \$1 AND class_session_id = \$2 AND enrollment_status IN \(\$3,\$4\)\)`).
+		WithArgs(9, 3, models.EnrollmentStatusActive, models.EnrollmentStatusNoShow).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "session_assets" WHERE \(class_session_id = \$1 AND \(expires_at IS NULL OR expires_at > \$2\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_session_id", "kind", "object_key"}).
+			AddRow(1, 3, models.SessionAssetRecording, "sessions/3/assets/1.mp4"))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/class_sessions/3/assets"})
+	wantStatus(t, resp, http.StatusOK)
+
+	got := string(readBody(t, resp.Body))
+	if !strings.Contains(got, `"kind":"recording"`) || strings.Contains(got, "sessions/3/assets/1.mp4") {
+		t.Fatalf("unexpected response %s", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403 (learner without a seat)
+func TestGetSessionAssets_NotEnrolled(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, enrolledLearnerUser(7, 9))
+
+	expSessionWithClass(mock, 3, 30, time.Now(), "")
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "enrollments"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/class_sessions/3/assets"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ DeleteSessionAsset ------------------ */
+
+// 200
+func TestDeleteSessionAsset_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{}
+	app := setupAppWithUploader(gdb, sessionTeacherUser(5, 30), up)
+
+	expSessionWithClass(mock, 3, 30, time.Now(), "")
+	mock.ExpectQuery(`SELECT \* FROM "session_assets" WHERE class_session_id = \$1 AND "session_assets"\."id" = \$2`).
+		WithArgs(3, 4, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_session_id", "object_key"}).AddRow(4, 3, "sessions/3/assets/4.pdf"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "session_assets" WHERE "session_assets"\."id" = \$1`).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodDelete, Path: "/class_sessions/3/assets/4"})
+	wantStatus(t, resp, http.StatusOK)
+
+	if len(up.removed) != 1 || up.removed[0] != "sessions/3/assets/4.pdf" {
+		t.Fatalf("expected the object to be removed, got %v", up.removed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ Storage policy ------------------ */
+
+// 200
+func TestGetStorageUsage_OverrideQuota(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	user := sessionTeacherUser(5, 30)
+	quota := int64(10 << 30)
+	user.Teacher.StorageQuotaBytes = &quota
+	app := setupAppAsUser(gdb, user)
+
+	expStoragePolicy(mock, 0)
+	expStorageUsed(mock, 30, 1<<30)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodGet, Path: "/storage/usage"})
+	wantStatus(t, resp, http.StatusOK)
+
+	got := string(readBody(t, resp.Body))
+	if !strings.Contains(got, `"used_bytes":1073741824`) || !strings.Contains(got, `"quota_bytes":10737418240`) {
+		t.Fatalf("unexpected usage %s", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestUpdateStoragePolicy_Negative(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupApp(gdb)
+	expStoragePolicy(mock, 0)
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/storage/policy",
+		Body:        jsonBody(map[string]any{"recording_retention_days": -1, "teacher_quota_bytes": 1 << 30}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200 (a partial body keeps the stored values)
+func TestUpdateStoragePolicy_Partial(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+	stored := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "recording_retention_days", "material_retention_days", "teacher_quota_bytes"}).
+			AddRow(1, 30, 0, int64(10<<30))
+	}
+	mock.ExpectQuery(`SELECT \* FROM "storage_policies" WHERE "storage_policies"\."id" = \$1`).WillReturnRows(stored())
+	mock.ExpectQuery(`SELECT \* FROM "storage_policies" WHERE "storage_policies"\."id" = \$1`).WillReturnRows(stored())
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "storage_policies" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/storage/policy",
+		Body:        jsonBody(map[string]any{"material_retention_days": 365}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	var policy models.StoragePolicy
+	if err := json.Unmarshal(readBody(t, resp.Body), &policy); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if policy.MaterialRetentionDays != 365 || policy.RecordingRetentionDays != 30 || policy.TeacherQuotaBytes != 10<<30 {
+		t.Fatalf("stored values were not kept: %+v", policy)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200 (a DeletedAt in the body does not soft-delete the policy)
+func TestUpdateStoragePolicy_IgnoresDeletedAt(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+	stored := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "recording_retention_days", "material_retention_days", "teacher_quota_bytes"}).
+			AddRow(1, 30, 0, int64(10<<30))
+	}
+	mock.ExpectQuery(`SELECT \* FROM "storage_policies" WHERE "storage_policies"\."id" = \$1`).WillReturnRows(stored())
+	mock.ExpectQuery(`SELECT \* FROM "storage_policies" WHERE "storage_policies"\."id" = \$1`).WillReturnRows(stored())
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "storage_policies" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPut,
+		Path:        "/storage/policy",
+		Body:        jsonBody(map[string]any{"DeletedAt": "2026-01-01T00:00:00Z", "material_retention_days": 365}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	var policy models.StoragePolicy
+	if err := json.Unmarshal(readBody(t, resp.Body), &policy); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if policy.DeletedAt.Valid || policy.MaterialRetentionDays != 365 || policy.RecordingRetentionDays != 30 || policy.TeacherQuotaBytes != 10<<30 {
+		t.Fatalf("DeletedAt was taken from the body: %+v", policy)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type TeacherStorageQuotaRequest struct {
+	QuotaBytes *int64 `json:"quota_bytes"`
+}
+
+func StorageRoutes(app *fiber.App) {
+	storageGroup := app.Group("/storage", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware())
+	storageGroup.Get("/usage", middlewares.TeacherRequired(), GetStorageUsage)
+	storageGroup.Get("/policy", middlewares.AdminRequired(), GetStoragePolicy)
+	storageGroup.Put("/policy", middlewares.AdminRequired(), UpdateStoragePolicy)
+	storageGroup.Put("/teachers/:id/quota", middlewares.AdminRequired(), SetTeacherStorageQuota)
+}
+
+// GetStorageUsage godoc
+//
+//	@Summary		Get my storage usage
+//	@Description	Returns how many bytes the calling teacher's session recordings and materials use and their quota
+//	@Tags			Storage
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	models.StorageUsageDoc
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Failure		403	{string}	string	"Not a teacher"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/storage/usage [get]
+func GetStorageUsage(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON("unauthorized")
+	}
+	if user.Teacher == nil {
+		return c.Status(403).JSON("teacher access required")
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	usage, err := services.TeacherStorageUsage(db, user.Teacher)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(usage)
+}
+
+// GetStoragePolicy godoc
+//
+//	@Summary		Get the storage policy
+//	@Description	Returns the retention periods for session recordings and materials and the default teacher quota
+//	@Tags			Storage
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	models.StoragePolicyDoc
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/storage/policy [get]
+func GetStoragePolicy(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	policy, err := services.GetStoragePolicy(db)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(policy)
+}
+
+// UpdateStoragePolicy godoc
+//
+//	@Summary		Update the storage policy
+//	@Description	Updates the storage policy; fields left out keep their current values. A retention period of 0 days keeps assets until their teacher deletes them. New retention periods apply to assets uploaded afterwards; the quota applies to the next upload.
+//	@Tags			Storage
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			policy	body		models.StoragePolicyDoc	true	"Storage policy"
+//	@Success		200		{object}	models.StoragePolicyDoc
+//	@Failure		400		{string}	string	"Invalid input"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/storage/policy [put]
+func UpdateStoragePolicy(c *fiber.Ctx) error {
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	// Fields left out of the body keep their current values.
+	policy, err := services.GetStoragePolicy(db)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	var req models.UpdateStoragePolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	req.ApplyTo(&policy)
+
+	if err := services.ValidateStoragePolicy(policy); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	var updatedBy *uint
+	if user, ok := c.Locals("currentUser").(*models.User); ok {
+		updatedBy = &user.ID
+	}
+
+	if err := services.SaveStoragePolicy(db, &policy, updatedBy); err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(policy)
+}
+
+// SetTeacherStorageQuota godoc
+//
+//	@Summary		Override a teacher's storage quota
+//	@Description	Sets the teacher's own quota in bytes; null falls back to the storage policy's default
+//	@Tags			Storage
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int								true	"Teacher ID"
+//	@Param			quota	body		models.TeacherStorageQuotaDoc	true	"Quota override"
+//	@Success		200		{object}	models.StorageUsageDoc
+//	@Failure		400		{string}	string	"Invalid input"
+//	@Failure		404		{string}	string	"Teacher not found"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/storage/teachers/{id}/quota [put]
+func SetTeacherStorageQuota(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+
+	var req TeacherStorageQuotaRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+	if req.QuotaBytes != nil && *req.QuotaBytes < 0 {
+		return c.Status(400).JSON("quota_bytes must not be negative")
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var teacher models.Teacher
+	err = db.First(&teacher, id).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("teacher not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	if err := db.Model(&teacher).Update("storage_quota_bytes", req.QuotaBytes).Error; err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	teacher.StorageQuotaBytes = req.QuotaBytes
+
+	usage, err := services.TeacherStorageUsage(db, &teacher)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	return c.Status(200).JSON(usage)
+}
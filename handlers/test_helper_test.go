@@ -3,7 +3,9 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,7 +16,9 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/a2n2k3p4/tutorium-backend/config"
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
 	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
 	"github.com/a2n2k3p4/tutorium-backend/storage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
@@ -49,11 +53,26 @@ func setupApp(gdb *gorm.DB) *fiber.App {
 	app := fiber.New()
 	// inject mocked DB into request context
 	app.Use(middlewares.DBMiddleware(gdb))
+	app.Use(middlewares.MeetingMiddleware(meeting.NewFake("")))
 	// now mount routes
 	AllRoutes(app)
 	return app
 }
 
+// setupAppAsUser mounts routes with currentUser pre-populated, for handlers
+// that act on the caller (the auth middleware is bypassed in tests).
+func setupAppAsUser(gdb *gorm.DB, user *models.User) *fiber.App {
+	app := fiber.New()
+	app.Use(middlewares.DBMiddleware(gdb))
+	app.Use(middlewares.MeetingMiddleware(meeting.NewFake("")))
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("currentUser", user)
+		return c.Next()
+	})
+	AllRoutes(app)
+	return app
+}
+
 /* ------------------ Reader Helper ------------------ */
 func readBody(t *testing.T, r io.Reader) []byte {
 	t.Helper()
@@ -144,12 +163,25 @@ func RunInDifferentStatus(t *testing.T,
 	}
 }
 
+// inProduction runs the middlewares as in production for the rest of t, so
+// tokens are checked and admins must have passed the second factor.
+func inProduction(t *testing.T) {
+	t.Setenv("STATUS", "production")
+	prev := middlewares.Status
+	middlewares.Status = func() string { return "production" }
+	t.Cleanup(func() { middlewares.Status = prev })
+}
+
 /* ------------------ Authentication Helper ------------------ */
 func preloadUserForAuth(mock sqlmock.Sqlmock, userID uint, hasAdmin bool, hasTeacher bool, hasLearner bool) {
 	if config.STATUS() == "development" {
 		return
 	}
+	expAuthUserRows(mock, userID, hasAdmin, hasTeacher, hasLearner)
+}
 
+// expAuthUserRows expects ProtectedMiddleware to load userID with its roles.
+func expAuthUserRows(mock sqlmock.Sqlmock, userID uint, hasAdmin bool, hasTeacher bool, hasLearner bool) {
 	mock.MatchExpectationsInOrder(false)
 
 	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1 AND "users"\."deleted_at" IS NULL ORDER BY "users"\."id" LIMIT .*`).
@@ -525,6 +557,18 @@ type fakeUploader struct {
 	lastFilename string
 	lastData     []byte
 	wantErr      error
+
+	lastObject string
+	removed    []string
+	objects    map[string][]byte // what clients uploaded through presigned URLs
+	// replaceAfterStat is uploaded over every object once it has been
+	// stat'd, like a client reusing its presigned upload mid-check.
+	replaceAfterStat []byte
+}
+
+func fakeETag(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
 }
 
 func (f *fakeUploader) UploadBytes(_ context.Context, bucket, filename string, b []byte) (string, error) {
@@ -537,6 +581,59 @@ func (f *fakeUploader) UploadBytes(_ context.Context, bucket, filename string, b
 	return bucket + "/" + filename, nil
 }
 
+func (f *fakeUploader) PutObject(_ context.Context, objectName string, r io.Reader, _ int64, _ string) error {
+	f.lastObject = objectName
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	f.lastData = b
+	return f.wantErr
+}
+
+func (f *fakeUploader) RemoveObject(_ context.Context, objectName string) error {
+	f.removed = append(f.removed, objectName)
+	return f.wantErr
+}
+
+func (f *fakeUploader) CopyObject(_ context.Context, srcName, dstName, matchETag string) error {
+	if f.wantErr != nil {
+		return f.wantErr
+	}
+	if fakeETag(f.objects[srcName]) != matchETag {
+		return storage.ErrObjectChanged
+	}
+	if f.objects == nil {
+		f.objects = map[string][]byte{}
+	}
+	f.objects[dstName] = f.objects[srcName]
+	f.lastObject = dstName
+	return nil
+}
+
+func (f *fakeUploader) PresignedPostObject(_ context.Context, objectName, contentType string, _ int64, _ time.Duration) (string, map[string]string, error) {
+	return "https://minio.test/", map[string]string{"key": objectName, "Content-Type": contentType}, f.wantErr
+}
+
+func (f *fakeUploader) StatObject(_ context.Context, objectName string) (storage.ObjectInfo, error) {
+	b, ok := f.objects[objectName]
+	if !ok {
+		return storage.ObjectInfo{}, storage.ErrObjectNotFound
+	}
+	if f.replaceAfterStat != nil {
+		f.objects[objectName] = f.replaceAfterStat
+	}
+	return storage.ObjectInfo{Size: int64(len(b)), ETag: fakeETag(b)}, nil
+}
+
+func (f *fakeUploader) ReadObjectHead(_ context.Context, objectName string, n int64, matchETag string) ([]byte, error) {
+	b := f.objects[objectName]
+	if fakeETag(b) != matchETag {
+		return nil, storage.ErrObjectChanged
+	}
+	return b[:min(int(n), len(b))], nil
+}
+
 func newCtxWithUploader(u storage.Uploader) (*fiber.App, *fiber.Ctx) {
 	app := fiber.New()
 	rc := new(fasthttp.RequestCtx)
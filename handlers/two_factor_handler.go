@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TwoFactorRoutes(app *fiber.App) {
+	twoFactor := app.Group("/auth/2fa", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware())
+
+	twoFactor.Post("/enroll", EnrollTwoFactor)
+	twoFactor.Post("/confirm", ConfirmTwoFactor)
+	twoFactor.Post("/verify", VerifyTwoFactor)
+	twoFactor.Post("/recovery_codes", middlewares.AdminRequired(), middlewares.StepUpRequired(middlewares.StepUpMaxAge), RegenerateRecoveryCodes)
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// twoFactorErrorStatus maps service errors to HTTP status codes.
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled):
+		return 409
+	case errors.Is(err, services.ErrTwoFactorNotEnrolled):
+		return 400
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		return 401
+	case errors.Is(err, services.ErrTwoFactorLocked):
+		return 429
+	default:
+		return 500
+	}
+}
+
+// EnrollTwoFactor godoc
+//
+//	@Summary		Start TOTP enrolment for an admin
+//	@Description	Generates a new TOTP secret, an otpauth:// provisioning URI (render it as a QR code) and a fresh set of recovery codes. The enrolment stays pending until confirmed.
+//	@Tags			TwoFactor
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	models.TwoFactorEnrollResponseDoc
+//	@Failure		401	{object}	map[string]string	"Unauthorized"
+//	@Failure		403	{object}	map[string]string	"Admin access required"
+//	@Failure		409	{object}	map[string]string	"Two-factor authentication already enabled"
+//	@Failure		500	{object}	map[string]string	"Server error"
+//	@Router			/auth/2fa/enroll [post]
+func EnrollTwoFactor(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	if user.Admin == nil {
+		return c.Status(403).JSON(fiber.Map{"error": "admin access required"})
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	secret, uri, codes, err := services.EnrollAdminTwoFactor(db, user)
+	if err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(200).JSON(fiber.Map{
+		"secret":           secret,
+		"provisioning_uri": uri,
+		"recovery_codes":   codes,
+	})
+}
+
+// ConfirmTwoFactor godoc
+//
+//	@Summary		Confirm TOTP enrolment
+//	@Description	Activates a pending enrolment with a code from the authenticator app and returns a token carrying the second factor
+//	@Tags			TwoFactor
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			code	body		models.TwoFactorCodeDoc	true	"TOTP code"
+//	@Success		200		{object}	models.TwoFactorVerifyResponseDoc
+//	@Failure		400		{object}	map[string]string	"Invalid input or not enrolled"
+//	@Failure		401		{object}	map[string]string	"Invalid code"
+//	@Failure		409		{object}	map[string]string	"Already enabled"
+//	@Failure		500		{object}	map[string]string	"Server error"
+//	@Router			/auth/2fa/confirm [post]
+func ConfirmTwoFactor(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "code is required"})
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := services.ConfirmAdminTwoFactor(db, user.ID, req.Code); err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	token, err := generateMFAJWT(*user, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(200).JSON(fiber.Map{"token": token})
+}
+
+// VerifyTwoFactor godoc
+//
+//	@Summary		Verify the second factor
+//	@Description	Exchanges a TOTP or recovery code for a token accepted by admin routes. Call again to step up before sensitive actions such as refunds or admin creation. Five wrong codes in a row lock verification for 15 minutes.
+//	@Tags			TwoFactor
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			code	body		models.TwoFactorCodeDoc	true	"TOTP or recovery code"
+//	@Success		200		{object}	models.TwoFactorVerifyResponseDoc
+//	@Failure		400		{object}	map[string]string	"Invalid input or not enrolled"
+//	@Failure		401		{object}	map[string]string	"Invalid code"
+//	@Failure		429		{object}	map[string]string	"Too many invalid codes"
+//	@Failure		500		{object}	map[string]string	"Server error"
+//	@Router			/auth/2fa/verify [post]
+func VerifyTwoFactor(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "code is required"})
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := services.VerifyAdminSecondFactor(db, user.ID, req.Code); err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	token, err := generateMFAJWT(*user, time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(200).JSON(fiber.Map{"token": token})
+}
+
+// RegenerateRecoveryCodes godoc
+//
+//	@Summary		Regenerate recovery codes
+//	@Description	Invalidates all previous recovery codes and returns a new set. Requires a recent second-factor verification.
+//	@Tags			TwoFactor
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Success		200	{object}	map[string][]string	"recovery_codes"
+//	@Failure		400	{object}	map[string]string	"Not enrolled"
+//	@Failure		403	{object}	map[string]string	"Recent two-factor verification required"
+//	@Failure		500	{object}	map[string]string	"Server error"
+//	@Router			/auth/2fa/recovery_codes [post]
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(db, user.ID)
+	if err != nil {
+		return c.Status(twoFactorErrorStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(200).JSON(fiber.Map{"recovery_codes": codes})
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+func adminUser(id uint) *models.User {
+	u := &models.User{StudentID: "b6600000000", Admin: &models.Admin{UserID: id}}
+	u.ID = id
+	return u
+}
+
+/* ------------------ EnrollTwoFactor ------------------ */
+
+// 403
+func TestEnrollTwoFactor_NotAdmin(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	user := &models.User{StudentID: "b6600000001"}
+	user.ID = 5
+	app := setupAppAsUser(gdb, user)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/auth/2fa/enroll"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200
+func TestEnrollTwoFactor_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "admin_two_factors" WHERE user_id = \$1`).
+		WithArgs(uint(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO "admin_two_factors".*RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`DELETE FROM "admin_recovery_codes" WHERE user_id = \$1`).
+		WithArgs(uint(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "admin_recovery_codes".*RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/auth/2fa/enroll"})
+	wantStatus(t, resp, http.StatusOK)
+
+	var out struct {
+		Secret          string   `json:"secret"`
+		ProvisioningURI string   `json:"provisioning_uri"`
+		RecoveryCodes   []string `json:"recovery_codes"`
+	}
+	if err := json.Unmarshal(readBody(t, resp.Body), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Secret == "" || !strings.HasPrefix(out.ProvisioningURI, "otpauth://totp/") {
+		t.Fatalf("unexpected enrolment payload: %+v", out)
+	}
+	if len(out.RecoveryCodes) != 10 {
+		t.Fatalf("expected 10 recovery codes, got %d", len(out.RecoveryCodes))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestEnrollTwoFactor_AlreadyEnabled(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "admin_two_factors" WHERE user_id = \$1`).
+		WithArgs(uint(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "secret", "enabled"}).AddRow(1, 1, testTOTPSecret, true))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/auth/2fa/enroll"})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ ConfirmTwoFactor ------------------ */
+
+// 400
+func TestConfirmTwoFactor_NotEnrolled(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "admin_two_factors" WHERE user_id = \$1`).
+		WithArgs(uint(1), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/auth/2fa/confirm",
+		Body:        jsonBody(TwoFactorCodeRequest{Code: "123456"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ VerifyTwoFactor ------------------ */
+
+// 200
+func TestVerifyTwoFactor_TOTP_OK(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	code, err := services.TOTPCode(testTOTPSecret, time.Now())
+	if err != nil {
+		t.Fatalf("totp: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "admin_two_factors" WHERE \(user_id = \$1 AND enabled = \$2\)`).
+		WithArgs(uint(1), true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "secret", "enabled", "last_used_step"}).AddRow(1, 1, testTOTPSecret, true, 0))
+	mock.ExpectExec(`UPDATE "admin_two_factors" SET "failed_attempts"=\$1,"last_used_step"=\$2,"locked_until"=\$3`).
+		WithArgs(0, sqlmock.AnyArg(), nil, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/auth/2fa/verify",
+		Body:        jsonBody(TwoFactorCodeRequest{Code: code}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusOK)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 401
+func TestVerifyTwoFactor_InvalidCode(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "admin_two_factors" WHERE \(user_id = \$1 AND enabled = \$2\)`).
+		WithArgs(uint(1), true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "secret", "enabled", "last_used_step"}).AddRow(1, 1, testTOTPSecret, true, 0))
+	mock.ExpectQuery(`SELECT \* FROM "admin_recovery_codes" WHERE \(user_id = \$1 AND code_hash = \$2 AND used_at IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE "admin_two_factors" SET "failed_attempts"=\$1,"updated_at"=\$2`).
+		WithArgs(1, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/auth/2fa/verify",
+		Body:        jsonBody(TwoFactorCodeRequest{Code: "not-a-code"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusUnauthorized)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 401: the fifth wrong code in a row locks verification.
+func TestVerifyTwoFactor_LocksAfterRepeatedFailures(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "admin_two_factors" WHERE \(user_id = \$1 AND enabled = \$2\) .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "secret", "enabled", "last_used_step", "failed_attempts"}).AddRow(1, 1, testTOTPSecret, true, 0, 4))
+	mock.ExpectQuery(`SELECT \* FROM "admin_recovery_codes"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE "admin_two_factors" SET "failed_attempts"=\$1,"locked_until"=\$2,"updated_at"=\$3`).
+		WithArgs(0, sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/auth/2fa/verify",
+		Body:        jsonBody(TwoFactorCodeRequest{Code: "not-a-code"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusUnauthorized)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 429: while locked even a valid code is refused.
+func TestVerifyTwoFactor_Locked(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	code, err := services.TOTPCode(testTOTPSecret, time.Now())
+	if err != nil {
+		t.Fatalf("totp: %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "admin_two_factors" WHERE \(user_id = \$1 AND enabled = \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "secret", "enabled", "last_used_step", "locked_until"}).
+			AddRow(1, 1, testTOTPSecret, true, 0, time.Now().Add(10*time.Minute)))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/auth/2fa/verify",
+		Body:        jsonBody(TwoFactorCodeRequest{Code: code}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusTooManyRequests)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestVerifyTwoFactor_MissingCode(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppAsUser(gdb, adminUser(1))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/auth/2fa/verify",
+		Body:        jsonBody(TwoFactorCodeRequest{}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
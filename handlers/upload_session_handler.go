@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/middlewares"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/a2n2k3p4/tutorium-backend/storage"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func UploadRoutes(app *fiber.App) {
+	uploads := app.Group("/uploads", middlewares.ProtectedMiddleware(), middlewares.BanMiddleware())
+	uploads.Post("/", CreateUploadSession)
+	uploads.Post("/:id/confirm", ConfirmUploadSession)
+}
+
+// uploadErrorStatus maps upload session service errors to HTTP status codes.
+func uploadErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUnknownUploadPurpose), errors.Is(err, services.ErrUnsupportedAttachment), errors.Is(err, services.ErrUploadRejected):
+		return 400
+	case errors.Is(err, services.ErrUploadNotAllowed):
+		return 403
+	case errors.Is(err, services.ErrUploadTargetNotFound):
+		return 404
+	case errors.Is(err, services.ErrUploadNotReceived), errors.Is(err, services.ErrUploadNotPending), errors.Is(err, services.ErrStorageQuotaExceeded),
+		errors.Is(err, services.ErrReportPictureLocked), errors.Is(err, services.ErrReportClosed), errors.Is(err, services.ErrAttachmentLimit):
+		return 409
+	case errors.Is(err, services.ErrUploadExpired):
+		return 410
+	default:
+		return 500
+	}
+}
+
+// CreateUploadSession godoc
+//
+//	@Summary		Start a direct upload
+//	@Description	Presigns a browser POST form for uploading a picture straight to storage, replacing base64 pictures in JSON bodies. The form only accepts the declared content_type and files up to max_bytes, and is valid for 15 minutes; confirm the upload afterwards to attach it. Purposes: profile_picture (target is your user ID, 10 MB), class_banner (a class you teach, 20 MB), report_picture (a report you filed that is still submitted, 20 MB) and session_asset (a class session you teach, up to 2 GiB for recordings; kind, title and file_name are optional and kind is detected when omitted) and report_attachment (evidence for an open report you filed, up to 50 MB for video clips; kind and file_name are optional). Admins signed in with two-factor authentication may target any user, class or report.
+//	@Tags			Uploads
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			upload	body		models.CreateUploadSessionRequestDoc	true	"What is being uploaded"
+//	@Success		201		{object}	models.UploadSessionDoc
+//	@Failure		400		{string}	string	"Invalid purpose or content type"
+//	@Failure		403		{string}	string	"Not allowed to change the target"
+//	@Failure		404		{string}	string	"Target not found"
+//	@Failure		409		{string}	string	"Report already picked up, closed or at its attachment limit"
+//	@Failure		500		{string}	string	"Server error"
+//	@Router			/uploads [post]
+func CreateUploadSession(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON("unauthorized")
+	}
+
+	var req models.CreateUploadSessionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+	store, ok := c.Locals("minio").(storage.DirectUploader)
+	if !ok {
+		return c.Status(500).JSON("storage not available")
+	}
+
+	upload, err := services.CreateUploadSession(c.Context(), db, store, user, middlewares.IsVerifiedAdmin(c), req, time.Now())
+	if err != nil {
+		return c.Status(uploadErrorStatus(err)).JSON(err.Error())
+	}
+	return c.Status(201).JSON(upload)
+}
+
+// ConfirmUploadSession godoc
+//
+//	@Summary		Confirm a direct upload
+//	@Description	Checks the uploaded object's size and sniffed content type and attaches it to the upload's target, shares it as a session asset counted against the teacher's storage quota or adds it to a report's evidence, returning a presigned read URL valid for 15 minutes. Objects that fail the check or do not fit in the quota are deleted and the upload rejected. Only the user who started the upload may confirm it, up to an hour after its URLs expire.
+//	@Tags			Uploads
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Upload session ID"
+//	@Success		200	{object}	models.UploadSessionDoc
+//	@Failure		400	{string}	string	"Uploaded file rejected"
+//	@Failure		403	{string}	string	"Not your upload"
+//	@Failure		404	{string}	string	"Upload session not found"
+//	@Failure		409	{string}	string	"Nothing uploaded yet, already confirmed, storage quota exceeded, report picked up, closed or at its attachment limit"
+//	@Failure		410	{string}	string	"Upload session expired"
+//	@Failure		500	{string}	string	"Server error"
+//	@Router			/uploads/{id}/confirm [post]
+func ConfirmUploadSession(c *fiber.Ctx) error {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok {
+		return c.Status(401).JSON("unauthorized")
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(400).JSON("Please ensure that :id is an integer")
+	}
+
+	db, err := middlewares.GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(err.Error())
+	}
+
+	var upload models.UploadSession
+	err = db.First(&upload, id).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON("upload session not found")
+	case err != nil:
+		return c.Status(500).JSON(err.Error())
+	}
+
+	store, ok := c.Locals("minio").(storage.DirectUploader)
+	if !ok {
+		return c.Status(500).JSON("storage not available")
+	}
+	if err := services.ConfirmUploadSession(c.Context(), db, store, user, middlewares.IsVerifiedAdmin(c), &upload, time.Now()); err != nil {
+		return c.Status(uploadErrorStatus(err)).JSON(err.Error())
+	}
+
+	if ps, ok := c.Locals("minio").(storage.Presigner); ok {
+		if u, err := ps.PresignedGetObject(c.Context(), upload.ObjectKey, 15*time.Minute); err == nil {
+			upload.URL = u
+		}
+	}
+	return c.Status(200).JSON(upload)
+}
@@ -0,0 +1,597 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+// expUploadSessionRow expects the upload session :id lookup.
+func expUploadSessionRow(mock sqlmock.Sqlmock, userID uint, status string, expiresAt time.Time) {
+	mock.ExpectQuery(`SELECT \* FROM "upload_sessions" WHERE "upload_sessions"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "purpose", "target_id", "content_type", "max_bytes", "object_key", "status", "expires_at"}).
+			AddRow(7, userID, models.UploadPurposeProfilePicture, 42, "image/png", 10<<20, "uploads/1.png", status, expiresAt))
+}
+
+// expUploadClaimed expects upload session 7 to be claimed for confirmation,
+// which fails when claimed is false because another request got there first.
+func expUploadClaimed(mock sqlmock.Sqlmock, claimed bool) {
+	rows := int64(0)
+	if claimed {
+		rows = 1
+	}
+	mock.ExpectExec(`UPDATE "upload_sessions" SET "confirmed_at"=\$1,"content_type"=\$2,"kind"=\$3,"object_key"=\$4,"size_bytes"=\$5,"status"=\$6,"updated_at"=\$7 WHERE \(id = \$8 AND status = \$9\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), models.UploadStatusConfirmed, sqlmock.AnyArg(), 7, models.UploadStatusPending).
+		WillReturnResult(sqlmock.NewResult(0, rows))
+}
+
+// expUploadRejected expects upload session 7 to be marked rejected unless
+// it was confirmed meanwhile.
+func expUploadRejected(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "upload_sessions" SET "status"=\$1,"updated_at"=\$2 WHERE status = \$3 AND "upload_sessions"\."deleted_at" IS NULL AND "id" = \$4`).
+		WithArgs(models.UploadStatusRejected, sqlmock.AnyArg(), models.UploadStatusPending, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func expUserRow(mock sqlmock.Sqlmock, id uint) {
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1`).
+		WithArgs(id, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+}
+
+/* ------------------ CreateUploadSession ------------------ */
+
+// 201
+func TestCreateUploadSession_ProfilePicture(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, reporterUser(42), &fakeUploader{})
+
+	expUserRow(mock, 42)
+	ExpInsertReturningID("upload_sessions", 7)(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/uploads",
+		Body:        jsonBody(map[string]any{"purpose": "profile_picture", "target_id": 42, "content_type": "image/png"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	var upload models.UploadSession
+	if err := json.Unmarshal(readBody(t, resp.Body), &upload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if key := upload.PostFields["key"]; !strings.HasPrefix(key, "uploads/") || !strings.HasSuffix(key, ".png") {
+		t.Fatalf("uploads must go to a staging key, got %q", key)
+	}
+	if upload.PostURL == "" || upload.PostFields["Content-Type"] != "image/png" {
+		t.Fatalf("unexpected post form %q %v", upload.PostURL, upload.PostFields)
+	}
+	if upload.Status != models.UploadStatusPending || upload.MaxBytes != 10<<20 {
+		t.Fatalf("unexpected upload session %+v", upload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400
+func TestCreateUploadSession_UnsupportedContentType(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, reporterUser(42), &fakeUploader{})
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/uploads",
+		Body:        jsonBody(map[string]any{"purpose": "profile_picture", "target_id": 42, "content_type": "application/pdf"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403 (banner of someone else's class)
+func TestCreateUploadSession_NotClassTeacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, sessionTeacherUser(5, 30), &fakeUploader{})
+
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WithArgs(12, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, 31))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/uploads",
+		Body:        jsonBody(map[string]any{"purpose": "class_banner", "target_id": 12, "content_type": "image/jpeg"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ ConfirmUploadSession ------------------ */
+
+// 200
+func TestConfirmUploadSession_AttachesProfilePicture(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	png, _ := base64.StdEncoding.DecodeString(tinyPNGRawBase64)
+	up := &fakeUploader{objects: map[string][]byte{"uploads/1.png": png}}
+	app := setupAppWithUploader(gdb, reporterUser(42), up)
+
+	expUploadSessionRow(mock, 42, models.UploadStatusPending, time.Now().Add(10*time.Minute))
+	expUserRow(mock, 42)
+	mock.ExpectBegin()
+	expUploadClaimed(mock, true)
+	mock.ExpectExec(`UPDATE "users" SET "profile_picture_url"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 42).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/uploads/7/confirm"})
+	wantStatus(t, resp, http.StatusOK)
+
+	var upload models.UploadSession
+	if err := json.Unmarshal(readBody(t, resp.Body), &upload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if upload.Status != models.UploadStatusConfirmed || upload.SizeBytes != int64(len(png)) || upload.ConfirmedAt == nil {
+		t.Fatalf("unexpected upload session %+v", upload)
+	}
+	if !strings.HasPrefix(up.lastObject, "users/") || !strings.HasSuffix(up.lastObject, ".png") {
+		t.Fatalf("upload must be copied to a fresh key, got %q", up.lastObject)
+	}
+	if len(up.removed) != 1 || up.removed[0] != "uploads/1.png" {
+		t.Fatalf("staged upload must be removed, got %v", up.removed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400 (content is not a picture)
+func TestConfirmUploadSession_RejectsSniffedType(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{objects: map[string][]byte{"uploads/1.png": []byte("<html><script>alert(1)</script></html>")}}
+	app := setupAppWithUploader(gdb, reporterUser(42), up)
+
+	expUploadSessionRow(mock, 42, models.UploadStatusPending, time.Now().Add(10*time.Minute))
+	expUploadRejected(mock)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/uploads/7/confirm"})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if len(up.removed) != 1 || up.removed[0] != "uploads/1.png" {
+		t.Fatalf("rejected object must be removed, got %v", up.removed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 400 (the checked picture was replaced before it could be copied)
+func TestConfirmUploadSession_RejectsReplacedObject(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	png, _ := base64.StdEncoding.DecodeString(tinyPNGRawBase64)
+	up := &fakeUploader{
+		objects:          map[string][]byte{"uploads/1.png": png},
+		replaceAfterStat: []byte("<html><script>alert(1)</script></html>"),
+	}
+	app := setupAppWithUploader(gdb, reporterUser(42), up)
+
+	expUploadSessionRow(mock, 42, models.UploadStatusPending, time.Now().Add(10*time.Minute))
+	expUploadRejected(mock)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/uploads/7/confirm"})
+	wantStatus(t, resp, http.StatusBadRequest)
+
+	if len(up.removed) != 1 || up.removed[0] != "uploads/1.png" {
+		t.Fatalf("replaced object must be removed, got %v", up.removed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409 (a concurrent confirmation claimed the upload first)
+func TestConfirmUploadSession_AlreadyClaimed(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	png, _ := base64.StdEncoding.DecodeString(tinyPNGRawBase64)
+	up := &fakeUploader{objects: map[string][]byte{"uploads/1.png": png}}
+	app := setupAppWithUploader(gdb, reporterUser(42), up)
+
+	expUploadSessionRow(mock, 42, models.UploadStatusPending, time.Now().Add(10*time.Minute))
+	expUserRow(mock, 42)
+	mock.ExpectBegin()
+	expUploadClaimed(mock, false)
+	mock.ExpectRollback()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/uploads/7/confirm"})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if len(up.removed) != 1 || up.removed[0] != up.lastObject {
+		t.Fatalf("only the unused copy may be removed, got %v", up.removed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestConfirmUploadSession_NothingUploaded(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, reporterUser(42), &fakeUploader{})
+
+	expUploadSessionRow(mock, 42, models.UploadStatusPending, time.Now().Add(10*time.Minute))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/uploads/7/confirm"})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403
+func TestConfirmUploadSession_SomeoneElses(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, reporterUser(50), &fakeUploader{})
+
+	expUploadSessionRow(mock, 42, models.UploadStatusPending, time.Now().Add(10*time.Minute))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/uploads/7/confirm"})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 410
+func TestConfirmUploadSession_Expired(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, reporterUser(42), &fakeUploader{})
+
+	expUploadSessionRow(mock, 42, models.UploadStatusPending, time.Now().Add(-2*time.Hour))
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/uploads/7/confirm"})
+	wantStatus(t, resp, http.StatusGone)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403 (an admin whose token skipped the second factor gets no override)
+func TestCreateUploadSession_AdminWithoutMFA(t *testing.T) {
+	inProduction(t)
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, adminUser(7), &fakeUploader{})
+
+	expAuthUserRows(mock, 7, true, false, false)
+	expUserRow(mock, 42)
+
+	uID := uint(7)
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/uploads",
+		Body:        jsonBody(map[string]any{"purpose": "profile_picture", "target_id": 42, "content_type": "image/png"}),
+		ContentType: "application/json",
+		UserID:      &uID,
+	})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ Session asset uploads ------------------ */
+
+// expSessionAssetUploadRow expects the lookup of upload session 7, a
+// session_asset upload for class session 3.
+func expSessionAssetUploadRow(mock sqlmock.Sqlmock, userID uint) {
+	mock.ExpectQuery(`SELECT \* FROM "upload_sessions" WHERE "upload_sessions"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "purpose", "target_id", "content_type", "title", "max_bytes", "object_key", "status", "expires_at"}).
+			AddRow(7, userID, models.UploadPurposeSessionAsset, 3, "application/pdf", "Week 3 slides", int64(2<<30), "uploads/1.pdf", models.UploadStatusPending, time.Now().Add(10*time.Minute)))
+}
+
+// expUploadTargetSession expects class session 3 to be loaded by ID with its
+// class, taught by teacherID.
+func expUploadTargetSession(mock sqlmock.Sqlmock, teacherID uint) {
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE "class_sessions"\."id" = \$1`).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_id", "class_finish"}).AddRow(3, 12, time.Now().Add(-time.Hour)))
+	mock.ExpectQuery(`SELECT \* FROM "classes" WHERE "classes"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id"}).AddRow(12, teacherID))
+}
+
+// 201
+func TestCreateUploadSession_SessionAsset(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, sessionTeacherUser(5, 30), &fakeUploader{})
+
+	expUploadTargetSession(mock, 30)
+	ExpInsertReturningID("upload_sessions", 7)(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/uploads",
+		Body:        jsonBody(map[string]any{"purpose": "session_asset", "target_id": 3, "content_type": "video/mp4", "kind": "recording", "file_name": "../week3.mp4"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	var upload models.UploadSession
+	if err := json.Unmarshal(readBody(t, resp.Body), &upload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if key := upload.PostFields["key"]; !strings.HasPrefix(key, "uploads/") || !strings.HasSuffix(key, ".mp4") {
+		t.Fatalf("uploads must go to a staging key, got %q", key)
+	}
+	if upload.MaxBytes != 2<<30 || upload.Kind != models.SessionAssetRecording || upload.FileName != "week3.mp4" {
+		t.Fatalf("unexpected upload session %+v", upload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 403 (an admin cannot spend a teacher's quota)
+func TestCreateUploadSession_SessionAssetNotTeacher(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, adminUser(1), &fakeUploader{})
+
+	expUploadTargetSession(mock, 30)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/uploads",
+		Body:        jsonBody(map[string]any{"purpose": "session_asset", "target_id": 3, "content_type": "video/mp4"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusForbidden)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200
+func TestConfirmUploadSession_CreatesSessionAsset(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{objects: map[string][]byte{"uploads/1.pdf": tinyPDF}}
+	app := setupAppWithUploader(gdb, sessionTeacherUser(5, 30), up)
+
+	expSessionAssetUploadRow(mock, 5)
+	expUploadTargetSession(mock, 30)
+	mock.ExpectBegin()
+	expUploadClaimed(mock, true)
+	expUploadTargetSession(mock, 30)
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "teachers" WHERE "teachers"\."id" = \$1 .* FOR UPDATE`).
+		WithArgs(30, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(30, 5))
+	expStoragePolicy(mock, 0)
+	expStorageUsed(mock, 30, 0)
+	mock.ExpectQuery(`INSERT INTO "session_assets"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 3, 30, models.SessionAssetSlides, "Week 3 slides", "", "application/pdf", int64(len(tinyPDF)), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/uploads/7/confirm"})
+	wantStatus(t, resp, http.StatusOK)
+
+	var upload models.UploadSession
+	if err := json.Unmarshal(readBody(t, resp.Body), &upload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if upload.Status != models.UploadStatusConfirmed || upload.Kind != models.SessionAssetSlides {
+		t.Fatalf("unexpected upload session %+v", upload)
+	}
+	if !strings.HasPrefix(up.lastObject, "sessions/3/assets/") {
+		t.Fatalf("asset must be copied under its session, got %q", up.lastObject)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409 (the upload does not fit in the teacher's quota)
+func TestConfirmUploadSession_SessionAssetOverQuota(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	up := &fakeUploader{objects: map[string][]byte{"uploads/1.pdf": tinyPDF}}
+	app := setupAppWithUploader(gdb, sessionTeacherUser(5, 30), up)
+
+	expSessionAssetUploadRow(mock, 5)
+	expUploadTargetSession(mock, 30)
+	mock.ExpectBegin()
+	expUploadClaimed(mock, true)
+	expUploadTargetSession(mock, 30)
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "teachers" WHERE "teachers"\."id" = \$1 .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(30, 5))
+	expStoragePolicy(mock, 1000)
+	expStorageUsed(mock, 30, 990)
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	expUploadRejected(mock)
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/uploads/7/confirm"})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if len(up.removed) != 2 || up.removed[0] != up.lastObject || up.removed[1] != "uploads/1.pdf" {
+		t.Fatalf("the copy and the staged object over quota must be removed, got %v", up.removed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409 (the report is already with a moderator)
+func TestCreateUploadSession_ReportPictureAfterTriage(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, reporterUser(42), &fakeUploader{})
+
+	mock.ExpectQuery(`SELECT \* FROM "reports" WHERE "reports"\."id" = \$1`).
+		WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "report_user_id", "report_status"}).AddRow(9, 42, models.ReportStatusTriaged))
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/uploads",
+		Body:        jsonBody(map[string]any{"purpose": "report_picture", "target_id": 9, "content_type": "image/png"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ Report attachment uploads ------------------ */
+
+// expUploadTargetReport expects open report 9, filed by user 42, to be
+// loaded and its attachments counted.
+func expUploadTargetReport(mock sqlmock.Sqlmock, attachments int) {
+	mock.ExpectQuery(`SELECT \* FROM "reports" WHERE "reports"\."id" = \$1`).
+		WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "report_user_id", "report_status"}).AddRow(9, 42, models.ReportStatusTriaged))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "report_attachments" WHERE report_id = \$1`).
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(attachments))
+}
+
+// 201 (evidence too large for a multipart request)
+func TestCreateUploadSession_ReportAttachment(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, reporterUser(42), &fakeUploader{})
+
+	expUploadTargetReport(mock, 2)
+	ExpInsertReturningID("upload_sessions", 7)(mock)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/uploads",
+		Body:        jsonBody(map[string]any{"purpose": "report_attachment", "target_id": 9, "content_type": "video/mp4", "file_name": "call.mp4"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusCreated)
+
+	var upload models.UploadSession
+	if err := json.Unmarshal(readBody(t, resp.Body), &upload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if upload.MaxBytes != 50<<20 || upload.FileName != "call.mp4" {
+		t.Fatalf("unexpected upload session %+v", upload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 409
+func TestCreateUploadSession_ReportAttachmentLimit(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupAppWithUploader(gdb, reporterUser(42), &fakeUploader{})
+
+	expUploadTargetReport(mock, 10)
+
+	resp := runHTTP(t, app, httpInput{
+		Method:      http.MethodPost,
+		Path:        "/uploads",
+		Body:        jsonBody(map[string]any{"purpose": "report_attachment", "target_id": 9, "content_type": "video/mp4"}),
+		ContentType: "application/json",
+	})
+	wantStatus(t, resp, http.StatusConflict)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// 200
+func TestConfirmUploadSession_CreatesReportAttachment(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	png, _ := base64.StdEncoding.DecodeString(tinyPNGRawBase64)
+	up := &fakeUploader{objects: map[string][]byte{"uploads/1.png": png}}
+	app := setupAppWithUploader(gdb, reporterUser(42), up)
+
+	mock.ExpectQuery(`SELECT \* FROM "upload_sessions" WHERE "upload_sessions"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "purpose", "target_id", "content_type", "file_name", "max_bytes", "object_key", "status", "expires_at"}).
+			AddRow(7, 42, models.UploadPurposeReportAttachment, 9, "image/png", "chat.png", int64(50<<20), "uploads/1.png", models.UploadStatusPending, time.Now().Add(10*time.Minute)))
+	expUploadTargetReport(mock, 2)
+	mock.ExpectBegin()
+	expUploadClaimed(mock, true)
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "reports" WHERE "reports"\."id" = \$1 .*FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "report_user_id", "report_status"}).AddRow(9, 42, models.ReportStatusTriaged))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "report_attachments" WHERE report_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`INSERT INTO "report_attachments"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 9, 42, "image", "chat.png", "image/png", int64(len(png)), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	resp := runHTTP(t, app, httpInput{Method: http.MethodPost, Path: "/uploads/7/confirm"})
+	wantStatus(t, resp, http.StatusOK)
+
+	if !strings.HasPrefix(up.lastObject, "reports/attachments/") {
+		t.Fatalf("evidence must be copied under reports/attachments, got %q", up.lastObject)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
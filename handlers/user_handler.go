@@ -9,6 +9,7 @@ import (
 
 	"github.com/a2n2k3p4/tutorium-backend/middlewares"
 	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
 	"github.com/a2n2k3p4/tutorium-backend/storage"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -46,6 +47,10 @@ func CreateUser(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	if err := services.ValidateTimezone(user.Timezone); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
 	if err := processProfilePicture(c, &user); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
@@ -223,6 +228,10 @@ func UpdateUser(c *fiber.Ctx) error {
 		return c.Status(400).JSON(err.Error())
 	}
 
+	if err := services.ValidateTimezone(user_update.Timezone); err != nil {
+		return c.Status(400).JSON(err.Error())
+	}
+
 	if err := processProfilePicture(c, &user_update); err != nil {
 		return c.Status(400).JSON(err.Error())
 	}
@@ -7,6 +7,7 @@ import (
 	// store functions related to connecting to PostgreSQL
 	"github.com/a2n2k3p4/tutorium-backend/config"
 	"github.com/a2n2k3p4/tutorium-backend/handlers"
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
 	"github.com/a2n2k3p4/tutorium-backend/middlewares"
 	"github.com/a2n2k3p4/tutorium-backend/models"
 	"github.com/a2n2k3p4/tutorium-backend/services"
@@ -41,6 +42,11 @@ import (
 //	@name						Authorization
 //	@description				Type "Bearer " followed by your JWT token.`
 
+//	@securityDefinitions.apikey	ApiKeyAuth
+//	@in							header
+//	@name						X-API-Key
+//	@description				Scoped API key for service-to-service integrations.
+
 func main() {
 	cfg := config.NewConfig()
 
@@ -59,13 +65,28 @@ func main() {
 	app.Use(cors.New())
 
 	// --- MinIO ---
+	var assetStore storage.ObjectStore
 	minioClient, err := storage.NewClientFromEnv()
 	if err != nil {
 		// Do not crash the app if MinIO is not available in this environment.
 		log.Printf("MinIO init failed: %v (continuing without storage middleware)", err)
 	} else {
 		app.Use(middlewares.MinioMiddleware(minioClient))
+		assetStore = minioClient
+	}
+
+	// --- Meetings ---
+	meetingProvider, err := meeting.NewProviderFromEnv()
+	if err != nil {
+		// Sessions store the provider that hosts them, so a fake room outlives
+		// the misconfiguration; only development may run without a provider.
+		if config.STATUS() != "development" {
+			log.Fatalf("Meeting provider init failed: %v", err)
+		}
+		log.Printf("Meeting provider init failed: %v (falling back to the in-memory fake)", err)
+		meetingProvider = meeting.NewFake("")
 	}
+	app.Use(middlewares.MeetingMiddleware(meetingProvider))
 
 	// --- Omise (Payments) ---
 	if pk, sk := config.OMISEPublicKey(), config.OMISESecretKey(); pk != "" && sk != "" {
@@ -101,7 +122,7 @@ func main() {
 	}))
 
 	// Check all class sessions every 5 minutes
-	go services.StartScheduler(db)
+	go services.StartScheduler(db, assetStore)
 
 	handlers.AllRoutes(app) // Register admin routes
 	// Define the /users route and handler inline
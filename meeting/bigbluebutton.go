@@ -0,0 +1,235 @@
+package meeting
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BigBlueButton hosts rooms on a BigBlueButton server, whose API calls are
+// signed with a checksum of the call name, query string and shared secret.
+//
+// BigBlueButton drops meetings nobody joins within minutes of creation, so
+// CreateRoom only reserves a meeting ID and JoinURL creates the meeting
+// (create is idempotent for an existing ID) right before each join.
+//
+// With Webhooks set, the server's bbb-webhooks service is expected to post
+// events here; they are signed like API calls with the shared secret.
+type BigBlueButton struct {
+	BaseURL  string // e.g. https://bbb.example.com/bigbluebutton/
+	Secret   string
+	Webhooks bool
+	HTTP     *http.Client
+}
+
+type bbbResponse struct {
+	ReturnCode string `xml:"returncode"`
+	MessageKey string `xml:"messageKey"`
+	Message    string `xml:"message"`
+	Attendees  []struct {
+		UserID   string `xml:"userID"`
+		FullName string `xml:"fullName"`
+		Role     string `xml:"role"`
+	} `xml:"attendees>attendee"`
+}
+
+func (b *BigBlueButton) Name() string { return ProviderBigBlueButton }
+
+// apiURL builds a signed API URL for call.
+func (b *BigBlueButton) apiURL(call string, params url.Values) string {
+	query := params.Encode()
+	sum := sha256.Sum256([]byte(call + query + b.Secret))
+	base := strings.TrimRight(b.BaseURL, "/") + "/api/" + call + "?"
+	if query != "" {
+		base += query + "&"
+	}
+	return base + "checksum=" + hex.EncodeToString(sum[:])
+}
+
+// call performs an API call and decodes its XML response. Failures whose
+// message key is in tolerated are not errors.
+func (b *BigBlueButton) call(ctx context.Context, name string, params url.Values, tolerated ...string) (*bbbResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.apiURL(name, params), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bigbluebutton %s: %s", name, resp.Status)
+	}
+
+	var out bbbResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if out.ReturnCode != "SUCCESS" {
+		for _, key := range tolerated {
+			if out.MessageKey == key {
+				return &out, nil
+			}
+		}
+		return nil, fmt.Errorf("bigbluebutton %s: %s (%s)", name, out.Message, out.MessageKey)
+	}
+	return &out, nil
+}
+
+func (b *BigBlueButton) CreateRoom(ctx context.Context, spec RoomSpec) (Room, error) {
+	id, err := newRoomID()
+	if err != nil {
+		return Room{}, err
+	}
+	return Room{Provider: ProviderBigBlueButton, ID: id}, nil
+}
+
+// JoinURL creates the meeting if it is not running yet and returns a signed
+// join link that puts the participant in with the moderator or viewer role.
+func (b *BigBlueButton) JoinURL(ctx context.Context, req JoinRequest) (Join, error) {
+	title := req.Title
+	if title == "" {
+		title = "Tutorium class"
+	}
+	if _, err := b.call(ctx, "create", url.Values{"meetingID": {req.RoomID}, "name": {title}}, "idNotUnique"); err != nil {
+		return Join{}, err
+	}
+
+	p := req.Participant
+	role := "VIEWER"
+	if p.Moderator {
+		role = "MODERATOR"
+	}
+	params := url.Values{
+		"meetingID": {req.RoomID},
+		"fullName":  {p.Name},
+		"role":      {role},
+		"userID":    {fmt.Sprint(p.UserID)},
+		"redirect":  {"true"},
+	}
+	if p.AvatarURL != "" {
+		params.Set("avatarURL", p.AvatarURL)
+	}
+	return Join{URL: b.apiURL("join", params)}, nil
+}
+
+func (b *BigBlueButton) EndMeeting(ctx context.Context, roomID string) error {
+	_, err := b.call(ctx, "end", url.Values{"meetingID": {roomID}}, "notFound")
+	return err
+}
+
+func (b *BigBlueButton) Participants(ctx context.Context, roomID string) ([]Attendee, error) {
+	out, err := b.call(ctx, "getMeetingInfo", url.Values{"meetingID": {roomID}}, "notFound")
+	if err != nil {
+		return nil, err
+	}
+	attendees := make([]Attendee, 0, len(out.Attendees))
+	for _, a := range out.Attendees {
+		attendees = append(attendees, Attendee{ID: a.UserID, Name: a.FullName, Moderator: a.Role == "MODERATOR"})
+	}
+	return attendees, nil
+}
+
+// bbbEvent is one bbb-webhooks event. The external user ID is the userID
+// JoinURL put in the participant's link.
+type bbbEvent struct {
+	Data struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Meeting struct {
+				ExternalMeetingID string `json:"external-meeting-id"`
+			} `json:"meeting"`
+			User struct {
+				ExternalUserID string `json:"external-user-id"`
+			} `json:"user"`
+		} `json:"attributes"`
+		Event struct {
+			TS int64 `json:"ts"`
+		} `json:"event"`
+	} `json:"data"`
+}
+
+func (b *BigBlueButton) ReceivesWebhooks() bool { return b.Webhooks }
+
+// ParseWebhook checks the checksum query parameter, a SHA-1 or SHA-256 of
+// the callback URL without it, the raw body and the shared secret. The body
+// is a JSON array of events, or a form whose "event" field holds one.
+func (b *BigBlueButton) ParseWebhook(hook Webhook) ([]PresenceEvent, error) {
+	u, err := url.Parse(hook.URL)
+	if err != nil {
+		return nil, ErrInvalidWebhook
+	}
+	// Drop the checksum without re-encoding the rest of the query, which
+	// the signature covers byte for byte.
+	var checksum string
+	var kept []string
+	for _, part := range strings.Split(u.RawQuery, "&") {
+		if v, ok := strings.CutPrefix(part, "checksum="); ok {
+			checksum = v
+		} else if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	u.RawQuery = strings.Join(kept, "&")
+	if !b.validWebhookChecksum(u.String(), hook.Body, checksum) {
+		return nil, ErrWebhookUnauthorized
+	}
+
+	payload := hook.Body
+	if strings.HasPrefix(hook.ContentType, "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(hook.Body))
+		if err != nil {
+			return nil, ErrInvalidWebhook
+		}
+		payload = []byte(form.Get("event"))
+	}
+	var events []bbbEvent
+	if err := json.Unmarshal(payload, &events); err != nil {
+		return nil, ErrInvalidWebhook
+	}
+
+	now := time.Now()
+	var out []PresenceEvent
+	for _, ev := range events {
+		d := ev.Data
+		e := PresenceEvent{RoomID: d.Attributes.Meeting.ExternalMeetingID, UserID: d.Attributes.User.ExternalUserID, At: unixTime(d.Event.TS, now)}
+		switch d.ID {
+		case "user-joined":
+			e.Kind = EventJoin
+		case "user-left":
+			e.Kind = EventLeave
+		case "meeting-ended":
+			e.Kind, e.UserID = EventEnd, ""
+		default:
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func (b *BigBlueButton) validWebhookChecksum(callbackURL string, body []byte, checksum string) bool {
+	if b.Secret == "" || checksum == "" {
+		return false
+	}
+	data := callbackURL + string(body) + b.Secret
+	var want string
+	if len(checksum) == sha1.Size*2 {
+		sum := sha1.Sum([]byte(data))
+		want = hex.EncodeToString(sum[:])
+	} else {
+		sum := sha256.Sum256([]byte(data))
+		want = hex.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.ToLower(checksum)), []byte(want)) == 1
+}
@@ -0,0 +1,139 @@
+package meeting
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Checksums below were computed independently with sha1sum/sha256sum over
+// the strings the BigBlueButton API documentation describes.
+
+func TestBigBlueButtonAPIURL(t *testing.T) {
+	b := &BigBlueButton{BaseURL: "https://bbb.example.com/bigbluebutton/", Secret: "s3cret"}
+
+	cases := []struct {
+		name   string
+		call   string
+		params url.Values
+		want   string
+	}{
+		{
+			"create",
+			"create",
+			url.Values{"meetingID": {"room-1"}, "name": {"Tutorium class"}},
+			"https://bbb.example.com/bigbluebutton/api/create?meetingID=room-1&name=Tutorium+class&checksum=3425e2aca3c0aab94da57020c1c178671a029793e8d42c4f2f33672164fe2003",
+		},
+		{
+			"join",
+			"join",
+			url.Values{"meetingID": {"room-1"}, "fullName": {"Ann Lee"}, "role": {"MODERATOR"}, "userID": {"7"}, "redirect": {"true"}},
+			"https://bbb.example.com/bigbluebutton/api/join?fullName=Ann+Lee&meetingID=room-1&redirect=true&role=MODERATOR&userID=7&checksum=abec660da1a0a3d92c788c2df3303873fe08d2d46114da2e1379d80a12c97f69",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := b.apiURL(tc.call, tc.params); got != tc.want {
+				t.Fatalf("apiURL =\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBigBlueButtonJoinURL(t *testing.T) {
+	var created url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bigbluebutton/api/create" {
+			t.Errorf("unexpected call %s", r.URL.Path)
+		}
+		created = r.URL.Query()
+		// An already running meeting is not an error.
+		w.Write([]byte(`<response><returncode>FAILED</returncode><messageKey>idNotUnique</messageKey></response>`))
+	}))
+	defer srv.Close()
+
+	b := &BigBlueButton{BaseURL: srv.URL + "/bigbluebutton", Secret: "s3cret", HTTP: srv.Client()}
+	join, err := b.JoinURL(context.Background(), JoinRequest{
+		RoomID:      "room-1",
+		Participant: Participant{UserID: 7, Name: "Ann Lee", Moderator: true},
+	})
+	if err != nil {
+		t.Fatalf("JoinURL: %v", err)
+	}
+	if got := created.Get("checksum"); got != "3425e2aca3c0aab94da57020c1c178671a029793e8d42c4f2f33672164fe2003" {
+		t.Fatalf("create checksum = %s", got)
+	}
+	want := srv.URL + "/bigbluebutton/api/join?fullName=Ann+Lee&meetingID=room-1&redirect=true&role=MODERATOR&userID=7&checksum=abec660da1a0a3d92c788c2df3303873fe08d2d46114da2e1379d80a12c97f69"
+	if join.URL != want {
+		t.Fatalf("join URL =\n%s\nwant\n%s", join.URL, want)
+	}
+}
+
+const bbbWebhookBody = `[{"data":{"id":"user-joined","attributes":{"meeting":{"external-meeting-id":"room-1"},"user":{"external-user-id":"7"}},"event":{"ts":1700000000000}}},{"data":{"id":"meeting-ended","attributes":{"meeting":{"external-meeting-id":"room-1"}},"event":{"ts":1700000600}}},{"data":{"id":"chat-group-message-sent"}}]`
+
+const (
+	bbbWebhookSHA1   = "503e5df652ed4974487b7dbb4e83bcb2593dcbce"
+	bbbWebhookSHA256 = "bb9721c77e771e4742e30687e8ebcc6f1460e5f9595aae4d2cf2e3445a97b01d"
+)
+
+func TestBigBlueButtonParseWebhook(t *testing.T) {
+	b := &BigBlueButton{Secret: "s3cret", Webhooks: true}
+
+	cases := []struct {
+		name string
+		url  string
+	}{
+		{"sha1", "https://api.example.com/webhooks/meetings?source=bbb&checksum=" + bbbWebhookSHA1},
+		{"sha256", "https://api.example.com/webhooks/meetings?source=bbb&checksum=" + bbbWebhookSHA256},
+		{"checksum first", "https://api.example.com/webhooks/meetings?checksum=" + bbbWebhookSHA256 + "&source=bbb"},
+		{"upper case checksum", "https://api.example.com/webhooks/meetings?source=bbb&checksum=503E5DF652ED4974487B7DBB4E83BCB2593DCBCE"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			events, err := b.ParseWebhook(Webhook{URL: tc.url, ContentType: "application/json", Body: []byte(bbbWebhookBody)})
+			if err != nil {
+				t.Fatalf("ParseWebhook: %v", err)
+			}
+			want := []PresenceEvent{
+				{Kind: EventJoin, RoomID: "room-1", UserID: "7", At: time.UnixMilli(1700000000000).UTC()},
+				{Kind: EventEnd, RoomID: "room-1", At: time.Unix(1700000600, 0).UTC()},
+			}
+			if len(events) != len(want) {
+				t.Fatalf("events = %+v, want %+v", events, want)
+			}
+			for i := range want {
+				if events[i] != want[i] {
+					t.Errorf("event %d = %+v, want %+v", i, events[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBigBlueButtonParseWebhook_Rejected(t *testing.T) {
+	cases := []struct {
+		name   string
+		secret string
+		url    string
+		body   string
+	}{
+		{"no checksum", "s3cret", "https://api.example.com/webhooks/meetings?source=bbb", bbbWebhookBody},
+		{"wrong secret", "other", "https://api.example.com/webhooks/meetings?source=bbb&checksum=" + bbbWebhookSHA256, bbbWebhookBody},
+		{"no secret configured", "", "https://api.example.com/webhooks/meetings?source=bbb&checksum=" + bbbWebhookSHA256, bbbWebhookBody},
+		{"tampered body", "s3cret", "https://api.example.com/webhooks/meetings?source=bbb&checksum=" + bbbWebhookSHA1, bbbWebhookBody + " "},
+		{"other callback URL", "s3cret", "https://evil.example.com/webhooks/meetings?source=bbb&checksum=" + bbbWebhookSHA1, bbbWebhookBody},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &BigBlueButton{Secret: tc.secret, Webhooks: true}
+			_, err := b.ParseWebhook(Webhook{URL: tc.url, ContentType: "application/json", Body: []byte(tc.body)})
+			if !errors.Is(err, ErrWebhookUnauthorized) {
+				t.Fatalf("err = %v, want ErrWebhookUnauthorized", err)
+			}
+		})
+	}
+}
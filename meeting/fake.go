@@ -0,0 +1,127 @@
+package meeting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fake keeps rooms in memory for tests and local development. Joining adds
+// the participant to the room roster and ending a room empties it.
+//
+// With WebhookSecret set it accepts presence events posted as JSON, e.g.
+// {"event":"join","room":"room-1","user_id":"7","at":"2025-09-05T14:00:00Z"},
+// with an "Authorization: Bearer <WebhookSecret>" header.
+type Fake struct {
+	BaseURL       string
+	WebhookSecret string
+
+	mu    sync.Mutex
+	next  int
+	rooms map[string]*fakeRoom
+}
+
+type fakeRoom struct {
+	attendees []Attendee
+	ended     bool
+}
+
+// NewFake returns an empty fake serving links under baseURL
+// (default https://meet.invalid).
+func NewFake(baseURL string) *Fake {
+	if baseURL == "" {
+		baseURL = "https://meet.invalid"
+	}
+	return &Fake{BaseURL: baseURL, rooms: map[string]*fakeRoom{}}
+}
+
+func (f *Fake) Name() string { return ProviderFake }
+
+func (f *Fake) CreateRoom(ctx context.Context, spec RoomSpec) (Room, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	id := fmt.Sprintf("room-%d", f.next)
+	f.rooms[id] = &fakeRoom{}
+	return Room{Provider: ProviderFake, ID: id, URL: strings.TrimRight(f.BaseURL, "/") + "/" + id}, nil
+}
+
+// room returns id's room, adding it when this fake did not create it, as
+// after a restart or in tests that load sessions from fixtures.
+func (f *Fake) room(id string) *fakeRoom {
+	r, ok := f.rooms[id]
+	if !ok {
+		r = &fakeRoom{}
+		f.rooms[id] = r
+	}
+	return r
+}
+
+func (f *Fake) JoinURL(ctx context.Context, req JoinRequest) (Join, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := f.room(req.RoomID)
+	r.ended = false
+	p := req.Participant
+	id := fmt.Sprint(p.UserID)
+	present := false
+	for _, a := range r.attendees {
+		present = present || a.ID == id
+	}
+	if !present {
+		r.attendees = append(r.attendees, Attendee{ID: id, Name: p.Name, Moderator: p.Moderator})
+	}
+	return Join{URL: fmt.Sprintf("%s/%s?user=%d", strings.TrimRight(f.BaseURL, "/"), req.RoomID, p.UserID)}, nil
+}
+
+func (f *Fake) EndMeeting(ctx context.Context, roomID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := f.room(roomID)
+	r.ended = true
+	r.attendees = nil
+	return nil
+}
+
+func (f *Fake) Participants(ctx context.Context, roomID string) ([]Attendee, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Attendee{}, f.room(roomID).attendees...), nil
+}
+
+// Ended reports whether EndMeeting closed roomID.
+func (f *Fake) Ended(roomID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, ok := f.rooms[roomID]
+	return ok && r.ended
+}
+
+func (f *Fake) ReceivesWebhooks() bool { return f.WebhookSecret != "" }
+
+func (f *Fake) ParseWebhook(hook Webhook) ([]PresenceEvent, error) {
+	if !bearerMatches(hook.Authorization, f.WebhookSecret) {
+		return nil, ErrWebhookUnauthorized
+	}
+	var ev struct {
+		Event  string    `json:"event"`
+		Room   string    `json:"room"`
+		UserID string    `json:"user_id"`
+		At     time.Time `json:"at"`
+	}
+	if err := json.Unmarshal(hook.Body, &ev); err != nil {
+		return nil, ErrInvalidWebhook
+	}
+	switch ev.Event {
+	case EventJoin, EventLeave, EventEnd:
+	default:
+		return nil, nil
+	}
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	return []PresenceEvent{{Kind: ev.Event, RoomID: ev.Room, UserID: ev.UserID, At: ev.At}}, nil
+}
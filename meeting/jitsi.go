@@ -0,0 +1,162 @@
+package meeting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Jitsi hosts rooms on a Jitsi Meet server using token authentication, so a
+// room link is useless without the participant's JWT signed with Secret.
+//
+// With WebhookSecret set, Prosody's mod_event_sync_component is expected to
+// post occupant events with an "Authorization: Bearer <WebhookSecret>" header.
+type Jitsi struct {
+	BaseURL       string
+	AppID         string
+	Secret        string
+	WebhookSecret string
+	HTTP          *http.Client
+}
+
+// errJitsiNoSecret stops JoinURL from handing out bare room links, which
+// would let anyone who learns a room name in.
+var errJitsiNoSecret = errors.New("jitsi: no app secret configured to sign join tokens")
+
+func (j *Jitsi) Name() string { return ProviderJitsi }
+
+func (j *Jitsi) CreateRoom(ctx context.Context, spec RoomSpec) (Room, error) {
+	id, err := newRoomID()
+	if err != nil {
+		return Room{}, err
+	}
+	return Room{Provider: ProviderJitsi, ID: id, URL: strings.TrimRight(j.BaseURL, "/") + "/" + id}, nil
+}
+
+// JoinURL signs a token that lets the participant into this room only, under
+// their display name and avatar, between NotBefore and Expires. Moderators
+// can mute, kick and end the meeting.
+func (j *Jitsi) JoinURL(ctx context.Context, req JoinRequest) (Join, error) {
+	if j.Secret == "" {
+		return Join{}, errJitsiNoSecret
+	}
+	link := strings.TrimRight(j.BaseURL, "/") + "/" + req.RoomID
+
+	domain := "*"
+	if u, err := url.Parse(j.BaseURL); err == nil && u.Host != "" {
+		domain = u.Host
+	}
+	p := req.Participant
+	claims := jwt.MapClaims{
+		"iss":       j.AppID,
+		"aud":       "jitsi",
+		"sub":       domain,
+		"room":      req.RoomID,
+		"nbf":       req.NotBefore.Unix(),
+		"exp":       req.Expires.Unix(),
+		"moderator": p.Moderator,
+		"context": map[string]interface{}{
+			"user": map[string]interface{}{
+				"id":        fmt.Sprint(p.UserID),
+				"name":      p.Name,
+				"avatar":    p.AvatarURL,
+				"moderator": p.Moderator,
+			},
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(j.Secret))
+	if err != nil {
+		return Join{}, err
+	}
+	exp := req.Expires
+	return Join{URL: link + "?jwt=" + token, Token: token, ExpiresAt: &exp}, nil
+}
+
+// EndMeeting is not available: Jitsi has no server API for it. The teacher
+// ends the meeting from the moderator controls and tokens expire anyway.
+func (j *Jitsi) EndMeeting(ctx context.Context, roomID string) error {
+	return ErrUnsupported
+}
+
+// Participants reads the room roster from Prosody's mod_muc_size endpoint.
+func (j *Jitsi) Participants(ctx context.Context, roomID string) ([]Attendee, error) {
+	base, err := url.Parse(j.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	base.Path = path.Join(base.Path, "room")
+	base.RawQuery = url.Values{"room": {roomID}, "domain": {base.Host}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return []Attendee{}, nil // nobody has opened the room
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("jitsi room roster: %s", resp.Status)
+	}
+
+	var occupants []struct {
+		JID         string `json:"jid"`
+		DisplayName string `json:"display_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&occupants); err != nil {
+		return nil, err
+	}
+	attendees := make([]Attendee, 0, len(occupants))
+	for _, o := range occupants {
+		attendees = append(attendees, Attendee{ID: o.JID, Name: o.DisplayName})
+	}
+	return attendees, nil
+}
+
+// jitsiEvent is a mod_event_sync_component payload. The occupant ID is the
+// context.user.id of the token the occupant joined with.
+type jitsiEvent struct {
+	EventName   string `json:"event_name"`
+	RoomName    string `json:"room_name"`
+	DestroyedAt int64  `json:"destroyed_at"`
+	Occupant    struct {
+		ID       string `json:"id"`
+		JoinedAt int64  `json:"joined_at"`
+		LeftAt   int64  `json:"left_at"`
+	} `json:"occupant"`
+}
+
+func (j *Jitsi) ReceivesWebhooks() bool { return j.WebhookSecret != "" }
+
+func (j *Jitsi) ParseWebhook(hook Webhook) ([]PresenceEvent, error) {
+	if !bearerMatches(hook.Authorization, j.WebhookSecret) {
+		return nil, ErrWebhookUnauthorized
+	}
+	var ev jitsiEvent
+	if err := json.Unmarshal(hook.Body, &ev); err != nil {
+		return nil, ErrInvalidWebhook
+	}
+	room, _, _ := strings.Cut(ev.RoomName, "@")
+	now := time.Now()
+	switch ev.EventName {
+	case "muc-occupant-joined":
+		return []PresenceEvent{{Kind: EventJoin, RoomID: room, UserID: ev.Occupant.ID, At: unixTime(ev.Occupant.JoinedAt, now)}}, nil
+	case "muc-occupant-left":
+		return []PresenceEvent{{Kind: EventLeave, RoomID: room, UserID: ev.Occupant.ID, At: unixTime(ev.Occupant.LeftAt, now)}}, nil
+	case "muc-room-destroyed":
+		return []PresenceEvent{{Kind: EventEnd, RoomID: room, At: unixTime(ev.DestroyedAt, now)}}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,87 @@
+package meeting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJitsiParseWebhook(t *testing.T) {
+	j := &Jitsi{WebhookSecret: "hook-secret"}
+
+	cases := []struct {
+		name string
+		body string
+		want []PresenceEvent
+	}{
+		{
+			"joined",
+			`{"event_name":"muc-occupant-joined","room_name":"room-1@conference.meet.example.com","occupant":{"id":"7","joined_at":1700000000}}`,
+			[]PresenceEvent{{Kind: EventJoin, RoomID: "room-1", UserID: "7", At: time.Unix(1700000000, 0).UTC()}},
+		},
+		{
+			"left in milliseconds",
+			`{"event_name":"muc-occupant-left","room_name":"room-1@conference.meet.example.com","occupant":{"id":"7","left_at":1700000600000}}`,
+			[]PresenceEvent{{Kind: EventLeave, RoomID: "room-1", UserID: "7", At: time.UnixMilli(1700000600000).UTC()}},
+		},
+		{
+			"room destroyed",
+			`{"event_name":"muc-room-destroyed","room_name":"room-1","destroyed_at":1700003600}`,
+			[]PresenceEvent{{Kind: EventEnd, RoomID: "room-1", At: time.Unix(1700003600, 0).UTC()}},
+		},
+		{
+			"other event",
+			`{"event_name":"muc-room-created","room_name":"room-1"}`,
+			nil,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			events, err := j.ParseWebhook(Webhook{Authorization: "Bearer hook-secret", Body: []byte(tc.body)})
+			if err != nil {
+				t.Fatalf("ParseWebhook: %v", err)
+			}
+			if len(events) != len(tc.want) {
+				t.Fatalf("events = %+v, want %+v", events, tc.want)
+			}
+			for i := range tc.want {
+				if events[i] != tc.want[i] {
+					t.Errorf("event %d = %+v, want %+v", i, events[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestJitsiParseWebhook_Rejected(t *testing.T) {
+	body := []byte(`{"event_name":"muc-occupant-joined","room_name":"room-1","occupant":{"id":"7"}}`)
+
+	cases := []struct {
+		name   string
+		secret string
+		auth   string
+		body   []byte
+		want   error
+	}{
+		{"wrong token", "hook-secret", "Bearer nope", body, ErrWebhookUnauthorized},
+		{"not bearer", "hook-secret", "hook-secret", body, ErrWebhookUnauthorized},
+		{"no secret configured", "", "Bearer ", body, ErrWebhookUnauthorized},
+		{"malformed body", "hook-secret", "Bearer hook-secret", []byte(`{`), ErrInvalidWebhook},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			j := &Jitsi{WebhookSecret: tc.secret}
+			if _, err := j.ParseWebhook(Webhook{Authorization: tc.auth, Body: tc.body}); !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestJitsiJoinURL_RequiresSecret(t *testing.T) {
+	j := &Jitsi{BaseURL: "https://meet.example.com"}
+	if join, err := j.JoinURL(context.Background(), JoinRequest{RoomID: "room"}); err == nil {
+		t.Fatalf("expected an error without a secret, got %+v", join)
+	}
+}
@@ -0,0 +1,136 @@
+// Package meeting hosts class sessions on a video conferencing service. The
+// service is chosen with MEETING_PROVIDER so schools can bring their own.
+package meeting
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/config"
+	"github.com/google/uuid"
+)
+
+// Provider names stored on ClassSession.MeetingProvider.
+const (
+	ProviderJitsi         = "jitsi"
+	ProviderBigBlueButton = "bigbluebutton"
+	ProviderFake          = "fake"
+)
+
+// ErrUnsupported is returned by providers that cannot perform an operation.
+var ErrUnsupported = errors.New("operation not supported by the meeting provider")
+
+// Room is a conference room allocated for one class session.
+type Room struct {
+	Provider string
+	ID       string
+	// URL is a shareable room address, empty when the provider has none
+	// and every participant needs their own join URL.
+	URL string
+}
+
+// RoomSpec describes the session a room is created for.
+type RoomSpec struct {
+	Title  string
+	Start  time.Time
+	Finish time.Time
+}
+
+// Participant is a user joining a room.
+type Participant struct {
+	UserID    uint
+	Name      string
+	AvatarURL string
+	Moderator bool
+}
+
+// JoinRequest asks for one participant's way into a room. NotBefore and
+// Expires bound credentials for providers that issue them.
+type JoinRequest struct {
+	RoomID      string
+	Title       string
+	Participant Participant
+	NotBefore   time.Time
+	Expires     time.Time
+}
+
+// Join is a personal join link. Token is set when the link carries one.
+type Join struct {
+	URL       string
+	Token     string
+	ExpiresAt *time.Time
+}
+
+// Attendee is someone currently in a room.
+type Attendee struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Moderator bool   `json:"moderator"`
+}
+
+// Provider is a video conferencing backend.
+type Provider interface {
+	// Name is the value stored on sessions hosted by this provider.
+	Name() string
+	// CreateRoom allocates a room for a new session.
+	CreateRoom(ctx context.Context, spec RoomSpec) (Room, error)
+	// JoinURL returns a link that lets one participant into a room.
+	JoinURL(ctx context.Context, req JoinRequest) (Join, error)
+	// EndMeeting closes a room for everyone in it.
+	EndMeeting(ctx context.Context, roomID string) error
+	// Participants lists who is in a room right now.
+	Participants(ctx context.Context, roomID string) ([]Attendee, error)
+}
+
+// NewProviderFromEnv builds the provider named by MEETING_PROVIDER (jitsi,
+// bigbluebutton or fake; default jitsi).
+func NewProviderFromEnv() (Provider, error) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	switch name := strings.ToLower(config.MeetingProvider()); name {
+	case "", ProviderJitsi:
+		if config.JITSIURL() == "" || config.JITSIAppSecret() == "" {
+			return nil, errors.New("meeting config missing (JITSI_URL, JITSI_APP_SECRET)")
+		}
+		return &Jitsi{BaseURL: config.JITSIURL(), AppID: config.JITSIAppID(), Secret: config.JITSIAppSecret(), WebhookSecret: config.MeetingWebhookSecret(), HTTP: httpClient}, nil
+	case ProviderBigBlueButton:
+		if config.BBBURL() == "" || config.BBBSecret() == "" {
+			return nil, errors.New("meeting config missing (BBB_URL, BBB_SECRET)")
+		}
+		webhooks, _ := strconv.ParseBool(config.BBBWebhooks())
+		return &BigBlueButton{BaseURL: config.BBBURL(), Secret: config.BBBSecret(), Webhooks: webhooks, HTTP: httpClient}, nil
+	case ProviderFake:
+		fake := NewFake("")
+		fake.WebhookSecret = config.MeetingWebhookSecret()
+		return fake, nil
+	default:
+		return nil, fmt.Errorf("unknown MEETING_PROVIDER %q", name)
+	}
+}
+
+// newRoomID returns an unguessable room name: a random UUID v8 hashed with
+// SHA-256.
+func newRoomID() (string, error) {
+	uuidBytes := make([]byte, 16)
+	if _, err := rand.Read(uuidBytes); err != nil {
+		return "", err
+	}
+	// Set version to 8 (bits 4-7 of byte 6)
+	uuidBytes[6] = (uuidBytes[6] & 0x0f) | 0x80
+	// Set variant to RFC4122 (bits 6-7 of byte 8)
+	uuidBytes[8] = (uuidBytes[8] & 0x3f) | 0x80
+
+	uuidV8, err := uuid.FromBytes(uuidBytes)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(uuidV8.String()))
+	return hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,76 @@
+package meeting
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Presence event kinds.
+const (
+	EventJoin  = "join"
+	EventLeave = "leave"
+	EventEnd   = "end"
+)
+
+var (
+	ErrWebhookUnauthorized = errors.New("meeting webhook is not signed by the provider")
+	ErrInvalidWebhook      = errors.New("meeting webhook payload could not be read")
+)
+
+// Webhook is an HTTP callback received from a provider. URL is the full
+// request URL including its query string.
+type Webhook struct {
+	URL           string
+	Authorization string
+	ContentType   string
+	Body          []byte
+}
+
+// PresenceEvent reports a participant joining or leaving a room, or the room
+// closing (EventEnd, UserID empty). UserID is the ID the participant joined
+// with, i.e. Participant.UserID.
+type PresenceEvent struct {
+	Kind   string
+	RoomID string
+	UserID string
+	At     time.Time
+}
+
+// WebhookReceiver is implemented by providers that can report who joins and
+// leaves their rooms.
+type WebhookReceiver interface {
+	// ReceivesWebhooks reports whether the provider is set up to send them.
+	ReceivesWebhooks() bool
+	// ParseWebhook authenticates hook and extracts its presence events.
+	// Events the provider sends that are not about presence are dropped.
+	ParseWebhook(hook Webhook) ([]PresenceEvent, error)
+}
+
+// TracksPresence reports whether p sends join and leave webhooks, so
+// presence comes from the room itself rather than from links handed out.
+func TracksPresence(p Provider) bool {
+	r, ok := p.(WebhookReceiver)
+	return ok && r.ReceivesWebhooks()
+}
+
+// bearerMatches reports whether auth is "Bearer <secret>". An empty secret
+// never matches.
+func bearerMatches(auth, secret string) bool {
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	return ok && secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// unixTime reads a provider timestamp, which may be in seconds or
+// milliseconds, falling back to now when it is missing.
+func unixTime(ts int64, now time.Time) time.Time {
+	switch {
+	case ts <= 0:
+		return now
+	case ts > 1e12:
+		return time.UnixMilli(ts).UTC()
+	default:
+		return time.Unix(ts, 0).UTC()
+	}
+}
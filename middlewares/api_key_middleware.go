@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// authenticateAPIKey is the ProtectedMiddleware branch for service-to-service
+// callers. The request runs as the key's owner, restricted to the key's scopes.
+func authenticateAPIKey(c *fiber.Ctx, raw string) error {
+	db, err := GetDB(c)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "db not available"})
+	}
+
+	key, err := services.AuthenticateAPIKey(db, raw, time.Now())
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "invalid api key"})
+	}
+
+	scope := services.RouteScope(c.Method(), c.Path())
+	if !key.HasScope(scope) {
+		return c.Status(403).JSON(fiber.Map{"error": "api key is missing scope " + scope})
+	}
+
+	var user models.User
+	if err := db.Preload("Learner").Preload("Teacher").Preload("Admin").
+		First(&user, key.OwnerUserID).Error; err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "invalid credentials"})
+	}
+
+	c.Locals("currentUser", &user)
+	c.Locals("apiKey", key)
+	return c.Next()
+}
@@ -0,0 +1,127 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+func expectAPIKeyLookup(mock sqlmock.Sqlmock, prefix, hash, scopes string, revokedAt any) {
+	mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE prefix = \$1 AND "api_keys"\."deleted_at" IS NULL ORDER BY "api_keys"\."id" LIMIT .*`).
+		WithArgs(prefix, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "owner_user_id", "prefix", "key_hash", "scopes", "revoked_at"}).
+			AddRow(1, 7, prefix, hash, scopes, revokedAt))
+}
+
+func TestProtectedMiddleware_APIKey(t *testing.T) {
+	raw, prefix, hash, err := services.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		method    string
+		scopes    string
+		revokedAt any
+		want      int
+	}{
+		{"read scope", http.MethodGet, `["reports:read"]`, nil, http.StatusOK},
+		{"write implies read", http.MethodGet, `["reports:write"]`, nil, http.StatusOK},
+		{"missing scope", http.MethodPost, `["reports:read"]`, nil, http.StatusForbidden},
+		{"revoked", http.MethodGet, `["reports:read"]`, time.Now().Add(-time.Hour), http.StatusUnauthorized},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("STATUS", "production")
+			mock, gdb, cleanup := setupMockGorm(t)
+			defer cleanup()
+			mock.MatchExpectationsInOrder(false)
+
+			expectAPIKeyLookup(mock, prefix, hash, tc.scopes, tc.revokedAt)
+			if tc.revokedAt == nil {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "api_keys" SET "last_used_at"=\$1`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+			if tc.want == http.StatusOK {
+				preloadUserForAuth(mock, 7, true, false, false)
+			}
+
+			app := fiber.New()
+			app.Use(DBMiddleware(gdb))
+			app.Add(tc.method, "/reports", ProtectedMiddleware(), AdminRequired(), func(c *fiber.Ctx) error {
+				return c.SendStatus(200)
+			})
+
+			req := httptest.NewRequest(tc.method, "/reports", nil)
+			req.Header.Set("X-API-Key", raw)
+			resp, _ := app.Test(req, -1)
+			if resp.StatusCode != tc.want {
+				t.Fatalf("status=%d want=%d", resp.StatusCode, tc.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestProtectedMiddleware_APIKey_Malformed_401(t *testing.T) {
+	t.Setenv("STATUS", "production")
+	_, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := fiber.New()
+	app.Use(DBMiddleware(gdb))
+	app.Get("/reports", ProtectedMiddleware(), func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+	req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+	req.Header.Set("X-API-Key", "not-an-api-key")
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestStepUpRequired_RejectsAPIKey_403(t *testing.T) {
+	raw, prefix, hash, err := services.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	t.Setenv("STATUS", "production")
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+	mock.MatchExpectationsInOrder(false)
+
+	expectAPIKeyLookup(mock, prefix, hash, `["admins:write"]`, nil)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "api_keys" SET "last_used_at"=\$1`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	preloadUserForAuth(mock, 7, true, false, false)
+
+	app := fiber.New()
+	app.Use(DBMiddleware(gdb))
+	app.Post("/admins", ProtectedMiddleware(), AdminRequired(), StepUpRequired(StepUpMaxAge), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/admins", nil)
+	req.Header.Set("X-API-Key", raw)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusForbidden)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
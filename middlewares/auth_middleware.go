@@ -3,6 +3,7 @@ package middlewares
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/a2n2k3p4/tutorium-backend/config"
 	"github.com/a2n2k3p4/tutorium-backend/models"
@@ -12,9 +13,17 @@ import (
 
 type Claims struct {
 	UserID uint `json:"user_id"`
+	// MFA is set on tokens issued after a successful second-factor check;
+	// MFAAt records when that check happened (unix seconds) for step-up auth.
+	MFA   bool  `json:"mfa,omitempty"`
+	MFAAt int64 `json:"mfa_at,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// StepUpMaxAge is how recently an admin must have re-verified their second
+// factor before StepUpRequired lets a sensitive action through.
+const StepUpMaxAge = 5 * time.Minute
+
 // if Status = development you can bypass all routes
 var Status = config.STATUS
 var Secret = func() []byte {
@@ -40,6 +49,9 @@ func ProtectedMiddleware() fiber.Handler {
 		if Status() == "development" {
 			return c.Next()
 		}
+		if rawKey := c.Get(apiKeyHeader); rawKey != "" {
+			return authenticateAPIKey(c, rawKey)
+		}
 		authHeader := c.Get("Authorization")
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 			return c.Status(401).JSON(fiber.Map{"error": "missing or invalid token"})
@@ -71,6 +83,7 @@ func ProtectedMiddleware() fiber.Handler {
 		}
 
 		c.Locals("currentUser", &user)
+		c.Locals("authClaims", claims)
 		return c.Next()
 	}
 }
@@ -87,6 +100,51 @@ func AdminRequired() fiber.Handler {
 		if user.Admin == nil {
 			return c.Status(403).JSON(fiber.Map{"error": "admin access required"})
 		}
+		// API keys are owned by admins and already limited by scope in ProtectedMiddleware.
+		if _, isKey := c.Locals("apiKey").(*models.APIKey); isKey {
+			return c.Next()
+		}
+		claims, ok := c.Locals("authClaims").(*Claims)
+		if !ok || !claims.MFA {
+			return c.Status(403).JSON(fiber.Map{"error": "two-factor authentication required"})
+		}
+		return c.Next()
+	}
+}
+
+// IsVerifiedAdmin reports whether the caller is an admin who would pass
+// AdminRequired: in development any admin, otherwise one using an API key or
+// a token whose second factor was verified. Routes open to everyone check it
+// before granting admins an override, so it cannot skip the MFA gate.
+func IsVerifiedAdmin(c *fiber.Ctx) bool {
+	user, ok := c.Locals("currentUser").(*models.User)
+	if !ok || user.Admin == nil {
+		return false
+	}
+	if Status() == "development" {
+		return true
+	}
+	if _, isKey := c.Locals("apiKey").(*models.APIKey); isKey {
+		return true
+	}
+	claims, ok := c.Locals("authClaims").(*Claims)
+	return ok && claims.MFA
+}
+
+// StepUpRequired must run after AdminRequired. It rejects tokens whose second
+// factor was verified longer than maxAge ago, forcing a fresh TOTP check.
+func StepUpRequired(maxAge time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if Status() == "development" {
+			return c.Next()
+		}
+		claims, ok := c.Locals("authClaims").(*Claims)
+		if !ok || !claims.MFA {
+			return c.Status(403).JSON(fiber.Map{"error": "two-factor authentication required"})
+		}
+		if time.Since(time.Unix(claims.MFAAt, 0)) > maxAge {
+			return c.Status(403).JSON(fiber.Map{"error": "recent two-factor verification required"})
+		}
 		return c.Next()
 	}
 }
@@ -47,6 +47,23 @@ func makeJWT(t *testing.T, userID uint) string {
 	return s
 }
 
+func makeMFAJWT(t *testing.T, userID uint, verifiedAt time.Time) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"mfa":     true,
+		"mfa_at":  verifiedAt.Unix(),
+		"iat":     time.Now().Unix(),
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	s, err := tok.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return s
+}
+
 func preloadUserForAuth(mock sqlmock.Sqlmock, userID uint, hasAdmin, hasTeacher, hasLearner bool) {
 	if config.STATUS() == "development" {
 		return
@@ -197,7 +214,7 @@ func TestAdminRequired_Success_200(t *testing.T) {
 		})
 
 		req := httptest.NewRequest(http.MethodGet, "/admin-only", nil)
-		req.Header.Set("Authorization", "Bearer "+makeJWT(t, userID))
+		req.Header.Set("Authorization", "Bearer "+makeMFAJWT(t, userID, time.Now()))
 		resp, _ := app.Test(req, -1)
 		if resp.StatusCode != http.StatusOK {
 			t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusOK)
@@ -233,6 +250,69 @@ func TestAdminRequired_Forbidden_403(t *testing.T) {
 	}
 }
 
+func TestAdminRequired_MissingSecondFactor_403(t *testing.T) {
+	t.Setenv("STATUS", "production")
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	userID := uint(7)
+	preloadUserForAuth(mock, userID, true, false, false) // has Admin, token without mfa
+
+	app := fiber.New()
+	app.Use(DBMiddleware(gdb))
+	app.Get("/admin-only", ProtectedMiddleware(), AdminRequired(), func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin-only", nil)
+	req.Header.Set("Authorization", "Bearer "+makeJWT(t, userID))
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusForbidden)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestStepUpRequired(t *testing.T) {
+	cases := []struct {
+		name       string
+		verifiedAt time.Time
+		want       int
+	}{
+		{"fresh", time.Now(), http.StatusOK},
+		{"stale", time.Now().Add(-2 * StepUpMaxAge), http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("STATUS", "production")
+			mock, gdb, cleanup := setupMockGorm(t)
+			defer cleanup()
+
+			userID := uint(7)
+			preloadUserForAuth(mock, userID, true, false, false)
+
+			app := fiber.New()
+			app.Use(DBMiddleware(gdb))
+			app.Post("/sensitive", ProtectedMiddleware(), AdminRequired(), StepUpRequired(StepUpMaxAge), func(c *fiber.Ctx) error {
+				return c.SendStatus(200)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/sensitive", nil)
+			req.Header.Set("Authorization", "Bearer "+makeMFAJWT(t, userID, tc.verifiedAt))
+			resp, _ := app.Test(req, -1)
+			if resp.StatusCode != tc.want {
+				t.Fatalf("status=%d want=%d", resp.StatusCode, tc.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
 func TestTeacherRequired_Success_200(t *testing.T) {
 	cases := []struct {
 		name   string
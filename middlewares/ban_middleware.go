@@ -9,12 +9,20 @@ import (
 	"gorm.io/gorm"
 )
 
-// BanMiddleware checks if the authenticated user has an active ban in any role.
-func BanMiddleware() fiber.Handler {
+// BanMiddleware rejects write requests from users with an active ban in one of
+// the given scopes. Platform bans are always enforced, so BanMiddleware() with
+// no scopes only blocks fully suspended accounts. Read-only requests pass so
+// banned users can still browse and read their notifications.
+func BanMiddleware(scopes ...string) fiber.Handler {
+	scopes = append([]string{models.BanScopePlatform}, scopes...)
 	return func(c *fiber.Ctx) error {
 		if Status() == "development" {
 			return c.Next()
 		}
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
 		user, ok := c.Locals("currentUser").(*models.User)
 		if !ok {
 			return c.Next()
@@ -25,42 +33,58 @@ func BanMiddleware() fiber.Handler {
 			return c.Status(500).JSON(fiber.Map{"error": "database not available"})
 		}
 
-		banned, banEnd, err := isUserBanned(db, user)
+		banned, scope, banEnd, err := isUserBanned(db, user, scopes)
 		if err != nil {
 			return c.Status(500).JSON(fiber.Map{"error": "Failed to check ban status"})
 		}
 		if banned {
 			return c.Status(403).JSON(fiber.Map{
-				"error": "Your account is suspended until " + banEnd.Format(time.RFC3339),
+				"error":     banMessage(scope, banEnd),
+				"ban_scope": scope,
 			})
 		}
 		return c.Next()
 	}
 }
 
-// isUserBanned is a helper function that checks all roles for an active ban.
-func isUserBanned(db *gorm.DB, user *models.User) (bool, time.Time, error) {
+func banMessage(scope string, banEnd time.Time) string {
+	subject := "Your account is"
+	switch scope {
+	case models.BanScopeTeaching:
+		subject = "Your teaching access is"
+	case models.BanScopeLearning:
+		subject = "Your learning access is"
+	}
+	if !banEnd.Before(models.PermanentBanEnd) {
+		return subject + " permanently suspended"
+	}
+	return subject + " suspended until " + banEnd.Format(time.RFC3339)
+}
+
+// isUserBanned is a helper function that checks all roles for an active ban in one of scopes.
+func isUserBanned(db *gorm.DB, user *models.User, scopes []string) (bool, string, time.Time, error) {
+	now := time.Now()
 	if user.Teacher != nil {
 		var teacherBan models.BanDetailsTeacher
-		err := db.Where("teacher_id = ? AND ban_end > ?", user.Teacher.ID, time.Now()).First(&teacherBan).Error
+		err := db.Where("teacher_id = ? AND ban_end > ? AND ban_scope IN ?", user.Teacher.ID, now, scopes).First(&teacherBan).Error
 		if err == nil {
-			return true, teacherBan.BanEnd, nil
+			return true, teacherBan.BanScope, teacherBan.BanEnd, nil
 		}
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return false, time.Time{}, err
+			return false, "", time.Time{}, err
 		}
 	}
 
 	if user.Learner != nil {
 		var learnerBan models.BanDetailsLearner
-		err := db.Where("learner_id = ? AND ban_end > ?", user.Learner.ID, time.Now()).First(&learnerBan).Error
+		err := db.Where("learner_id = ? AND ban_end > ? AND ban_scope IN ?", user.Learner.ID, now, scopes).First(&learnerBan).Error
 		if err == nil {
-			return true, learnerBan.BanEnd, nil
+			return true, learnerBan.BanScope, learnerBan.BanEnd, nil
 		}
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return false, time.Time{}, err
+			return false, "", time.Time{}, err
 		}
 	}
 
-	return false, time.Time{}, nil
+	return false, "", time.Time{}, nil
 }
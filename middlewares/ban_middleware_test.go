@@ -0,0 +1,90 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func setupBanApp(gdb *gorm.DB, user *models.User, scopes ...string) *fiber.App {
+	app := fiber.New()
+	app.Use(DBMiddleware(gdb))
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("currentUser", user)
+		return c.Next()
+	})
+	handler := func(c *fiber.Ctx) error { return c.SendStatus(200) }
+	app.Get("/resource", BanMiddleware(scopes...), handler)
+	app.Post("/resource", BanMiddleware(scopes...), handler)
+	return app
+}
+
+func bannableTeacher() *models.User {
+	u := &models.User{Teacher: &models.Teacher{UserID: 5}}
+	u.ID = 5
+	u.Teacher.ID = 30
+	return u
+}
+
+func TestBanMiddleware_ReadOnlyPasses(t *testing.T) {
+	t.Setenv("STATUS", "production")
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupBanApp(gdb, bannableTeacher(), models.BanScopeTeaching)
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/resource", nil), -1)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusOK)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestBanMiddleware_TeachingBanOutsideScope(t *testing.T) {
+	t.Setenv("STATUS", "production")
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupBanApp(gdb, bannableTeacher())
+
+	mock.ExpectQuery(`SELECT \* FROM "ban_details_teachers" WHERE \(teacher_id = \$1 AND ban_end > \$2 AND ban_scope IN \(\$3\)\)`).
+		WithArgs(30, sqlmock.AnyArg(), models.BanScopePlatform, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodPost, "/resource", nil), -1)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusOK)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestBanMiddleware_TeachingBanInScope_403(t *testing.T) {
+	t.Setenv("STATUS", "production")
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	app := setupBanApp(gdb, bannableTeacher(), models.BanScopeTeaching)
+
+	mock.ExpectQuery(`SELECT \* FROM "ban_details_teachers" WHERE \(teacher_id = \$1 AND ban_end > \$2 AND ban_scope IN \(\$3,\$4\)\)`).
+		WithArgs(30, sqlmock.AnyArg(), models.BanScopePlatform, models.BanScopeTeaching, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "teacher_id", "ban_scope", "ban_end"}).
+			AddRow(1, 30, models.BanScopeTeaching, time.Now().Add(24*time.Hour)))
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodPost, "/resource", nil), -1)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status=%d want=%d", resp.StatusCode, http.StatusForbidden)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
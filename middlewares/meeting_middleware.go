@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"errors"
+
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
+	"github.com/gofiber/fiber/v2"
+)
+
+const meetingCtxKey = "tutorium_meeting_provider"
+
+// MeetingMiddleware injects the configured meeting provider into the request context.
+func MeetingMiddleware(p meeting.Provider) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(meetingCtxKey, p)
+		return c.Next()
+	}
+}
+
+// GetMeetingProvider extracts the meeting provider from the request context.
+func GetMeetingProvider(c *fiber.Ctx) (meeting.Provider, error) {
+	v := c.Locals(meetingCtxKey)
+	if v == nil {
+		return nil, errors.New("meeting provider not found in context")
+	}
+	p, ok := v.(meeting.Provider)
+	if !ok {
+		return nil, errors.New("invalid meeting provider in context")
+	}
+	return p, nil
+}
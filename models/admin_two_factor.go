@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AdminTwoFactor stores the TOTP enrolment of an admin account.
+type AdminTwoFactor struct {
+	gorm.Model
+	UserID       uint       `json:"user_id" gorm:"unique;not null"`
+	Secret       string     `json:"-" gorm:"size:64;not null"`
+	Enabled      bool       `json:"enabled" gorm:"default:false;not null"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `json:"-" gorm:"default:0;not null"`
+
+	// FailedAttempts counts wrong codes in a row; reaching the limit sets
+	// LockedUntil, before which no code is checked.
+	FailedAttempts int        `json:"-" gorm:"default:0;not null"`
+	LockedUntil    *time.Time `json:"-"`
+
+	User User `json:"-" gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+}
+
+// AdminRecoveryCode is a single-use fallback code; only its SHA-256 hash is stored.
+type AdminRecoveryCode struct {
+	gorm.Model
+	UserID   uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	UsedAt   *time.Time `json:"used_at,omitempty"`
+
+	User User `json:"-" gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type TwoFactorEnrollResponseDoc struct {
+	Secret          string   `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	ProvisioningURI string   `json:"provisioning_uri" example:"otpauth://totp/Tutorium:b6600000000?secret=JBSWY3DPEHPK3PXP&issuer=Tutorium"`
+	RecoveryCodes   []string `json:"recovery_codes" swaggertype:"array,string" example:"7f3k-9q2m-x8p4"`
+}
+
+type TwoFactorCodeDoc struct {
+	Code string `json:"code" example:"492039"`
+}
+
+type TwoFactorVerifyResponseDoc struct {
+	Token string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// APIKey grants a non-interactive integration access to a fixed set of route scopes.
+// Only the SHA-256 hash of the key is stored; Prefix identifies the key in logs and listings.
+type APIKey struct {
+	gorm.Model
+	OwnerUserID uint                        `json:"owner_user_id" gorm:"index;not null"`
+	Name        string                      `json:"name" gorm:"size:100;not null"`
+	Prefix      string                      `json:"prefix" gorm:"size:16;uniqueIndex;not null"`
+	KeyHash     string                      `json:"-" gorm:"size:64;not null"`
+	Scopes      datatypes.JSONSlice[string] `json:"scopes" gorm:"type:jsonb" swaggertype:"array,string"`
+	ExpiresAt   *time.Time                  `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time                  `json:"revoked_at,omitempty"`
+	LastUsedAt  *time.Time                  `json:"last_used_at,omitempty"`
+
+	Owner User `json:"-" gorm:"foreignKey:OwnerUserID;references:ID;constraint:OnDelete:CASCADE"`
+}
+
+// Active reports whether the key is neither revoked nor expired at now.
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// HasScope reports whether the key grants scope ("<resource>:<read|write>").
+// A write scope also grants read access to the same resource.
+func (k *APIKey) HasScope(scope string) bool {
+	resource, _, _ := strings.Cut(scope, ":")
+	for _, s := range k.Scopes {
+		if s == scope || (s == resource+":write" && strings.HasSuffix(scope, ":read")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type CreateAPIKeyRequestDoc struct {
+	Name      string     `json:"name" example:"LMS nightly sync"`
+	Scopes    []string   `json:"scopes" swaggertype:"array,string" example:"class_sessions:read"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" example:"2026-12-31T23:59:59Z"`
+}
+
+type APIKeyDoc struct {
+	OwnerUserID uint       `json:"owner_user_id" example:"1"`
+	Name        string     `json:"name" example:"LMS nightly sync"`
+	Prefix      string     `json:"prefix" example:"3f9a1c2b"`
+	Scopes      []string   `json:"scopes" swaggertype:"array,string" example:"class_sessions:read"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" example:"2026-12-31T23:59:59Z"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty" example:"2026-06-01T00:00:00Z"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty" example:"2026-05-30T02:00:00Z"`
+}
+
+type CreateAPIKeyResponseDoc struct {
+	Key    string    `json:"key" example:"tut_3f9a1c2b_Jx8v0mQe3b1kT9wz5rLpAq7cYd2nHs4u"`
+	APIKey APIKeyDoc `json:"api_key"`
+}
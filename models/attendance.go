@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attendance statuses.
+const (
+	AttendancePresent = "present"
+	AttendanceLate    = "late"
+	AttendanceAbsent  = "absent"
+)
+
+// Attendance sources, from most to least authoritative. A teacher's roll call
+// is never overwritten by a check-in or meeting join.
+const (
+	AttendanceSourceRollCall    = "roll_call"
+	AttendanceSourceCheckIn     = "check_in"
+	AttendanceSourceMeetingJoin = "meeting_join"
+)
+
+// ValidAttendanceStatus reports whether status is one a teacher may record.
+func ValidAttendanceStatus(status string) bool {
+	switch status {
+	case AttendancePresent, AttendanceLate, AttendanceAbsent:
+		return true
+	}
+	return false
+}
+
+// Attendance records whether a learner attended a ClassSession and how that
+// was established. There is at most one row per learner and session.
+type Attendance struct {
+	gorm.Model
+	ClassSessionID   uint       `json:"class_session_id" gorm:"not null;uniqueIndex:idx_attendance_session_learner"`
+	LearnerID        uint       `json:"learner_id" gorm:"not null;uniqueIndex:idx_attendance_session_learner;index"`
+	Status           string     `json:"status" gorm:"size:10;not null"`
+	Source           string     `json:"source" gorm:"size:20;not null"`
+	RecordedByUserID *uint      `json:"recorded_by_user_id,omitempty"`
+	CheckedInAt      *time.Time `json:"checked_in_at,omitempty"`
+
+	ClassSession ClassSession `json:"-" gorm:"foreignKey:ClassSessionID;references:ID;constraint:OnDelete:CASCADE"`
+	Learner      Learner      `json:"-" gorm:"foreignKey:LearnerID;references:ID;constraint:OnDelete:CASCADE"`
+}
+
+// SessionAttendanceSummary counts attendance for one ClassSession.
+type SessionAttendanceSummary struct {
+	ClassSessionID uint         `json:"class_session_id"`
+	Enrolled       int          `json:"enrolled"`
+	Present        int          `json:"present"`
+	Late           int          `json:"late"`
+	Absent         int          `json:"absent"`
+	Unrecorded     int          `json:"unrecorded"`
+	Records        []Attendance `json:"records"`
+}
+
+// LearnerAttendanceSummary counts attendance across a learner's finished sessions.
+type LearnerAttendanceSummary struct {
+	LearnerID      uint         `json:"learner_id"`
+	Sessions       int          `json:"sessions"`
+	Attended       int          `json:"attended"`
+	Absent         int          `json:"absent"`
+	NoShows        int          `json:"no_shows"`
+	AttendanceRate float64      `json:"attendance_rate"`
+	Records        []Attendance `json:"records"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type AttendanceDoc struct {
+	ClassSessionID   uint       `json:"class_session_id" example:"3"`
+	LearnerID        uint       `json:"learner_id" example:"1"`
+	Status           string     `json:"status" example:"present"`
+	Source           string     `json:"source" example:"roll_call"`
+	RecordedByUserID *uint      `json:"recorded_by_user_id,omitempty" example:"5"`
+	CheckedInAt      *time.Time `json:"checked_in_at,omitempty" example:"2025-09-05T14:02:00Z"`
+}
+
+type RollCallEntryDoc struct {
+	LearnerID uint   `json:"learner_id" example:"1"`
+	Status    string `json:"status" example:"present"`
+}
+
+type RollCallDoc struct {
+	Entries []RollCallEntryDoc `json:"entries"`
+}
+
+type CheckInDoc struct {
+	Code string `json:"code" example:"482913"`
+}
+
+type CheckInCodeDoc struct {
+	Code      string    `json:"code" example:"482913"`
+	ExpiresAt time.Time `json:"expires_at" example:"2025-09-05T16:00:00Z"`
+}
+
+type SessionAttendanceSummaryDoc struct {
+	ClassSessionID uint            `json:"class_session_id" example:"3"`
+	Enrolled       int             `json:"enrolled" example:"5"`
+	Present        int             `json:"present" example:"3"`
+	Late           int             `json:"late" example:"1"`
+	Absent         int             `json:"absent" example:"0"`
+	Unrecorded     int             `json:"unrecorded" example:"1"`
+	Records        []AttendanceDoc `json:"records"`
+}
+
+type LearnerAttendanceSummaryDoc struct {
+	LearnerID      uint            `json:"learner_id" example:"1"`
+	Sessions       int             `json:"sessions" example:"10"`
+	Attended       int             `json:"attended" example:"8"`
+	Absent         int             `json:"absent" example:"1"`
+	NoShows        int             `json:"no_shows" example:"1"`
+	AttendanceRate float64         `json:"attendance_rate" example:"0.8"`
+	Records        []AttendanceDoc `json:"records"`
+}
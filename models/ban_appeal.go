@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// BanAppeal is a banned user's request to have a single BanDetailsLearner or
+// BanDetailsTeacher row shortened or lifted. BanRole says which table BanID refers to.
+type BanAppeal struct {
+	gorm.Model
+	UserID           uint                        `json:"user_id" gorm:"index;not null"`
+	BanRole          string                      `json:"ban_role" gorm:"size:10;not null;uniqueIndex:idx_ban_appeal_ban"`
+	BanID            uint                        `json:"ban_id" gorm:"not null;uniqueIndex:idx_ban_appeal_ban"`
+	AppealText       string                      `json:"appeal_text" gorm:"size:1000;not null"`
+	EvidenceURLs     datatypes.JSONSlice[string] `json:"evidence_urls" gorm:"type:jsonb" swaggertype:"array,string"`
+	Status           string                      `json:"status" gorm:"size:10;not null;default:'pending'"`
+	ReviewNote       string                      `json:"review_note" gorm:"size:255"`
+	ReviewedByUserID *uint                       `json:"reviewed_by_user_id,omitempty"`
+	ReviewedAt       *time.Time                  `json:"reviewed_at,omitempty"`
+	OriginalBanEnd   time.Time                   `json:"original_ban_end"`
+	ResultingBanEnd  *time.Time                  `json:"resulting_ban_end,omitempty"`
+
+	User User `json:"-" gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type CreateBanAppealDoc struct {
+	BanRole    string   `json:"ban_role" example:"learner"`
+	BanID      uint     `json:"ban_id" example:"12"`
+	AppealText string   `json:"appeal_text" example:"The report was about a different learner with the same nickname."`
+	Evidence   []string `json:"evidence,omitempty" swaggertype:"array,string" example:"<base64-encoded-image>"`
+}
+
+type ReviewBanAppealDoc struct {
+	Decision   string     `json:"decision" example:"accept"`
+	ReviewNote string     `json:"review_note" example:"Evidence confirms mistaken identity"`
+	NewBanEnd  *time.Time `json:"new_ban_end,omitempty" example:"2025-08-22T12:00:00Z"`
+}
+
+type BanAppealDoc struct {
+	UserID           uint       `json:"user_id" example:"8"`
+	BanRole          string     `json:"ban_role" example:"learner"`
+	BanID            uint       `json:"ban_id" example:"12"`
+	AppealText       string     `json:"appeal_text" example:"The report was about a different learner with the same nickname."`
+	EvidenceURLs     []string   `json:"evidence_urls" swaggertype:"array,string" example:"appeals/1724155200000000000.png"`
+	Status           string     `json:"status" example:"pending"`
+	ReviewNote       string     `json:"review_note" example:"Evidence confirms mistaken identity"`
+	ReviewedByUserID *uint      `json:"reviewed_by_user_id,omitempty" example:"1"`
+	ReviewedAt       *time.Time `json:"reviewed_at,omitempty" example:"2025-08-21T09:00:00Z"`
+	OriginalBanEnd   time.Time  `json:"original_ban_end" example:"2025-08-30T12:00:00Z"`
+	ResultingBanEnd  *time.Time `json:"resulting_ban_end,omitempty" example:"2025-08-21T09:00:00Z"`
+}
+
+type ActiveBanDoc struct {
+	BanRole        string    `json:"ban_role" example:"learner"`
+	BanID          uint      `json:"ban_id" example:"12"`
+	BanStart       time.Time `json:"ban_start" example:"2025-08-20T12:00:00Z"`
+	BanEnd         time.Time `json:"ban_end" example:"2025-08-30T12:00:00Z"`
+	BanDescription string    `json:"ban_description" example:"Flag threshold reached"`
+	BanScope       string    `json:"ban_scope" example:"learning"`
+}
@@ -12,6 +12,7 @@ type BanDetailsLearner struct {
 	BanStart       time.Time `json:"ban_start" gorm:"default:CURRENT_TIMESTAMP"`
 	BanEnd         time.Time `json:"ban_end" gorm:"not null"`
 	BanDescription string    `json:"ban_description" gorm:"size:255"`
+	BanScope       string    `json:"ban_scope" gorm:"size:10;not null;default:'learning'"`
 
 	Learner Learner `gorm:"foreignKey:LearnerID;references:ID;constraint:OnDelete:CASCADE"`
 }
@@ -23,4 +24,5 @@ type BanDetailsLearnerDoc struct {
 	BanStart       time.Time `json:"ban_start" example:"2025-08-20T12:00:00Z"`
 	BanEnd         time.Time `json:"ban_end" example:"2025-08-30T12:00:00Z"`
 	BanDescription string    `json:"ban_description" example:"Spamming inappropriate content"`
+	BanScope       string    `json:"ban_scope" example:"learning"`
 }
@@ -0,0 +1,26 @@
+package models
+
+// Ban scopes decide which route groups a ban blocks. Teaching bans only block
+// teacher routes and learning bans only learner routes; platform bans block
+// every write. Read-only requests and ban appeals stay available for all scopes.
+const (
+	BanScopeTeaching = "teaching"
+	BanScopeLearning = "learning"
+	BanScopePlatform = "platform"
+)
+
+// ValidBanScope reports whether scope can be set on a ban of the given role
+// ("learner" or "teacher"). A learner ban blocks learning or the whole
+// platform and a teacher ban teaching or the whole platform; a learner ban
+// scoped to teaching would block nothing the learner does.
+func ValidBanScope(role, scope string) bool {
+	switch scope {
+	case BanScopePlatform:
+		return role == "learner" || role == "teacher"
+	case BanScopeLearning:
+		return role == "learner"
+	case BanScopeTeaching:
+		return role == "teacher"
+	}
+	return false
+}
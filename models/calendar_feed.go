@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CalendarFeed is a user's private iCalendar subscription. The token is part
+// of the feed URL because calendar apps cannot send auth headers, so only
+// its SHA-256 hash is stored; rotating the token revokes the old URL.
+type CalendarFeed struct {
+	gorm.Model
+	UserID         uint       `json:"user_id" gorm:"uniqueIndex;not null"`
+	TokenHash      string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+
+	User User `json:"-" gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
+}
+
+// CalendarFeedResponse carries the raw token, which is only shown once.
+type CalendarFeedResponse struct {
+	Token   string `json:"token"`
+	FeedURL string `json:"feed_url"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type CalendarFeedResponseDoc struct {
+	Token   string `json:"token" example:"Jx8v0mQe3b1kT9wz5rLpAq7cYd2nHs4uK3pW"`
+	FeedURL string `json:"feed_url" example:"https://api.tutorium.example/calendar/Jx8v0mQe3b1kT9wz5rLpAq7cYd2nHs4uK3pW.ics"`
+}
@@ -10,9 +10,15 @@ type Class struct {
 	ClassName        string          `json:"class_name" gorm:"size:255;not null"`
 	ClassDescription string          `json:"class_description" gorm:"size:1000"`
 	BannerPictureURL string          `json:"banner_picture,omitempty"`
+	Timezone         string          `json:"timezone" gorm:"size:64;not null;default:'Asia/Bangkok'"` // IANA name; recurring sessions keep their wall-clock time in it
 	Teacher          Teacher         `gorm:"foreignKey:TeacherID;references:ID;constraint:OnDelete:CASCADE"`
 	Categories       []ClassCategory `gorm:"many2many:class_class_categories;constraint:OnDelete:CASCADE"`
 	Sessions         []ClassSession  `json:"sessions" gorm:"foreignKey:ClassID;constraint:OnDelete:CASCADE"`
+
+	// Learners can book one-to-one lessons of this class in the teacher's
+	// availability windows when PrivateLessonMinutes is set.
+	PrivateLessonMinutes int     `json:"private_lesson_minutes" gorm:"default:0"`
+	PrivateLessonPrice   float64 `json:"private_lesson_price" gorm:"type:numeric(12,2);default:0;check:private_lesson_price >= 0"`
 }
 
 // ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
@@ -22,6 +28,10 @@ type ClassDoc struct {
 	ClassName        string `json:"class_name" example:"Advanced Python Programming"`
 	ClassDescription string `json:"class_description" example:"Advanced Python programming course"`
 	BannerPicture    string `json:"banner_picture,omitempty" example:"<base64-encoded-image>"`
+	Timezone         string `json:"timezone" example:"Asia/Bangkok"`
+
+	PrivateLessonMinutes int     `json:"private_lesson_minutes" example:"60"`
+	PrivateLessonPrice   float64 `json:"private_lesson_price" example:"500.00"`
 }
 
 type RecommendClassesDoc struct {
@@ -1,11 +1,71 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// ClassSession lifecycle states. Completed, cancelled and teacher_absent are terminal.
+const (
+	SessionStatusScheduled     = "scheduled"
+	SessionStatusOpen          = "open"
+	SessionStatusInProgress    = "in_progress"
+	SessionStatusCompleted     = "completed"
+	SessionStatusCancelled     = "cancelled"
+	SessionStatusTeacherAbsent = "teacher_absent"
+)
+
+// sessionTransitions lists the statuses each status may move to.
+var sessionTransitions = map[string][]string{
+	SessionStatusScheduled:  {SessionStatusOpen, SessionStatusInProgress, SessionStatusCancelled, SessionStatusTeacherAbsent},
+	SessionStatusOpen:       {SessionStatusScheduled, SessionStatusInProgress, SessionStatusCancelled, SessionStatusTeacherAbsent},
+	SessionStatusInProgress: {SessionStatusCompleted},
+}
+
+// AttendanceCheckLookback bounds how far back the scheduler's absence and
+// no-show checks look. Sessions that ended longer ago are settled by the
+// migration instead, so records from before the checks existed are never
+// penalised.
+const AttendanceCheckLookback = 24 * time.Hour
+
+// legacySessionStatuses maps free-form values written before the lifecycle existed.
+var legacySessionStatuses = map[string]string{
+	"":            SessionStatusScheduled,
+	"not_started": SessionStatusScheduled,
+	"pending":     SessionStatusScheduled,
+	"upcoming":    SessionStatusScheduled,
+	"available":   SessionStatusOpen,
+	"absent":      SessionStatusTeacherAbsent,
+}
+
+// UpcomingSessionStatuses are the statuses of sessions that have not started yet.
+var UpcomingSessionStatuses = []string{SessionStatusScheduled, SessionStatusOpen}
+
+// ManualSessionStatuses are the statuses a teacher may set directly; the
+// others are set by the scheduler.
+var ManualSessionStatuses = []string{SessionStatusScheduled, SessionStatusOpen, SessionStatusCancelled}
+
+// NormalizeSessionStatus maps legacy spellings onto lifecycle statuses.
+func NormalizeSessionStatus(status string) string {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if s, ok := legacySessionStatuses[status]; ok {
+		return s
+	}
+	return status
+}
+
+// CanTransitionSession reports whether a session may move from one status to another.
+func CanTransitionSession(from, to string) bool {
+	for _, s := range sessionTransitions[NormalizeSessionStatus(from)] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
 // ---- CreateClassSessionRequest temporary holds the POST API json data ----
 type CreateClassSessionRequest struct {
 	ClassID            uint      `json:"class_id"`
@@ -20,15 +80,23 @@ type CreateClassSessionRequest struct {
 
 type ClassSession struct {
 	gorm.Model
-	ClassID            uint      `json:"class_id"`
-	Description        string    `json:"description" gorm:"size:1000"`
-	Price              float64   `json:"price" gorm:"type:numeric(12,2);default:0;check:price >= 0"`
-	LearnerLimit       int       `json:"learner_limit" gorm:"not null;default:50"`
-	EnrollmentDeadline time.Time `json:"enrollment_deadline" gorm:"not null"`
-	ClassStart         time.Time `json:"class_start" gorm:"not null"`
-	ClassFinish        time.Time `json:"class_finish" gorm:"not null"`
-	ClassStatus        string    `json:"class_status" gorm:"size:20"`
-	MeetingUrl         string    `json:"class_url" gorm:"size:128"`
+	ClassID             uint       `json:"class_id"`
+	Description         string     `json:"description" gorm:"size:1000"`
+	Price               float64    `json:"price" gorm:"type:numeric(12,2);default:0;check:price >= 0"`
+	LearnerLimit        int        `json:"learner_limit" gorm:"not null;default:50"`
+	EnrollmentDeadline  time.Time  `json:"enrollment_deadline" gorm:"not null"`
+	ClassStart          time.Time  `json:"class_start" gorm:"not null;index:idx_class_sessions_time"`
+	ClassFinish         time.Time  `json:"class_finish" gorm:"not null;index:idx_class_sessions_time"`
+	ClassStatus         string     `json:"class_status" gorm:"size:20;default:'scheduled';index"`
+	MeetingUrl          string     `json:"-" gorm:"size:128"`
+	MeetingProvider     string     `json:"meeting_provider" gorm:"size:20"`
+	MeetingRoomID       string     `json:"-" gorm:"size:128"`
+	CheckInCode         string     `json:"-" gorm:"size:8"`
+	CheckInCodeIssuedAt *time.Time `json:"-"`
+	TeacherCheckedInAt  *time.Time `json:"teacher_checked_in_at,omitempty"`
+	SeriesID            *uint      `json:"series_id,omitempty" gorm:"index"`
+	CalendarSequence    int        `json:"calendar_sequence" gorm:"default:0;not null"`
+	ReminderSentAt      *time.Time `json:"-"`
 
 	Class Class `gorm:"foreignKey:ClassID;references:ID;constraint:OnDelete:CASCADE"`
 }
@@ -43,7 +111,7 @@ type CreateClassSessionRequestDoc struct {
 	EnrollmentDeadline time.Time `json:"enrollment_deadline" example:"2025-09-01T23:59:59Z"`
 	ClassStart         time.Time `json:"class_start" example:"2025-09-05T14:00:00Z"`
 	ClassFinish        time.Time `json:"class_finish" example:"2025-09-05T16:00:00Z"`
-	ClassStatus        string    `json:"class_status" example:"Scheduled"`
+	ClassStatus        string    `json:"class_status" example:"scheduled"`
 }
 
 type ClassSessionDoc struct {
@@ -54,6 +122,7 @@ type ClassSessionDoc struct {
 	EnrollmentDeadline time.Time `json:"enrollment_deadline" example:"2025-09-01T23:59:59Z"`
 	ClassStart         time.Time `json:"class_start" example:"2025-09-05T14:00:00Z"`
 	ClassFinish        time.Time `json:"class_finish" example:"2025-09-05T16:00:00Z"`
-	ClassStatus        string    `json:"class_status" example:"Scheduled"`
-	MeetingUrl         string    `json:"class_url" example:"https://meet.jit.si/KUtutorium_12_1758630058"`
+	ClassStatus        string    `json:"class_status" example:"scheduled"`
+	MeetingProvider    string    `json:"meeting_provider" example:"jitsi"`
+	SeriesID           *uint     `json:"series_id,omitempty" example:"4"`
 }
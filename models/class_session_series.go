@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ClassSessionSeries statuses.
+const (
+	SeriesStatusActive    = "active"
+	SeriesStatusCancelled = "cancelled"
+)
+
+// ClassSessionSeries is the template behind a set of recurring ClassSession
+// rows. Occurrences are materialized when the series is created; RRule and
+// ExcludedDates are kept so the series can be shown and audited.
+type ClassSessionSeries struct {
+	gorm.Model
+	ClassID             uint                        `json:"class_id" gorm:"not null;index"`
+	RRule               string                      `json:"rrule" gorm:"size:255;not null"`
+	ExcludedDates       datatypes.JSONSlice[string] `json:"excluded_dates" gorm:"type:jsonb" swaggertype:"array,string"`
+	FirstStart          time.Time                   `json:"first_start" gorm:"not null"`
+	DurationMinutes     int                         `json:"duration_minutes" gorm:"not null;check:duration_minutes > 0"`
+	EnrollmentLeadHours int                         `json:"enrollment_lead_hours" gorm:"not null;default:0"`
+	Description         string                      `json:"description" gorm:"size:1000"`
+	Price               float64                     `json:"price" gorm:"type:numeric(12,2);default:0;check:price >= 0"`
+	LearnerLimit        int                         `json:"learner_limit" gorm:"not null;default:50"`
+	Status              string                      `json:"status" gorm:"size:10;not null;default:'active'"`
+
+	Class    Class          `json:"-" gorm:"foreignKey:ClassID;references:ID;constraint:OnDelete:CASCADE"`
+	Sessions []ClassSession `json:"sessions,omitempty" gorm:"foreignKey:SeriesID;constraint:OnDelete:SET NULL"`
+}
+
+// CreateClassSessionSeriesRequest temporarily holds the POST API json data.
+type CreateClassSessionSeriesRequest struct {
+	ClassID             uint      `json:"class_id"`
+	Description         string    `json:"description"`
+	Price               float64   `json:"price"`
+	LearnerLimit        int       `json:"learner_limit"`
+	FirstStart          time.Time `json:"first_start"`
+	DurationMinutes     int       `json:"duration_minutes"`
+	EnrollmentLeadHours int       `json:"enrollment_lead_hours"`
+	RRule               string    `json:"rrule"`
+	ExcludedDates       []string  `json:"excluded_dates"`
+	ClassStatus         string    `json:"class_status"`
+}
+
+// UpdateSeriesOccurrenceRequest changes one occurrence, or it and every later
+// one. A new ClassStart/ClassFinish shifts the later occurrences by the same amount.
+type UpdateSeriesOccurrenceRequest struct {
+	Description  *string    `json:"description"`
+	Price        *float64   `json:"price"`
+	LearnerLimit *int       `json:"learner_limit"`
+	ClassStart   *time.Time `json:"class_start"`
+	ClassFinish  *time.Time `json:"class_finish"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type CreateClassSessionSeriesRequestDoc struct {
+	ClassID             uint      `json:"class_id" example:"12"`
+	Description         string    `json:"description" example:"Weekly calculus tutoring"`
+	Price               float64   `json:"price" example:"499.00"`
+	LearnerLimit        int       `json:"learner_limit" example:"5"`
+	FirstStart          time.Time `json:"first_start" example:"2025-09-01T18:00:00+07:00"`
+	DurationMinutes     int       `json:"duration_minutes" example:"90"`
+	EnrollmentLeadHours int       `json:"enrollment_lead_hours" example:"24"`
+	RRule               string    `json:"rrule" example:"FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10"`
+	ExcludedDates       []string  `json:"excluded_dates" example:"2025-10-13"`
+	ClassStatus         string    `json:"class_status" example:"open"`
+}
+
+type UpdateSeriesOccurrenceRequestDoc struct {
+	Description  string    `json:"description,omitempty" example:"Moved to the evening"`
+	Price        float64   `json:"price,omitempty" example:"549.00"`
+	LearnerLimit int       `json:"learner_limit,omitempty" example:"6"`
+	ClassStart   time.Time `json:"class_start,omitempty" example:"2025-09-08T19:00:00+07:00"`
+	ClassFinish  time.Time `json:"class_finish,omitempty" example:"2025-09-08T20:30:00+07:00"`
+}
+
+type ClassSessionSeriesDoc struct {
+	ClassID             uint              `json:"class_id" example:"12"`
+	RRule               string            `json:"rrule" example:"FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10"`
+	ExcludedDates       []string          `json:"excluded_dates" example:"2025-10-13"`
+	FirstStart          time.Time         `json:"first_start" example:"2025-09-01T18:00:00+07:00"`
+	DurationMinutes     int               `json:"duration_minutes" example:"90"`
+	EnrollmentLeadHours int               `json:"enrollment_lead_hours" example:"24"`
+	Description         string            `json:"description" example:"Weekly calculus tutoring"`
+	Price               float64           `json:"price" example:"499.00"`
+	LearnerLimit        int               `json:"learner_limit" example:"5"`
+	Status              string            `json:"status" example:"active"`
+	Sessions            []ClassSessionDoc `json:"sessions"`
+}
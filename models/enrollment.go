@@ -1,14 +1,35 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// Enrollment statuses. A no-show is an active enrollment whose learner never
+// joined the meeting and was not marked present by the teacher; a refunded
+// enrollment no longer holds a seat.
+const (
+	EnrollmentStatusActive   = "active"
+	EnrollmentStatusNoShow   = "no_show"
+	EnrollmentStatusRefunded = "refunded"
+)
+
+var enrollmentStatuses = []string{EnrollmentStatusActive, EnrollmentStatusNoShow, EnrollmentStatusRefunded}
+
 type Enrollment struct {
 	gorm.Model
-	LearnerID        uint   `json:"learner_id" gorm:"not null;uniqueIndex:idx_learner_session"`
-	ClassSessionID   uint   `json:"class_session_id" gorm:"not null;uniqueIndex:idx_learner_session"`
-	EnrollmentStatus string `json:"enrollment_status" gorm:"size:20"`
+	LearnerID        uint       `json:"learner_id" gorm:"not null;uniqueIndex:idx_learner_session"`
+	ClassSessionID   uint       `json:"class_session_id" gorm:"not null;uniqueIndex:idx_learner_session"`
+	EnrollmentStatus string     `json:"enrollment_status" gorm:"size:20"`
+	AttendedAt       *time.Time `json:"attended_at,omitempty"`
+	AmountPaid       float64    `json:"amount_paid" gorm:"type:numeric(12,2);default:0"` // debited from the learner's balance when booking; refunds never exceed it
+	RefundAmount     float64    `json:"refund_amount" gorm:"type:numeric(12,2);default:0"`
+	RefundedAt       *time.Time `json:"refunded_at,omitempty"`
+	// Wrong check-in codes entered in a row, and until when a learner who
+	// entered too many may not try again.
+	CheckInFailures    int        `json:"-" gorm:"not null;default:0"`
+	CheckInLockedUntil *time.Time `json:"-"`
 
 	Learner      Learner      `gorm:"foreignKey:LearnerID;references:ID;constraint:OnDelete:CASCADE"`
 	ClassSession ClassSession `gorm:"foreignKey:ClassSessionID;references:ID;constraint:OnDelete:CASCADE"`
@@ -22,7 +43,11 @@ type EnrollmentResponse struct {
 // ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
 
 type EnrollmentDoc struct {
-	LearnerID        uint   `json:"learner_id" example:"1"`
-	ClassSessionID   uint   `json:"class_session_id" example:"3"`
-	EnrollmentStatus string `json:"enrollment_status" example:"active"`
+	LearnerID        uint       `json:"learner_id" example:"1"`
+	ClassSessionID   uint       `json:"class_session_id" example:"3"`
+	EnrollmentStatus string     `json:"enrollment_status" example:"active"`
+	AttendedAt       *time.Time `json:"attended_at,omitempty" example:"2025-09-05T14:03:00Z"`
+	AmountPaid       float64    `json:"amount_paid" example:"500.00"`
+	RefundAmount     float64    `json:"refund_amount" example:"0"`
+	RefundedAt       *time.Time `json:"refunded_at,omitempty"`
 }
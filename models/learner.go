@@ -1,17 +1,25 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Learner struct {
 	gorm.Model
-	UserID     uint            `json:"user_id" gorm:"unique;not null"`
-	FlagCount  int             `json:"flag_count" gorm:"default:0;not null"`
-	Interested []ClassCategory `gorm:"many2many:interested_class_categories;constraint:OnDelete:CASCADE"`
+	UserID        uint            `json:"user_id" gorm:"unique;not null"`
+	FlagCount     int             `json:"flag_count" gorm:"default:0;not null"`
+	LastFlaggedAt *time.Time      `json:"last_flagged_at,omitempty"`
+	LastDecayedAt *time.Time      `json:"last_decayed_at,omitempty"`
+	NoShowCount   int             `json:"no_show_count" gorm:"default:0;not null"`
+	Interested    []ClassCategory `gorm:"many2many:interested_class_categories;constraint:OnDelete:CASCADE"`
 }
 
 // ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
 
 type LearnerDoc struct {
-	UserID    uint `json:"user_id" example:"1"`
-	FlagCount int  `json:"flag_count" example:"1"`
+	UserID      uint `json:"user_id" example:"1"`
+	FlagCount   int  `json:"flag_count" example:"1"`
+	NoShowCount int  `json:"no_show_count" example:"0"`
 }
@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+// MeetingJoinInfo is what one participant needs to join a session's
+// meeting. MeetingLink is personal: it carries JWT when the provider issues
+// tokens and only works for that participant until ExpiresAt.
+type MeetingJoinInfo struct {
+	Provider    string     `json:"provider"`
+	MeetingLink string     `json:"meeting_link"`
+	Room        string     `json:"room"`
+	JWT         string     `json:"jwt,omitempty"`
+	Moderator   bool       `json:"moderator"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// Meeting access roles.
+const (
+	MeetingRoleTeacher = "teacher"
+	MeetingRoleLearner = "learner"
+)
+
+// MeetingAccess is an append-only log of join links handed out for a
+// ClassSession's meeting.
+type MeetingAccess struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt      time.Time `json:"created_at"`
+	ClassSessionID uint      `json:"class_session_id" gorm:"not null;index"`
+	UserID         uint      `json:"user_id" gorm:"not null;index"`
+	Role           string    `json:"role" gorm:"size:10;not null"`
+	IP             string    `json:"ip" gorm:"size:45"`
+}
+
+// MeetingPresence is one stay of a participant in a ClassSession's meeting,
+// as reported by the provider's webhooks. LeftAt is nil while they are in
+// the room.
+type MeetingPresence struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	ClassSessionID uint       `json:"class_session_id" gorm:"not null;index:idx_presence_session_user"`
+	UserID         uint       `json:"user_id" gorm:"not null;index:idx_presence_session_user"`
+	Role           string     `json:"role" gorm:"size:10;not null"`
+	JoinedAt       time.Time  `json:"joined_at" gorm:"not null"`
+	LeftAt         *time.Time `json:"left_at,omitempty"`
+}
+
+// ParticipantPresence totals one participant's time in a session's meeting.
+// Overlapping stays, e.g. from two devices, are counted once.
+type ParticipantPresence struct {
+	UserID        uint       `json:"user_id"`
+	Role          string     `json:"role"`
+	FirstJoinedAt time.Time  `json:"first_joined_at"`
+	LastLeftAt    *time.Time `json:"last_left_at,omitempty"`
+	InRoom        bool       `json:"in_room"`
+	Seconds       int64      `json:"seconds_in_room"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type MeetingJoinInfoDoc struct {
+	Provider    string    `json:"provider" example:"jitsi"`
+	MeetingLink string    `json:"meeting_link" example:"https://meet.example.com/3f1c9a0e5b7d?jwt=eyJhbGciOiJIUzI1NiIs..."`
+	Room        string    `json:"room" example:"3f1c9a0e5b7d"`
+	JWT         string    `json:"jwt,omitempty" example:"eyJhbGciOiJIUzI1NiIs..."`
+	Moderator   bool      `json:"moderator" example:"true"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty" example:"2025-09-05T16:15:00Z"`
+}
+
+type MeetingAttendeeDoc struct {
+	ID        string `json:"id" example:"42"`
+	Name      string `json:"name" example:"Alice Smith"`
+	Moderator bool   `json:"moderator" example:"false"`
+}
+
+type MeetingAccessDoc struct {
+	ID             uint      `json:"id" example:"81"`
+	CreatedAt      time.Time `json:"created_at" example:"2025-09-05T13:52:10Z"`
+	ClassSessionID uint      `json:"class_session_id" example:"3"`
+	UserID         uint      `json:"user_id" example:"42"`
+	Role           string    `json:"role" example:"learner"`
+	IP             string    `json:"ip" example:"203.0.113.7"`
+}
+
+type ParticipantPresenceDoc struct {
+	UserID        uint      `json:"user_id" example:"42"`
+	Role          string    `json:"role" example:"learner"`
+	FirstJoinedAt time.Time `json:"first_joined_at" example:"2025-09-05T13:58:12Z"`
+	LastLeftAt    time.Time `json:"last_left_at,omitempty" example:"2025-09-05T16:01:40Z"`
+	InRoom        bool      `json:"in_room" example:"false"`
+	Seconds       int64     `json:"seconds_in_room" example:"7408"`
+}
@@ -11,6 +11,10 @@ import (
 )
 
 func Migrate(db *gorm.DB) {
+	if err := dedupeReports(db); err != nil {
+		log.Fatalf("report deduplication failed: %v", err)
+	}
+
 	err := db.AutoMigrate(
 		&User{},
 		&Admin{},
@@ -20,18 +24,52 @@ func Migrate(db *gorm.DB) {
 		&BanDetailsTeacher{},
 		&Class{},
 		&ClassCategory{},
+		&ClassSessionSeries{},
 		&ClassSession{},
 		&Enrollment{},
 		&Notification{},
+		&ReportCase{},
 		&Report{},
 		&Review{},
 		&Transaction{},
+		&AdminTwoFactor{},
+		&AdminRecoveryCode{},
+		&APIKey{},
+		&ModerationPolicy{},
+		&BanAppeal{},
+		&ReportNote{},
+		&ReportAttachment{},
+		&Attendance{},
+		&SessionReschedule{},
+		&RescheduleResponse{},
+		&SessionHistory{},
+		&TeacherAvailability{},
+		&AvailabilityException{},
+		&CalendarFeed{},
+		&MeetingAccess{},
+		&MeetingPresence{},
+		&StoragePolicy{},
+		&SessionAsset{},
+		&UploadSession{},
 	)
 	if err != nil {
 		log.Fatalf("migration failed: %v", err)
 	}
 	log.Println("Database migrated successfully")
 
+	if err := migrateLegacyReportStatuses(db); err != nil {
+		log.Fatalf("report status migration failed: %v", err)
+	}
+	if err := migrateLegacySessionStatuses(db); err != nil {
+		log.Fatalf("class session status migration failed: %v", err)
+	}
+	if err := migrateLegacyEnrollmentStatuses(db); err != nil {
+		log.Fatalf("enrollment status migration failed: %v", err)
+	}
+	if err := backfillLastFlaggedAt(db); err != nil {
+		log.Fatalf("flag date backfill failed: %v", err)
+	}
+
 	if config.STATUS() == "development" {
 		var dummy int
 		tx := db.Table("users").Select("1").Limit(1).Scan(&dummy)
@@ -53,6 +91,75 @@ func Migrate(db *gorm.DB) {
 
 }
 
+// dedupeReports soft-deletes all but the first of the reports one reporter
+// filed against the same user, session and reason, which concurrent
+// submissions could create before idx_report_reporter_subject made them
+// unique.
+func dedupeReports(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&Report{}) || db.Migrator().HasIndex(&Report{}, "idx_report_reporter_subject") {
+		return nil
+	}
+	return db.Exec(`UPDATE reports SET deleted_at = ? WHERE deleted_at IS NULL AND report_user_id IS NOT NULL AND id NOT IN (
+		SELECT MIN(id) FROM reports WHERE deleted_at IS NULL AND report_user_id IS NOT NULL
+		GROUP BY report_user_id, reported_user_id, class_session_id, report_reason)`, time.Now()).Error
+}
+
+// migrateLegacyReportStatuses rewrites the pre-lifecycle "pending", "resolve"
+// and "reject" values to their ReportStatus equivalents.
+func migrateLegacyReportStatuses(db *gorm.DB) error {
+	for legacy, status := range legacyReportStatuses {
+		if err := db.Model(&Report{}).Where("report_status = ?", legacy).Update("report_status", status).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateLegacySessionStatuses maps free-form statuses onto the lifecycle
+// and completes sessions that ended before AttendanceCheckLookback, which
+// were never tracked and must not be reported as absences.
+func migrateLegacySessionStatuses(db *gorm.DB) error {
+	if err := db.Model(&ClassSession{}).Where("class_status <> LOWER(class_status)").
+		Update("class_status", gorm.Expr("LOWER(class_status)")).Error; err != nil {
+		return err
+	}
+	for legacy, status := range legacySessionStatuses {
+		if err := db.Model(&ClassSession{}).Where("class_status = ?", legacy).Update("class_status", status).Error; err != nil {
+			return err
+		}
+	}
+	return db.Model(&ClassSession{}).
+		Where("class_status IN ? AND class_finish < ?", UpcomingSessionStatuses, time.Now().Add(-AttendanceCheckLookback)).
+		Update("class_status", SessionStatusCompleted).Error
+}
+
+// migrateLegacyEnrollmentStatuses makes enrollments written while clients
+// still set their own status, such as "Success" or blank, active, so they
+// keep their seat and are checked for no-shows.
+func migrateLegacyEnrollmentStatuses(db *gorm.DB) error {
+	if err := db.Model(&Enrollment{}).Where("enrollment_status <> LOWER(enrollment_status)").
+		Update("enrollment_status", gorm.Expr("LOWER(enrollment_status)")).Error; err != nil {
+		return err
+	}
+	return db.Model(&Enrollment{}).
+		Where("enrollment_status IS NULL OR enrollment_status NOT IN ?", enrollmentStatuses).
+		Update("enrollment_status", EnrollmentStatusActive).Error
+}
+
+// backfillLastFlaggedAt stamps flagged learners and teachers that predate
+// LastFlaggedAt with the current time, so their flags decay after a full clean
+// period from now rather than all at once on the next decay run.
+func backfillLastFlaggedAt(db *gorm.DB) error {
+	now := time.Now()
+	for _, model := range []any{&Learner{}, &Teacher{}} {
+		if err := db.Model(model).Where("flag_count > 0 AND last_flagged_at IS NULL").
+			Update("last_flagged_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 /* -------------------- Helper for seed the database ,it will do nothing if entry already exist -------------------- */
 
 func seedHelper[T any](tx *gorm.DB, items []T, conflictCols ...string) error {
@@ -270,7 +377,7 @@ func Seed(db *gorm.DB) error {
 				ReportReason:      "spam",
 				ReportDescription: "Spam messages",
 				ReportDate:        now,
-				ReportStatus:      "pending",
+				ReportStatus:      ReportStatusSubmitted,
 			},
 			{
 				ReportUserID:      userBySID[userKeys[3]].ID,
@@ -280,7 +387,7 @@ func Seed(db *gorm.DB) error {
 				ReportReason:      "inappropriate",
 				ReportDescription: "Inappropriate content",
 				ReportDate:        now,
-				ReportStatus:      "pending",
+				ReportStatus:      ReportStatusSubmitted,
 			},
 		}
 		if err := seedHelper(tx, reportRows); err != nil {
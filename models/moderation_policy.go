@@ -0,0 +1,119 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ModerationPolicy holds the tunable enforcement rules used by the flag, ban and
+// absence services. Only one row is kept; see services.GetModerationPolicy.
+type ModerationPolicy struct {
+	gorm.Model
+	FlagThreshold        int                                `json:"flag_threshold" gorm:"not null;default:3;check:flag_threshold > 0"`
+	BanDurationHours     int                                `json:"ban_duration_hours" gorm:"not null;default:168;check:ban_duration_hours > 0"`
+	AbsenceAutoFlagLimit int                                `json:"absence_auto_flag_limit" gorm:"not null;default:2"`
+	FalseReportFlags     int                                `json:"false_report_flags" gorm:"not null;default:1"`
+	FlagDecayDays        int                                `json:"flag_decay_days" gorm:"not null;default:30"`
+	BanEscalationHours   datatypes.JSONSlice[int]           `json:"ban_escalation_hours" gorm:"type:jsonb" swaggertype:"array,integer"`
+	ReasonFlagWeights    datatypes.JSONType[map[string]int] `json:"reason_flag_weights" gorm:"type:jsonb" swaggertype:"object"`
+	TriageSLAHours       int                                `json:"triage_sla_hours" gorm:"not null;default:24"`
+	ResolutionSLAHours   int                                `json:"resolution_sla_hours" gorm:"not null;default:72"`
+	ReportsPerDayLimit   int                                `json:"reports_per_day_limit" gorm:"not null;default:10"`
+	NoShowFlagThreshold  int                                `json:"no_show_flag_threshold" gorm:"not null;default:3"`
+	NoShowGraceMinutes   int                                `json:"no_show_grace_minutes" gorm:"not null;default:15"`
+	LateCancelHours      int                                `json:"late_cancel_hours" gorm:"not null;default:24"`
+	LateCancelFlags      int                                `json:"late_cancel_flags" gorm:"not null;default:1"`
+	UpdatedByUserID      *uint                              `json:"updated_by_user_id,omitempty"`
+}
+
+// FlagsForReason returns how many flags a resolved report with the given reason is worth.
+func (p *ModerationPolicy) FlagsForReason(reason string) int {
+	return p.ReasonFlagWeights.Data()[reason]
+}
+
+// PermanentBanEnd is stored as BanEnd for bans that never expire.
+var PermanentBanEnd = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// BanEndFor returns when a ban starting at start ends for a user who has
+// already been banned priorBans times. BanEscalationHours is indexed by
+// priorBans (the last entry repeats) and 0 means permanent; without a
+// ladder every ban lasts BanDurationHours.
+func (p *ModerationPolicy) BanEndFor(priorBans int, start time.Time) time.Time {
+	hours := p.BanDurationHours
+	if n := len(p.BanEscalationHours); n > 0 {
+		hours = p.BanEscalationHours[min(max(priorBans, 0), n-1)]
+	}
+	if hours == 0 {
+		return PermanentBanEnd
+	}
+	return start.Add(time.Duration(hours) * time.Hour)
+}
+
+// UpdateModerationPolicyRequest is the body of PUT /moderation/policy. Fields
+// left out keep their current values; a list or map given replaces the stored
+// one, so reasons can be removed from ReasonFlagWeights.
+type UpdateModerationPolicyRequest struct {
+	FlagThreshold        *int            `json:"flag_threshold"`
+	BanDurationHours     *int            `json:"ban_duration_hours"`
+	AbsenceAutoFlagLimit *int            `json:"absence_auto_flag_limit"`
+	FalseReportFlags     *int            `json:"false_report_flags"`
+	FlagDecayDays        *int            `json:"flag_decay_days"`
+	BanEscalationHours   *[]int          `json:"ban_escalation_hours"`
+	ReasonFlagWeights    *map[string]int `json:"reason_flag_weights"`
+	TriageSLAHours       *int            `json:"triage_sla_hours"`
+	ResolutionSLAHours   *int            `json:"resolution_sla_hours"`
+	ReportsPerDayLimit   *int            `json:"reports_per_day_limit"`
+	NoShowFlagThreshold  *int            `json:"no_show_flag_threshold"`
+	NoShowGraceMinutes   *int            `json:"no_show_grace_minutes"`
+	LateCancelHours      *int            `json:"late_cancel_hours"`
+	LateCancelFlags      *int            `json:"late_cancel_flags"`
+}
+
+// ApplyTo copies the fields set in r onto p.
+func (r *UpdateModerationPolicyRequest) ApplyTo(p *ModerationPolicy) {
+	for dst, src := range map[*int]*int{
+		&p.FlagThreshold:        r.FlagThreshold,
+		&p.BanDurationHours:     r.BanDurationHours,
+		&p.AbsenceAutoFlagLimit: r.AbsenceAutoFlagLimit,
+		&p.FalseReportFlags:     r.FalseReportFlags,
+		&p.FlagDecayDays:        r.FlagDecayDays,
+		&p.TriageSLAHours:       r.TriageSLAHours,
+		&p.ResolutionSLAHours:   r.ResolutionSLAHours,
+		&p.ReportsPerDayLimit:   r.ReportsPerDayLimit,
+		&p.NoShowFlagThreshold:  r.NoShowFlagThreshold,
+		&p.NoShowGraceMinutes:   r.NoShowGraceMinutes,
+		&p.LateCancelHours:      r.LateCancelHours,
+		&p.LateCancelFlags:      r.LateCancelFlags,
+	} {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	if r.BanEscalationHours != nil {
+		p.BanEscalationHours = datatypes.JSONSlice[int](*r.BanEscalationHours)
+	}
+	if r.ReasonFlagWeights != nil {
+		p.ReasonFlagWeights = datatypes.NewJSONType(*r.ReasonFlagWeights)
+	}
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type ModerationPolicyDoc struct {
+	FlagThreshold        int            `json:"flag_threshold" example:"3"`
+	BanDurationHours     int            `json:"ban_duration_hours" example:"168"`
+	AbsenceAutoFlagLimit int            `json:"absence_auto_flag_limit" example:"2"`
+	FalseReportFlags     int            `json:"false_report_flags" example:"1"`
+	FlagDecayDays        int            `json:"flag_decay_days" example:"30"`
+	BanEscalationHours   []int          `json:"ban_escalation_hours" swaggertype:"array,integer" example:"168,720,0"`
+	ReasonFlagWeights    map[string]int `json:"reason_flag_weights" swaggertype:"object,integer" example:"harassment:2"`
+	TriageSLAHours       int            `json:"triage_sla_hours" example:"24"`
+	ResolutionSLAHours   int            `json:"resolution_sla_hours" example:"72"`
+	ReportsPerDayLimit   int            `json:"reports_per_day_limit" example:"10"`
+	NoShowFlagThreshold  int            `json:"no_show_flag_threshold" example:"3"`
+	NoShowGraceMinutes   int            `json:"no_show_grace_minutes" example:"15"`
+	LateCancelHours      int            `json:"late_cancel_hours" example:"24"`
+	LateCancelFlags      int            `json:"late_cancel_flags" example:"1"`
+}
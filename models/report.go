@@ -6,35 +6,125 @@ import (
 	"gorm.io/gorm"
 )
 
+// Report lifecycle states. Resolved and rejected are terminal.
+const (
+	ReportStatusSubmitted   = "submitted"
+	ReportStatusTriaged     = "triaged"
+	ReportStatusUnderReview = "under_review"
+	ReportStatusResolved    = "resolved"
+	ReportStatusRejected    = "rejected"
+	ReportStatusEscalated   = "escalated"
+)
+
+// reportTransitions lists the statuses each status may move to.
+var reportTransitions = map[string][]string{
+	ReportStatusSubmitted:   {ReportStatusTriaged, ReportStatusUnderReview, ReportStatusEscalated, ReportStatusResolved, ReportStatusRejected},
+	ReportStatusTriaged:     {ReportStatusUnderReview, ReportStatusEscalated, ReportStatusResolved, ReportStatusRejected},
+	ReportStatusUnderReview: {ReportStatusEscalated, ReportStatusResolved, ReportStatusRejected},
+	ReportStatusEscalated:   {ReportStatusUnderReview, ReportStatusResolved, ReportStatusRejected},
+}
+
+// legacyReportStatuses maps values written before the lifecycle existed.
+var legacyReportStatuses = map[string]string{
+	"pending": ReportStatusSubmitted,
+	"resolve": ReportStatusResolved,
+	"reject":  ReportStatusRejected,
+}
+
+// OpenReportStatuses are the statuses that still need moderator attention.
+var OpenReportStatuses = []string{ReportStatusSubmitted, ReportStatusTriaged, ReportStatusUnderReview, ReportStatusEscalated}
+
+// NormalizeReportStatus maps legacy spellings onto lifecycle statuses.
+func NormalizeReportStatus(status string) string {
+	if s, ok := legacyReportStatuses[status]; ok {
+		return s
+	}
+	return status
+}
+
+// CanTransitionReport reports whether a report may move from one status to another.
+func CanTransitionReport(from, to string) bool {
+	for _, s := range reportTransitions[NormalizeReportStatus(from)] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
 type Report struct {
 	gorm.Model
-	ReportUserID      uint      `json:"report_user_id"`
-	ReportedUserID    uint      `json:"reported_user_id" gorm:"not null"`
-	ClassSessionID    uint      `json:"class_session_id" gorm:"not null"`
-	ReportType        string    `json:"report_type" gorm:"size:20;not null"`
-	ReportReason      string    `json:"report_reason" gorm:"size:50;not null"`
-	ReportDescription string    `json:"report_description" gorm:"size:255"`
-	ReportPictureURL  string    `json:"report_picture,omitempty"`
-	ReportDate        time.Time `json:"report_date" gorm:"default:CURRENT_TIMESTAMP"`
-	ReportStatus      string    `json:"report_status" gorm:"size:10;default:'pending'"`
-	ReportResult      string    `json:"report_result" gorm:"size:255"`
-
-	Reporter     User         `gorm:"foreignKey:ReportUserID;constraint:OnDelete:SET NULL"`
-	Reported     User         `gorm:"foreignKey:ReportedUserID;constraint:OnDelete:CASCADE"`
-	ClassSession ClassSession `gorm:"foreignKey:ClassSessionID;constraint:OnDelete:CASCADE"`
+	ReportUserID        uint       `json:"report_user_id" gorm:"uniqueIndex:idx_report_reporter_subject,where:deleted_at IS NULL"`
+	ReportedUserID      uint       `json:"reported_user_id" gorm:"not null;uniqueIndex:idx_report_reporter_subject"`
+	ClassSessionID      uint       `json:"class_session_id" gorm:"not null;uniqueIndex:idx_report_reporter_subject"`
+	ReportType          string     `json:"report_type" gorm:"size:20;not null"`
+	ReportReason        string     `json:"report_reason" gorm:"size:50;not null;uniqueIndex:idx_report_reporter_subject"`
+	ReportDescription   string     `json:"report_description" gorm:"size:255"`
+	ReportPictureURL    string     `json:"report_picture,omitempty"`
+	ReportDate          time.Time  `json:"report_date" gorm:"default:CURRENT_TIMESTAMP"`
+	ReportStatus        string     `json:"report_status" gorm:"size:20;default:'submitted';index"`
+	ReportResult        string     `json:"report_result" gorm:"size:255"`
+	Severity            int        `json:"severity" gorm:"not null;default:0"`
+	AssignedModeratorID *uint      `json:"assigned_moderator_id,omitempty" gorm:"index"`
+	CaseID              *uint      `json:"case_id,omitempty" gorm:"index"`
+	EvidenceDisclosed   bool       `json:"evidence_disclosed" gorm:"not null;default:false"`
+	TriageDueAt         *time.Time `json:"triage_due_at,omitempty"`
+	ResolutionDueAt     *time.Time `json:"resolution_due_at,omitempty"`
+	TriagedAt           *time.Time `json:"triaged_at,omitempty"`
+	EscalatedAt         *time.Time `json:"escalated_at,omitempty"`
+	ClosedAt            *time.Time `json:"closed_at,omitempty"`
+
+	Reporter          User         `gorm:"foreignKey:ReportUserID;constraint:OnDelete:SET NULL"`
+	Reported          User         `gorm:"foreignKey:ReportedUserID;constraint:OnDelete:CASCADE"`
+	ClassSession      ClassSession `gorm:"foreignKey:ClassSessionID;constraint:OnDelete:CASCADE"`
+	AssignedModerator *User        `json:"-" gorm:"foreignKey:AssignedModeratorID;constraint:OnDelete:SET NULL"`
+}
+
+// ReportNote is an internal moderator note; it is never shown to the reporter
+// or the reported user.
+type ReportNote struct {
+	gorm.Model
+	ReportID     uint   `json:"report_id" gorm:"index;not null"`
+	AuthorUserID uint   `json:"author_user_id" gorm:"not null"`
+	Body         string `json:"body" gorm:"size:1000;not null"`
+
+	Report Report `json:"-" gorm:"foreignKey:ReportID;constraint:OnDelete:CASCADE"`
 }
 
 // ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
 
 type ReportDoc struct {
-	ReportUserID      uint      `json:"report_user_id" example:"5"`
-	ReportedUserID    uint      `json:"reported_user_id" example:"8"`
-	ClassSessionID    uint      `json:"class_session_id" example:"20"`
-	ReportType        string    `json:"report_type" example:"Abuse"`
-	ReportReason      string    `json:"report_reason" example:"teacher_absent"`
-	ReportDescription string    `json:"report_description" example:"User sent inappropriate messages"`
-	ReportPicture     string    `json:"report_picture,omitempty" example:"<base64-encoded-image>"`
-	ReportDate        time.Time `json:"report_date" example:"2025-08-20T14:30:00Z"`
-	ReportStatus      string    `json:"report_status" example:"pending"`
-	ReportResult      string    `json:"report_result,omitempty" example:"Report approved"`
+	ReportUserID        uint       `json:"report_user_id" example:"5"`
+	ReportedUserID      uint       `json:"reported_user_id" example:"8"`
+	ClassSessionID      uint       `json:"class_session_id" example:"20"`
+	ReportType          string     `json:"report_type" example:"Abuse"`
+	ReportReason        string     `json:"report_reason" example:"teacher_absent"`
+	ReportDescription   string     `json:"report_description" example:"User sent inappropriate messages"`
+	ReportPicture       string     `json:"report_picture,omitempty" example:"<base64-encoded-image>"`
+	ReportDate          time.Time  `json:"report_date" example:"2025-08-20T14:30:00Z"`
+	ReportStatus        string     `json:"report_status" example:"submitted"`
+	ReportResult        string     `json:"report_result,omitempty" example:"Report approved"`
+	Severity            int        `json:"severity" example:"2"`
+	AssignedModeratorID *uint      `json:"assigned_moderator_id,omitempty" example:"1"`
+	CaseID              *uint      `json:"case_id,omitempty" example:"3"`
+	EvidenceDisclosed   bool       `json:"evidence_disclosed" example:"false"`
+	TriageDueAt         *time.Time `json:"triage_due_at,omitempty" example:"2025-08-21T14:30:00Z"`
+	ResolutionDueAt     *time.Time `json:"resolution_due_at,omitempty" example:"2025-08-23T14:30:00Z"`
+	TriagedAt           *time.Time `json:"triaged_at,omitempty" example:"2025-08-20T16:00:00Z"`
+	EscalatedAt         *time.Time `json:"escalated_at,omitempty" example:"2025-08-21T10:00:00Z"`
+	ClosedAt            *time.Time `json:"closed_at,omitempty" example:"2025-08-22T09:00:00Z"`
+}
+
+type ReportTransitionDoc struct {
+	ReportStatus string `json:"report_status" example:"under_review"`
+	ReportResult string `json:"report_result,omitempty" example:"Warning issued"`
+	Note         string `json:"note,omitempty" example:"Checked the session chat log"`
+}
+
+type ReportAssignDoc struct {
+	ModeratorUserID uint `json:"moderator_user_id" example:"1"`
+}
+
+type ReportNoteDoc struct {
+	Body string `json:"body" example:"Reporter sent a follow-up with more screenshots"`
 }
@@ -0,0 +1,35 @@
+package models
+
+import "gorm.io/gorm"
+
+// ReportAttachment is one piece of evidence stored in MinIO for a report.
+// ObjectKey is never exposed; readers get a short-lived presigned URL instead.
+type ReportAttachment struct {
+	gorm.Model
+	ReportID       uint   `json:"report_id" gorm:"index;not null"`
+	UploaderUserID uint   `json:"uploader_user_id" gorm:"not null"`
+	Kind           string `json:"kind" gorm:"size:20;not null"`
+	FileName       string `json:"file_name" gorm:"size:255"`
+	ContentType    string `json:"content_type" gorm:"size:100;not null"`
+	SizeBytes      int64  `json:"size_bytes" gorm:"not null"`
+	ObjectKey      string `json:"-" gorm:"size:255;not null"`
+	URL            string `json:"url,omitempty" gorm:"-"`
+
+	Report Report `json:"-" gorm:"foreignKey:ReportID;constraint:OnDelete:CASCADE"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type ReportAttachmentDoc struct {
+	ReportID       uint   `json:"report_id" example:"1"`
+	UploaderUserID uint   `json:"uploader_user_id" example:"5"`
+	Kind           string `json:"kind" example:"image"`
+	FileName       string `json:"file_name" example:"chat-screenshot.png"`
+	ContentType    string `json:"content_type" example:"image/png"`
+	SizeBytes      int64  `json:"size_bytes" example:"184223"`
+	URL            string `json:"url,omitempty" example:"https://minio.example.com/tutorium/reports/attachments/1724155200000000000.png?X-Amz-Signature=..."`
+}
+
+type ReportDisclosureDoc struct {
+	EvidenceDisclosed bool `json:"evidence_disclosed" example:"true"`
+}
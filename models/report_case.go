@@ -0,0 +1,29 @@
+package models
+
+import "gorm.io/gorm"
+
+// ReportCase groups every report filed against the same user for the same
+// class session, so moderators review one consolidated case. WeightScore sums
+// the severity of the grouped reports (at least 1 each) and grows with every
+// additional reporter.
+type ReportCase struct {
+	gorm.Model
+	ReportedUserID uint `json:"reported_user_id" gorm:"not null;uniqueIndex:idx_report_case_subject"`
+	ClassSessionID uint `json:"class_session_id" gorm:"not null;uniqueIndex:idx_report_case_subject"`
+	ReportCount    int  `json:"report_count" gorm:"not null;default:0"`
+	ReporterCount  int  `json:"reporter_count" gorm:"not null;default:0"`
+	WeightScore    int  `json:"weight_score" gorm:"not null;default:0;index"`
+
+	Reports []Report `json:"reports,omitempty" gorm:"foreignKey:CaseID"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type ReportCaseDoc struct {
+	ReportedUserID uint        `json:"reported_user_id" example:"8"`
+	ClassSessionID uint        `json:"class_session_id" example:"20"`
+	ReportCount    int         `json:"report_count" example:"4"`
+	ReporterCount  int         `json:"reporter_count" example:"3"`
+	WeightScore    int         `json:"weight_score" example:"7"`
+	Reports        []ReportDoc `json:"reports,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ScheduleConflict is a session that overlaps the time a teacher or learner
+// is trying to book.
+type ScheduleConflict struct {
+	ClassSessionID uint      `json:"class_session_id"`
+	ClassID        uint      `json:"class_id"`
+	ClassName      string    `json:"class_name"`
+	ClassStart     time.Time `json:"class_start"`
+	ClassFinish    time.Time `json:"class_finish"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type ScheduleConflictDoc struct {
+	ClassSessionID uint      `json:"class_session_id" example:"7"`
+	ClassID        uint      `json:"class_id" example:"12"`
+	ClassName      string    `json:"class_name" example:"Calculus I"`
+	ClassStart     time.Time `json:"class_start" example:"2025-09-05T14:00:00Z"`
+	ClassFinish    time.Time `json:"class_finish" example:"2025-09-05T16:00:00Z"`
+}
+
+type ScheduleConflictErrorDoc struct {
+	Error     string                `json:"error" example:"schedule conflicts with 1 other session(s)"`
+	Conflicts []ScheduleConflictDoc `json:"conflicts"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session asset kinds.
+const (
+	SessionAssetRecording = "recording"
+	SessionAssetSlides    = "slides"
+	SessionAssetWorksheet = "worksheet"
+)
+
+// SessionAsset is a recording or teaching material a teacher shared with a
+// ClassSession's learners. The object lives in MinIO and counts against the
+// teacher's storage quota until it is deleted or ExpiresAt passes. ObjectKey
+// is never exposed; readers get a short-lived presigned URL instead.
+type SessionAsset struct {
+	gorm.Model
+	ClassSessionID uint       `json:"class_session_id" gorm:"not null;index"`
+	TeacherID      uint       `json:"teacher_id" gorm:"not null;index"`
+	Kind           string     `json:"kind" gorm:"size:20;not null"`
+	Title          string     `json:"title" gorm:"size:255"`
+	FileName       string     `json:"file_name" gorm:"size:255"`
+	ContentType    string     `json:"content_type" gorm:"size:100;not null"`
+	SizeBytes      int64      `json:"size_bytes" gorm:"not null"`
+	ObjectKey      string     `json:"-" gorm:"size:255;not null"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	URL            string     `json:"url,omitempty" gorm:"-"`
+
+	ClassSession ClassSession `json:"-" gorm:"foreignKey:ClassSessionID;constraint:OnDelete:CASCADE"`
+}
+
+// StorageUsage is how much of their quota a teacher's session assets use.
+type StorageUsage struct {
+	TeacherID  uint  `json:"teacher_id"`
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type SessionAssetDoc struct {
+	ID             uint      `json:"ID" example:"14"`
+	ClassSessionID uint      `json:"class_session_id" example:"3"`
+	TeacherID      uint      `json:"teacher_id" example:"2"`
+	Kind           string    `json:"kind" example:"recording"`
+	Title          string    `json:"title" example:"Week 3 recording"`
+	FileName       string    `json:"file_name" example:"calculus-week3.mp4"`
+	ContentType    string    `json:"content_type" example:"video/mp4"`
+	SizeBytes      int64     `json:"size_bytes" example:"734003200"`
+	ExpiresAt      time.Time `json:"expires_at,omitempty" example:"2025-12-04T16:00:00Z"`
+	URL            string    `json:"url,omitempty" example:"https://minio.example.com/tutorium/sessions/3/assets/1725552000000000000.mp4?X-Amz-Signature=..."`
+}
+
+type StorageUsageDoc struct {
+	TeacherID  uint  `json:"teacher_id" example:"2"`
+	UsedBytes  int64 `json:"used_bytes" example:"1288490188"`
+	QuotaBytes int64 `json:"quota_bytes" example:"5368709120"`
+}
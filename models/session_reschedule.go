@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SessionReschedule statuses.
+const (
+	RescheduleStatusPending   = "pending"
+	RescheduleStatusApplied   = "applied"
+	RescheduleStatusWithdrawn = "withdrawn"
+)
+
+// Learner decisions on a proposed reschedule.
+const (
+	RescheduleDecisionPending  = "pending"
+	RescheduleDecisionAccepted = "accepted"
+	RescheduleDecisionDeclined = "declined"
+)
+
+// SessionReschedule is a teacher's proposal to move a ClassSession. Enrolled
+// learners answer until ResponseDeadline; the session then moves and learners
+// who declined have already been refunded.
+type SessionReschedule struct {
+	gorm.Model
+	ClassSessionID   uint       `json:"class_session_id" gorm:"not null;index"`
+	ProposedByUserID uint       `json:"proposed_by_user_id" gorm:"not null"`
+	OldStart         time.Time  `json:"old_start" gorm:"not null"`
+	OldFinish        time.Time  `json:"old_finish" gorm:"not null"`
+	NewStart         time.Time  `json:"new_start" gorm:"not null"`
+	NewFinish        time.Time  `json:"new_finish" gorm:"not null"`
+	ResponseDeadline time.Time  `json:"response_deadline" gorm:"not null;index"`
+	Reason           string     `json:"reason" gorm:"size:255"`
+	Status           string     `json:"status" gorm:"size:10;not null;default:'pending';index"`
+	AppliedAt        *time.Time `json:"applied_at,omitempty"`
+
+	Responses []RescheduleResponse `json:"responses,omitempty" gorm:"foreignKey:RescheduleID;constraint:OnDelete:CASCADE"`
+}
+
+// RescheduleResponse is one enrolled learner's answer to a SessionReschedule.
+type RescheduleResponse struct {
+	gorm.Model
+	RescheduleID uint       `json:"reschedule_id" gorm:"not null;uniqueIndex:idx_reschedule_learner"`
+	LearnerID    uint       `json:"learner_id" gorm:"not null;uniqueIndex:idx_reschedule_learner"`
+	Decision     string     `json:"decision" gorm:"size:10;not null;default:'pending'"`
+	RespondedAt  *time.Time `json:"responded_at,omitempty"`
+}
+
+// SessionHistory is an append-only log of what happened to a ClassSession.
+type SessionHistory struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt      time.Time `json:"created_at"`
+	ClassSessionID uint      `json:"class_session_id" gorm:"not null;index"`
+	ActorUserID    *uint     `json:"actor_user_id,omitempty"`
+	Event          string    `json:"event" gorm:"size:30;not null"`
+	Detail         string    `json:"detail" gorm:"size:500"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type ProposeRescheduleDoc struct {
+	ClassStart       time.Time `json:"class_start" example:"2025-09-06T14:00:00Z"`
+	ClassFinish      time.Time `json:"class_finish" example:"2025-09-06T16:00:00Z"`
+	ResponseDeadline time.Time `json:"response_deadline" example:"2025-09-04T12:00:00Z"`
+	Reason           string    `json:"reason" example:"Teacher is travelling"`
+}
+
+type RescheduleDecisionDoc struct {
+	Decision string `json:"decision" example:"accept"`
+}
+
+type RescheduleResponseDoc struct {
+	RescheduleID uint       `json:"reschedule_id" example:"2"`
+	LearnerID    uint       `json:"learner_id" example:"1"`
+	Decision     string     `json:"decision" example:"accepted"`
+	RespondedAt  *time.Time `json:"responded_at,omitempty" example:"2025-09-03T10:00:00Z"`
+}
+
+type SessionRescheduleDoc struct {
+	ClassSessionID   uint                    `json:"class_session_id" example:"3"`
+	ProposedByUserID uint                    `json:"proposed_by_user_id" example:"5"`
+	OldStart         time.Time               `json:"old_start" example:"2025-09-05T14:00:00Z"`
+	OldFinish        time.Time               `json:"old_finish" example:"2025-09-05T16:00:00Z"`
+	NewStart         time.Time               `json:"new_start" example:"2025-09-06T14:00:00Z"`
+	NewFinish        time.Time               `json:"new_finish" example:"2025-09-06T16:00:00Z"`
+	ResponseDeadline time.Time               `json:"response_deadline" example:"2025-09-04T12:00:00Z"`
+	Reason           string                  `json:"reason" example:"Teacher is travelling"`
+	Status           string                  `json:"status" example:"pending"`
+	AppliedAt        *time.Time              `json:"applied_at,omitempty"`
+	Responses        []RescheduleResponseDoc `json:"responses"`
+}
+
+type SessionHistoryDoc struct {
+	ClassSessionID uint      `json:"class_session_id" example:"3"`
+	ActorUserID    *uint     `json:"actor_user_id,omitempty" example:"5"`
+	Event          string    `json:"event" example:"reschedule_proposed"`
+	Detail         string    `json:"detail" example:"Proposed moving to 2025-09-06T14:00:00Z"`
+	CreatedAt      time.Time `json:"created_at" example:"2025-09-02T09:00:00Z"`
+}
+
+type CancelClassSessionDoc struct {
+	Reason string `json:"reason" example:"Teacher is ill"`
+}
+
+type CancellationResultDoc struct {
+	Session          ClassSessionDoc `json:"session"`
+	RefundedLearners int             `json:"refunded_learners" example:"4"`
+	RefundTotal      float64         `json:"refund_total" example:"1800.00"`
+	TeacherFlagged   bool            `json:"teacher_flagged" example:"false"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "gorm.io/gorm"
+
+// StoragePolicy holds the retention and quota rules for session assets. Only
+// one row is kept; see services.GetStoragePolicy.
+type StoragePolicy struct {
+	gorm.Model
+	RecordingRetentionDays int   `json:"recording_retention_days" gorm:"not null;default:90"`
+	MaterialRetentionDays  int   `json:"material_retention_days" gorm:"not null;default:0"`
+	TeacherQuotaBytes      int64 `json:"teacher_quota_bytes" gorm:"not null;default:5368709120"`
+	UpdatedByUserID        *uint `json:"updated_by_user_id,omitempty"`
+}
+
+// UpdateStoragePolicyRequest is the body of PUT /storage/policy. Fields left
+// out keep their current values.
+type UpdateStoragePolicyRequest struct {
+	RecordingRetentionDays *int   `json:"recording_retention_days"`
+	MaterialRetentionDays  *int   `json:"material_retention_days"`
+	TeacherQuotaBytes      *int64 `json:"teacher_quota_bytes"`
+}
+
+// ApplyTo copies the fields set in r onto p.
+func (r *UpdateStoragePolicyRequest) ApplyTo(p *StoragePolicy) {
+	if r.RecordingRetentionDays != nil {
+		p.RecordingRetentionDays = *r.RecordingRetentionDays
+	}
+	if r.MaterialRetentionDays != nil {
+		p.MaterialRetentionDays = *r.MaterialRetentionDays
+	}
+	if r.TeacherQuotaBytes != nil {
+		p.TeacherQuotaBytes = *r.TeacherQuotaBytes
+	}
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type StoragePolicyDoc struct {
+	RecordingRetentionDays int   `json:"recording_retention_days" example:"90"`
+	MaterialRetentionDays  int   `json:"material_retention_days" example:"0"`
+	TeacherQuotaBytes      int64 `json:"teacher_quota_bytes" example:"5368709120"`
+}
+
+type TeacherStorageQuotaDoc struct {
+	QuotaBytes *int64 `json:"quota_bytes" example:"10737418240"`
+}
@@ -1,13 +1,23 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Teacher struct {
 	gorm.Model
-	UserID      uint   `json:"user_id" gorm:"unique;not null"`
-	Description string `json:"description" gorm:"size:255"`
-	FlagCount   int    `json:"flag_count" gorm:"default:0;not null"`
-	Email       string `json:"email" gorm:"size:100;unique;not null"`
+	UserID        uint       `json:"user_id" gorm:"unique;not null"`
+	Description   string     `json:"description" gorm:"size:255"`
+	FlagCount     int        `json:"flag_count" gorm:"default:0;not null"`
+	LastFlaggedAt *time.Time `json:"last_flagged_at,omitempty"`
+	LastDecayedAt *time.Time `json:"last_decayed_at,omitempty"`
+	Email         string     `json:"email" gorm:"size:100;unique;not null"`
+
+	// StorageQuotaBytes overrides StoragePolicy.TeacherQuotaBytes for this
+	// teacher. Only admins set it, through /storage/teachers/:id/quota.
+	StorageQuotaBytes *int64 `json:"-"`
 }
 
 // ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
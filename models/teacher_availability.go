@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TeacherAvailability is a weekly window in which a teacher takes private
+// lessons, e.g. every Tuesday 18:00-21:00. Times are wall-clock times in
+// Timezone, so a window keeps its local hours across DST changes.
+type TeacherAvailability struct {
+	gorm.Model
+	TeacherID uint   `json:"teacher_id" gorm:"not null;index"`
+	Weekday   int    `json:"weekday" gorm:"not null"`           // 0 = Sunday ... 6 = Saturday
+	StartTime string `json:"start_time" gorm:"size:5;not null"` // "HH:MM"
+	EndTime   string `json:"end_time" gorm:"size:5;not null"`   // "HH:MM", after StartTime
+	Timezone  string `json:"timezone" gorm:"size:64;not null"`  // IANA name, e.g. "Asia/Bangkok"
+
+	Teacher Teacher `json:"-" gorm:"foreignKey:TeacherID;references:ID;constraint:OnDelete:CASCADE"`
+}
+
+// AvailabilityException blocks out time inside the teacher's weekly windows,
+// e.g. a holiday or a dentist appointment.
+type AvailabilityException struct {
+	gorm.Model
+	TeacherID uint      `json:"teacher_id" gorm:"not null;index"`
+	StartAt   time.Time `json:"start_at" gorm:"not null"`
+	EndAt     time.Time `json:"end_at" gorm:"not null"`
+	Reason    string    `json:"reason" gorm:"size:255"`
+
+	Teacher Teacher `json:"-" gorm:"foreignKey:TeacherID;references:ID;constraint:OnDelete:CASCADE"`
+}
+
+// TeacherAvailabilityResponse is a teacher's weekly windows and upcoming exceptions.
+type TeacherAvailabilityResponse struct {
+	TeacherID  uint                    `json:"teacher_id"`
+	Windows    []TeacherAvailability   `json:"windows"`
+	Exceptions []AvailabilityException `json:"exceptions"`
+}
+
+// BookableSlot is a free private-lesson slot, expressed in the learner's timezone.
+type BookableSlot struct {
+	Start  time.Time `json:"start"`
+	Finish time.Time `json:"finish"`
+}
+
+// BookPrivateLessonRequest books the slot starting at ClassStart.
+type BookPrivateLessonRequest struct {
+	ClassStart  time.Time `json:"class_start"`
+	Description string    `json:"description"`
+}
+
+// PrivateLessonBooking is what a successful booking created.
+type PrivateLessonBooking struct {
+	ClassSession ClassSession `json:"class_session"`
+	Enrollment   Enrollment   `json:"enrollment"`
+	AmountPaid   float64      `json:"amount_paid"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type TeacherAvailabilityDoc struct {
+	Weekday   int    `json:"weekday" example:"2"`
+	StartTime string `json:"start_time" example:"18:00"`
+	EndTime   string `json:"end_time" example:"21:00"`
+	Timezone  string `json:"timezone" example:"Asia/Bangkok"`
+}
+
+type AvailabilityExceptionDoc struct {
+	StartAt time.Time `json:"start_at" example:"2025-12-24T00:00:00+07:00"`
+	EndAt   time.Time `json:"end_at" example:"2025-12-27T00:00:00+07:00"`
+	Reason  string    `json:"reason" example:"Holiday"`
+}
+
+type TeacherAvailabilityResponseDoc struct {
+	TeacherID  uint                       `json:"teacher_id" example:"7"`
+	Windows    []TeacherAvailabilityDoc   `json:"windows"`
+	Exceptions []AvailabilityExceptionDoc `json:"exceptions"`
+}
+
+type BookableSlotDoc struct {
+	Start  time.Time `json:"start" example:"2025-09-09T18:00:00+07:00"`
+	Finish time.Time `json:"finish" example:"2025-09-09T19:00:00+07:00"`
+}
+
+type BookPrivateLessonRequestDoc struct {
+	ClassStart  time.Time `json:"class_start" example:"2025-09-09T18:00:00+07:00"`
+	Description string    `json:"description" example:"Help with integration by parts"`
+}
+
+type PrivateLessonBookingDoc struct {
+	ClassSession ClassSessionDoc `json:"class_session"`
+	Enrollment   EnrollmentDoc   `json:"enrollment"`
+	AmountPaid   float64         `json:"amount_paid" example:"500.00"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Upload purposes: which picture a direct upload replaces once confirmed.
+const (
+	UploadPurposeProfilePicture   = "profile_picture"   // User.ProfilePictureURL
+	UploadPurposeClassBanner      = "class_banner"      // Class.BannerPictureURL
+	UploadPurposeReportPicture    = "report_picture"    // Report.ReportPictureURL
+	UploadPurposeSessionAsset     = "session_asset"     // a new SessionAsset of ClassSession TargetID
+	UploadPurposeReportAttachment = "report_attachment" // a new ReportAttachment of Report TargetID
+)
+
+// Upload session statuses.
+const (
+	UploadStatusPending   = "pending"
+	UploadStatusConfirmed = "confirmed"
+	UploadStatusRejected  = "rejected"
+)
+
+// UploadSession lets a client upload a file straight to MinIO through a
+// presigned POST instead of sending it base64-encoded in JSON. The file
+// lands under a staging key; once the client confirms the upload, the object
+// is checked, copied to its own key and that key stored on the target User,
+// Class or Report, or recorded as a SessionAsset of the target ClassSession
+// or a ReportAttachment of the target Report. Kind and FileName only apply to
+// session assets and report attachments, Title to session assets.
+type UploadSession struct {
+	gorm.Model
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	Purpose     string     `json:"purpose" gorm:"size:30;not null"`
+	TargetID    uint       `json:"target_id" gorm:"not null"`
+	ContentType string     `json:"content_type" gorm:"size:100;not null"`
+	Kind        string     `json:"kind,omitempty" gorm:"size:20"`
+	Title       string     `json:"title,omitempty" gorm:"size:255"`
+	FileName    string     `json:"file_name,omitempty" gorm:"size:255"`
+	MaxBytes    int64      `json:"max_bytes" gorm:"not null"`
+	ObjectKey   string     `json:"-" gorm:"size:255;not null"`
+	Status      string     `json:"status" gorm:"size:20;not null;default:'pending';index"`
+	SizeBytes   int64      `json:"size_bytes,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null;index"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+
+	PostURL    string            `json:"post_url,omitempty" gorm:"-"`
+	PostFields map[string]string `json:"post_fields,omitempty" gorm:"-"`
+	URL        string            `json:"url,omitempty" gorm:"-"`
+}
+
+type CreateUploadSessionRequest struct {
+	Purpose     string `json:"purpose"`
+	TargetID    uint   `json:"target_id"`
+	ContentType string `json:"content_type"`
+	Kind        string `json:"kind"`
+	Title       string `json:"title"`
+	FileName    string `json:"file_name"`
+}
+
+// ---- DOC-ONLY STRUCT FOR SWAGGER BELOW ----
+
+type UploadSessionDoc struct {
+	ID          uint              `json:"ID" example:"31"`
+	UserID      uint              `json:"user_id" example:"42"`
+	Purpose     string            `json:"purpose" example:"profile_picture"`
+	TargetID    uint              `json:"target_id" example:"42"`
+	ContentType string            `json:"content_type" example:"image/png"`
+	Kind        string            `json:"kind,omitempty" example:"recording"`
+	Title       string            `json:"title,omitempty" example:"Week 3 recording"`
+	FileName    string            `json:"file_name,omitempty" example:"calculus-week3.mp4"`
+	MaxBytes    int64             `json:"max_bytes" example:"10485760"`
+	Status      string            `json:"status" example:"pending"`
+	SizeBytes   int64             `json:"size_bytes,omitempty" example:"482133"`
+	ExpiresAt   time.Time         `json:"expires_at" example:"2025-09-05T14:15:00Z"`
+	ConfirmedAt time.Time         `json:"confirmed_at,omitempty" example:"2025-09-05T14:02:41Z"`
+	PostURL     string            `json:"post_url,omitempty" example:"https://minio.example.com/tutorium/"`
+	PostFields  map[string]string `json:"post_fields,omitempty"`
+	URL         string            `json:"url,omitempty" example:"https://minio.example.com/tutorium/users/1725544800000000000.png?X-Amz-Signature=..."`
+}
+
+type CreateUploadSessionRequestDoc struct {
+	Purpose     string `json:"purpose" example:"profile_picture"`
+	TargetID    uint   `json:"target_id" example:"42"`
+	ContentType string `json:"content_type" example:"image/png"`
+	Kind        string `json:"kind,omitempty" example:"recording"`
+	Title       string `json:"title,omitempty" example:"Week 3 recording"`
+	FileName    string `json:"file_name,omitempty" example:"calculus-week3.mp4"`
+}
@@ -15,6 +15,7 @@ type User struct {
 	PhoneNumber       string  `json:"phone_number" gorm:"size:20"`
 	Balance           float64 `json:"balance" gorm:"type:numeric(12,2);default:0;check:balance >= 0"`
 	BanCount          int     `json:"ban_count" gorm:"default:0;not null"`
+	Timezone          string  `json:"timezone" gorm:"size:64;not null;default:'Asia/Bangkok'"` // IANA name; times are rendered in it
 
 	Learner *Learner
 	Teacher *Teacher
@@ -32,4 +33,5 @@ type UserDoc struct {
 	PhoneNumber    string  `json:"phone_number" example:"+66912345678"`
 	Balance        float64 `json:"balance" example:"250.75"`
 	BanCount       int     `json:"ban_count" example:"1"`
+	Timezone       string  `json:"timezone" example:"Asia/Bangkok"`
 }
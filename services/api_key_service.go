@@ -0,0 +1,177 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// API keys look like "tut_<prefix>_<secret>"; the prefix is stored in clear for lookup.
+const apiKeyTag = "tut"
+
+var (
+	ErrInvalidAPIKey        = errors.New("invalid api key")
+	ErrInvalidAPIKeyRequest = errors.New("invalid api key request")
+)
+
+// APIKeyResources lists the route groups an API key may be scoped to.
+// Authentication and API-key management are deliberately absent, as are
+// provider webhooks, which are signed rather than authenticated.
+var APIKeyResources = []string{
+	"admins",
+	"appeals",
+	"attendance",
+	"availability",
+	"banlearners",
+	"banteachers",
+	"calendar",
+	"class_categories",
+	"class_session_series",
+	"class_sessions",
+	"classes",
+	"enrollments",
+	"learners",
+	"meetings",
+	"moderation",
+	"notifications",
+	"payments",
+	"reports",
+	"reviews",
+	"storage",
+	"teachers",
+	"uploads",
+	"users",
+}
+
+// RouteScope derives the scope required for a request from its first path segment
+// and method: GET/HEAD need "<resource>:read", everything else "<resource>:write".
+func RouteScope(method, path string) string {
+	resource, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	access := "write"
+	if method == "GET" || method == "HEAD" {
+		access = "read"
+	}
+	return resource + ":" + access
+}
+
+// ValidateAPIKeyScopes rejects unknown resources and malformed scopes.
+func ValidateAPIKeyScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyRequest)
+	}
+	for _, s := range scopes {
+		resource, access, ok := strings.Cut(s, ":")
+		if !ok || (access != "read" && access != "write") {
+			return fmt.Errorf("%w: invalid scope %q (expected <resource>:read or <resource>:write)", ErrInvalidAPIKeyRequest, s)
+		}
+		known := false
+		for _, r := range APIKeyResources {
+			if r == resource {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown scope resource %q", ErrInvalidAPIKeyRequest, resource)
+		}
+	}
+	return nil
+}
+
+func randomAlphanumeric(n int) (string, error) {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[idx.Int64()]
+	}
+	return string(b), nil
+}
+
+// HashAPIKey returns the hex SHA-256 digest stored in place of the raw key.
+func HashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey returns a new raw key together with its lookup prefix and hash.
+func GenerateAPIKey() (raw, prefix, hash string, err error) {
+	pb := make([]byte, 4)
+	if _, err = rand.Read(pb); err != nil {
+		return "", "", "", err
+	}
+	prefix = hex.EncodeToString(pb)
+	secret, err := randomAlphanumeric(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	raw = fmt.Sprintf("%s_%s_%s", apiKeyTag, prefix, secret)
+	return raw, prefix, HashAPIKey(raw), nil
+}
+
+// CreateAPIKey mints a key for ownerUserID and returns the raw value, which is never stored.
+func CreateAPIKey(db *gorm.DB, ownerUserID uint, name string, scopes []string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", nil, fmt.Errorf("%w: name is required", ErrInvalidAPIKeyRequest)
+	}
+	if err := ValidateAPIKeyScopes(scopes); err != nil {
+		return "", nil, err
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKeyRequest)
+	}
+
+	raw, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+	key := models.APIKey{
+		OwnerUserID: ownerUserID,
+		Name:        name,
+		Prefix:      prefix,
+		KeyHash:     hash,
+		Scopes:      scopes,
+		ExpiresAt:   expiresAt,
+	}
+	if err := db.Create(&key).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, &key, nil
+}
+
+// AuthenticateAPIKey resolves a raw key to an active APIKey and records its use.
+func AuthenticateAPIKey(db *gorm.DB, raw string, now time.Time) (*models.APIKey, error) {
+	parts := strings.Split(raw, "_")
+	if len(parts) != 3 || parts[0] != apiKeyTag {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var key models.APIKey
+	if err := db.Where("prefix = ?", parts[1]).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(HashAPIKey(raw))) != 1 || !key.Active(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if err := db.Model(&key).UpdateColumn("last_used_at", now).Error; err != nil {
+		return nil, err
+	}
+	key.LastUsedAt = &now
+	return &key, nil
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotSessionTeacher       = errors.New("only the session's teacher can do this")
+	ErrNotEnrolled             = errors.New("learner is not enrolled in this session")
+	ErrCheckInClosed           = errors.New("check-in is only open while the session is running")
+	ErrInvalidCheckInCode      = errors.New("invalid check-in code")
+	ErrCheckInLocked           = errors.New("too many wrong check-in codes; try again later")
+	ErrInvalidAttendanceStatus = errors.New("status must be present, late or absent")
+)
+
+const (
+	// AttendanceWindowLead is how long before ClassStart check-ins and
+	// meeting joins count towards attendance.
+	AttendanceWindowLead = 15 * time.Minute
+	// LateAfter is how long after ClassStart a self check-in counts as late.
+	LateAfter = 10 * time.Minute
+	// CheckInCodeLifetime is how long a check-in code is accepted. The
+	// teacher's screen fetches a fresh one when it expires.
+	CheckInCodeLifetime = 5 * time.Minute
+)
+
+// A learner who enters checkInMaxFailures wrong codes in a row may not try
+// again for checkInLockout, so a code cannot be guessed within its lifetime.
+const (
+	checkInMaxFailures = 5
+	checkInLockout     = 15 * time.Minute
+)
+
+// attendedEnrollmentStatuses are the enrollment states a learner can be
+// recorded against; no_show is included so a teacher can correct the job.
+var attendedEnrollmentStatuses = []string{models.EnrollmentStatusActive, models.EnrollmentStatusNoShow}
+
+// IsClassTeacher reports whether user teaches class.
+func IsClassTeacher(user *models.User, class *models.Class) bool {
+	return user.Teacher != nil && class.TeacherID == user.Teacher.ID
+}
+
+// IsSessionTeacher reports whether user teaches session. session.Class must be loaded.
+func IsSessionTeacher(user *models.User, session *models.ClassSession) bool {
+	return IsClassTeacher(user, &session.Class)
+}
+
+// InAttendanceWindow reports whether now falls between shortly before
+// ClassStart and ClassFinish.
+func InAttendanceWindow(session *models.ClassSession, now time.Time) bool {
+	return !now.Before(session.ClassStart.Add(-AttendanceWindowLead)) && now.Before(session.ClassFinish)
+}
+
+// RecordAttendance upserts a learner's attendance for a session and keeps
+// Enrollment.AttendedAt in sync for the no-show checker. A roll-call record is
+// kept when a lower-priority source reports later. Recording a no-show learner
+// as attending reverts the no-show, including the flag it may have caused.
+func RecordAttendance(db *gorm.DB, sessionID, learnerID uint, status, source string, recordedBy *uint, at time.Time) (*models.Attendance, error) {
+	if !models.ValidAttendanceStatus(status) {
+		return nil, ErrInvalidAttendanceStatus
+	}
+
+	var record models.Attendance
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var enrolled int64
+		if err := tx.Model(&models.Enrollment{}).
+			Where("learner_id = ? AND class_session_id = ? AND enrollment_status IN ?", learnerID, sessionID, attendedEnrollmentStatuses).
+			Count(&enrolled).Error; err != nil {
+			return err
+		}
+		if enrolled == 0 {
+			return ErrNotEnrolled
+		}
+
+		err := tx.Where("class_session_id = ? AND learner_id = ?", sessionID, learnerID).First(&record).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			record = models.Attendance{ClassSessionID: sessionID, LearnerID: learnerID}
+		case err != nil:
+			return err
+		case record.Source == models.AttendanceSourceRollCall && source != models.AttendanceSourceRollCall:
+			return nil
+		}
+
+		record.Status = status
+		record.Source = source
+		record.RecordedByUserID = recordedBy
+		if status != models.AttendanceAbsent && record.CheckedInAt == nil {
+			record.CheckedInAt = &at
+		}
+		if err := tx.Save(&record).Error; err != nil {
+			return err
+		}
+
+		if status == models.AttendanceAbsent {
+			return tx.Model(&models.Enrollment{}).Where("learner_id = ? AND class_session_id = ?", learnerID, sessionID).
+				Update("attended_at", nil).Error
+		}
+		// A teacher may correct a no-show the checker already recorded.
+		res := tx.Model(&models.Enrollment{}).
+			Where("learner_id = ? AND class_session_id = ? AND enrollment_status = ?", learnerID, sessionID, models.EnrollmentStatusNoShow).
+			Update("enrollment_status", models.EnrollmentStatusActive)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			if err := revertLearnerNoShow(tx, learnerID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Enrollment{}).Where("learner_id = ? AND class_session_id = ?", learnerID, sessionID).
+			Update("attended_at", record.CheckedInAt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// IssueCheckInCode returns the session's six-digit check-in code and when it
+// expires, issuing a new one during the attendance window whenever the
+// current code is older than CheckInCodeLifetime.
+func IssueCheckInCode(db *gorm.DB, session *models.ClassSession, now time.Time) (string, time.Time, error) {
+	if !InAttendanceWindow(session, now) {
+		return "", time.Time{}, ErrCheckInClosed
+	}
+	if !checkInCodeCurrent(session, now) {
+		n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		code := fmt.Sprintf("%06d", n.Int64())
+		// Only replace the code this request saw, so two of the teacher's
+		// screens rotating at once end up showing the same code.
+		res := db.Model(&models.ClassSession{}).
+			Where("id = ? AND check_in_code = ?", session.ID, session.CheckInCode).
+			Updates(map[string]any{"check_in_code": code, "check_in_code_issued_at": now})
+		if res.Error != nil {
+			return "", time.Time{}, res.Error
+		}
+		if res.RowsAffected == 0 {
+			if err := db.Select("check_in_code", "check_in_code_issued_at").First(session, session.ID).Error; err != nil {
+				return "", time.Time{}, err
+			}
+		} else {
+			session.CheckInCode, session.CheckInCodeIssuedAt = code, &now
+		}
+	}
+	expires := session.CheckInCodeIssuedAt.Add(CheckInCodeLifetime)
+	if session.ClassFinish.Before(expires) {
+		expires = session.ClassFinish
+	}
+	return session.CheckInCode, expires, nil
+}
+
+// checkInCodeCurrent reports whether session has a code issued less than
+// CheckInCodeLifetime before now.
+func checkInCodeCurrent(session *models.ClassSession, now time.Time) bool {
+	return session.CheckInCode != "" && session.CheckInCodeIssuedAt != nil &&
+		now.Before(session.CheckInCodeIssuedAt.Add(CheckInCodeLifetime))
+}
+
+// CheckIn records a learner's self check-in with the code shown in class.
+// Check-ins more than LateAfter past ClassStart are recorded as late. Wrong
+// or expired codes count against the learner's enrollment, which is locked
+// out for a while after checkInMaxFailures in a row.
+func CheckIn(db *gorm.DB, session *models.ClassSession, learnerID uint, code string, now time.Time) (*models.Attendance, error) {
+	if !InAttendanceWindow(session, now) {
+		return nil, ErrCheckInClosed
+	}
+
+	var result error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var enrollment models.Enrollment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("learner_id = ? AND class_session_id = ? AND enrollment_status IN ?", learnerID, session.ID, attendedEnrollmentStatuses).
+			First(&enrollment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotEnrolled
+		}
+		if err != nil {
+			return err
+		}
+		if enrollment.CheckInLockedUntil != nil && now.Before(*enrollment.CheckInLockedUntil) {
+			result = ErrCheckInLocked
+			return nil
+		}
+
+		if !checkInCodeCurrent(session, now) || subtle.ConstantTimeCompare([]byte(session.CheckInCode), []byte(code)) != 1 {
+			// The failure is committed even though the check-in fails.
+			result = ErrInvalidCheckInCode
+			updates := map[string]any{"check_in_failures": enrollment.CheckInFailures + 1}
+			if enrollment.CheckInFailures+1 >= checkInMaxFailures {
+				updates = map[string]any{"check_in_failures": 0, "check_in_locked_until": now.Add(checkInLockout)}
+			}
+			return tx.Model(&enrollment).Updates(updates).Error
+		}
+		if enrollment.CheckInFailures > 0 {
+			return tx.Model(&enrollment).Update("check_in_failures", 0).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		return nil, result
+	}
+
+	status := models.AttendancePresent
+	if now.After(session.ClassStart.Add(LateAfter)) {
+		status = models.AttendanceLate
+	}
+	return RecordAttendance(db, session.ID, learnerID, status, models.AttendanceSourceCheckIn, nil, now)
+}
+
+// SessionAttendance summarises who attended a session.
+func SessionAttendance(db *gorm.DB, sessionID uint) (models.SessionAttendanceSummary, error) {
+	summary := models.SessionAttendanceSummary{ClassSessionID: sessionID, Records: []models.Attendance{}}
+
+	var enrolled int64
+	if err := db.Model(&models.Enrollment{}).
+		Where("class_session_id = ? AND enrollment_status IN ?", sessionID, attendedEnrollmentStatuses).
+		Count(&enrolled).Error; err != nil {
+		return summary, err
+	}
+	if err := db.Where("class_session_id = ?", sessionID).Order("learner_id").Find(&summary.Records).Error; err != nil {
+		return summary, err
+	}
+
+	summary.Enrolled = int(enrolled)
+	for _, r := range summary.Records {
+		switch r.Status {
+		case models.AttendancePresent:
+			summary.Present++
+		case models.AttendanceLate:
+			summary.Late++
+		case models.AttendanceAbsent:
+			summary.Absent++
+		}
+	}
+	summary.Unrecorded = max(summary.Enrolled-len(summary.Records), 0)
+	return summary, nil
+}
+
+// LearnerAttendance summarises a learner's attendance over sessions that have
+// already finished.
+func LearnerAttendance(db *gorm.DB, learnerID uint, now time.Time) (models.LearnerAttendanceSummary, error) {
+	summary := models.LearnerAttendanceSummary{LearnerID: learnerID, Records: []models.Attendance{}}
+
+	var enrollments []models.Enrollment
+	if err := db.Joins("ClassSession").
+		Where("enrollments.learner_id = ? AND enrollments.enrollment_status IN ?", learnerID, attendedEnrollmentStatuses).
+		Where(`"ClassSession".class_finish < ?`, now).
+		Find(&enrollments).Error; err != nil {
+		return summary, err
+	}
+	if err := db.Where("learner_id = ?", learnerID).Order("class_session_id").Find(&summary.Records).Error; err != nil {
+		return summary, err
+	}
+
+	summary.Sessions = len(enrollments)
+	for _, e := range enrollments {
+		switch {
+		case e.EnrollmentStatus == models.EnrollmentStatusNoShow:
+			summary.NoShows++
+		case e.AttendedAt != nil:
+			summary.Attended++
+		}
+	}
+	for _, r := range summary.Records {
+		if r.Status == models.AttendanceAbsent {
+			summary.Absent++
+		}
+	}
+	if summary.Sessions > 0 {
+		summary.AttendanceRate = float64(summary.Attended) / float64(summary.Sessions)
+	}
+	return summary, nil
+}
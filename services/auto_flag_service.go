@@ -7,32 +7,83 @@ import (
 	"time"
 
 	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/storage"
 	"github.com/robfig/cron/v3"
 	"gorm.io/gorm"
 )
 
-// StartScheduler initializes all cron jobs for the application.
-func StartScheduler(db *gorm.DB) {
+// StartScheduler initializes all cron jobs for the application. Expired
+// session assets and abandoned direct uploads are only purged when store is
+// set.
+func StartScheduler(db *gorm.DB, store storage.ObjectStore) {
 	c := cron.New()
+	c.AddFunc("@every 1m", func() {
+		log.Println("Running class session status job...")
+		AdvanceSessionStatuses(db)
+	})
+	c.AddFunc("@every 5m", func() {
+		log.Println("Running reschedule deadline job...")
+		ApplyDueReschedules(db)
+	})
+	c.AddFunc("@every 5m", func() {
+		log.Println("Running session reminder job...")
+		SendSessionReminders(db, time.Now())
+	})
 	c.AddFunc("@every 5m", func() {
 		log.Println("Running teacher absence checker job...")
 		CheckForAbsentTeachers(db)
 	})
+	c.AddFunc("@every 15m", func() {
+		log.Println("Running learner no-show checker job...")
+		CheckForLearnerNoShows(db)
+	})
+	c.AddFunc("@hourly", func() {
+		log.Println("Running flag threshold enforcement job...")
+		EnforceFlagThresholds(db)
+	})
+	c.AddFunc("@daily", func() {
+		log.Println("Running flag decay job...")
+		DecayFlagCounts(db)
+	})
+	if store != nil {
+		c.AddFunc("@daily", func() {
+			log.Println("Running session asset retention job...")
+			PurgeExpiredSessionAssets(db, store, time.Now())
+		})
+		c.AddFunc("@hourly", func() {
+			log.Println("Running abandoned upload cleanup job...")
+			PurgeAbandonedUploads(db, store, time.Now())
+		})
+	}
 	c.Start()
 	log.Println("Cron job scheduler started.")
 }
 
 // CheckForAbsentTeachers finds absent teachers and either flags them or creates a report.
+// A teacher is absent when teacher_checked_in_at is still unset 15 minutes
+// into the session. It is set when the teacher joins the meeting, as reported
+// by the provider's presence webhooks, or for providers that send none, when
+// the teacher fetches their join link. Only sessions that started within
+// AttendanceCheckLookback are checked, and a teacher is only penalised once
+// the session has been marked teacher_absent.
 func CheckForAbsentTeachers(db *gorm.DB) {
 	var sessions []models.ClassSession
-	fifteenMinutesAgo := time.Now().Add(-15 * time.Minute)
+	now := time.Now()
+	fifteenMinutesAgo := now.Add(-15 * time.Minute)
 
-	err := db.Where("class_start < ? AND class_status = ?", fifteenMinutesAgo, "not_started").Find(&sessions).Error
+	err := db.Where("class_start < ? AND class_start >= ? AND class_status IN ? AND teacher_checked_in_at IS NULL",
+		fifteenMinutesAgo, now.Add(-models.AttendanceCheckLookback), models.UpcomingSessionStatuses).Find(&sessions).Error
 	if err != nil {
 		log.Printf("Error finding absent sessions: %v", err)
 		return
 	}
 
+	policy, err := GetModerationPolicy(db)
+	if err != nil {
+		log.Printf("Error loading moderation policy: %v", err)
+		return
+	}
+
 	for _, session := range sessions {
 		var class models.Class
 		if err := db.First(&class, session.ClassID).Error; err != nil {
@@ -46,14 +97,23 @@ func CheckForAbsentTeachers(db *gorm.DB) {
 			continue
 		}
 
+		// Someone else (a check-in, a cancellation or another run) may have
+		// moved the session on since it was loaded; only the run that marks
+		// it absent penalises the teacher.
+		if err := TransitionClassSession(db, &session, models.SessionStatusTeacherAbsent); err != nil {
+			log.Printf("Failed to mark session %d as teacher absent: %v", session.ID, err)
+			continue
+		}
+
 		// Auto-flag absent teacher; create system report for admin review if flag threshold exceeded
-		if teacher.FlagCount < 2 {
+		if teacher.FlagCount < policy.AbsenceAutoFlagLimit {
 			log.Printf("Automatically flagging teacher %d (current flags: %d)", teacher.ID, teacher.FlagCount)
 			if err := AddTeacherFlag(db, teacher.ID, 1); err != nil {
 				log.Printf("Error applying immediate flag to teacher %d: %v", teacher.ID, err)
 			} else {
-				desc := fmt.Sprintf("You have been automatically flagged for absence from your class session on %s.", session.ClassStart.Format(time.RFC3339))
-				CreateNotification(db, teacher.UserID, "system", desc)
+				notifyLocalized(db, []uint{teacher.UserID}, "system", func(loc *time.Location) string {
+					return fmt.Sprintf("You have been automatically flagged for absence from your class session on %s.", FormatLocalTime(session.ClassStart, loc))
+				})
 			}
 		} else {
 			// A report must have a reporter; use the admin account for system-generated reports
@@ -71,15 +131,19 @@ func CheckForAbsentTeachers(db *gorm.DB) {
 				ReportType:        "learner",
 				ReportReason:      "teacher_absent",
 				ReportDescription: fmt.Sprintf("System detected teacher absence that requires admin review before banning. Teacher already has %d flags.", teacher.FlagCount),
-				ReportStatus:      "pending",
+				ReportDate:        now,
 			}
-			if err := db.Create(&systemReport).Error; err != nil {
+			StampNewReport(&policy, &systemReport, now)
+			// System reports are not held to the daily limit meant for people.
+			systemPolicy := policy
+			systemPolicy.ReportsPerDayLimit = 0
+			if _, err := FileReport(db, &systemPolicy, &systemReport, now); err != nil {
 				log.Printf("Failed to create system report for session %d: %v", session.ID, err)
 			} else {
-				desc := fmt.Sprintf("Your absence from the class on %s has been flagged for admin review due to your current flag count.", session.ClassStart.Format(time.RFC3339))
-				CreateNotification(db, teacher.UserID, "system", desc)
+				notifyLocalized(db, []uint{teacher.UserID}, "system", func(loc *time.Location) string {
+					return fmt.Sprintf("Your absence from the class on %s has been flagged for admin review due to your current flag count.", FormatLocalTime(session.ClassStart, loc))
+				})
 			}
 		}
-		db.Model(&session).Update("class_status", "absent")
 	}
 }
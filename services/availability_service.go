@@ -0,0 +1,242 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// BookingMinNotice is how far ahead a private lesson must be booked.
+	BookingMinNotice = 2 * time.Hour
+	// MaxSlotRangeDays caps how many days of slots one request may compute.
+	MaxSlotRangeDays = 31
+)
+
+var (
+	ErrInvalidAvailabilityWindow = errors.New("weekday must be 0-6 and start_time/end_time HH:MM with end_time after start_time")
+	ErrInvalidException          = errors.New("end_at must be after start_at")
+	ErrInvalidSlotRange          = errors.New("slot range must be positive and at most 31 days")
+	ErrNotBookable               = errors.New("class does not offer private lessons")
+	ErrSlotUnavailable           = errors.New("slot is not available")
+	ErrInsufficientBalance       = errors.New("insufficient balance")
+)
+
+// parseClock turns "HH:MM" into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidateAvailabilityWindow checks a weekly window and fills in the default timezone.
+func ValidateAvailabilityWindow(w *models.TeacherAvailability) error {
+	if w.Weekday < 0 || w.Weekday > 6 {
+		return ErrInvalidAvailabilityWindow
+	}
+	start, err := parseClock(w.StartTime)
+	if err != nil {
+		return ErrInvalidAvailabilityWindow
+	}
+	end, err := parseClock(w.EndTime)
+	if err != nil || end <= start {
+		return ErrInvalidAvailabilityWindow
+	}
+	if w.Timezone == "" {
+		w.Timezone = DefaultTimezone
+	}
+	if _, err := LoadTimezone(w.Timezone); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ValidateAvailabilityException checks an exception's time range.
+func ValidateAvailabilityException(e *models.AvailabilityException) error {
+	if e.StartAt.IsZero() || !e.EndAt.After(e.StartAt) {
+		return ErrInvalidException
+	}
+	return nil
+}
+
+type timeRange struct{ start, end time.Time }
+
+func overlapsAny(start, end time.Time, ranges []timeRange) bool {
+	for _, r := range ranges {
+		if start.Before(r.end) && end.After(r.start) {
+			return true
+		}
+	}
+	return false
+}
+
+// BookableSlots lists the private-lesson slots of class between from and to,
+// expressed in loc. Slots are cut from the teacher's weekly windows in the
+// window's own timezone, one lesson long, and drop out when they start
+// within BookingMinNotice of now, fall in an exception or overlap any
+// session the teacher already teaches.
+func BookableSlots(db *gorm.DB, class *models.Class, from, to time.Time, loc *time.Location, now time.Time) ([]models.BookableSlot, error) {
+	if class.PrivateLessonMinutes <= 0 {
+		return nil, ErrNotBookable
+	}
+	if !to.After(from) || to.Sub(from) > MaxSlotRangeDays*24*time.Hour {
+		return nil, ErrInvalidSlotRange
+	}
+
+	var windows []models.TeacherAvailability
+	if err := db.Where("teacher_id = ?", class.TeacherID).Find(&windows).Error; err != nil {
+		return nil, err
+	}
+	if len(windows) == 0 {
+		return []models.BookableSlot{}, nil
+	}
+
+	var exceptions []models.AvailabilityException
+	if err := db.Where("teacher_id = ? AND start_at < ? AND end_at > ?", class.TeacherID, to, from).
+		Find(&exceptions).Error; err != nil {
+		return nil, err
+	}
+	var busy []models.ScheduleConflict
+	if err := conflictQuery(db, from, to).Where("classes.teacher_id = ?", class.TeacherID).
+		Scan(&busy).Error; err != nil {
+		return nil, err
+	}
+	blocked := make([]timeRange, 0, len(exceptions)+len(busy))
+	for _, e := range exceptions {
+		blocked = append(blocked, timeRange{e.StartAt, e.EndAt})
+	}
+	for _, b := range busy {
+		blocked = append(blocked, timeRange{b.ClassStart, b.ClassFinish})
+	}
+
+	length := time.Duration(class.PrivateLessonMinutes) * time.Minute
+	earliest := now.Add(BookingMinNotice)
+	seen := map[int64]bool{}
+	slots := []models.BookableSlot{}
+	for _, w := range windows {
+		wloc, err := LoadTimezone(w.Timezone)
+		if err != nil {
+			continue
+		}
+		startMin, err1 := parseClock(w.StartTime)
+		endMin, err2 := parseClock(w.EndTime)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+
+		// Walk calendar days in the window's timezone; time.Date keeps the
+		// wall-clock hours on days when the UTC offset changes.
+		first := from.In(wloc)
+		last := to.In(wloc)
+		for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, wloc); !day.After(last); day = day.AddDate(0, 0, 1) {
+			if int(day.Weekday()) != w.Weekday {
+				continue
+			}
+			windowStart := time.Date(day.Year(), day.Month(), day.Day(), startMin/60, startMin%60, 0, 0, wloc)
+			windowEnd := time.Date(day.Year(), day.Month(), day.Day(), endMin/60, endMin%60, 0, 0, wloc)
+			for s := windowStart; !s.Add(length).After(windowEnd); s = s.Add(length) {
+				f := s.Add(length)
+				if s.Before(from) || f.After(to) || s.Before(earliest) || seen[s.Unix()] {
+					continue
+				}
+				if overlapsAny(s, f, blocked) {
+					continue
+				}
+				seen[s.Unix()] = true
+				slots = append(slots, models.BookableSlot{Start: s.In(loc), Finish: f.In(loc)})
+			}
+		}
+	}
+
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+	return slots, nil
+}
+
+// BookPrivateLesson books the slot of class starting at start for learner:
+// in one transaction it re-checks the slot, debits the lesson price from the
+// learner's balance and creates a one-learner ClassSession with its
+// Enrollment. The teacher row is locked so two learners cannot take the same
+// slot.
+func BookPrivateLesson(db *gorm.DB, class *models.Class, learner *models.Learner, start time.Time, description string, room meeting.Room, now time.Time) (*models.PrivateLessonBooking, error) {
+	if class.PrivateLessonMinutes <= 0 {
+		return nil, ErrNotBookable
+	}
+	finish := start.Add(time.Duration(class.PrivateLessonMinutes) * time.Minute)
+	price := class.PrivateLessonPrice
+	if description == "" {
+		description = "Private lesson: " + class.ClassName
+	}
+
+	booking := models.PrivateLessonBooking{AmountPaid: price}
+	var teacher models.Teacher
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&teacher, class.TeacherID).Error; err != nil {
+			return err
+		}
+
+		slots, err := BookableSlots(tx, class, start, finish, time.UTC, now)
+		if err != nil {
+			return err
+		}
+		if len(slots) == 0 || !slots[0].Start.Equal(start) {
+			return ErrSlotUnavailable
+		}
+		if err := CheckLearnerSchedule(tx, learner.ID, &models.ClassSession{ClassStart: start, ClassFinish: finish}); err != nil {
+			return err
+		}
+
+		if price > 0 {
+			res := tx.Model(&models.User{}).
+				Where("id = ? AND balance >= ?", learner.UserID, price).
+				Update("balance", gorm.Expr("balance - ?", price))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrInsufficientBalance
+			}
+		}
+
+		booking.ClassSession = models.ClassSession{
+			ClassID:            class.ID,
+			Description:        description,
+			Price:              price,
+			LearnerLimit:       1,
+			EnrollmentDeadline: start,
+			ClassStart:         start,
+			ClassFinish:        finish,
+			ClassStatus:        models.SessionStatusScheduled,
+		}
+		AttachMeetingRoom(&booking.ClassSession, room)
+		if err := tx.Omit(clause.Associations).Create(&booking.ClassSession).Error; err != nil {
+			return err
+		}
+		booking.Enrollment = models.Enrollment{
+			LearnerID:        learner.ID,
+			ClassSessionID:   booking.ClassSession.ID,
+			EnrollmentStatus: models.EnrollmentStatusActive,
+			AmountPaid:       price,
+		}
+		if err := tx.Omit(clause.Associations).Create(&booking.Enrollment).Error; err != nil {
+			return err
+		}
+		return RecordSessionHistory(tx, booking.ClassSession.ID, &learner.UserID, HistorySessionBooked,
+			fmt.Sprintf("Private lesson booked by learner %d for %.2f", learner.ID, price))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifyLocalized(db, []uint{teacher.UserID}, "system", func(loc *time.Location) string {
+		return fmt.Sprintf("A private lesson of %s was booked for %s.", class.ClassName, FormatLocalTime(start, loc))
+	})
+	return &booking, nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidBanRole        = errors.New("ban_role must be learner or teacher")
+	ErrBanNotFound           = errors.New("no active ban found for this user")
+	ErrBanAppealExists       = errors.New("an appeal has already been submitted for this ban")
+	ErrBanAppealReviewed     = errors.New("appeal has already been reviewed")
+	ErrInvalidAppealDecision = errors.New("decision must be accept or reject")
+	ErrBanEndNotShorter      = errors.New("new_ban_end must be earlier than the current ban end")
+)
+
+// ActiveBan is a ban currently in force against one of a user's roles.
+type ActiveBan struct {
+	BanRole        string    `json:"ban_role"`
+	BanID          uint      `json:"ban_id"`
+	BanStart       time.Time `json:"ban_start"`
+	BanEnd         time.Time `json:"ban_end"`
+	BanDescription string    `json:"ban_description"`
+	BanScope       string    `json:"ban_scope"`
+}
+
+// ActiveBansForUser lists the learner and teacher bans still in force at now.
+func ActiveBansForUser(db *gorm.DB, user *models.User, now time.Time) ([]ActiveBan, error) {
+	bans := []ActiveBan{}
+	if user.Learner != nil {
+		var rows []models.BanDetailsLearner
+		if err := db.Where("learner_id = ? AND ban_end > ?", user.Learner.ID, now).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, b := range rows {
+			bans = append(bans, ActiveBan{"learner", b.ID, b.BanStart, b.BanEnd, b.BanDescription, b.BanScope})
+		}
+	}
+	if user.Teacher != nil {
+		var rows []models.BanDetailsTeacher
+		if err := db.Where("teacher_id = ? AND ban_end > ?", user.Teacher.ID, now).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, b := range rows {
+			bans = append(bans, ActiveBan{"teacher", b.ID, b.BanStart, b.BanEnd, b.BanDescription, b.BanScope})
+		}
+	}
+	return bans, nil
+}
+
+// FindAppealableBan checks that banID is an active ban on the user's role and
+// has not been appealed yet, returning its current end.
+func FindAppealableBan(db *gorm.DB, user *models.User, role string, banID uint, now time.Time) (time.Time, error) {
+	var banEnd time.Time
+	var err error
+	switch role {
+	case "learner":
+		if user.Learner == nil {
+			return time.Time{}, ErrBanNotFound
+		}
+		var ban models.BanDetailsLearner
+		err = db.Where("id = ? AND learner_id = ? AND ban_end > ?", banID, user.Learner.ID, now).First(&ban).Error
+		banEnd = ban.BanEnd
+	case "teacher":
+		if user.Teacher == nil {
+			return time.Time{}, ErrBanNotFound
+		}
+		var ban models.BanDetailsTeacher
+		err = db.Where("id = ? AND teacher_id = ? AND ban_end > ?", banID, user.Teacher.ID, now).First(&ban).Error
+		banEnd = ban.BanEnd
+	default:
+		return time.Time{}, ErrInvalidBanRole
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, ErrBanNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var count int64
+	if err := db.Model(&models.BanAppeal{}).Where("ban_role = ? AND ban_id = ?", role, banID).Count(&count).Error; err != nil {
+		return time.Time{}, err
+	}
+	if count > 0 {
+		return time.Time{}, ErrBanAppealExists
+	}
+	return banEnd, nil
+}
+
+// ReviewBanAppeal records an admin decision. Accepting moves the ban's end to
+// newBanEnd, or lifts it immediately when newBanEnd is nil; the user is notified
+// either way. The appeal is locked while it is reviewed, so of two admins
+// deciding at once the second finds it already reviewed.
+func ReviewBanAppeal(db *gorm.DB, appealID uint, reviewerID *uint, decision, note string, newBanEnd *time.Time) (*models.BanAppeal, error) {
+	if decision != "accept" && decision != "reject" {
+		return nil, ErrInvalidAppealDecision
+	}
+
+	var appeal models.BanAppeal
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&appeal, appealID).Error; err != nil {
+			return err
+		}
+		if appeal.Status != "pending" {
+			return ErrBanAppealReviewed
+		}
+
+		now := time.Now()
+		appeal.ReviewNote = note
+		appeal.ReviewedByUserID = reviewerID
+		appeal.ReviewedAt = &now
+		appeal.Status = "rejected"
+
+		if decision == "accept" {
+			end := now
+			if newBanEnd != nil && newBanEnd.After(now) {
+				end = *newBanEnd
+			}
+			if !end.Before(appeal.OriginalBanEnd) {
+				return ErrBanEndNotShorter
+			}
+
+			var ban interface{} = &models.BanDetailsLearner{}
+			if appeal.BanRole == "teacher" {
+				ban = &models.BanDetailsTeacher{}
+			}
+			if err := tx.Model(ban).Where("id = ?", appeal.BanID).Update("ban_end", end).Error; err != nil {
+				return err
+			}
+			appeal.Status = "accepted"
+			appeal.ResultingBanEnd = &end
+		}
+		return tx.Save(&appeal).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	CreateNotification(db, appeal.UserID, "system", banAppealNotice(&appeal))
+	return &appeal, nil
+}
+
+// banAppealNotice summarises a review decision; the reviewer's note is
+// available on the appeal itself since notifications are capped at 255 chars.
+func banAppealNotice(appeal *models.BanAppeal) string {
+	switch {
+	case appeal.Status == "rejected":
+		return fmt.Sprintf("Your appeal #%d was rejected; the ban remains in place.", appeal.ID)
+	case appeal.ResultingBanEnd.After(*appeal.ReviewedAt):
+		return fmt.Sprintf("Your appeal #%d was accepted; your ban now ends on %s.", appeal.ID, appeal.ResultingBanEnd.Format(time.RFC3339))
+	default:
+		return fmt.Sprintf("Your appeal #%d was accepted and your ban has been lifted.", appeal.ID)
+	}
+}
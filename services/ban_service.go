@@ -7,8 +7,9 @@ import (
 	"gorm.io/gorm"
 )
 
-// ApplyLearnerFlags adds flags to a learner. If the flag count reaches 3,
-// it issues a 7-day ban and updates the main user's total ban count.
+// ApplyLearnerFlags adds flags to a learner. If the flag count reaches the
+// moderation policy threshold, it issues a ban whose length escalates with the
+// user's previous ban count and updates that total.
 func ApplyLearnerFlags(db *gorm.DB, learnerID uint, flagsToAdd int, reason string) error {
 	return db.Transaction(func(tx *gorm.DB) error {
 		var learner models.Learner
@@ -16,27 +17,18 @@ func ApplyLearnerFlags(db *gorm.DB, learnerID uint, flagsToAdd int, reason strin
 			return err
 		}
 
+		policy, err := GetModerationPolicy(tx)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
 		learner.FlagCount += flagsToAdd
+		learner.LastFlaggedAt = &now
 
-		if learner.FlagCount >= 3 {
-			var user models.User
-			if err := tx.First(&user, learner.UserID).Error; err != nil {
-				return err
-			}
-
-			learner.FlagCount -= 3
-			user.BanCount += 1
-
-			banDetails := models.BanDetailsLearner{
-				LearnerID:      learnerID,
-				BanStart:       time.Now(),
-				BanEnd:         time.Now().Add(7 * 24 * time.Hour),
-				BanDescription: reason,
-			}
-			if err := tx.Create(&banDetails).Error; err != nil {
-				return err
-			}
-			if err := tx.Save(&user).Error; err != nil {
+		if learner.FlagCount >= policy.FlagThreshold {
+			learner.FlagCount -= policy.FlagThreshold
+			if _, err := issueLearnerBan(tx, &policy, learner, reason, now); err != nil {
 				return err
 			}
 		}
@@ -44,8 +36,9 @@ func ApplyLearnerFlags(db *gorm.DB, learnerID uint, flagsToAdd int, reason strin
 	})
 }
 
-// ApplyTeacherFlags adds flags to a teacher. If the flag count reaches 3,
-// it issues a 7-day ban and updates the main user's total ban count.
+// ApplyTeacherFlags adds flags to a teacher. If the flag count reaches the
+// moderation policy threshold, it issues a ban whose length escalates with the
+// user's previous ban count and updates that total.
 func ApplyTeacherFlags(db *gorm.DB, teacherID uint, flagsToAdd int, reason string) error {
 	return db.Transaction(func(tx *gorm.DB) error {
 		var teacher models.Teacher
@@ -53,27 +46,18 @@ func ApplyTeacherFlags(db *gorm.DB, teacherID uint, flagsToAdd int, reason strin
 			return err
 		}
 
-		teacher.FlagCount += flagsToAdd
-
-		if teacher.FlagCount >= 3 {
-			var user models.User
-			if err := tx.First(&user, teacher.UserID).Error; err != nil {
-				return err
-			}
+		policy, err := GetModerationPolicy(tx)
+		if err != nil {
+			return err
+		}
 
-			teacher.FlagCount -= 3
-			user.BanCount += 1
+		now := time.Now()
+		teacher.FlagCount += flagsToAdd
+		teacher.LastFlaggedAt = &now
 
-			banDetails := models.BanDetailsTeacher{
-				TeacherID:      teacherID,
-				BanStart:       time.Now(),
-				BanEnd:         time.Now().Add(7 * 24 * time.Hour),
-				BanDescription: reason,
-			}
-			if err := tx.Create(&banDetails).Error; err != nil {
-				return err
-			}
-			if err := tx.Save(&user).Error; err != nil {
+		if teacher.FlagCount >= policy.FlagThreshold {
+			teacher.FlagCount -= policy.FlagThreshold
+			if _, err := issueTeacherBan(tx, &policy, teacher, reason, now); err != nil {
 				return err
 			}
 		}
@@ -89,7 +73,53 @@ func AddTeacherFlag(db *gorm.DB, teacherID uint, flagsToAdd int) error {
 		if err := tx.First(&teacher, teacherID).Error; err != nil {
 			return err
 		}
+		now := time.Now()
 		teacher.FlagCount += flagsToAdd
+		teacher.LastFlaggedAt = &now
 		return tx.Save(&teacher).Error
 	})
 }
+
+// issueLearnerBan creates a ban sized by the policy's escalation ladder and
+// increments the owning user's BanCount.
+func issueLearnerBan(tx *gorm.DB, policy *models.ModerationPolicy, learner models.Learner, reason string, now time.Time) (*models.BanDetailsLearner, error) {
+	var user models.User
+	if err := tx.First(&user, learner.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	banDetails := models.BanDetailsLearner{
+		LearnerID:      learner.ID,
+		BanStart:       now,
+		BanEnd:         policy.BanEndFor(user.BanCount, now),
+		BanDescription: reason,
+		BanScope:       models.BanScopeLearning,
+	}
+	if err := tx.Create(&banDetails).Error; err != nil {
+		return nil, err
+	}
+	user.BanCount += 1
+	return &banDetails, tx.Save(&user).Error
+}
+
+// issueTeacherBan creates a ban sized by the policy's escalation ladder and
+// increments the owning user's BanCount.
+func issueTeacherBan(tx *gorm.DB, policy *models.ModerationPolicy, teacher models.Teacher, reason string, now time.Time) (*models.BanDetailsTeacher, error) {
+	var user models.User
+	if err := tx.First(&user, teacher.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	banDetails := models.BanDetailsTeacher{
+		TeacherID:      teacher.ID,
+		BanStart:       now,
+		BanEnd:         policy.BanEndFor(user.BanCount, now),
+		BanDescription: reason,
+		BanScope:       models.BanScopeTeaching,
+	}
+	if err := tx.Create(&banDetails).Error; err != nil {
+		return nil, err
+	}
+	user.BanCount += 1
+	return &banDetails, tx.Save(&user).Error
+}
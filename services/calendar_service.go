@@ -0,0 +1,237 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CalendarFeedHistory is how far back a feed lists finished sessions.
+const CalendarFeedHistory = 90 * 24 * time.Hour
+
+var ErrInvalidCalendarToken = errors.New("invalid calendar token")
+
+// CalendarEvent is one VEVENT of a feed.
+type CalendarEvent struct {
+	UID          string
+	Summary      string
+	Description  string
+	URL          string
+	Start        time.Time
+	End          time.Time
+	LastModified time.Time
+	Sequence     int
+	Cancelled    bool
+}
+
+// SessionCalendarEvent turns session into an event. session.Class should be
+// loaded for the summary. cancelled marks the event cancelled for this
+// viewer, e.g. a learner whose enrollment was refunded. The meeting link
+// itself is left out: links are personal and only handed out around class
+// time. Calendar apps open links without the user's bearer token, so the
+// event links to sessionURL, a web app page with {id} standing for the
+// session ID, and carries no link when sessionURL is empty.
+//
+// SEQUENCE is CalendarSequence, bumped by every time change, plus one once
+// the event is cancelled; cancellation is final so the value never goes back.
+func SessionCalendarEvent(session *models.ClassSession, cancelled bool, sessionURL string) CalendarEvent {
+	cancelled = cancelled || models.NormalizeSessionStatus(session.ClassStatus) == models.SessionStatusCancelled
+	seq := session.CalendarSequence
+	if cancelled {
+		seq++
+	}
+
+	summary := session.Class.ClassName
+	if summary == "" {
+		summary = "Tutorium class"
+	}
+	var link string
+	description := session.Description
+	if sessionURL != "" {
+		link = strings.ReplaceAll(sessionURL, "{id}", strconv.FormatUint(uint64(session.ID), 10))
+		if description != "" {
+			description += "\n\n"
+		}
+		description += "Open in Tutorium: " + link
+	}
+	return CalendarEvent{
+		UID:          fmt.Sprintf("class-session-%d@tutorium", session.ID),
+		Summary:      summary,
+		Description:  description,
+		URL:          link,
+		Start:        session.ClassStart,
+		End:          session.ClassFinish,
+		LastModified: session.UpdatedAt,
+		Sequence:     seq,
+		Cancelled:    cancelled,
+	}
+}
+
+// icsEscape escapes TEXT values as RFC 5545 section 3.3.11 requires.
+func icsEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ";", `\;`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	s = strings.ReplaceAll(s, "\r\n", `\n`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+// icsTime formats t as a UTC DATE-TIME. Events are always exported in UTC so
+// they land at the right instant whatever timezone the calendar app uses.
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// writeICSLine writes a content line folded at 75 octets, never splitting a
+// UTF-8 sequence.
+func writeICSLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // the leading space counts
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// BuildCalendar renders events as an iCalendar (RFC 5545) document. loc is
+// advertised as X-WR-TIMEZONE, the zone apps show the calendar in by default.
+func BuildCalendar(name string, loc *time.Location, events []CalendarEvent, now time.Time) string {
+	var b strings.Builder
+	for _, l := range []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//Tutorium//Tutorium Schedule//EN",
+		"CALSCALE:GREGORIAN", "METHOD:PUBLISH", "X-WR-CALNAME:" + icsEscape(name), "X-WR-TIMEZONE:" + loc.String()} {
+		writeICSLine(&b, l)
+	}
+	for _, e := range events {
+		status := "CONFIRMED"
+		if e.Cancelled {
+			status = "CANCELLED"
+		}
+		writeICSLine(&b, "BEGIN:VEVENT")
+		writeICSLine(&b, "UID:"+e.UID)
+		writeICSLine(&b, "DTSTAMP:"+icsTime(now))
+		writeICSLine(&b, "DTSTART:"+icsTime(e.Start))
+		writeICSLine(&b, "DTEND:"+icsTime(e.End))
+		if !e.LastModified.IsZero() {
+			writeICSLine(&b, "LAST-MODIFIED:"+icsTime(e.LastModified))
+		}
+		writeICSLine(&b, fmt.Sprintf("SEQUENCE:%d", e.Sequence))
+		writeICSLine(&b, "STATUS:"+status)
+		writeICSLine(&b, "SUMMARY:"+icsEscape(e.Summary))
+		if e.Description != "" {
+			writeICSLine(&b, "DESCRIPTION:"+icsEscape(e.Description))
+		}
+		if e.URL != "" {
+			writeICSLine(&b, "URL:"+e.URL)
+		}
+		writeICSLine(&b, "END:VEVENT")
+	}
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// UserCalendarEvents collects the sessions user teaches and the sessions
+// they are enrolled in, from CalendarFeedHistory ago onwards. Refunded
+// enrollments stay in the feed as cancelled events so calendars drop them.
+// sessionURL is passed on to SessionCalendarEvent.
+func UserCalendarEvents(db *gorm.DB, user *models.User, sessionURL string, now time.Time) ([]CalendarEvent, error) {
+	since := now.Add(-CalendarFeedHistory)
+	events := []CalendarEvent{}
+	seen := map[uint]bool{}
+
+	if user.Teacher != nil {
+		var taught []models.ClassSession
+		if err := db.Preload("Class").
+			Joins("JOIN classes ON classes.id = class_sessions.class_id").
+			Where("classes.teacher_id = ? AND class_sessions.class_finish > ?", user.Teacher.ID, since).
+			Order("class_sessions.class_start").Find(&taught).Error; err != nil {
+			return nil, err
+		}
+		for i := range taught {
+			seen[taught[i].ID] = true
+			events = append(events, SessionCalendarEvent(&taught[i], false, sessionURL))
+		}
+	}
+
+	if user.Learner != nil {
+		var enrollments []models.Enrollment
+		if err := db.Preload("ClassSession.Class").
+			Joins("JOIN class_sessions ON class_sessions.id = enrollments.class_session_id AND class_sessions.deleted_at IS NULL").
+			Where("enrollments.learner_id = ? AND class_sessions.class_finish > ?", user.Learner.ID, since).
+			Order("class_sessions.class_start").Find(&enrollments).Error; err != nil {
+			return nil, err
+		}
+		for i := range enrollments {
+			session := &enrollments[i].ClassSession
+			if seen[session.ID] {
+				continue
+			}
+			seen[session.ID] = true
+			events = append(events, SessionCalendarEvent(session, enrollments[i].EnrollmentStatus == models.EnrollmentStatusRefunded, sessionURL))
+		}
+	}
+	return events, nil
+}
+
+// RotateCalendarToken issues a new feed token for userID, replacing any
+// previous one, and returns the raw token, which is never stored.
+func RotateCalendarToken(db *gorm.DB, userID uint, now time.Time) (string, error) {
+	token, err := randomAlphanumeric(40)
+	if err != nil {
+		return "", err
+	}
+	hash := HashAPIKey(token)
+
+	feed := models.CalendarFeed{UserID: userID, TokenHash: hash}
+	err = db.Omit("User").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"token_hash": hash, "updated_at": now, "last_accessed_at": nil}),
+	}).Create(&feed).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeCalendarToken disables userID's feed URL.
+func RevokeCalendarToken(db *gorm.DB, userID uint) error {
+	return db.Unscoped().Where("user_id = ?", userID).Delete(&models.CalendarFeed{}).Error
+}
+
+// AuthenticateCalendarToken resolves a feed token to its user, with the
+// learner and teacher profiles loaded, and records the access.
+func AuthenticateCalendarToken(db *gorm.DB, token string, now time.Time) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidCalendarToken
+	}
+
+	var feed models.CalendarFeed
+	if err := db.Where("token_hash = ?", HashAPIKey(token)).First(&feed).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCalendarToken
+		}
+		return nil, err
+	}
+
+	var user models.User
+	if err := db.Preload("Learner").Preload("Teacher").First(&user, feed.UserID).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&feed).UpdateColumn("last_accessed_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
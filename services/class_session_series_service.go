@@ -0,0 +1,234 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Series edit scopes.
+const (
+	SeriesScopeThis   = "this"
+	SeriesScopeFuture = "future"
+)
+
+var (
+	ErrSeriesCancelled    = errors.New("class session series is cancelled")
+	ErrNotSeriesSession   = errors.New("class session does not belong to this series")
+	ErrOccurrenceStarted  = errors.New("only occurrences that have not started can be edited")
+	ErrInvalidSeriesSpan  = errors.New("class_finish must be after class_start")
+	ErrOccurrenceEnrolled = errors.New("learners are enrolled in an occurrence being moved; propose the new time with POST /class_sessions/:id/reschedule")
+)
+
+// ExpandSeries materializes one ClassSession per occurrence of the series
+// rule. Occurrences keep the wall-clock time of FirstStart in loc, the
+// class timezone, so a weekly 18:00 class stays at 18:00 across DST changes
+// and excluded dates are read as dates in loc. Meeting links are left for
+// the caller to fill in.
+func ExpandSeries(series *models.ClassSessionSeries, status string, loc *time.Location) ([]models.ClassSession, error) {
+	rule, err := ParseRRule(series.RRule)
+	if err != nil {
+		return nil, err
+	}
+	starts, err := rule.Occurrences(series.FirstStart.In(loc), series.ExcludedDates)
+	if err != nil {
+		return nil, err
+	}
+	if len(starts) == 0 {
+		return nil, fmt.Errorf("%w: the rule produces no occurrences", ErrInvalidRRule)
+	}
+
+	duration := time.Duration(series.DurationMinutes) * time.Minute
+	lead := time.Duration(series.EnrollmentLeadHours) * time.Hour
+	sessions := make([]models.ClassSession, len(starts))
+	for i, start := range starts {
+		sessions[i] = models.ClassSession{
+			ClassID:            series.ClassID,
+			Description:        series.Description,
+			Price:              series.Price,
+			LearnerLimit:       series.LearnerLimit,
+			EnrollmentDeadline: start.Add(-lead),
+			ClassStart:         start,
+			ClassFinish:        start.Add(duration),
+			ClassStatus:        status,
+		}
+	}
+	return sessions, nil
+}
+
+// UpdateSeriesOccurrence applies req to occurrence and, for SeriesScopeFuture,
+// to every later upcoming occurrence in the series. Time changes are applied
+// as a shift so later occurrences keep their own dates, and are refused with
+// ErrOccurrenceEnrolled when any moved occurrence has active enrollments.
+func UpdateSeriesOccurrence(db *gorm.DB, series *models.ClassSessionSeries, occurrence *models.ClassSession, scope string, req models.UpdateSeriesOccurrenceRequest) ([]models.ClassSession, error) {
+	if series.Status == models.SeriesStatusCancelled {
+		return nil, ErrSeriesCancelled
+	}
+	if occurrence.SeriesID == nil || *occurrence.SeriesID != series.ID {
+		return nil, ErrNotSeriesSession
+	}
+	if !isUpcomingSession(occurrence) {
+		return nil, ErrOccurrenceStarted
+	}
+
+	var startShift, finishShift time.Duration
+	if req.ClassStart != nil {
+		startShift = req.ClassStart.Sub(occurrence.ClassStart)
+	}
+	if req.ClassFinish != nil {
+		finishShift = req.ClassFinish.Sub(occurrence.ClassFinish)
+	}
+	if !occurrence.ClassFinish.Add(finishShift).After(occurrence.ClassStart.Add(startShift)) {
+		return nil, ErrInvalidSeriesSpan
+	}
+
+	targets := []models.ClassSession{*occurrence}
+	if scope == SeriesScopeFuture {
+		targets = nil
+		if err := db.Where("series_id = ? AND class_start >= ? AND class_status IN ?", series.ID, occurrence.ClassStart, models.UpcomingSessionStatuses).
+			Order("class_start").Find(&targets).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]uint, len(targets))
+	for i := range targets {
+		ids[i] = targets[i].ID
+	}
+	moved := startShift != 0 || finishShift != 0
+
+	for i := range targets {
+		s := &targets[i]
+		if req.Description != nil {
+			s.Description = *req.Description
+		}
+		if req.Price != nil {
+			s.Price = *req.Price
+		}
+		if req.LearnerLimit != nil {
+			s.LearnerLimit = *req.LearnerLimit
+		}
+		s.EnrollmentDeadline = s.EnrollmentDeadline.Add(startShift)
+		s.ClassStart = s.ClassStart.Add(startShift)
+		s.ClassFinish = s.ClassFinish.Add(finishShift)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if moved {
+			// The occurrences being moved cannot block one another.
+			for i := range targets {
+				if err := CheckTeacherSchedule(tx, series.ClassID, targets[i].ClassStart, targets[i].ClassFinish, ids...); err != nil {
+					return err
+				}
+			}
+			// Moving a session with learners needs their consent; see
+			// ProposeReschedule. The occurrences are locked after the teacher,
+			// as UpdateClassSession does, so nobody enrolls while they move.
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+				Where("id IN ?", ids).Find(&[]models.ClassSession{}).Error; err != nil {
+				return err
+			}
+			var enrolled int64
+			if err := tx.Model(&models.Enrollment{}).
+				Where("class_session_id IN ? AND enrollment_status = ?", ids, models.EnrollmentStatusActive).
+				Count(&enrolled).Error; err != nil {
+				return err
+			}
+			if enrolled > 0 {
+				return ErrOccurrenceEnrolled
+			}
+		}
+
+		for i := range targets {
+			s := &targets[i]
+			updates := map[string]interface{}{
+				"description":         s.Description,
+				"price":               s.Price,
+				"learner_limit":       s.LearnerLimit,
+				"enrollment_deadline": s.EnrollmentDeadline,
+				"class_start":         s.ClassStart,
+				"class_finish":        s.ClassFinish,
+			}
+			// Only a new time reissues calendar events and reminders.
+			if moved {
+				updates["calendar_sequence"] = gorm.Expr("calendar_sequence + 1")
+				updates["reminder_sent_at"] = nil
+			}
+			if err := tx.Model(&models.ClassSession{}).Where("id = ?", s.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if scope != SeriesScopeFuture {
+			return nil
+		}
+
+		// Keep the template in step so the series shows what future sessions look like.
+		updates := map[string]interface{}{}
+		if req.Description != nil {
+			updates["description"] = *req.Description
+		}
+		if req.Price != nil {
+			updates["price"] = *req.Price
+		}
+		if req.LearnerLimit != nil {
+			updates["learner_limit"] = *req.LearnerLimit
+		}
+		if finishShift != startShift {
+			updates["duration_minutes"] = series.DurationMinutes + int((finishShift-startShift)/time.Minute)
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(series).Omit(clause.Associations).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// CancelSeries cancels every upcoming occurrence of the series, with the same
+// refunds and late-cancellation penalty as CancelClassSession, and marks the
+// series cancelled. Occurrences already running or finished are kept.
+func CancelSeries(db *gorm.DB, series *models.ClassSessionSeries, actorUserID *uint, now time.Time) ([]models.ClassSession, error) {
+	if series.Status == models.SeriesStatusCancelled {
+		return nil, ErrSeriesCancelled
+	}
+
+	var upcoming []models.ClassSession
+	if err := db.Where("series_id = ? AND class_status IN ?", series.ID, models.UpcomingSessionStatuses).
+		Order("class_start").Find(&upcoming).Error; err != nil {
+		return nil, err
+	}
+
+	cancelled := make([]models.ClassSession, 0, len(upcoming))
+	for i := range upcoming {
+		_, err := CancelClassSession(db, &upcoming[i], series.Class.TeacherID, actorUserID, "The whole series was cancelled.", now)
+		if errors.Is(err, ErrSessionStatusChanged) {
+			continue
+		}
+		if err != nil {
+			return cancelled, err
+		}
+		cancelled = append(cancelled, upcoming[i])
+	}
+
+	if err := db.Model(series).Omit(clause.Associations).Update("status", models.SeriesStatusCancelled).Error; err != nil {
+		return cancelled, err
+	}
+	return cancelled, nil
+}
+
+func isUpcomingSession(s *models.ClassSession) bool {
+	status := models.NormalizeSessionStatus(s.ClassStatus)
+	for _, u := range models.UpcomingSessionStatuses {
+		if status == u {
+			return true
+		}
+	}
+	return false
+}
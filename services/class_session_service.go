@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidSessionTransition = errors.New("invalid class session status transition")
+	ErrSessionStatusAutomatic   = errors.New("this class session status is set automatically")
+	ErrSessionStatusChanged     = errors.New("class session status changed concurrently")
+)
+
+// SessionEvent is emitted after a ClassSession changes status.
+type SessionEvent struct {
+	SessionID uint
+	From      string
+	To        string
+	At        time.Time
+}
+
+// SessionEventHandler reacts to a session status change. Handlers run
+// synchronously after the change is committed, so they must not block.
+type SessionEventHandler func(db *gorm.DB, event SessionEvent)
+
+var (
+	sessionHandlersMu sync.RWMutex
+	sessionHandlers   []SessionEventHandler
+)
+
+// OnSessionTransition registers h to be called after every session status change.
+func OnSessionTransition(h SessionEventHandler) {
+	sessionHandlersMu.Lock()
+	defer sessionHandlersMu.Unlock()
+	sessionHandlers = append(sessionHandlers, h)
+}
+
+func emitSessionEvent(db *gorm.DB, event SessionEvent) {
+	sessionHandlersMu.RLock()
+	handlers := append([]SessionEventHandler(nil), sessionHandlers...)
+	sessionHandlersMu.RUnlock()
+	for _, h := range handlers {
+		h(db, event)
+	}
+}
+
+// IsManualSessionStatus reports whether a teacher may set status directly.
+func IsManualSessionStatus(status string) bool {
+	for _, s := range models.ManualSessionStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionClassSession validates and applies a status change, then emits a
+// SessionEvent. The update is conditional on the current status so the
+// scheduler and a teacher cannot both move the same session.
+func TransitionClassSession(db *gorm.DB, session *models.ClassSession, to string) error {
+	from := models.NormalizeSessionStatus(session.ClassStatus)
+	if !models.CanTransitionSession(from, to) {
+		return ErrInvalidSessionTransition
+	}
+
+	res := db.Model(&models.ClassSession{}).
+		Where("id = ? AND class_status = ?", session.ID, session.ClassStatus).
+		Update("class_status", to)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSessionStatusChanged
+	}
+
+	session.ClassStatus = to
+	emitSessionEvent(db, SessionEvent{SessionID: session.ID, From: from, To: to, At: time.Now()})
+	return nil
+}
+
+// RecordTeacherCheckIn stamps the first time the session's teacher joins.
+func RecordTeacherCheckIn(db *gorm.DB, session *models.ClassSession, now time.Time) error {
+	if session.TeacherCheckedInAt != nil {
+		return nil
+	}
+	if err := db.Model(&models.ClassSession{}).
+		Where("id = ? AND teacher_checked_in_at IS NULL", session.ID).
+		Update("teacher_checked_in_at", now).Error; err != nil {
+		return err
+	}
+	session.TeacherCheckedInAt = &now
+	return nil
+}
+
+// AdvanceSessionStatuses starts sessions whose teacher has checked in once
+// ClassStart has passed and completes running sessions after ClassFinish.
+// Sessions the teacher never joins are left to CheckForAbsentTeachers.
+func AdvanceSessionStatuses(db *gorm.DB) {
+	now := time.Now()
+
+	var starting []models.ClassSession
+	if err := db.Where("class_status IN ? AND class_start <= ? AND teacher_checked_in_at IS NOT NULL", models.UpcomingSessionStatuses, now).
+		Find(&starting).Error; err != nil {
+		log.Printf("Error finding sessions to start: %v", err)
+	}
+	for i := range starting {
+		if err := TransitionClassSession(db, &starting[i], models.SessionStatusInProgress); err != nil {
+			log.Printf("Failed to start class session %d: %v", starting[i].ID, err)
+		}
+	}
+
+	var finished []models.ClassSession
+	if err := db.Where("class_status = ? AND class_finish <= ?", models.SessionStatusInProgress, now).
+		Find(&finished).Error; err != nil {
+		log.Printf("Error finding sessions to complete: %v", err)
+	}
+	for i := range finished {
+		if err := TransitionClassSession(db, &finished[i], models.SessionStatusCompleted); err != nil {
+			log.Printf("Failed to complete class session %d: %v", finished[i].ID, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+// expSessionTransition expects sessionID to move from one status to
+// another, changing rows rows (0 when it changed in the meantime), and the
+// change to be recorded in the session's history.
+func expSessionTransition(mock sqlmock.Sqlmock, sessionID uint, from, to string, rows int64) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "class_sessions" SET "class_status"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND class_status = \$4\)`).
+		WithArgs(to, sqlmock.AnyArg(), sessionID, from).
+		WillReturnResult(sqlmock.NewResult(0, rows))
+	mock.ExpectCommit()
+	if rows == 0 {
+		return
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "session_histories"`).
+		WithArgs(sqlmock.AnyArg(), sessionID, nil, HistoryStatusChanged, from+" -> "+to).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+}
+
+func expSessionsToComplete(mock sqlmock.Sqlmock, ids ...uint) {
+	rows := sqlmock.NewRows([]string{"id", "class_status"})
+	for _, id := range ids {
+		rows.AddRow(id, models.SessionStatusInProgress)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE \(class_status = \$1 AND class_finish <= \$2\)`).
+		WithArgs(models.SessionStatusInProgress, nearTime{time.Now()}).
+		WillReturnRows(rows)
+}
+
+/* ------------------ AdvanceSessionStatuses ------------------ */
+
+func TestAdvanceSessionStatuses(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE \(class_status IN \(\$1,\$2\) AND class_start <= \$3 AND teacher_checked_in_at IS NOT NULL\)`).
+		WithArgs(models.SessionStatusScheduled, models.SessionStatusOpen, nearTime{time.Now()}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "class_status"}).
+			AddRow(3, models.SessionStatusScheduled).
+			AddRow(4, models.SessionStatusOpen))
+	expSessionTransition(mock, 3, models.SessionStatusScheduled, models.SessionStatusInProgress, 1)
+	// Session 4 was cancelled after it was loaded; the job moves on.
+	expSessionTransition(mock, 4, models.SessionStatusOpen, models.SessionStatusInProgress, 0)
+	expSessionsToComplete(mock, 5)
+	expSessionTransition(mock, 5, models.SessionStatusInProgress, models.SessionStatusCompleted, 1)
+
+	AdvanceSessionStatuses(gdb)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAdvanceSessionStatuses_CompletesAfterStartLookupFails(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT \* FROM "class_sessions" WHERE \(class_status IN`).
+		WillReturnError(fmt.Errorf("select failed"))
+	expSessionsToComplete(mock, 5)
+	expSessionTransition(mock, 5, models.SessionStatusInProgress, models.SessionStatusCompleted, 1)
+
+	AdvanceSessionStatuses(gdb)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+var ErrUnknownMeetingRoom = errors.New("no class session is hosted in this meeting room")
+
+// RecordPresenceEvent stores a join or leave reported by provider's webhooks.
+// The first time a participant joins inside the attendance window it
+// counts: the teacher is checked in and a learner is marked present, or late
+// after LateAfter. Rejoining keeps the first verdict.
+func RecordPresenceEvent(db *gorm.DB, provider string, ev meeting.PresenceEvent) error {
+	var session models.ClassSession
+	err := db.Preload("Class").Where("meeting_provider = ? AND meeting_room_id = ?", provider, ev.RoomID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUnknownMeetingRoom
+	}
+	if err != nil {
+		return err
+	}
+
+	if ev.Kind == meeting.EventEnd {
+		return db.Model(&models.MeetingPresence{}).
+			Where("class_session_id = ? AND left_at IS NULL", session.ID).
+			Update("left_at", ev.At).Error
+	}
+
+	userID, err := strconv.ParseUint(ev.UserID, 10, 0)
+	if err != nil {
+		return ErrNotMeetingParticipant
+	}
+	role, learnerID, err := meetingRole(db, &session, uint(userID))
+	if err != nil {
+		return err
+	}
+
+	var open models.MeetingPresence
+	err = db.Where("class_session_id = ? AND user_id = ? AND left_at IS NULL", session.ID, userID).
+		Order("joined_at DESC").First(&open).Error
+	inRoom := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	switch ev.Kind {
+	case meeting.EventLeave:
+		if !inRoom {
+			return nil
+		}
+		left := ev.At
+		if left.Before(open.JoinedAt) {
+			left = open.JoinedAt
+		}
+		return db.Model(&open).Update("left_at", left).Error
+
+	case meeting.EventJoin:
+		if inRoom {
+			return nil // a second device; the stay is already open
+		}
+		var earlier int64
+		if err := db.Model(&models.MeetingPresence{}).
+			Where("class_session_id = ? AND user_id = ?", session.ID, userID).
+			Count(&earlier).Error; err != nil {
+			return err
+		}
+		if err := db.Create(&models.MeetingPresence{
+			ClassSessionID: session.ID,
+			UserID:         uint(userID),
+			Role:           role,
+			JoinedAt:       ev.At,
+		}).Error; err != nil {
+			return err
+		}
+		if earlier > 0 || !InAttendanceWindow(&session, ev.At) {
+			return nil
+		}
+		if role == models.MeetingRoleTeacher {
+			return RecordTeacherCheckIn(db, &session, ev.At)
+		}
+		status := models.AttendancePresent
+		if ev.At.After(session.ClassStart.Add(LateAfter)) {
+			status = models.AttendanceLate
+		}
+		_, err := RecordAttendance(db, session.ID, learnerID, status, models.AttendanceSourceMeetingJoin, nil, ev.At)
+		return err
+	}
+	return nil
+}
+
+// meetingRole tells whether userID teaches session or holds a seat in it,
+// returning their learner ID in the latter case.
+func meetingRole(db *gorm.DB, session *models.ClassSession, userID uint) (string, uint, error) {
+	var teacher models.Teacher
+	err := db.Select("id", "user_id").First(&teacher, session.Class.TeacherID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", 0, err
+	}
+	if err == nil && teacher.UserID == userID {
+		return models.MeetingRoleTeacher, 0, nil
+	}
+
+	var learner models.Learner
+	err = db.Select("id").Where("user_id = ?", userID).First(&learner).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", 0, ErrNotMeetingParticipant
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	var enrolled int64
+	if err := db.Model(&models.Enrollment{}).
+		Where("learner_id = ? AND class_session_id = ? AND enrollment_status IN ?", learner.ID, session.ID, attendedEnrollmentStatuses).
+		Count(&enrolled).Error; err != nil {
+		return "", 0, err
+	}
+	if enrolled == 0 {
+		return "", 0, ErrNotMeetingParticipant
+	}
+	return models.MeetingRoleLearner, learner.ID, nil
+}
+
+// SessionPresence totals each participant's time in session's meeting up to
+// now. Stays still open when the meeting window closes, e.g. because the
+// leave event never arrived, are cut off there.
+func SessionPresence(db *gorm.DB, session *models.ClassSession, now time.Time) ([]models.ParticipantPresence, error) {
+	var stays []models.MeetingPresence
+	if err := db.Where("class_session_id = ?", session.ID).Order("user_id, joined_at").Find(&stays).Error; err != nil {
+		return nil, err
+	}
+	_, closes := MeetingTokenWindow(session)
+	cutoff := now
+	if closes.Before(now) {
+		cutoff = closes
+	}
+
+	out := []models.ParticipantPresence{}
+	var covered time.Time // end of the participant's time counted so far
+	for _, s := range stays {
+		if len(out) == 0 || out[len(out)-1].UserID != s.UserID {
+			out = append(out, models.ParticipantPresence{UserID: s.UserID, Role: s.Role, FirstJoinedAt: s.JoinedAt})
+			covered = time.Time{}
+		}
+		p := &out[len(out)-1]
+
+		end := cutoff
+		if s.LeftAt != nil {
+			end = *s.LeftAt
+			if p.LastLeftAt == nil || end.After(*p.LastLeftAt) {
+				left := end
+				p.LastLeftAt = &left
+			}
+		} else if now.Before(closes) {
+			p.InRoom = true
+		}
+
+		start := s.JoinedAt
+		if start.Before(covered) {
+			start = covered
+		}
+		if end.After(start) {
+			p.Seconds += int64(end.Sub(start) / time.Second)
+			covered = end
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/meeting"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// MeetingTokenGrace is how long after ClassFinish a join link stays valid,
+// so an overrunning class is not cut off. Links become valid
+// AttendanceWindowLead before ClassStart.
+const MeetingTokenGrace = 15 * time.Minute
+
+var (
+	ErrNotMeetingParticipant   = errors.New("only the session's teacher and enrolled learners can join its meeting")
+	ErrMeetingNotOpen          = errors.New("the meeting for this class session has not opened yet")
+	ErrMeetingClosed           = errors.New("the meeting for this class session has ended")
+	ErrNoMeetingRoom           = errors.New("no meeting was created for your class session yet")
+	ErrMeetingProviderMismatch = errors.New("the class session's meeting is hosted on a provider that is not configured")
+	ErrMeetingBanned           = errors.New("your account is suspended from joining this meeting")
+)
+
+// AttachMeetingRoom records room on a session that is about to be created.
+func AttachMeetingRoom(session *models.ClassSession, room meeting.Room) {
+	session.MeetingProvider = room.Provider
+	session.MeetingRoomID = room.ID
+	session.MeetingUrl = room.URL
+}
+
+// SessionRoomID is the provider's ID of session's room. Sessions created
+// before rooms were tracked only have a Jitsi link, whose last path segment
+// is the room.
+func SessionRoomID(session *models.ClassSession) string {
+	if session.MeetingRoomID != "" {
+		return session.MeetingRoomID
+	}
+	if session.MeetingUrl == "" {
+		return ""
+	}
+	return path.Base(strings.SplitN(session.MeetingUrl, "?", 2)[0])
+}
+
+// sessionProvider is the provider hosting session's room.
+func sessionProvider(session *models.ClassSession) string {
+	if session.MeetingProvider == "" {
+		return meeting.ProviderJitsi
+	}
+	return session.MeetingProvider
+}
+
+// MeetingTokenWindow is when a session's join links are valid.
+func MeetingTokenWindow(session *models.ClassSession) (notBefore, expires time.Time) {
+	return session.ClassStart.Add(-AttendanceWindowLead), session.ClassFinish.Add(MeetingTokenGrace)
+}
+
+// AuthorizeMeetingAccess decides whether user may join session's meeting at
+// now and in which role: the session's teacher, or a learner with an active
+// enrollment, inside MeetingTokenWindow. Users with an active platform ban,
+// or a teaching or learning ban matching their role, are turned away.
+// Cancelled sessions and sessions the teacher missed have no meeting.
+func AuthorizeMeetingAccess(db *gorm.DB, session *models.ClassSession, user *models.User, now time.Time) (string, error) {
+	role := ""
+	if IsSessionTeacher(user, session) {
+		role = models.MeetingRoleTeacher
+	} else if user.Learner != nil {
+		var enrolled int64
+		if err := db.Model(&models.Enrollment{}).
+			Where("learner_id = ? AND class_session_id = ? AND enrollment_status = ?", user.Learner.ID, session.ID, models.EnrollmentStatusActive).
+			Count(&enrolled).Error; err != nil {
+			return "", err
+		}
+		if enrolled > 0 {
+			role = models.MeetingRoleLearner
+		}
+	}
+	if role == "" {
+		return "", ErrNotMeetingParticipant
+	}
+	banned, err := hasMeetingBan(db, user, role, now)
+	if err != nil {
+		return "", err
+	}
+	if banned {
+		return "", ErrMeetingBanned
+	}
+
+	switch models.NormalizeSessionStatus(session.ClassStatus) {
+	case models.SessionStatusCancelled, models.SessionStatusTeacherAbsent:
+		return "", ErrMeetingClosed
+	}
+	nbf, exp := MeetingTokenWindow(session)
+	switch {
+	case now.Before(nbf):
+		return "", ErrMeetingNotOpen
+	case !now.Before(exp):
+		return "", ErrMeetingClosed
+	}
+	return role, nil
+}
+
+// hasMeetingBan reports whether user has an active ban that keeps them out
+// of a meeting in role: a platform ban on either profile, or the ban scope
+// the role needs.
+func hasMeetingBan(db *gorm.DB, user *models.User, role string, now time.Time) (bool, error) {
+	var banned int64
+	if user.Teacher != nil {
+		scopes := []string{models.BanScopePlatform}
+		if role == models.MeetingRoleTeacher {
+			scopes = append(scopes, models.BanScopeTeaching)
+		}
+		if err := db.Model(&models.BanDetailsTeacher{}).
+			Where("teacher_id = ? AND ban_end > ? AND ban_scope IN ?", user.Teacher.ID, now, scopes).
+			Count(&banned).Error; err != nil || banned > 0 {
+			return banned > 0, err
+		}
+	}
+	if user.Learner != nil {
+		scopes := []string{models.BanScopePlatform}
+		if role == models.MeetingRoleLearner {
+			scopes = append(scopes, models.BanScopeLearning)
+		}
+		if err := db.Model(&models.BanDetailsLearner{}).
+			Where("learner_id = ? AND ban_end > ? AND ban_scope IN ?", user.Learner.ID, now, scopes).
+			Count(&banned).Error; err != nil {
+			return false, err
+		}
+	}
+	return banned > 0, nil
+}
+
+// RecordMeetingAccess logs that user fetched session's join link in role.
+// With countsAsJoin, for providers that send no presence webhooks, fetching
+// the link while the session runs counts as joining: the teacher's first
+// join lets the scheduler start the session and a learner's marks them
+// present.
+func RecordMeetingAccess(db *gorm.DB, session *models.ClassSession, user *models.User, role, ip string, countsAsJoin bool, now time.Time) error {
+	if err := db.Create(&models.MeetingAccess{
+		CreatedAt:      now,
+		ClassSessionID: session.ID,
+		UserID:         user.ID,
+		Role:           role,
+		IP:             ip,
+	}).Error; err != nil {
+		return err
+	}
+	if !countsAsJoin || !InAttendanceWindow(session, now) {
+		return nil
+	}
+	if role == models.MeetingRoleTeacher {
+		return RecordTeacherCheckIn(db, session, now)
+	}
+	_, err := RecordAttendance(db, session.ID, user.Learner.ID, models.AttendancePresent, models.AttendanceSourceMeetingJoin, nil, now)
+	return err
+}
+
+// SessionMeetingAccesses lists the join links handed out for a session, oldest first.
+func SessionMeetingAccesses(db *gorm.DB, sessionID uint) ([]models.MeetingAccess, error) {
+	var accesses []models.MeetingAccess
+	err := db.Where("class_session_id = ?", sessionID).Order("created_at, id").Find(&accesses).Error
+	return accesses, err
+}
+
+// SessionMeetingRoom returns session's room ID after checking it is hosted
+// on provider.
+func SessionMeetingRoom(provider meeting.Provider, session *models.ClassSession) (string, error) {
+	roomID := SessionRoomID(session)
+	if roomID == "" {
+		return "", ErrNoMeetingRoom
+	}
+	if sessionProvider(session) != provider.Name() {
+		return "", ErrMeetingProviderMismatch
+	}
+	return roomID, nil
+}
+
+// JoinSessionMeeting returns user's personal way into session's meeting,
+// as moderator when they teach it. avatarURL must be loadable by the
+// meeting clients.
+func JoinSessionMeeting(ctx context.Context, provider meeting.Provider, session *models.ClassSession, user *models.User, avatarURL string, now time.Time) (*models.MeetingJoinInfo, error) {
+	roomID, err := SessionMeetingRoom(provider, session)
+	if err != nil {
+		return nil, err
+	}
+	nbf, exp := MeetingTokenWindow(session)
+	if !now.Before(exp) {
+		return nil, ErrMeetingClosed
+	}
+
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = fmt.Sprintf("User %d", user.ID)
+	}
+	moderator := IsSessionTeacher(user, session)
+	join, err := provider.JoinURL(ctx, meeting.JoinRequest{
+		RoomID: roomID,
+		Title:  session.Class.ClassName,
+		Participant: meeting.Participant{
+			UserID:    user.ID,
+			Name:      name,
+			AvatarURL: avatarURL,
+			Moderator: moderator,
+		},
+		NotBefore: nbf,
+		Expires:   exp,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.MeetingJoinInfo{
+		Provider:    provider.Name(),
+		MeetingLink: join.URL,
+		Room:        roomID,
+		JWT:         join.Token,
+		Moderator:   moderator,
+		ExpiresAt:   join.ExpiresAt,
+	}, nil
+}
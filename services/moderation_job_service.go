@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// DecayFlagCounts removes one flag from every learner and teacher whose last
+// flag is older than the policy's FlagDecayDays, and then one more after each
+// further clean period. LastDecayedAt tracks the decay so LastFlaggedAt keeps
+// recording when the user was actually flagged.
+func DecayFlagCounts(db *gorm.DB) {
+	policy, err := GetModerationPolicy(db)
+	if err != nil {
+		log.Printf("Error loading moderation policy: %v", err)
+		return
+	}
+	if policy.FlagDecayDays <= 0 {
+		return
+	}
+
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -policy.FlagDecayDays)
+	due := "flag_count > 0 AND last_flagged_at < ? AND (last_decayed_at IS NULL OR last_decayed_at < ?)"
+	decay := map[string]interface{}{
+		"flag_count":      gorm.Expr("flag_count - 1"),
+		"last_decayed_at": now,
+	}
+
+	res := db.Model(&models.Learner{}).Where(due, cutoff, cutoff).Updates(decay)
+	if res.Error != nil {
+		log.Printf("Error decaying learner flags: %v", res.Error)
+	} else if res.RowsAffected > 0 {
+		log.Printf("Decayed one flag for %d learner(s)", res.RowsAffected)
+	}
+
+	res = db.Model(&models.Teacher{}).Where(due, cutoff, cutoff).Updates(decay)
+	if res.Error != nil {
+		log.Printf("Error decaying teacher flags: %v", res.Error)
+	} else if res.RowsAffected > 0 {
+		log.Printf("Decayed one flag for %d teacher(s)", res.RowsAffected)
+	}
+}
+
+// EnforceFlagThresholds bans learners and teachers whose flag count is at or
+// above the policy threshold without an active ban, e.g. after an admin lowered
+// the threshold or edited a flag count directly.
+func EnforceFlagThresholds(db *gorm.DB) {
+	policy, err := GetModerationPolicy(db)
+	if err != nil {
+		log.Printf("Error loading moderation policy: %v", err)
+		return
+	}
+
+	var learners []models.Learner
+	if err := db.Where("flag_count >= ?", policy.FlagThreshold).Find(&learners).Error; err != nil {
+		log.Printf("Error finding flagged learners: %v", err)
+	}
+	for _, learner := range learners {
+		if err := enforceLearnerThreshold(db, &policy, learner.ID); err != nil {
+			log.Printf("Failed to ban learner %d: %v", learner.ID, err)
+		}
+	}
+
+	var teachers []models.Teacher
+	if err := db.Where("flag_count >= ?", policy.FlagThreshold).Find(&teachers).Error; err != nil {
+		log.Printf("Error finding flagged teachers: %v", err)
+	}
+	for _, teacher := range teachers {
+		if err := enforceTeacherThreshold(db, &policy, teacher.ID); err != nil {
+			log.Printf("Failed to ban teacher %d: %v", teacher.ID, err)
+		}
+	}
+}
+
+func enforceLearnerThreshold(db *gorm.DB, policy *models.ModerationPolicy, learnerID uint) error {
+	var ban *models.BanDetailsLearner
+	var userID uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var learner models.Learner
+		if err := tx.First(&learner, learnerID).Error; err != nil {
+			return err
+		}
+		if learner.FlagCount < policy.FlagThreshold {
+			return nil
+		}
+
+		now := time.Now()
+		var active models.BanDetailsLearner
+		err := tx.Where("learner_id = ? AND ban_end > ?", learner.ID, now).First(&active).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		learner.FlagCount -= policy.FlagThreshold
+		ban, err = issueLearnerBan(tx, policy, learner, "Flag threshold reached", now)
+		if err != nil {
+			return err
+		}
+		userID = learner.UserID
+		return tx.Save(&learner).Error
+	})
+	if err != nil {
+		return err
+	}
+	if ban != nil {
+		CreateNotification(db, userID, "system", banNotice(ban.BanEnd))
+	}
+	return nil
+}
+
+func enforceTeacherThreshold(db *gorm.DB, policy *models.ModerationPolicy, teacherID uint) error {
+	var ban *models.BanDetailsTeacher
+	var userID uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var teacher models.Teacher
+		if err := tx.First(&teacher, teacherID).Error; err != nil {
+			return err
+		}
+		if teacher.FlagCount < policy.FlagThreshold {
+			return nil
+		}
+
+		now := time.Now()
+		var active models.BanDetailsTeacher
+		err := tx.Where("teacher_id = ? AND ban_end > ?", teacher.ID, now).First(&active).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		teacher.FlagCount -= policy.FlagThreshold
+		ban, err = issueTeacherBan(tx, policy, teacher, "Flag threshold reached", now)
+		if err != nil {
+			return err
+		}
+		userID = teacher.UserID
+		return tx.Save(&teacher).Error
+	})
+	if err != nil {
+		return err
+	}
+	if ban != nil {
+		CreateNotification(db, userID, "system", banNotice(ban.BanEnd))
+	}
+	return nil
+}
+
+// banNotice describes a ban ending at banEnd for a user notification.
+func banNotice(banEnd time.Time) string {
+	if !banEnd.Before(models.PermanentBanEnd) {
+		return "Your account has been permanently suspended after repeated violations."
+	}
+	return fmt.Sprintf("Your account has been suspended until %s after reaching the flag limit.", banEnd.Format(time.RFC3339))
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupMockGorm(t *testing.T) (sqlmock.Sqlmock, *gorm.DB, func()) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	gdb, err := gorm.Open(
+		postgres.New(postgres.Config{Conn: sqlDB, PreferSimpleProtocol: true}),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)},
+	)
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	cleanup := func() { _ = sqlDB.Close() }
+	return mock, gdb, cleanup
+}
+
+// nearTime matches a time argument within a minute of the wanted one, for
+// values the jobs derive from time.Now.
+type nearTime struct{ want time.Time }
+
+func (n nearTime) Match(v driver.Value) bool {
+	got, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	d := got.Sub(n.want)
+	return d > -time.Minute && d < time.Minute
+}
+
+// expPolicy expects the moderation policy lookup and returns the given
+// columns of the stored row.
+func expPolicy(mock sqlmock.Sqlmock, cols []string, vals ...driver.Value) {
+	mock.ExpectQuery(`SELECT \* FROM "moderation_policies" WHERE "moderation_policies"\."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows(append([]string{"id"}, cols...)).AddRow(append([]driver.Value{1}, vals...)...))
+}
+
+/* ------------------ DecayFlagCounts ------------------ */
+
+func TestDecayFlagCounts(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	expPolicy(mock, []string{"flag_threshold", "ban_duration_hours", "flag_decay_days"}, 3, 168, 30)
+	cutoff := nearTime{time.Now().AddDate(0, 0, -30)}
+	for _, table := range []string{"learners", "teachers"} {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "`+table+`" SET "flag_count"=flag_count - 1,"last_decayed_at"=\$1,"updated_at"=\$2 `+
+			`WHERE \(flag_count > 0 AND last_flagged_at < \$3 AND \(last_decayed_at IS NULL OR last_decayed_at < \$4\)\)`).
+			WithArgs(nearTime{time.Now()}, sqlmock.AnyArg(), cutoff, cutoff).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+	}
+
+	DecayFlagCounts(gdb)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDecayFlagCounts_Disabled(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	expPolicy(mock, []string{"flag_threshold", "ban_duration_hours", "flag_decay_days"}, 3, 168, 0)
+
+	DecayFlagCounts(gdb)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ EnforceFlagThresholds ------------------ */
+
+// expLearnerThresholdBan expects learner 9 (user 7, banned priorBans times
+// before) to be banned until banEnd, with its flags reduced to zero and the
+// user's ban count raised.
+func expLearnerThresholdBan(mock sqlmock.Sqlmock, priorBans int, banEnd driver.Value) {
+	mock.ExpectQuery(`SELECT \* FROM "learners" WHERE flag_count >= \$1`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "flag_count"}).AddRow(9, 7, 3))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "learners" WHERE "learners"\."id" = \$1`).
+		WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "flag_count"}).AddRow(9, 7, 3))
+	mock.ExpectQuery(`SELECT \* FROM "ban_details_learners" WHERE \(learner_id = \$1 AND ban_end > \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"\."id" = \$1`).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "ban_count"}).AddRow(7, priorBans))
+	mock.ExpectQuery(`INSERT INTO "ban_details_learners"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 9, banEnd, "Flag threshold reached", models.BanScopeLearning, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`UPDATE "users" SET .*"balance"=\$10,"ban_count"=\$11,"timezone"=\$12 WHERE .*"id" = \$13`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), priorBans+1, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "learners" SET "created_at"=\$1,"updated_at"=\$2,"deleted_at"=\$3,"user_id"=\$4,"flag_count"=\$5`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 7, 0, nil, nil, 0, 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "notifications"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "teachers" WHERE flag_count >= \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+func TestEnforceFlagThresholds_EscalatesBan(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	// The second ban takes the ladder's second step.
+	expPolicy(mock, []string{"flag_threshold", "ban_duration_hours", "ban_escalation_hours"}, 3, 168, `[72,720,0]`)
+	expLearnerThresholdBan(mock, 1, nearTime{time.Now().Add(720 * time.Hour)})
+
+	EnforceFlagThresholds(gdb)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnforceFlagThresholds_LadderEndsInPermanentBan(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	// Past the end of the ladder its last step, 0, repeats: permanent.
+	expPolicy(mock, []string{"flag_threshold", "ban_duration_hours", "ban_escalation_hours"}, 3, 168, `[72,720,0]`)
+	expLearnerThresholdBan(mock, 5, models.PermanentBanEnd)
+
+	EnforceFlagThresholds(gdb)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnforceFlagThresholds_NoLadderUsesBanDuration(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	expPolicy(mock, []string{"flag_threshold", "ban_duration_hours"}, 3, 48)
+	expLearnerThresholdBan(mock, 4, nearTime{time.Now().Add(48 * time.Hour)})
+
+	EnforceFlagThresholds(gdb)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
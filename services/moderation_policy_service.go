@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// moderationPolicyID is the primary key of the single persisted policy row.
+const moderationPolicyID = 1
+
+// DefaultModerationPolicy mirrors the rules that used to be hard-coded:
+// 3 flags = ban, 2 automatic absence flags before an admin report, and 1 or 2
+// flags depending on the report reason. Bans escalate from 7 days to 30 days
+// to permanent, one flag decays after 30 clean days, every third learner
+// no-show costs a flag, and so does a teacher cancelling within 24 hours.
+func DefaultModerationPolicy() models.ModerationPolicy {
+	return models.ModerationPolicy{
+		FlagThreshold:        3,
+		BanDurationHours:     7 * 24,
+		AbsenceAutoFlagLimit: 2,
+		FalseReportFlags:     1,
+		FlagDecayDays:        30,
+		BanEscalationHours:   datatypes.JSONSlice[int]{7 * 24, 30 * 24, 0},
+		TriageSLAHours:       24,
+		ResolutionSLAHours:   72,
+		ReportsPerDayLimit:   10,
+		NoShowFlagThreshold:  3,
+		NoShowGraceMinutes:   15,
+		LateCancelHours:      24,
+		LateCancelFlags:      1,
+		ReasonFlagWeights: datatypes.NewJSONType(map[string]int{
+			"teacher_absent": 1,
+			"poor_teaching":  1,
+			"not_teaching":   1,
+			"fake_review":    2,
+			"disruption":     2,
+			"disrespected":   2,
+			"harassment":     2,
+			"bullying":       2,
+		}),
+	}
+}
+
+// GetModerationPolicy returns the persisted policy, falling back to the defaults
+// when no admin has saved one yet.
+func GetModerationPolicy(db *gorm.DB) (models.ModerationPolicy, error) {
+	var policy models.ModerationPolicy
+	err := db.First(&policy, moderationPolicyID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultModerationPolicy(), nil
+	}
+	return policy, err
+}
+
+// ValidateModerationPolicy rejects values that would disable enforcement by accident.
+func ValidateModerationPolicy(p models.ModerationPolicy) error {
+	if p.FlagThreshold < 1 {
+		return errors.New("flag_threshold must be at least 1")
+	}
+	if p.BanDurationHours < 1 {
+		return errors.New("ban_duration_hours must be at least 1")
+	}
+	if p.AbsenceAutoFlagLimit < 0 || p.FalseReportFlags < 0 || p.FlagDecayDays < 0 {
+		return errors.New("absence_auto_flag_limit, false_report_flags and flag_decay_days must not be negative")
+	}
+	if p.TriageSLAHours < 0 || p.ResolutionSLAHours < 0 {
+		return errors.New("triage_sla_hours and resolution_sla_hours must not be negative (0 disables the deadline)")
+	}
+	if p.ReportsPerDayLimit < 0 {
+		return errors.New("reports_per_day_limit must not be negative (0 disables the limit)")
+	}
+	if p.NoShowFlagThreshold < 0 || p.NoShowGraceMinutes < 0 {
+		return errors.New("no_show_flag_threshold and no_show_grace_minutes must not be negative (0 threshold disables no-show flags)")
+	}
+	if p.LateCancelHours < 0 || p.LateCancelFlags < 0 {
+		return errors.New("late_cancel_hours and late_cancel_flags must not be negative (0 disables the penalty)")
+	}
+	for _, hours := range p.BanEscalationHours {
+		if hours < 0 {
+			return errors.New("ban_escalation_hours entries must not be negative (0 means permanent)")
+		}
+	}
+	for reason, weight := range p.ReasonFlagWeights.Data() {
+		if weight < 0 {
+			return fmt.Errorf("flag weight for %q must not be negative", reason)
+		}
+	}
+	return nil
+}
+
+// SaveModerationPolicy validates and upserts the single policy row.
+func SaveModerationPolicy(db *gorm.DB, p *models.ModerationPolicy, updatedBy *uint) error {
+	if err := ValidateModerationPolicy(*p); err != nil {
+		return err
+	}
+	if p.ReasonFlagWeights.Data() == nil {
+		p.ReasonFlagWeights = datatypes.NewJSONType(map[string]int{})
+	}
+	p.ID = moderationPolicyID
+	p.UpdatedByUserID = updatedBy
+	p.DeletedAt = gorm.DeletedAt{}
+
+	// A row soft-deleted by hand is revived rather than inserted again.
+	var existing models.ModerationPolicy
+	err := db.Unscoped().First(&existing, moderationPolicyID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return db.Create(p).Error
+	case err != nil:
+		return err
+	}
+	p.CreatedAt = existing.CreatedAt
+	return db.Save(p).Error
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// noShowExemptStatuses are session statuses where a missing learner is not
+// the learner's fault, e.g. the teacher never showed up.
+var noShowExemptStatuses = []string{models.SessionStatusTeacherAbsent, models.SessionStatusCancelled}
+
+// CheckForLearnerNoShows marks active enrollments as no_show once their
+// session has finished (plus the policy grace period) without the learner
+// joining the meeting or being marked present. Every NoShowFlagThreshold
+// no-shows add one learner flag, which may in turn trigger a ban. Only
+// sessions that finished within AttendanceCheckLookback of the cutoff are
+// checked, so enrollments from before attendance was tracked are left alone.
+func CheckForLearnerNoShows(db *gorm.DB) {
+	policy, err := GetModerationPolicy(db)
+	if err != nil {
+		log.Printf("Error loading moderation policy: %v", err)
+		return
+	}
+
+	cutoff := time.Now().Add(-time.Duration(policy.NoShowGraceMinutes) * time.Minute)
+	var enrollments []models.Enrollment
+	err = db.Joins("ClassSession").
+		Where("enrollments.enrollment_status = ? AND enrollments.attended_at IS NULL", models.EnrollmentStatusActive).
+		Where(`"ClassSession".class_finish < ? AND "ClassSession".class_finish >= ? AND "ClassSession".class_status NOT IN ?`,
+			cutoff, cutoff.Add(-models.AttendanceCheckLookback), noShowExemptStatuses).
+		Find(&enrollments).Error
+	if err != nil {
+		log.Printf("Error finding learner no-shows: %v", err)
+		return
+	}
+
+	for _, enrollment := range enrollments {
+		if err := MarkLearnerNoShow(db, &policy, enrollment); err != nil {
+			log.Printf("Failed to mark enrollment %d as no-show: %v", enrollment.ID, err)
+		}
+	}
+}
+
+// MarkLearnerNoShow records a single no-show, flags the learner when the
+// policy threshold is reached and notifies them.
+func MarkLearnerNoShow(db *gorm.DB, policy *models.ModerationPolicy, enrollment models.Enrollment) error {
+	var learner models.Learner
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Enrollment{}).
+			Where("id = ? AND enrollment_status = ?", enrollment.ID, models.EnrollmentStatusActive).
+			Update("enrollment_status", models.EnrollmentStatusNoShow)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.Learner{}).Where("id = ?", enrollment.LearnerID).
+			Update("no_show_count", gorm.Expr("no_show_count + 1")).Error; err != nil {
+			return err
+		}
+		return tx.First(&learner, enrollment.LearnerID).Error
+	})
+	if err != nil || learner.ID == 0 {
+		return err
+	}
+
+	flagged := ""
+	if policy.NoShowFlagThreshold > 0 && learner.NoShowCount%policy.NoShowFlagThreshold == 0 {
+		if err := ApplyLearnerFlags(db, learner.ID, 1, "no_show"); err != nil {
+			return err
+		}
+		flagged = fmt.Sprintf(" After %d no-shows your account has been flagged.", learner.NoShowCount)
+	}
+	notifyLocalized(db, []uint{learner.UserID}, "system", func(loc *time.Location) string {
+		return fmt.Sprintf("You were marked as a no-show for the class session on %s.%s", FormatLocalTime(enrollment.ClassSession.ClassStart, loc), flagged)
+	})
+	return nil
+}
+
+// revertLearnerNoShow takes back a no-show a teacher has corrected: the count
+// goes down and, if that no-show brought the learner to a flag, the flag is
+// removed too. A ban the flag already led to is left to the appeal process.
+func revertLearnerNoShow(tx *gorm.DB, learnerID uint) error {
+	policy, err := GetModerationPolicy(tx)
+	if err != nil {
+		return err
+	}
+	var learner models.Learner
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&learner, learnerID).Error; err != nil {
+		return err
+	}
+	if learner.NoShowCount <= 0 {
+		return nil
+	}
+
+	updates := map[string]interface{}{"no_show_count": learner.NoShowCount - 1}
+	if policy.NoShowFlagThreshold > 0 && learner.NoShowCount%policy.NoShowFlagThreshold == 0 && learner.FlagCount > 0 {
+		updates["flag_count"] = learner.FlagCount - 1
+	}
+	return tx.Model(&learner).Updates(updates).Error
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+)
+
+// notice matches a notification description containing want and, when
+// without is set, not containing it.
+type notice struct{ want, without string }
+
+func (n notice) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	return ok && strings.Contains(s, n.want) && (n.without == "" || !strings.Contains(s, n.without))
+}
+
+var noShowPolicyCols = []string{"flag_threshold", "ban_duration_hours", "no_show_flag_threshold", "no_show_grace_minutes"}
+
+// expNoShowMarked expects enrollment 1 of learner 9 (user 7) to become a
+// no-show, leaving the learner with noShows.
+func expNoShowMarked(mock sqlmock.Sqlmock, noShows int) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "enrollments" SET "enrollment_status"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND enrollment_status = \$4\)`).
+		WithArgs(models.EnrollmentStatusNoShow, sqlmock.AnyArg(), 1, models.EnrollmentStatusActive).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "learners" SET "no_show_count"=no_show_count \+ 1,"updated_at"=\$1 WHERE id = \$2`).
+		WithArgs(sqlmock.AnyArg(), 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "learners" WHERE "learners"\."id" = \$1`).
+		WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "flag_count", "no_show_count"}).AddRow(9, 7, 0, noShows))
+	mock.ExpectCommit()
+}
+
+// expNoShowNotice expects user 7 to be told about the no-show.
+func expNoShowNotice(mock sqlmock.Sqlmock, desc notice) {
+	mock.ExpectQuery(`SELECT "id","timezone" FROM "users" WHERE id IN`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "timezone"}).AddRow(7, "Asia/Bangkok"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "notifications"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 7, sqlmock.AnyArg(), "system", desc, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+}
+
+// expNoShowEnrollments expects the job's search for missed sessions.
+func expNoShowEnrollments(mock sqlmock.Sqlmock, start time.Time) {
+	mock.ExpectQuery(`SELECT .* FROM "enrollments" LEFT JOIN "class_sessions" "ClassSession" .* `+
+		`WHERE \(enrollments\.enrollment_status = \$1 AND enrollments\.attended_at IS NULL\) `+
+		`AND \("ClassSession"\.class_finish < \$2 AND "ClassSession"\.class_finish >= \$3 AND "ClassSession"\.class_status NOT IN \(\$4,\$5\)\)`).
+		WithArgs(models.EnrollmentStatusActive, nearTime{time.Now().Add(-15 * time.Minute)}, sqlmock.AnyArg(),
+			models.SessionStatusTeacherAbsent, models.SessionStatusCancelled).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "learner_id", "class_session_id", "enrollment_status", "ClassSession__id", "ClassSession__class_start"}).
+			AddRow(1, 9, 3, models.EnrollmentStatusActive, 3, start))
+}
+
+/* ------------------ CheckForLearnerNoShows ------------------ */
+
+func TestCheckForLearnerNoShows_BelowThreshold(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	expPolicy(mock, noShowPolicyCols, 3, 168, 3, 15)
+	expNoShowEnrollments(mock, time.Now().Add(-3*time.Hour))
+	expNoShowMarked(mock, 2)
+	expNoShowNotice(mock, notice{want: "You were marked as a no-show", without: "flagged"})
+
+	CheckForLearnerNoShows(gdb)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCheckForLearnerNoShows_FlagsAtThreshold(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	expPolicy(mock, noShowPolicyCols, 3, 168, 3, 15)
+	expNoShowEnrollments(mock, time.Now().Add(-3*time.Hour))
+	expNoShowMarked(mock, 3)
+
+	// The third no-show adds one flag, still short of a ban.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "learners" WHERE "learners"\."id" = \$1`).
+		WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "flag_count", "no_show_count"}).AddRow(9, 7, 0, 3))
+	expPolicy(mock, noShowPolicyCols, 3, 168, 3, 15)
+	mock.ExpectExec(`UPDATE "learners" SET "created_at"=\$1,"updated_at"=\$2,"deleted_at"=\$3,"user_id"=\$4,"flag_count"=\$5,"last_flagged_at"=\$6,"last_decayed_at"=\$7,"no_show_count"=\$8 WHERE .*"id" = \$9`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 7, 1, nearTime{time.Now()}, nil, 3, 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expNoShowNotice(mock, notice{want: "After 3 no-shows your account has been flagged."})
+
+	CheckForLearnerNoShows(gdb)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ MarkLearnerNoShow ------------------ */
+
+func TestMarkLearnerNoShow_AlreadyChanged(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	// Marked present (or refunded) since the job loaded it: nothing happens.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "enrollments" SET "enrollment_status"=\$1`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	policy := models.ModerationPolicy{NoShowFlagThreshold: 1}
+	enrollment := models.Enrollment{LearnerID: 9}
+	enrollment.ID = 1
+	if err := MarkLearnerNoShow(gdb, &policy, enrollment); err != nil {
+		t.Fatalf("MarkLearnerNoShow: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+/* ------------------ revertLearnerNoShow ------------------ */
+
+// expNoShowLearnerLock expects the locked learner row read by
+// revertLearnerNoShow.
+func expNoShowLearnerLock(mock sqlmock.Sqlmock, flags, noShows int) {
+	mock.ExpectQuery(`SELECT \* FROM "learners" WHERE "learners"\."id" = \$1 .*FOR UPDATE`).
+		WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "flag_count", "no_show_count"}).AddRow(9, 7, flags, noShows))
+}
+
+func TestRevertLearnerNoShow_RemovesThresholdFlag(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	// The reverted no-show was the third, which had added a flag.
+	expPolicy(mock, noShowPolicyCols, 3, 168, 3, 15)
+	expNoShowLearnerLock(mock, 2, 3)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "learners" SET "flag_count"=\$1,"no_show_count"=\$2,"updated_at"=\$3 WHERE .*"id" = \$4`).
+		WithArgs(1, 2, sqlmock.AnyArg(), 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := revertLearnerNoShow(gdb, 9); err != nil {
+		t.Fatalf("revertLearnerNoShow: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRevertLearnerNoShow_BelowThreshold(t *testing.T) {
+	mock, gdb, cleanup := setupMockGorm(t)
+	defer cleanup()
+
+	expPolicy(mock, noShowPolicyCols, 3, 168, 3, 15)
+	expNoShowLearnerLock(mock, 2, 4)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "learners" SET "no_show_count"=\$1,"updated_at"=\$2 WHERE .*"id" = \$3`).
+		WithArgs(3, sqlmock.AnyArg(), 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := revertLearnerNoShow(gdb, 9); err != nil {
+		t.Fatalf("revertLearnerNoShow: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
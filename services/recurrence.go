@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxSeriesOccurrences caps how many sessions one recurrence rule may create.
+const MaxSeriesOccurrences = 104
+
+var ErrInvalidRRule = errors.New("invalid recurrence rule")
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Recurrence is the subset of an iCalendar RRULE (RFC 5545) that session
+// series support: FREQ=DAILY or WEEKLY with INTERVAL, BYDAY, COUNT and UNTIL.
+type Recurrence struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	Count    int
+	Until    time.Time
+	// UntilFloating marks an UNTIL without a trailing Z, which RFC 5545
+	// reads as wall-clock time in the series' own timezone.
+	UntilFloating bool
+}
+
+// ParseRRule parses rule, with or without a leading "RRULE:". Either COUNT or
+// UNTIL is required so a series is always finite.
+func ParseRRule(rule string) (Recurrence, error) {
+	r := Recurrence{Interval: 1}
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return r, fmt.Errorf("%w: rule is empty", ErrInvalidRRule)
+	}
+
+	for _, part := range strings.Split(rule, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return r, fmt.Errorf("%w: %q is not KEY=VALUE", ErrInvalidRRule, part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = errors.New("must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = errors.New("must be positive")
+			}
+		case "UNTIL":
+			r.Until, err = parseRRuleTime(value)
+			r.UntilFloating = !strings.HasSuffix(strings.ToUpper(value), "Z")
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(value), ",") {
+				wd, ok := rruleWeekdays[day]
+				if !ok {
+					return r, fmt.Errorf("%w: unsupported BYDAY value %q", ErrInvalidRRule, day)
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "WKST":
+			// Weeks always start on Monday, the RFC 5545 default.
+		default:
+			return r, fmt.Errorf("%w: unsupported part %s", ErrInvalidRRule, key)
+		}
+		if err != nil {
+			return r, fmt.Errorf("%w: %s: %v", ErrInvalidRRule, key, err)
+		}
+	}
+
+	if r.Freq != "DAILY" && r.Freq != "WEEKLY" {
+		return r, fmt.Errorf("%w: FREQ must be DAILY or WEEKLY", ErrInvalidRRule)
+	}
+	if r.Count == 0 && r.Until.IsZero() {
+		return r, fmt.Errorf("%w: COUNT or UNTIL is required", ErrInvalidRRule)
+	}
+	return r, nil
+}
+
+func parseRRuleTime(v string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	// A date-only UNTIL includes the whole day.
+	if t, err := time.Parse("20060102", v); err == nil {
+		return t.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q", v)
+}
+
+// Occurrences expands the rule from start, keeping start's wall-clock time in
+// its location. As in RFC 5545, excluded dates (YYYY-MM-DD in start's
+// location) still count towards COUNT. A floating UNTIL is read in start's
+// location too. More than MaxSeriesOccurrences occurrences is an error.
+func (r Recurrence) Occurrences(start time.Time, excluded []string) ([]time.Time, error) {
+	if r.UntilFloating && !r.Until.IsZero() {
+		u := r.Until
+		r.Until = time.Date(u.Year(), u.Month(), u.Day(), u.Hour(), u.Minute(), u.Second(), u.Nanosecond(), start.Location())
+	}
+	skip := make(map[string]bool, len(excluded))
+	for _, d := range excluded {
+		skip[d] = true
+	}
+
+	days := r.ByDay
+	if r.Freq == "WEEKLY" && len(days) == 0 {
+		days = []time.Weekday{start.Weekday()}
+	}
+	sort.Slice(days, func(i, j int) bool { return mondayIndex(days[i]) < mondayIndex(days[j]) })
+
+	var out []time.Time
+	generated := 0
+	emit := func(t time.Time) (bool, error) {
+		if !r.Until.IsZero() && t.After(r.Until) {
+			return false, nil
+		}
+		generated++
+		if !skip[t.Format("2006-01-02")] {
+			if len(out) == MaxSeriesOccurrences {
+				return false, fmt.Errorf("%w: more than %d occurrences", ErrInvalidRRule, MaxSeriesOccurrences)
+			}
+			out = append(out, t)
+		}
+		return r.Count == 0 || generated < r.Count, nil
+	}
+
+	switch r.Freq {
+	case "DAILY":
+		for i := 0; ; i += r.Interval {
+			more, err := emit(start.AddDate(0, 0, i))
+			if err != nil || !more {
+				return out, err
+			}
+		}
+	default:
+		weekStart := start.AddDate(0, 0, -mondayIndex(start.Weekday()))
+		for week := 0; ; week += r.Interval {
+			for _, wd := range days {
+				t := weekStart.AddDate(0, 0, week*7+mondayIndex(wd))
+				if t.Before(start) {
+					continue
+				}
+				more, err := emit(t)
+				if err != nil || !more {
+					return out, err
+				}
+			}
+		}
+	}
+}
+
+// mondayIndex numbers weekdays from Monday = 0.
+func mondayIndex(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
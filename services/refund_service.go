@@ -0,0 +1,49 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+var ErrAlreadyRefunded = errors.New("enrollment has already been refunded")
+
+// RefundEnrollment credits what the learner paid for enrollment (its
+// AmountPaid, never the session's current price, which may have changed or
+// never been charged) back to their balance and marks the enrollment
+// refunded, which frees its seat. It returns the learner's user ID
+// so the caller can notify them. Run it inside the caller's transaction.
+func RefundEnrollment(tx *gorm.DB, enrollment *models.Enrollment, now time.Time) (uint, error) {
+	amount := enrollment.AmountPaid
+	res := tx.Model(&models.Enrollment{}).
+		Where("id = ? AND enrollment_status <> ?", enrollment.ID, models.EnrollmentStatusRefunded).
+		Updates(map[string]interface{}{
+			"enrollment_status": models.EnrollmentStatusRefunded,
+			"refund_amount":     amount,
+			"refunded_at":       now,
+		})
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return 0, ErrAlreadyRefunded
+	}
+
+	var learner models.Learner
+	if err := tx.First(&learner, enrollment.LearnerID).Error; err != nil {
+		return 0, err
+	}
+	if amount > 0 {
+		if err := tx.Model(&models.User{}).Where("id = ?", learner.UserID).
+			Update("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	enrollment.EnrollmentStatus = models.EnrollmentStatusRefunded
+	enrollment.RefundAmount = amount
+	enrollment.RefundedAt = &now
+	return learner.UserID, nil
+}
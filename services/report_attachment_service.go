@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxReportAttachments caps the evidence files stored per report.
+const MaxReportAttachments = 10
+
+var (
+	ErrUnsupportedAttachment = errors.New("unsupported attachment type")
+	ErrAttachmentLimit       = errors.New("attachment limit reached for this report")
+)
+
+// AttachmentRule is the size limit and the sniffed content types accepted for
+// one kind of upload.
+type AttachmentRule struct {
+	MaxBytes     int64
+	ContentTypes []string
+}
+
+// reportAttachmentKinds is the auto-detection order; mp4/webm containers are
+// treated as video unless the uploader says the clip is audio.
+var reportAttachmentKinds = []string{"image", "video", "audio", "chat_export"}
+
+// ReportAttachmentRules are the size limits and sniffed content types per
+// evidence kind. Chat exports must be plain text: HTML served back from
+// storage would run as a page.
+var ReportAttachmentRules = map[string]AttachmentRule{
+	"image":       {MaxBytes: 5 << 20, ContentTypes: []string{"image/jpeg", "image/png", "image/gif", "image/webp"}},
+	"video":       {MaxBytes: 50 << 20, ContentTypes: []string{"video/mp4", "video/webm"}},
+	"audio":       {MaxBytes: 20 << 20, ContentTypes: []string{"audio/mpeg", "audio/wave", "audio/aiff", "application/ogg", "video/mp4", "video/webm"}},
+	"chat_export": {MaxBytes: 2 << 20, ContentTypes: []string{"text/plain"}},
+}
+
+// ClassifyReportAttachment sniffs b and returns its kind and content type,
+// checking it against the rule for the requested kind (or the first kind that
+// accepts it when kind is empty).
+func ClassifyReportAttachment(b []byte, kind string) (string, string, error) {
+	return classifyUpload(b[:min(512, len(b))], int64(len(b)), kind, reportAttachmentKinds, ReportAttachmentRules)
+}
+
+// classifyUpload sniffs head, the first bytes of a size-byte upload, and
+// checks it against rules for kind, or for the first of kinds that accepts
+// its content type when kind is empty.
+func classifyUpload(head []byte, size int64, kind string, kinds []string, rules map[string]AttachmentRule) (string, string, error) {
+	if size == 0 || len(head) == 0 {
+		return "", "", errors.New("empty file")
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+
+	if kind != "" {
+		if _, ok := rules[kind]; !ok {
+			return "", "", fmt.Errorf("%w: unknown kind %q", ErrUnsupportedAttachment, kind)
+		}
+		kinds = []string{kind}
+	}
+
+	for _, k := range kinds {
+		rule := rules[k]
+		for _, ct := range rule.ContentTypes {
+			if ct != contentType {
+				continue
+			}
+			if size > rule.MaxBytes {
+				return "", "", fmt.Errorf("%s files must be at most %d bytes", k, rule.MaxBytes)
+			}
+			return k, contentType, nil
+		}
+	}
+	return "", "", fmt.Errorf("%w: %s", ErrUnsupportedAttachment, contentType)
+}
+
+// CanViewReportEvidence reports whether user may read a report's attachments:
+// moderators and the reporter always, the reported user only once disclosed.
+// moderator says whether user acts as a moderator, which takes an admin who
+// passed the second factor, not just an Admin row.
+func CanViewReportEvidence(user *models.User, report *models.Report, moderator bool) bool {
+	switch {
+	case moderator, user.ID == report.ReportUserID:
+		return true
+	case user.ID == report.ReportedUserID:
+		return report.EvidenceDisclosed
+	default:
+		return false
+	}
+}
+
+// CheckReportOpenForEvidence fails when report is closed or already holds
+// MaxReportAttachments files. AddReportAttachment checks again under a lock.
+func CheckReportOpenForEvidence(db *gorm.DB, report *models.Report) error {
+	if status := models.NormalizeReportStatus(report.ReportStatus); status == models.ReportStatusResolved || status == models.ReportStatusRejected {
+		return ErrReportClosed
+	}
+	var count int64
+	if err := db.Model(&models.ReportAttachment{}).Where("report_id = ?", report.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count >= MaxReportAttachments {
+		return ErrAttachmentLimit
+	}
+	return nil
+}
+
+// AddReportAttachment stores attachment on its report. The report row is
+// locked, as FileReport locks the reporter, so concurrent uploads are counted
+// one by one and cannot pass MaxReportAttachments together.
+func AddReportAttachment(db *gorm.DB, attachment *models.ReportAttachment) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var report models.Report
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&report, attachment.ReportID).Error; err != nil {
+			return err
+		}
+		if err := CheckReportOpenForEvidence(tx, &report); err != nil {
+			return err
+		}
+		return tx.Create(attachment).Error
+	})
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidReportTransition = errors.New("invalid report status transition")
+	ErrReportClosed            = errors.New("report is already closed")
+	ErrReportStatusChanged     = errors.New("report status changed concurrently")
+	ErrNotModerator            = errors.New("moderator must be an admin")
+	ErrDuplicateReport         = errors.New("you have already reported this user for this session and reason")
+	ErrReportRateLimited       = errors.New("too many reports submitted in the last 24 hours")
+)
+
+// StampNewReport resets the lifecycle fields of a freshly submitted report and
+// derives its severity and SLA deadlines from the moderation policy.
+func StampNewReport(policy *models.ModerationPolicy, report *models.Report, now time.Time) {
+	report.ReportStatus = models.ReportStatusSubmitted
+	report.ReportResult = ""
+	report.Severity = policy.FlagsForReason(report.ReportReason)
+	report.AssignedModeratorID, report.CaseID = nil, nil
+	report.EvidenceDisclosed = false
+	report.TriagedAt, report.EscalatedAt, report.ClosedAt = nil, nil, nil
+	report.TriageDueAt, report.ResolutionDueAt = nil, nil
+	if policy.TriageSLAHours > 0 {
+		due := now.Add(time.Duration(policy.TriageSLAHours) * time.Hour)
+		report.TriageDueAt = &due
+	}
+	if policy.ResolutionSLAHours > 0 {
+		due := now.Add(time.Duration(policy.ResolutionSLAHours) * time.Hour)
+		report.ResolutionDueAt = &due
+	}
+}
+
+// FileReport stores a stamped report after rejecting duplicates (same reporter,
+// reported user, session and reason) and reporters over the policy's daily
+// limit. Reports by the same reporter are filed one at a time, so concurrent
+// submissions cannot slip past either check. The report joins the case for
+// its reported user and session, which is created on first use and
+// re-weighted with every report. On a duplicate the existing report, if it
+// can still be found, is returned alongside ErrDuplicateReport.
+func FileReport(db *gorm.DB, policy *models.ModerationPolicy, report *models.Report, now time.Time) (*models.Report, error) {
+	var existing *models.Report
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			First(&models.User{}, report.ReportUserID).Error; err != nil {
+			return err
+		}
+
+		if policy.ReportsPerDayLimit > 0 {
+			var recent int64
+			if err := tx.Model(&models.Report{}).
+				Where("report_user_id = ? AND report_date > ?", report.ReportUserID, now.Add(-24*time.Hour)).
+				Count(&recent).Error; err != nil {
+				return err
+			}
+			if recent >= int64(policy.ReportsPerDayLimit) {
+				return ErrReportRateLimited
+			}
+		}
+
+		var err error
+		if existing, err = findDuplicateReport(tx, report); err != nil || existing != nil {
+			return err
+		}
+
+		reportCase, err := lockReportCase(tx, report.ReportedUserID, report.ClassSessionID)
+		if err != nil {
+			return err
+		}
+		report.CaseID = &reportCase.ID
+		if err := tx.Create(report).Error; err != nil {
+			if IsUniqueViolation(err, "idx_report_reporter_subject") {
+				return ErrDuplicateReport
+			}
+			return err
+		}
+
+		var reporters int64
+		if err := tx.Model(&models.Report{}).Where("case_id = ?", reportCase.ID).
+			Distinct("report_user_id").Count(&reporters).Error; err != nil {
+			return err
+		}
+		return tx.Model(reportCase).Updates(map[string]interface{}{
+			"report_count":   gorm.Expr("report_count + 1"),
+			"reporter_count": reporters,
+			"weight_score":   gorm.Expr("weight_score + ?", max(report.Severity, 1)),
+		}).Error
+	})
+	if errors.Is(err, ErrDuplicateReport) && existing == nil {
+		// The unique index caught a duplicate the lookup missed; the
+		// duplicate may be gone again, leaving existing nil.
+		if existing, err = findDuplicateReport(db, report); err == nil {
+			err = ErrDuplicateReport
+		}
+	}
+	return existing, err
+}
+
+// findDuplicateReport returns the report matching report's reporter,
+// reported user, session and reason, with ErrDuplicateReport, or nil if
+// there is none.
+func findDuplicateReport(db *gorm.DB, report *models.Report) (*models.Report, error) {
+	var duplicate models.Report
+	err := db.Where("report_user_id = ? AND reported_user_id = ? AND class_session_id = ? AND report_reason = ?",
+		report.ReportUserID, report.ReportedUserID, report.ClassSessionID, report.ReportReason).
+		First(&duplicate).Error
+	switch {
+	case err == nil:
+		return &duplicate, ErrDuplicateReport
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// lockReportCase locks the case for reportedUserID and sessionID, creating
+// it first if needed. When two first reports race, the loser's insert does
+// nothing and it locks the winner's case instead.
+func lockReportCase(tx *gorm.DB, reportedUserID, sessionID uint) (*models.ReportCase, error) {
+	var reportCase models.ReportCase
+	lock := func() error {
+		return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("reported_user_id = ? AND class_session_id = ?", reportedUserID, sessionID).
+			First(&reportCase).Error
+	}
+	err := lock()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "reported_user_id"}, {Name: "class_session_id"}},
+			DoNothing: true,
+		}).Create(&models.ReportCase{ReportedUserID: reportedUserID, ClassSessionID: sessionID}).Error
+		if err == nil {
+			err = lock()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reportCase, nil
+}
+
+// TransitionReport moves a report to a new status if the lifecycle allows it,
+// stamps the matching timestamp and, when the report is closed, applies the
+// flags dictated by the moderation policy. The update is conditional on the
+// status the report was loaded with, and resolved and rejected are terminal,
+// so those flags can only ever be applied once.
+func TransitionReport(db *gorm.DB, report *models.Report, to, result string, actorID *uint) error {
+	from := models.NormalizeReportStatus(report.ReportStatus)
+	to = models.NormalizeReportStatus(to)
+	if !models.CanTransitionReport(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidReportTransition, from, to)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"report_status": to}
+	switch to {
+	case models.ReportStatusTriaged:
+		updates["triaged_at"] = now
+	case models.ReportStatusUnderReview:
+		if report.TriagedAt == nil {
+			updates["triaged_at"] = now
+		}
+		if report.AssignedModeratorID == nil && actorID != nil {
+			updates["assigned_moderator_id"] = *actorID
+		}
+	case models.ReportStatusEscalated:
+		updates["escalated_at"] = now
+	case models.ReportStatusResolved, models.ReportStatusRejected:
+		updates["closed_at"] = now
+	}
+	if result != "" {
+		updates["report_result"] = result
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(report).Omit(clause.Associations).
+			Where("report_status = ?", report.ReportStatus).
+			Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrReportStatusChanged
+		}
+		if to == models.ReportStatusResolved || to == models.ReportStatusRejected {
+			return applyReportOutcome(tx, report, to)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	report.ReportStatus = to
+
+	if to == models.ReportStatusEscalated {
+		var admins []models.Admin
+		db.Find(&admins)
+		for _, admin := range admins {
+			CreateNotification(db, admin.UserID, "system", fmt.Sprintf("Report #%d has been escalated and needs senior review.", report.ID))
+		}
+	}
+	return nil
+}
+
+// AssignReport hands an open report to an admin moderator.
+func AssignReport(db *gorm.DB, report *models.Report, moderatorUserID uint) error {
+	status := models.NormalizeReportStatus(report.ReportStatus)
+	if status == models.ReportStatusResolved || status == models.ReportStatusRejected {
+		return ErrReportClosed
+	}
+	var admin models.Admin
+	if err := db.Where("user_id = ?", moderatorUserID).First(&admin).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotModerator
+		}
+		return err
+	}
+	if err := db.Model(report).Omit(clause.Associations).Update("assigned_moderator_id", moderatorUserID).Error; err != nil {
+		return err
+	}
+	CreateNotification(db, moderatorUserID, "system", fmt.Sprintf("Report #%d has been assigned to you.", report.ID))
+	return nil
+}
+
+// applyReportOutcome flags the reported user when a report is resolved, or
+// the reporter when it is rejected as false, according to the moderation policy.
+func applyReportOutcome(db *gorm.DB, report *models.Report, status string) error {
+	policy, err := GetModerationPolicy(db)
+	if err != nil {
+		return err
+	}
+
+	if status == models.ReportStatusResolved {
+		flags := policy.FlagsForReason(report.ReportReason)
+		if flags <= 0 {
+			return nil
+		}
+		reason := fmt.Sprintf("Flagged from resolved report ID %d: %s", report.ID, report.ReportDescription)
+		desc := fmt.Sprintf("An admin has reviewed report #%d and issued a warning with %d flag(s).", report.ID, flags)
+		switch report.ReportType {
+		case "learner": // Learner reported a Teacher
+			var teacher models.Teacher
+			if err := db.Where("user_id = ?", report.ReportedUserID).First(&teacher).Error; err == nil {
+				CreateNotification(db, teacher.UserID, "system", desc)
+				return ApplyTeacherFlags(db, teacher.ID, flags, reason)
+			}
+		case "teacher": // Teacher reported a Learner
+			var learner models.Learner
+			if err := db.Where("user_id = ?", report.ReportedUserID).First(&learner).Error; err == nil {
+				CreateNotification(db, learner.UserID, "system", desc)
+				return ApplyLearnerFlags(db, learner.ID, flags, reason)
+			}
+		}
+		return nil
+	}
+
+	// False report
+	flags := policy.FalseReportFlags
+	if flags <= 0 {
+		return nil
+	}
+	reason := fmt.Sprintf("Flagged for submitting a false report (ID: %d)", report.ID)
+	desc := fmt.Sprintf("Report #%d was found to be false. You have received %d flag(s) as a warning.", report.ID, flags)
+	switch report.ReportType {
+	case "learner": // The reporter was a Learner
+		var learner models.Learner
+		if err := db.Where("user_id = ?", report.ReportUserID).First(&learner).Error; err == nil {
+			CreateNotification(db, learner.UserID, "system", desc)
+			return ApplyLearnerFlags(db, learner.ID, flags, reason)
+		}
+	case "teacher": // The reporter was a Teacher
+		var teacher models.Teacher
+		if err := db.Where("user_id = ?", report.ReportUserID).First(&teacher).Error; err == nil {
+			CreateNotification(db, teacher.UserID, "system", desc)
+			return ApplyTeacherFlags(db, teacher.ID, flags, reason)
+		}
+	}
+	return nil
+}
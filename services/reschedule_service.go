@@ -0,0 +1,344 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSessionNotUpcoming         = errors.New("only sessions that have not started can be rescheduled")
+	ErrReschedulePending          = errors.New("a reschedule is already waiting for learner responses")
+	ErrNoPendingReschedule        = errors.New("there is no pending reschedule for this session")
+	ErrRescheduleDeadlinePassed   = errors.New("the response deadline for this reschedule has passed")
+	ErrRescheduleAlreadyAnswered  = errors.New("you have already responded to this reschedule")
+	ErrInvalidRescheduleDecision  = errors.New("decision must be accept or decline")
+	ErrInvalidRescheduleTimes     = errors.New("class_finish must be after class_start and class_start must be in the future")
+	ErrInvalidRescheduleDeadlines = errors.New("response_deadline must be in the future and no later than the current or new start")
+	ErrSessionReasonTooLong       = fmt.Errorf("reason must be at most %d characters", MaxSessionReasonLength)
+)
+
+// MaxSessionReasonLength caps the reason a teacher gives for moving or
+// cancelling a session, so it still fits the learners' notifications.
+const MaxSessionReasonLength = 80
+
+func validSessionReason(reason string) bool {
+	return utf8.RuneCountInString(reason) <= MaxSessionReasonLength
+}
+
+// ProposeReschedule records a teacher's proposal to move session and asks
+// every enrolled learner to respond before deadline. With nobody enrolled the
+// session moves straight away.
+func ProposeReschedule(db *gorm.DB, session *models.ClassSession, proposerID uint, newStart, newFinish, deadline time.Time, reason string, now time.Time) (*models.SessionReschedule, error) {
+	if !validSessionReason(reason) {
+		return nil, ErrSessionReasonTooLong
+	}
+	if !isUpcomingSession(session) {
+		return nil, ErrSessionNotUpcoming
+	}
+	if !newFinish.After(newStart) || !newStart.After(now) {
+		return nil, ErrInvalidRescheduleTimes
+	}
+	latest := session.ClassStart
+	if newStart.Before(latest) {
+		latest = newStart
+	}
+	if !deadline.After(now) || deadline.After(latest) {
+		return nil, ErrInvalidRescheduleDeadlines
+	}
+
+	reschedule := models.SessionReschedule{
+		ClassSessionID:   session.ID,
+		ProposedByUserID: proposerID,
+		OldStart:         session.ClassStart,
+		OldFinish:        session.ClassFinish,
+		NewStart:         newStart,
+		NewFinish:        newFinish,
+		ResponseDeadline: deadline,
+		Reason:           reason,
+		Status:           models.RescheduleStatusPending,
+	}
+	var learnerUserIDs []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Enrollments share-lock the session, so this holds off new learners
+		// until every enrolled one has a response row; later ones get theirs
+		// from AddPendingRescheduleResponse.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			First(&models.ClassSession{}, session.ID).Error; err != nil {
+			return err
+		}
+		var pending int64
+		if err := tx.Model(&models.SessionReschedule{}).
+			Where("class_session_id = ? AND status = ?", session.ID, models.RescheduleStatusPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrReschedulePending
+		}
+		if err := CheckTeacherSchedule(tx, session.ClassID, newStart, newFinish, session.ID); err != nil {
+			return err
+		}
+
+		var learnerIDs []uint
+		if err := tx.Model(&models.Enrollment{}).
+			Where("class_session_id = ? AND enrollment_status = ?", session.ID, models.EnrollmentStatusActive).
+			Pluck("learner_id", &learnerIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range learnerIDs {
+			reschedule.Responses = append(reschedule.Responses, models.RescheduleResponse{LearnerID: id, Decision: models.RescheduleDecisionPending})
+		}
+		if err := tx.Create(&reschedule).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if learnerUserIDs, err = activeLearnerUserIDs(tx, session.ID); err != nil {
+			return err
+		}
+		return RecordSessionHistory(tx, session.ID, &proposerID, HistoryRescheduleProposed,
+			fmt.Sprintf("Proposed moving from %s to %s; responses due %s. %s", session.ClassStart.Format(time.RFC3339), newStart.Format(time.RFC3339), deadline.Format(time.RFC3339), reason))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(reschedule.Responses) == 0 {
+		if err := ApplyReschedule(db, &reschedule, now); err != nil {
+			return nil, err
+		}
+		return &reschedule, nil
+	}
+
+	notifyLocalized(db, learnerUserIDs, "system", rescheduleProposedNotice(&reschedule))
+	return &reschedule, nil
+}
+
+func rescheduleProposedNotice(reschedule *models.SessionReschedule) func(loc *time.Location) string {
+	return func(loc *time.Location) string {
+		return fmt.Sprintf("Your class on %s is proposed to move to %s. Accept or decline (with a full refund) before %s.",
+			FormatLocalTime(reschedule.OldStart, loc), FormatLocalTime(reschedule.NewStart, loc), FormatLocalTime(reschedule.ResponseDeadline, loc))
+	}
+}
+
+// AddPendingRescheduleResponse gives a learner who just enrolled in sessionID
+// a say in its pending reschedule, if there is one. Call it in the transaction
+// that writes the enrollment, after locking the session with
+// FOR SHARE, and pass the result to NotifyPendingReschedule once it commits.
+func AddPendingRescheduleResponse(tx *gorm.DB, sessionID, learnerID uint) (*models.SessionReschedule, error) {
+	var reschedule models.SessionReschedule
+	res := tx.Where("class_session_id = ? AND status = ?", sessionID, models.RescheduleStatusPending).Limit(1).Find(&reschedule)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, res.Error
+	}
+	response := models.RescheduleResponse{RescheduleID: reschedule.ID, LearnerID: learnerID, Decision: models.RescheduleDecisionPending}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "reschedule_id"}, {Name: "learner_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"decision": models.RescheduleDecisionPending, "responded_at": nil}),
+	}).Create(&response).Error; err != nil {
+		return nil, err
+	}
+	return &reschedule, nil
+}
+
+// NotifyPendingReschedule asks learnerID to answer reschedule, as
+// ProposeReschedule does for the learners enrolled when it was proposed.
+func NotifyPendingReschedule(db *gorm.DB, reschedule *models.SessionReschedule, learnerID uint) {
+	if reschedule == nil {
+		return
+	}
+	var userIDs []uint
+	if err := db.Model(&models.Learner{}).Where("id = ?", learnerID).Pluck("user_id", &userIDs).Error; err != nil {
+		log.Printf("Failed to load learner %d for reschedule %d: %v", learnerID, reschedule.ID, err)
+		return
+	}
+	notifyLocalized(db, userIDs, "system", rescheduleProposedNotice(reschedule))
+}
+
+// RespondToReschedule records a learner's decision on the session's pending
+// reschedule. Declining refunds the session price and frees the seat.
+func RespondToReschedule(db *gorm.DB, session *models.ClassSession, learnerID uint, decision string, now time.Time) (*models.RescheduleResponse, error) {
+	switch decision {
+	case "accept":
+		decision = models.RescheduleDecisionAccepted
+	case "decline":
+		decision = models.RescheduleDecisionDeclined
+	default:
+		return nil, ErrInvalidRescheduleDecision
+	}
+
+	var response models.RescheduleResponse
+	var refundedUserID uint
+	var refunded float64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var reschedule models.SessionReschedule
+		err := tx.Where("class_session_id = ? AND status = ?", session.ID, models.RescheduleStatusPending).First(&reschedule).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNoPendingReschedule
+		}
+		if err != nil {
+			return err
+		}
+		if now.After(reschedule.ResponseDeadline) {
+			return ErrRescheduleDeadlinePassed
+		}
+
+		err = tx.Where("reschedule_id = ? AND learner_id = ?", reschedule.ID, learnerID).First(&response).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotEnrolled
+		}
+		if err != nil {
+			return err
+		}
+		if response.Decision != models.RescheduleDecisionPending {
+			return ErrRescheduleAlreadyAnswered
+		}
+
+		response.Decision = decision
+		response.RespondedAt = &now
+		if err := tx.Model(&response).Updates(map[string]interface{}{"decision": decision, "responded_at": now}).Error; err != nil {
+			return err
+		}
+
+		event := HistoryRescheduleAccepted
+		if decision == models.RescheduleDecisionDeclined {
+			event = HistoryRescheduleDeclined
+		}
+		if err := RecordSessionHistory(tx, session.ID, nil, event, fmt.Sprintf("Learner %d %s the reschedule", learnerID, decision)); err != nil {
+			return err
+		}
+		if decision != models.RescheduleDecisionDeclined {
+			return nil
+		}
+
+		var enrollment models.Enrollment
+		if err := tx.Where("learner_id = ? AND class_session_id = ? AND enrollment_status = ?", learnerID, session.ID, models.EnrollmentStatusActive).
+			First(&enrollment).Error; err != nil {
+			return err
+		}
+		if refundedUserID, err = RefundEnrollment(tx, &enrollment, now); err != nil {
+			return err
+		}
+		refunded = enrollment.RefundAmount
+		return RecordSessionHistory(tx, session.ID, nil, HistoryLearnerRefunded,
+			fmt.Sprintf("Refunded %.2f to learner %d after declining the reschedule", refunded, learnerID))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if refundedUserID != 0 {
+		notifyLocalized(db, []uint{refundedUserID}, "system", func(loc *time.Location) string {
+			desc := fmt.Sprintf("You declined the new time for your class on %s and your seat was released.", FormatLocalTime(session.ClassStart, loc))
+			if refunded > 0 {
+				desc += fmt.Sprintf(" %.2f has been refunded to your balance.", refunded)
+			}
+			return desc
+		})
+	}
+	return &response, nil
+}
+
+// ApplyReschedule moves the session to the proposed time and tells everyone
+// still enrolled. A session that has since started or been cancelled, or whose
+// new time now overlaps another of the teacher's sessions, is left alone and
+// the proposal withdrawn.
+func ApplyReschedule(db *gorm.DB, reschedule *models.SessionReschedule, now time.Time) error {
+	var session models.ClassSession
+	if err := db.First(&session, reschedule.ClassSessionID).Error; err != nil {
+		return err
+	}
+
+	status, event := models.RescheduleStatusApplied, HistoryRescheduleApplied
+	detail := fmt.Sprintf("Moved from %s to %s", reschedule.OldStart.Format(time.RFC3339), reschedule.NewStart.Format(time.RFC3339))
+	if !isUpcomingSession(&session) {
+		status, event = models.RescheduleStatusWithdrawn, HistoryRescheduleWithdrawn
+		detail = fmt.Sprintf("Not applied because the session is %s", session.ClassStatus)
+	}
+
+	var learnerUserIDs []uint
+	var conflict *ScheduleConflictError
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// The slot may have been taken while learners were answering.
+		if status == models.RescheduleStatusApplied {
+			err := CheckTeacherSchedule(tx, session.ClassID, reschedule.NewStart, reschedule.NewFinish, session.ID)
+			switch {
+			case errors.As(err, &conflict):
+				status, event = models.RescheduleStatusWithdrawn, HistoryRescheduleWithdrawn
+				detail = fmt.Sprintf("Not applied because the new time overlaps %s", conflict.Error())
+			case err != nil:
+				return err
+			}
+		}
+
+		res := tx.Model(&models.SessionReschedule{}).
+			Where("id = ? AND status = ?", reschedule.ID, models.RescheduleStatusPending).
+			Updates(map[string]interface{}{"status": status, "applied_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		if status == models.RescheduleStatusApplied {
+			updates := map[string]interface{}{
+				"class_start":       reschedule.NewStart,
+				"class_finish":      reschedule.NewFinish,
+				"calendar_sequence": gorm.Expr("calendar_sequence + 1"),
+				"reminder_sent_at":  nil,
+			}
+			if session.EnrollmentDeadline.After(reschedule.NewStart) {
+				updates["enrollment_deadline"] = reschedule.NewStart
+			}
+			if err := tx.Model(&models.ClassSession{}).Where("id = ?", session.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if status == models.RescheduleStatusApplied || conflict != nil {
+			var err error
+			if learnerUserIDs, err = activeLearnerUserIDs(tx, session.ID); err != nil {
+				return err
+			}
+		}
+		return RecordSessionHistory(tx, session.ID, nil, event, detail)
+	})
+	if err != nil {
+		return err
+	}
+	reschedule.Status = status
+	reschedule.AppliedAt = &now
+
+	if conflict != nil {
+		notifyLocalized(db, learnerUserIDs, "system", func(loc *time.Location) string {
+			return fmt.Sprintf("The proposed move of your class on %s was called off; it stays at its original time.", FormatLocalTime(reschedule.OldStart, loc))
+		})
+		return nil
+	}
+	notifyLocalized(db, learnerUserIDs, "system", func(loc *time.Location) string {
+		return fmt.Sprintf("Your class on %s has moved to %s.", FormatLocalTime(reschedule.OldStart, loc), FormatLocalTime(reschedule.NewStart, loc))
+	})
+	return nil
+}
+
+// ApplyDueReschedules applies every pending reschedule whose response
+// deadline has passed. Learners who did not answer keep their seat.
+func ApplyDueReschedules(db *gorm.DB) {
+	now := time.Now()
+	var due []models.SessionReschedule
+	if err := db.Where("status = ? AND response_deadline <= ?", models.RescheduleStatusPending, now).Find(&due).Error; err != nil {
+		log.Printf("Error finding due reschedules: %v", err)
+		return
+	}
+	for i := range due {
+		if err := ApplyReschedule(db, &due[i], now); err != nil {
+			log.Printf("Failed to apply reschedule %d: %v", due[i].ID, err)
+		}
+	}
+}
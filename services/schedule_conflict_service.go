@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrScheduleConflict = errors.New("schedule conflict")
+	ErrInvalidTimeRange = errors.New("class_finish must be after class_start")
+)
+
+// ScheduleConflictError lists the sessions that overlap the requested time.
+// It matches ErrScheduleConflict with errors.Is.
+type ScheduleConflictError struct {
+	Conflicts []models.ScheduleConflict
+}
+
+func (e *ScheduleConflictError) Error() string {
+	parts := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		parts[i] = fmt.Sprintf("session %d (%s, %s - %s)", c.ClassSessionID, c.ClassName,
+			c.ClassStart.Format(time.RFC3339), c.ClassFinish.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("schedule conflicts with %d other session(s): %s", len(e.Conflicts), strings.Join(parts, "; "))
+}
+
+func (e *ScheduleConflictError) Is(target error) bool {
+	return target == ErrScheduleConflict
+}
+
+// scheduleFreeStatuses are session statuses that no longer occupy anyone's time.
+var scheduleFreeStatuses = []string{models.SessionStatusCancelled, models.SessionStatusTeacherAbsent}
+
+// conflictQuery selects sessions overlapping [start, finish), other than
+// excludeSessionIDs. Sessions that merely touch (one ends as the next starts)
+// do not conflict.
+func conflictQuery(db *gorm.DB, start, finish time.Time, excludeSessionIDs ...uint) *gorm.DB {
+	if len(excludeSessionIDs) == 0 {
+		excludeSessionIDs = []uint{0}
+	}
+	return db.Model(&models.ClassSession{}).
+		Select("class_sessions.id AS class_session_id, class_sessions.class_id, classes.class_name, class_sessions.class_start, class_sessions.class_finish").
+		Joins("JOIN classes ON classes.id = class_sessions.class_id").
+		Where("class_sessions.id NOT IN ? AND class_sessions.class_status NOT IN ?", excludeSessionIDs, scheduleFreeStatuses).
+		Where("class_sessions.class_start < ? AND class_sessions.class_finish > ?", finish, start).
+		Order("class_sessions.class_start")
+}
+
+// CheckTeacherSchedule rejects a session of classID running from start to
+// finish when it overlaps another session taught by the same teacher in any
+// of their classes. Pass the IDs of the sessions being moved as
+// excludeSessionIDs.
+//
+// The teacher lives on the class rather than the session, so this is a range
+// query and not an exclusion constraint. Instead the teacher's row is locked,
+// as in BookPrivateLesson: call this in the transaction that writes the
+// session so concurrent writes for the same teacher are checked one by one.
+func CheckTeacherSchedule(db *gorm.DB, classID uint, start, finish time.Time, excludeSessionIDs ...uint) error {
+	if !finish.After(start) {
+		return ErrInvalidTimeRange
+	}
+
+	var teacher models.Teacher
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = (SELECT teacher_id FROM classes WHERE id = ?)", classID).
+		Find(&teacher).Error; err != nil {
+		return err
+	}
+
+	var conflicts []models.ScheduleConflict
+	if err := conflictQuery(db, start, finish, excludeSessionIDs...).
+		Where("classes.teacher_id = (SELECT teacher_id FROM classes WHERE id = ?)", classID).
+		Scan(&conflicts).Error; err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &ScheduleConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// CheckLearnerSchedule rejects enrolling learnerID in session when it overlaps
+// another session the learner is actively enrolled in. Like
+// CheckTeacherSchedule it locks the learner's row, so call it in the
+// transaction that writes the enrollment.
+func CheckLearnerSchedule(db *gorm.DB, learnerID uint, session *models.ClassSession) error {
+	var learner models.Learner
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", learnerID).
+		Find(&learner).Error; err != nil {
+		return err
+	}
+
+	var conflicts []models.ScheduleConflict
+	if err := conflictQuery(db, session.ClassStart, session.ClassFinish, session.ID).
+		Joins("JOIN enrollments ON enrollments.class_session_id = class_sessions.id").
+		Where("enrollments.learner_id = ? AND enrollments.enrollment_status = ? AND enrollments.deleted_at IS NULL",
+			learnerID, models.EnrollmentStatusActive).
+		Scan(&conflicts).Error; err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return &ScheduleConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxSessionAssetBytes is the largest asset accepted, a long HD recording.
+const MaxSessionAssetBytes = 2 << 30
+
+var ErrStorageQuotaExceeded = errors.New("this upload would exceed your storage quota")
+
+// sessionAssetKinds is the auto-detection order.
+var sessionAssetKinds = []string{models.SessionAssetRecording, models.SessionAssetSlides, models.SessionAssetWorksheet}
+
+// SessionAssetRules are the size limits and sniffed content types accepted
+// per asset kind. Office documents sniff as zip archives.
+var SessionAssetRules = map[string]AttachmentRule{
+	models.SessionAssetRecording: {MaxBytes: MaxSessionAssetBytes, ContentTypes: []string{"video/mp4", "video/webm", "audio/mpeg", "audio/wave", "application/ogg"}},
+	models.SessionAssetSlides:    {MaxBytes: 200 << 20, ContentTypes: []string{"application/pdf", "application/zip"}},
+	models.SessionAssetWorksheet: {MaxBytes: 50 << 20, ContentTypes: []string{"application/pdf", "application/zip", "text/plain", "image/jpeg", "image/png"}},
+}
+
+// ClassifySessionAsset sniffs head, the first bytes of a size-byte upload,
+// and returns its kind and content type.
+func ClassifySessionAsset(head []byte, size int64, kind string) (string, string, error) {
+	return classifyUpload(head, size, kind, sessionAssetKinds, SessionAssetRules)
+}
+
+// CanViewSessionAssets reports whether user may read session's assets: its
+// teacher and learners holding a seat. session.Class must be loaded.
+func CanViewSessionAssets(db *gorm.DB, user *models.User, session *models.ClassSession) (bool, error) {
+	if IsSessionTeacher(user, session) {
+		return true, nil
+	}
+	if user.Learner == nil {
+		return false, nil
+	}
+	var enrolled int64
+	err := db.Model(&models.Enrollment{}).
+		Where("learner_id = ? AND class_session_id = ? AND enrollment_status IN ?", user.Learner.ID, session.ID, attendedEnrollmentStatuses).
+		Count(&enrolled).Error
+	return enrolled > 0, err
+}
+
+// SessionAssetExpiry is when an asset of kind uploaded at now for session is
+// purged, counted from the end of the session; nil keeps it until deleted.
+func SessionAssetExpiry(policy models.StoragePolicy, kind string, session *models.ClassSession, now time.Time) *time.Time {
+	days := policy.MaterialRetentionDays
+	if kind == models.SessionAssetRecording {
+		days = policy.RecordingRetentionDays
+	}
+	if days == 0 {
+		return nil
+	}
+	from := session.ClassFinish
+	if now.After(from) {
+		from = now
+	}
+	expires := from.AddDate(0, 0, days)
+	return &expires
+}
+
+// CheckStorageQuota fails early when size more bytes would not fit in
+// teacher's quota. CreateSessionAsset checks again under a lock.
+func CheckStorageQuota(db *gorm.DB, teacher *models.Teacher, size int64) error {
+	usage, err := TeacherStorageUsage(db, teacher)
+	if err != nil {
+		return err
+	}
+	if usage.UsedBytes+size > usage.QuotaBytes {
+		return ErrStorageQuotaExceeded
+	}
+	return nil
+}
+
+// CreateSessionAsset records an uploaded asset, setting its expiry from the
+// storage policy. The teacher row is locked so concurrent uploads cannot
+// overshoot the quota together.
+func CreateSessionAsset(db *gorm.DB, asset *models.SessionAsset, session *models.ClassSession, now time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var teacher models.Teacher
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&teacher, asset.TeacherID).Error; err != nil {
+			return err
+		}
+		policy, err := GetStoragePolicy(tx)
+		if err != nil {
+			return err
+		}
+		used, err := teacherStorageUsed(tx, teacher.ID)
+		if err != nil {
+			return err
+		}
+		if used+asset.SizeBytes > TeacherQuota(&teacher, policy) {
+			return ErrStorageQuotaExceeded
+		}
+		asset.ExpiresAt = SessionAssetExpiry(policy, asset.Kind, session, now)
+		return tx.Create(asset).Error
+	})
+}
+
+// DeleteSessionAsset removes asset's object and then its row, freeing its
+// space in the teacher's quota.
+func DeleteSessionAsset(ctx context.Context, db *gorm.DB, store storage.ObjectStore, asset *models.SessionAsset) error {
+	if err := store.RemoveObject(ctx, asset.ObjectKey); err != nil {
+		return err
+	}
+	return db.Unscoped().Delete(asset).Error
+}
+
+// PurgeExpiredSessionAssets deletes assets whose retention period is over.
+func PurgeExpiredSessionAssets(db *gorm.DB, store storage.ObjectStore, now time.Time) {
+	var expired []models.SessionAsset
+	if err := db.Where("expires_at <= ?", now).Find(&expired).Error; err != nil {
+		log.Printf("Error finding expired session assets: %v", err)
+		return
+	}
+	for i := range expired {
+		if err := DeleteSessionAsset(context.Background(), db, store, &expired[i]); err != nil {
+			log.Printf("Failed to purge session asset %d: %v", expired[i].ID, err)
+		}
+	}
+}
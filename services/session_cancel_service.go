@@ -0,0 +1,94 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// CancellationResult summarises what cancelling a session did.
+type CancellationResult struct {
+	Session          models.ClassSession `json:"session"`
+	RefundedLearners int                 `json:"refunded_learners"`
+	RefundTotal      float64             `json:"refund_total"`
+	TeacherFlagged   bool                `json:"teacher_flagged"`
+}
+
+// CancelClassSession cancels an upcoming session, refunds every enrolled
+// learner what they paid and notifies them. Cancelling within the policy's
+// LateCancelHours of ClassStart adds LateCancelFlags to the teacher.
+func CancelClassSession(db *gorm.DB, session *models.ClassSession, teacherID uint, actorUserID *uint, reason string, now time.Time) (*CancellationResult, error) {
+	if !validSessionReason(reason) {
+		return nil, ErrSessionReasonTooLong
+	}
+	if !isUpcomingSession(session) {
+		return nil, ErrSessionNotUpcoming
+	}
+	policy, err := GetModerationPolicy(db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := CancellationResult{}
+	var refundedUserIDs []uint
+	refunds := map[uint]float64{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := TransitionClassSession(tx, session, models.SessionStatusCancelled); err != nil {
+			return err
+		}
+
+		var enrollments []models.Enrollment
+		if err := tx.Where("class_session_id = ? AND enrollment_status = ?", session.ID, models.EnrollmentStatusActive).
+			Find(&enrollments).Error; err != nil {
+			return err
+		}
+		for i := range enrollments {
+			userID, err := RefundEnrollment(tx, &enrollments[i], now)
+			if err != nil {
+				return err
+			}
+			refundedUserIDs = append(refundedUserIDs, userID)
+			refunds[userID] = enrollments[i].RefundAmount
+			result.RefundTotal += enrollments[i].RefundAmount
+		}
+		result.RefundedLearners = len(enrollments)
+
+		detail := fmt.Sprintf("Cancelled by the teacher; refunded %.2f to %d learner(s). %s", result.RefundTotal, result.RefundedLearners, reason)
+		return RecordSessionHistory(tx, session.ID, actorUserID, HistorySessionCancelled, detail)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifyEachLocalized(db, refundedUserIDs, "system", func(userID uint, loc *time.Location) string {
+		desc := fmt.Sprintf("Your class on %s was cancelled by the teacher.", FormatLocalTime(session.ClassStart, loc))
+		if refunds[userID] > 0 {
+			desc += fmt.Sprintf(" %.2f has been refunded to your balance.", refunds[userID])
+		}
+		if reason != "" {
+			desc += " Reason: " + reason
+		}
+		return desc
+	})
+
+	late := policy.LateCancelHours > 0 && session.ClassStart.Sub(now) < time.Duration(policy.LateCancelHours)*time.Hour
+	if late && policy.LateCancelFlags > 0 {
+		if err := ApplyTeacherFlags(db, teacherID, policy.LateCancelFlags, "late_cancellation"); err != nil {
+			log.Printf("Failed to flag teacher %d for late cancellation: %v", teacherID, err)
+		} else {
+			result.TeacherFlagged = true
+			var teacher models.Teacher
+			if err := db.First(&teacher, teacherID).Error; err == nil {
+				notifyLocalized(db, []uint{teacher.UserID}, "system", func(loc *time.Location) string {
+					return fmt.Sprintf("You were flagged for cancelling the class on %s less than %d hours before it started.", FormatLocalTime(session.ClassStart, loc), policy.LateCancelHours)
+				})
+			}
+		}
+	}
+
+	result.Session = *session
+	return &result, nil
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// Session history events.
+const (
+	HistoryStatusChanged       = "status_changed"
+	HistoryRescheduleProposed  = "reschedule_proposed"
+	HistoryRescheduleAccepted  = "reschedule_accepted"
+	HistoryRescheduleDeclined  = "reschedule_declined"
+	HistoryRescheduleApplied   = "reschedule_applied"
+	HistoryRescheduleWithdrawn = "reschedule_withdrawn"
+	HistoryLearnerRefunded     = "learner_refunded"
+	HistorySessionCancelled    = "session_cancelled"
+	HistorySessionBooked       = "session_booked"
+)
+
+func init() {
+	OnSessionTransition(func(db *gorm.DB, e SessionEvent) {
+		if err := RecordSessionHistory(db, e.SessionID, nil, HistoryStatusChanged, fmt.Sprintf("%s -> %s", e.From, e.To)); err != nil {
+			log.Printf("Failed to record history for class session %d: %v", e.SessionID, err)
+		}
+	})
+}
+
+// RecordSessionHistory appends an entry to a session's history.
+func RecordSessionHistory(db *gorm.DB, sessionID uint, actorUserID *uint, event, detail string) error {
+	if len(detail) > 500 {
+		detail = detail[:500]
+	}
+	return db.Create(&models.SessionHistory{
+		ClassSessionID: sessionID,
+		ActorUserID:    actorUserID,
+		Event:          event,
+		Detail:         detail,
+	}).Error
+}
+
+// activeLearnerUserIDs returns the user IDs of learners holding a seat in a session.
+func activeLearnerUserIDs(db *gorm.DB, sessionID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&models.Enrollment{}).
+		Joins("JOIN learners ON learners.id = enrollments.learner_id").
+		Where("enrollments.class_session_id = ? AND enrollments.enrollment_status = ?", sessionID, models.EnrollmentStatusActive).
+		Pluck("learners.user_id", &ids).Error
+	return ids, err
+}
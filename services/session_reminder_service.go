@@ -0,0 +1,56 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// SessionReminderLead is how long before a session starts its teacher and
+// learners are reminded.
+const SessionReminderLead = time.Hour
+
+// SendSessionReminders notifies the teacher and active learners of every
+// upcoming session starting within SessionReminderLead of now. Each session
+// is reminded once; moving it clears ReminderSentAt so the new time is
+// reminded again. Start times are shown in each recipient's timezone.
+func SendSessionReminders(db *gorm.DB, now time.Time) {
+	var sessions []models.ClassSession
+	err := db.Preload("Class.Teacher").
+		Where("class_status IN ? AND reminder_sent_at IS NULL AND class_start > ? AND class_start <= ?",
+			models.UpcomingSessionStatuses, now, now.Add(SessionReminderLead)).
+		Find(&sessions).Error
+	if err != nil {
+		log.Printf("Error finding sessions to remind: %v", err)
+		return
+	}
+
+	for i := range sessions {
+		session := &sessions[i]
+		// Claim the session so overlapping runs do not remind twice.
+		res := db.Model(&models.ClassSession{}).
+			Where("id = ? AND reminder_sent_at IS NULL", session.ID).
+			UpdateColumn("reminder_sent_at", now)
+		if res.Error != nil {
+			log.Printf("Failed to claim reminder for session %d: %v", session.ID, res.Error)
+			continue
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		userIDs, err := activeLearnerUserIDs(db, session.ID)
+		if err != nil {
+			log.Printf("Failed to load learners of session %d: %v", session.ID, err)
+		}
+		if session.Class.Teacher.UserID != 0 {
+			userIDs = append(userIDs, session.Class.Teacher.UserID)
+		}
+		notifyLocalized(db, userIDs, "system", func(loc *time.Location) string {
+			return fmt.Sprintf("Reminder: %s starts at %s.", session.Class.ClassName, FormatLocalTime(session.ClassStart, loc))
+		})
+	}
+}
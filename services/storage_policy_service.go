@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// storagePolicyID is the primary key of the single persisted policy row.
+const storagePolicyID = 1
+
+// DefaultStoragePolicy keeps recordings for 90 days after their session,
+// keeps slides and worksheets until the teacher deletes them, and gives every
+// teacher 5 GiB.
+func DefaultStoragePolicy() models.StoragePolicy {
+	return models.StoragePolicy{
+		RecordingRetentionDays: 90,
+		MaterialRetentionDays:  0,
+		TeacherQuotaBytes:      5 << 30,
+	}
+}
+
+// GetStoragePolicy returns the persisted policy, falling back to the defaults
+// when no admin has saved one yet.
+func GetStoragePolicy(db *gorm.DB) (models.StoragePolicy, error) {
+	var policy models.StoragePolicy
+	err := db.First(&policy, storagePolicyID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultStoragePolicy(), nil
+	}
+	return policy, err
+}
+
+// ValidateStoragePolicy rejects negative retention periods and quotas.
+func ValidateStoragePolicy(p models.StoragePolicy) error {
+	if p.RecordingRetentionDays < 0 || p.MaterialRetentionDays < 0 {
+		return errors.New("recording_retention_days and material_retention_days must not be negative (0 keeps assets until deleted)")
+	}
+	if p.TeacherQuotaBytes < 0 {
+		return errors.New("teacher_quota_bytes must not be negative")
+	}
+	return nil
+}
+
+// SaveStoragePolicy validates and upserts the single policy row. New
+// retention periods apply to assets uploaded afterwards.
+func SaveStoragePolicy(db *gorm.DB, p *models.StoragePolicy, updatedBy *uint) error {
+	if err := ValidateStoragePolicy(*p); err != nil {
+		return err
+	}
+	p.ID = storagePolicyID
+	p.UpdatedByUserID = updatedBy
+	p.DeletedAt = gorm.DeletedAt{}
+
+	// A row soft-deleted by hand is revived rather than inserted again.
+	var existing models.StoragePolicy
+	err := db.Unscoped().First(&existing, storagePolicyID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return db.Create(p).Error
+	case err != nil:
+		return err
+	}
+	p.CreatedAt = existing.CreatedAt
+	return db.Save(p).Error
+}
+
+// TeacherQuota is teacher's storage quota: their own override or the policy's.
+func TeacherQuota(teacher *models.Teacher, policy models.StoragePolicy) int64 {
+	if teacher.StorageQuotaBytes != nil {
+		return *teacher.StorageQuotaBytes
+	}
+	return policy.TeacherQuotaBytes
+}
+
+// teacherStorageUsed sums the sizes of teacher's session assets.
+func teacherStorageUsed(db *gorm.DB, teacherID uint) (int64, error) {
+	var used int64
+	err := db.Model(&models.SessionAsset{}).
+		Where("teacher_id = ?", teacherID).
+		Select("COALESCE(SUM(size_bytes), 0)").Scan(&used).Error
+	return used, err
+}
+
+// TeacherStorageUsage reports how much of their quota teacher uses.
+func TeacherStorageUsage(db *gorm.DB, teacher *models.Teacher) (models.StorageUsage, error) {
+	policy, err := GetStoragePolicy(db)
+	if err != nil {
+		return models.StorageUsage{}, err
+	}
+	used, err := teacherStorageUsed(db, teacher.ID)
+	if err != nil {
+		return models.StorageUsage{}, err
+	}
+	return models.StorageUsage{TeacherID: teacher.ID, UsedBytes: used, QuotaBytes: TeacherQuota(teacher, policy)}, nil
+}
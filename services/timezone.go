@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+	_ "time/tzdata" // DST rules must not depend on the host's zoneinfo
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+)
+
+// DefaultTimezone is used when a caller does not name one.
+const DefaultTimezone = "Asia/Bangkok"
+
+var ErrInvalidTimezone = errors.New("timezone must be an IANA name such as Asia/Bangkok")
+
+// LoadTimezone resolves an IANA timezone name, defaulting to DefaultTimezone.
+// "Local" is rejected because it depends on the server.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		name = DefaultTimezone
+	}
+	if name == "Local" {
+		return nil, ErrInvalidTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
+
+// ValidateTimezone checks an optional IANA name from a request body.
+func ValidateTimezone(name string) error {
+	_, err := LoadTimezone(name)
+	return err
+}
+
+// locationOrDefault resolves a stored timezone, falling back to
+// DefaultTimezone for rows written before timezones were validated.
+func locationOrDefault(name string) *time.Location {
+	if loc, err := LoadTimezone(name); err == nil {
+		return loc
+	}
+	loc, _ := LoadTimezone(DefaultTimezone)
+	return loc
+}
+
+// UserLocation is the timezone user reads times in.
+func UserLocation(user *models.User) *time.Location {
+	return locationOrDefault(user.Timezone)
+}
+
+// ClassLocation is the timezone whose wall clock a class is scheduled in.
+func ClassLocation(class *models.Class) *time.Location {
+	return locationOrDefault(class.Timezone)
+}
+
+// FormatLocalTime renders t for people in loc, with the UTC offset spelled
+// out so the text stays unambiguous around DST changes.
+func FormatLocalTime(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("Mon 2 Jan 2006 15:04 -07:00") + " (" + loc.String() + ")"
+}
+
+// userLocations loads the timezones of userIDs in one query. Users that
+// cannot be loaded get DefaultTimezone.
+func userLocations(db *gorm.DB, userIDs []uint) map[uint]*time.Location {
+	var users []models.User
+	if err := db.Select("id", "timezone").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		log.Printf("Failed to load timezones of users %v: %v", userIDs, err)
+	}
+	locs := make(map[uint]*time.Location, len(userIDs))
+	for _, id := range userIDs {
+		locs[id] = locationOrDefault("")
+	}
+	for i := range users {
+		locs[users[i].ID] = UserLocation(&users[i])
+	}
+	return locs
+}
+
+// notifyLocalized sends each of userIDs a notification whose text describe
+// renders in that user's timezone.
+func notifyLocalized(db *gorm.DB, userIDs []uint, notifType string, describe func(loc *time.Location) string) {
+	notifyEachLocalized(db, userIDs, notifType, func(_ uint, loc *time.Location) string { return describe(loc) })
+}
+
+// notifyEachLocalized is notifyLocalized for texts that also differ per user.
+func notifyEachLocalized(db *gorm.DB, userIDs []uint, notifType string, describe func(userID uint, loc *time.Location) string) {
+	if len(userIDs) == 0 {
+		return
+	}
+	locs := userLocations(db, userIDs)
+	for _, id := range userIDs {
+		if err := CreateNotification(db, id, notifType, describe(id, locs[id])); err != nil {
+			log.Printf("Failed to notify user %d: %v", id, err)
+		}
+	}
+}
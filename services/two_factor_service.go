@@ -0,0 +1,286 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/config"
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	totpPeriod        = 30 * time.Second
+	totpDigits        = 6
+	totpSkewSteps     = 1 // accept one step before/after to tolerate clock drift
+	recoveryCodeCount = 10
+
+	// secondFactorMaxFailures wrong codes in a row lock verification for
+	// secondFactorLockout, which caps guessing at a few codes per window.
+	secondFactorMaxFailures = 5
+	secondFactorLockout     = 15 * time.Minute
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorLocked         = errors.New("too many invalid two-factor codes; try again later")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random 160-bit secret encoded as unpadded base32.
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPCode computes the RFC 6238 code (HMAC-SHA1, 6 digits, 30s period) for secret at t.
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid totp secret: %w", err)
+	}
+	return hotp(key, uint64(t.Unix()/int64(totpPeriod/time.Second))), nil
+}
+
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	off := sum[len(sum)-1] & 0x0f
+	v := (uint32(sum[off])&0x7f)<<24 |
+		uint32(sum[off+1])<<16 |
+		uint32(sum[off+2])<<8 |
+		uint32(sum[off+3])
+	return fmt.Sprintf("%0*d", totpDigits, v%1000000)
+}
+
+// validateTOTP checks code against the steps around now and returns the matched step.
+// Steps at or below lastUsedStep are rejected so a code cannot be replayed.
+func validateTOTP(secret, code string, now time.Time, lastUsedStep int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	code = strings.TrimSpace(code)
+	current := now.Unix() / int64(totpPeriod/time.Second)
+	for i := -totpSkewSteps; i <= totpSkewSteps; i++ {
+		step := current + int64(i)
+		if step <= lastUsedStep {
+			continue
+		}
+		if hmac.Equal([]byte(hotp(key, uint64(step))), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPProvisioningURI builds the otpauth:// URI that authenticator apps render as a QR code.
+func TOTPProvisioningURI(account, secret string) string {
+	issuer := config.TOTPIssuer()
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(int(totpPeriod/time.Second)))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// HashRecoveryCode normalises and hashes a recovery code for storage and lookup.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateRecoveryCodes(n int) ([]string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		for j := range b {
+			b[j] = alphabet[int(b[j])%len(alphabet)]
+		}
+		codes[i] = fmt.Sprintf("%s-%s-%s", b[0:4], b[4:8], b[8:12])
+	}
+	return codes, nil
+}
+
+// replaceRecoveryCodes deletes the user's existing recovery codes and stores a fresh set.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.AdminRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]models.AdminRecoveryCode, len(codes))
+	for i, code := range codes {
+		rows[i] = models.AdminRecoveryCode{UserID: userID, CodeHash: HashRecoveryCode(code)}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// EnrollAdminTwoFactor creates (or restarts) a pending TOTP enrolment for the user.
+// The returned secret and recovery codes are shown to the admin once and never again.
+func EnrollAdminTwoFactor(db *gorm.DB, user *models.User) (string, string, []string, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var codes []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var tf models.AdminTwoFactor
+		err := tx.Where("user_id = ?", user.ID).First(&tf).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			tf = models.AdminTwoFactor{UserID: user.ID}
+		case err != nil:
+			return err
+		case tf.Enabled:
+			return ErrTwoFactorAlreadyEnabled
+		}
+
+		tf.Secret = secret
+		tf.LastUsedStep = 0
+		if err := tx.Save(&tf).Error; err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return "", "", nil, err
+	}
+	return secret, TOTPProvisioningURI(user.StudentID, secret), codes, nil
+}
+
+// ConfirmAdminTwoFactor activates a pending enrolment once the admin proves possession of the secret.
+func ConfirmAdminTwoFactor(db *gorm.DB, userID uint, code string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var tf models.AdminTwoFactor
+		if err := tx.Where("user_id = ?", userID).First(&tf).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTwoFactorNotEnrolled
+			}
+			return err
+		}
+		if tf.Enabled {
+			return ErrTwoFactorAlreadyEnabled
+		}
+
+		step, ok := validateTOTP(tf.Secret, code, time.Now(), tf.LastUsedStep)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		now := time.Now()
+		return tx.Model(&tf).Updates(map[string]any{
+			"enabled":        true,
+			"confirmed_at":   now,
+			"last_used_step": step,
+		}).Error
+	})
+}
+
+// VerifyAdminSecondFactor accepts either a current TOTP code or an unused recovery code.
+// Recovery codes are consumed on success. After secondFactorMaxFailures wrong
+// codes in a row the user is locked out for secondFactorLockout; the
+// enrolment row is locked so concurrent guesses are all counted.
+func VerifyAdminSecondFactor(db *gorm.DB, userID uint, code string) error {
+	var result error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var tf models.AdminTwoFactor
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND enabled = ?", userID, true).First(&tf).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTwoFactorNotEnrolled
+			}
+			return err
+		}
+		now := time.Now()
+		if tf.LockedUntil != nil && now.Before(*tf.LockedUntil) {
+			result = ErrTwoFactorLocked
+			return nil
+		}
+		reset := map[string]any{"failed_attempts": 0, "locked_until": nil}
+
+		if step, ok := validateTOTP(tf.Secret, code, now, tf.LastUsedStep); ok {
+			reset["last_used_step"] = step
+			return tx.Model(&tf).Updates(reset).Error
+		}
+
+		var rc models.AdminRecoveryCode
+		err := tx.Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashRecoveryCode(code)).
+			First(&rc).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Commit the failure rather than rolling it back with the error.
+			result = ErrInvalidTwoFactorCode
+			failure := map[string]any{"failed_attempts": tf.FailedAttempts + 1}
+			if tf.FailedAttempts+1 >= secondFactorMaxFailures {
+				failure = map[string]any{"failed_attempts": 0, "locked_until": now.Add(secondFactorLockout)}
+			}
+			return tx.Model(&tf).Updates(failure).Error
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&rc).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&tf).Updates(reset).Error
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// RegenerateRecoveryCodes invalidates all previous recovery codes for an enrolled admin.
+func RegenerateRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	var codes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var tf models.AdminTwoFactor
+		if err := tx.Where("user_id = ? AND enabled = ?", userID, true).First(&tf).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTwoFactorNotEnrolled
+			}
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/a2n2k3p4/tutorium-backend/models"
+	"github.com/a2n2k3p4/tutorium-backend/storage"
+	"gorm.io/gorm"
+)
+
+// UploadURLExpiry is how long presigned upload URLs stay valid. An upload
+// started in time may still be confirmed for UploadConfirmGrace afterwards;
+// unconfirmed uploads are purged after that.
+const (
+	UploadURLExpiry    = 15 * time.Minute
+	UploadConfirmGrace = time.Hour
+)
+
+var (
+	ErrUnknownUploadPurpose = errors.New("purpose must be profile_picture, class_banner, report_picture, session_asset or report_attachment")
+	ErrUploadTargetNotFound = errors.New("upload target not found")
+	ErrUploadNotAllowed     = errors.New("you are not allowed to change this picture")
+	ErrUploadNotReceived    = errors.New("nothing has been uploaded for this upload session yet")
+	ErrUploadNotPending     = errors.New("this upload session was already confirmed or rejected")
+	ErrUploadExpired        = errors.New("this upload session has expired; start a new one")
+	ErrUploadRejected       = errors.New("the uploaded file was rejected")
+	ErrReportPictureLocked  = errors.New("a report's picture can only be changed until a moderator picks it up; add further evidence as attachments")
+)
+
+var pictureContentTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// UploadPurposeRules are the size limits and sniffed content types accepted
+// for each direct upload purpose. Session assets and report attachments are
+// checked again against the rules of their kind on confirmation.
+var UploadPurposeRules = map[string]AttachmentRule{
+	models.UploadPurposeProfilePicture:   {MaxBytes: 10 << 20, ContentTypes: pictureContentTypes},
+	models.UploadPurposeClassBanner:      {MaxBytes: 20 << 20, ContentTypes: pictureContentTypes},
+	models.UploadPurposeReportPicture:    {MaxBytes: 20 << 20, ContentTypes: pictureContentTypes},
+	models.UploadPurposeSessionAsset:     {MaxBytes: MaxSessionAssetBytes, ContentTypes: kindContentTypes(sessionAssetKinds, SessionAssetRules)},
+	models.UploadPurposeReportAttachment: {MaxBytes: ReportAttachmentRules["video"].MaxBytes, ContentTypes: kindContentTypes(reportAttachmentKinds, ReportAttachmentRules)},
+}
+
+// uploadKinds are the kinds, in auto-detection order, and per-kind rules of
+// the purposes whose uploads are stored as one of several kinds.
+var uploadKinds = map[string]struct {
+	kinds []string
+	rules map[string]AttachmentRule
+}{
+	models.UploadPurposeSessionAsset:     {sessionAssetKinds, SessionAssetRules},
+	models.UploadPurposeReportAttachment: {reportAttachmentKinds, ReportAttachmentRules},
+}
+
+// kindContentTypes lists every content type some of kinds accepts.
+func kindContentTypes(kinds []string, rules map[string]AttachmentRule) []string {
+	var types []string
+	for _, kind := range kinds {
+		for _, ct := range rules[kind].ContentTypes {
+			if !slices.Contains(types, ct) {
+				types = append(types, ct)
+			}
+		}
+	}
+	return types
+}
+
+// uploadFolders are the object key prefixes per purpose, shared with the
+// base64 uploads.
+var uploadFolders = map[string]string{
+	models.UploadPurposeProfilePicture:   "users",
+	models.UploadPurposeClassBanner:      "classes",
+	models.UploadPurposeReportPicture:    "reports",
+	models.UploadPurposeReportAttachment: "reports/attachments",
+}
+
+// AuthorizeUploadTarget checks that user may replace purpose's picture on
+// targetID: their own profile, a class they teach or a report they filed.
+// Admins may change any of them when admin is set, which the caller only does
+// for admins who passed the second factor. A report's picture is fixed once the report
+// leaves submitted, so moderators review what they triaged. Evidence may be
+// attached until the report is closed or holds MaxReportAttachments files.
+// Session assets may only be added by the session's teacher, whose quota
+// they count against.
+func AuthorizeUploadTarget(db *gorm.DB, user *models.User, admin bool, purpose string, targetID uint) error {
+	var (
+		model    any
+		allowed  func() bool
+		editable = func() error { return nil }
+		query    = db
+	)
+	switch purpose {
+	case models.UploadPurposeProfilePicture:
+		var target models.User
+		model, allowed = &target, func() bool { return target.ID == user.ID }
+	case models.UploadPurposeClassBanner:
+		var target models.Class
+		model, allowed = &target, func() bool { return IsClassTeacher(user, &target) }
+	case models.UploadPurposeReportPicture:
+		var target models.Report
+		model, allowed = &target, func() bool { return target.ReportUserID == user.ID }
+		editable = func() error {
+			if models.NormalizeReportStatus(target.ReportStatus) != models.ReportStatusSubmitted {
+				return ErrReportPictureLocked
+			}
+			return nil
+		}
+	case models.UploadPurposeReportAttachment:
+		var target models.Report
+		model, allowed = &target, func() bool { return target.ReportUserID == user.ID }
+		editable = func() error { return CheckReportOpenForEvidence(db, &target) }
+	case models.UploadPurposeSessionAsset:
+		var target models.ClassSession
+		model, allowed, query = &target, func() bool { return IsSessionTeacher(user, &target) }, db.Preload("Class")
+	default:
+		return ErrUnknownUploadPurpose
+	}
+
+	err := query.First(model, targetID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrUploadTargetNotFound
+	case err != nil:
+		return err
+	}
+	if (!admin || purpose == models.UploadPurposeSessionAsset) && !allowed() {
+		return ErrUploadNotAllowed
+	}
+	return editable()
+}
+
+// submittedReportStatuses are the stored values of a report nobody has
+// picked up yet, including the legacy "pending".
+var submittedReportStatuses = []string{models.ReportStatusSubmitted, "pending"}
+
+// uploadObjectKey is where a confirmed upload for purpose and targetID is
+// kept, matching the keys of the equivalent multipart and base64 uploads.
+func uploadObjectKey(purpose string, targetID uint, contentType string) string {
+	if purpose == models.UploadPurposeSessionAsset {
+		return fmt.Sprintf("sessions/%d/assets/%s", targetID, storage.GenerateFilename(contentType))
+	}
+	return uploadFolders[purpose] + "/" + storage.GenerateFilename(contentType)
+}
+
+// uploadStagingKey is where a client uploads a contentType file before
+// confirming it. Nothing references staging keys, so a client reusing its
+// presigned form after confirmation cannot replace a file in use.
+func uploadStagingKey(contentType string) string {
+	return "uploads/" + storage.GenerateFilename(contentType)
+}
+
+// CreateUploadSession opens a direct upload of a contentType file for req's
+// target and presigns a POST for it, limited to the purpose's size. admin is
+// passed on to AuthorizeUploadTarget.
+func CreateUploadSession(ctx context.Context, db *gorm.DB, store storage.DirectUploader, user *models.User, admin bool, req models.CreateUploadSessionRequest, now time.Time) (*models.UploadSession, error) {
+	rule, ok := UploadPurposeRules[req.Purpose]
+	if !ok {
+		return nil, ErrUnknownUploadPurpose
+	}
+	if !slices.Contains(rule.ContentTypes, req.ContentType) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAttachment, req.ContentType)
+	}
+	byKind, hasKinds := uploadKinds[req.Purpose]
+	if hasKinds && req.Kind != "" {
+		kindRule, ok := byKind.rules[req.Kind]
+		if !ok || !slices.Contains(kindRule.ContentTypes, req.ContentType) {
+			return nil, fmt.Errorf("%w: %s as %q", ErrUnsupportedAttachment, req.ContentType, req.Kind)
+		}
+		rule = kindRule
+	}
+	if err := AuthorizeUploadTarget(db, user, admin, req.Purpose, req.TargetID); err != nil {
+		return nil, err
+	}
+
+	upload := models.UploadSession{
+		UserID:      user.ID,
+		Purpose:     req.Purpose,
+		TargetID:    req.TargetID,
+		ContentType: req.ContentType,
+		MaxBytes:    rule.MaxBytes,
+		ObjectKey:   uploadStagingKey(req.ContentType),
+		Status:      models.UploadStatusPending,
+		ExpiresAt:   now.Add(UploadURLExpiry),
+	}
+	if hasKinds {
+		upload.Kind = req.Kind
+		if req.FileName != "" {
+			upload.FileName = filepath.Base(req.FileName)
+		}
+	}
+	if req.Purpose == models.UploadPurposeSessionAsset {
+		upload.Title = req.Title
+	}
+	postURL, fields, err := store.PresignedPostObject(ctx, upload.ObjectKey, upload.ContentType, upload.MaxBytes, UploadURLExpiry)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Create(&upload).Error; err != nil {
+		return nil, err
+	}
+	upload.PostURL, upload.PostFields = postURL, fields
+	return &upload, nil
+}
+
+// ConfirmUploadSession checks the object user uploaded for upload, sniffing
+// its content rather than trusting the declared type, copies it from the
+// staging key to a fresh key and stores that on the target or records it as
+// a session asset or report attachment. The upload is claimed in the same
+// transaction, so of two concurrent confirmations only one attaches the file. Objects that fail the
+// check, that do not fit in the teacher's storage quota or that arrive after
+// the report closed or filled up are removed and the session rejected.
+func ConfirmUploadSession(ctx context.Context, db *gorm.DB, store storage.DirectUploader, user *models.User, admin bool, upload *models.UploadSession, now time.Time) error {
+	if upload.UserID != user.ID {
+		return ErrUploadNotAllowed
+	}
+	if now.After(upload.ExpiresAt.Add(UploadConfirmGrace)) {
+		return ErrUploadExpired
+	}
+
+	info, err := store.StatObject(ctx, upload.ObjectKey)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return ErrUploadNotReceived
+	}
+	if err != nil {
+		return err
+	}
+	// The head is read and the copy made from the version stat'd here, so
+	// the presigned upload cannot replace the file once it has been checked.
+	head, err := store.ReadObjectHead(ctx, upload.ObjectKey, 512, info.ETag)
+	if errors.Is(err, storage.ErrObjectChanged) {
+		return rejectChangedUpload(ctx, db, store, upload)
+	}
+	if err != nil {
+		return err
+	}
+	kind := upload.Kind
+	var contentType string
+	if byKind, ok := uploadKinds[upload.Purpose]; ok {
+		kind, contentType, err = classifyUpload(head, info.Size, upload.Kind, byKind.kinds, byKind.rules)
+	} else {
+		_, contentType, err = classifyUpload(head, info.Size, upload.Purpose, nil, UploadPurposeRules)
+	}
+	if err != nil {
+		if rejErr := rejectUpload(ctx, db, store, upload); rejErr != nil {
+			return rejErr
+		}
+		return fmt.Errorf("%w: %v", ErrUploadRejected, err)
+	}
+	if err := AuthorizeUploadTarget(db, user, admin, upload.Purpose, upload.TargetID); err != nil {
+		return err
+	}
+
+	objectKey := uploadObjectKey(upload.Purpose, upload.TargetID, contentType)
+	if err := store.CopyObject(ctx, upload.ObjectKey, objectKey, info.ETag); err != nil {
+		if errors.Is(err, storage.ErrObjectChanged) {
+			return rejectChangedUpload(ctx, db, store, upload)
+		}
+		return err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.UploadSession{}).
+			Where("id = ? AND status = ?", upload.ID, models.UploadStatusPending).
+			Updates(map[string]any{
+				"status":       models.UploadStatusConfirmed,
+				"kind":         kind,
+				"content_type": contentType,
+				"size_bytes":   info.Size,
+				"object_key":   objectKey,
+				"confirmed_at": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrUploadNotPending
+		}
+
+		switch upload.Purpose {
+		case models.UploadPurposeProfilePicture:
+			return tx.Model(&models.User{}).Where("id = ?", upload.TargetID).Update("profile_picture_url", objectKey).Error
+		case models.UploadPurposeClassBanner:
+			return tx.Model(&models.Class{}).Where("id = ?", upload.TargetID).Update("banner_picture_url", objectKey).Error
+		case models.UploadPurposeReportPicture:
+			res := tx.Model(&models.Report{}).Where("id = ? AND report_status IN ?", upload.TargetID, submittedReportStatuses).
+				Update("report_picture_url", objectKey)
+			if res.Error == nil && res.RowsAffected == 0 {
+				return ErrReportPictureLocked
+			}
+			return res.Error
+		case models.UploadPurposeSessionAsset:
+			return createUploadedSessionAsset(tx, upload, objectKey, kind, contentType, info.Size, now)
+		case models.UploadPurposeReportAttachment:
+			return AddReportAttachment(tx, &models.ReportAttachment{
+				ReportID:       upload.TargetID,
+				UploaderUserID: upload.UserID,
+				Kind:           kind,
+				FileName:       upload.FileName,
+				ContentType:    contentType,
+				SizeBytes:      info.Size,
+				ObjectKey:      objectKey,
+			})
+		default:
+			return ErrUnknownUploadPurpose
+		}
+	})
+	if err != nil {
+		if rmErr := store.RemoveObject(ctx, objectKey); rmErr != nil {
+			log.Printf("Failed to remove copy of upload %d: %v", upload.ID, rmErr)
+		}
+		if errors.Is(err, ErrStorageQuotaExceeded) || errors.Is(err, ErrAttachmentLimit) || errors.Is(err, ErrReportClosed) {
+			if rejErr := rejectUpload(ctx, db, store, upload); rejErr != nil {
+				return rejErr
+			}
+		}
+		return err
+	}
+	if err := store.RemoveObject(ctx, upload.ObjectKey); err != nil {
+		log.Printf("Failed to remove staged upload %d: %v", upload.ID, err)
+	}
+
+	upload.Status = models.UploadStatusConfirmed
+	upload.Kind = kind
+	upload.ContentType = contentType
+	upload.SizeBytes = info.Size
+	upload.ObjectKey = objectKey
+	upload.ConfirmedAt = &now
+	return nil
+}
+
+// rejectUpload removes the object uploaded for upload and marks it rejected
+// unless another request confirmed it meanwhile.
+func rejectUpload(ctx context.Context, db *gorm.DB, store storage.DirectUploader, upload *models.UploadSession) error {
+	if err := store.RemoveObject(ctx, upload.ObjectKey); err != nil {
+		return err
+	}
+	return db.Model(upload).Where("status = ?", models.UploadStatusPending).Update("status", models.UploadStatusRejected).Error
+}
+
+// rejectChangedUpload rejects upload because its object was overwritten
+// while it was being checked.
+func rejectChangedUpload(ctx context.Context, db *gorm.DB, store storage.DirectUploader, upload *models.UploadSession) error {
+	if err := rejectUpload(ctx, db, store, upload); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: the file was replaced while it was being checked", ErrUploadRejected)
+}
+
+// createUploadedSessionAsset records a confirmed session_asset upload as a
+// SessionAsset of its session, stored under objectKey, within the teacher's
+// storage quota.
+func createUploadedSessionAsset(tx *gorm.DB, upload *models.UploadSession, objectKey, kind, contentType string, size int64, now time.Time) error {
+	var session models.ClassSession
+	if err := tx.Preload("Class").First(&session, upload.TargetID).Error; err != nil {
+		return err
+	}
+	asset := models.SessionAsset{
+		ClassSessionID: session.ID,
+		TeacherID:      session.Class.TeacherID,
+		Kind:           kind,
+		Title:          upload.Title,
+		FileName:       upload.FileName,
+		ContentType:    contentType,
+		SizeBytes:      size,
+		ObjectKey:      objectKey,
+	}
+	return CreateSessionAsset(tx, &asset, &session, now)
+}
+
+// PurgeAbandonedUploads removes objects uploaded for sessions that were
+// never confirmed, and the sessions themselves.
+func PurgeAbandonedUploads(db *gorm.DB, store storage.ObjectStore, now time.Time) {
+	var abandoned []models.UploadSession
+	if err := db.Where("status = ? AND expires_at < ?", models.UploadStatusPending, now.Add(-UploadConfirmGrace)).
+		Find(&abandoned).Error; err != nil {
+		log.Printf("Error finding abandoned uploads: %v", err)
+		return
+	}
+	for i := range abandoned {
+		if err := store.RemoveObject(context.Background(), abandoned[i].ObjectKey); err != nil {
+			log.Printf("Failed to remove abandoned upload %d: %v", abandoned[i].ID, err)
+			continue
+		}
+		if err := db.Unscoped().Delete(&abandoned[i]).Error; err != nil {
+			log.Printf("Failed to delete abandoned upload %d: %v", abandoned[i].ID, err)
+		}
+	}
+}
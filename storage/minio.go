@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -28,6 +29,50 @@ type Uploader interface {
 	UploadBytes(ctx context.Context, folder, filename string, b []byte) (string, error)
 }
 
+// Presigner issues temporary read URLs for stored objects.
+type Presigner interface {
+	PresignedGetObject(ctx context.Context, objectName string, expiry time.Duration) (string, error)
+}
+
+// ObjectStore streams objects into the bucket and removes them. Objects
+// larger than one part are sent as multipart uploads.
+type ObjectStore interface {
+	PutObject(ctx context.Context, objectName string, r io.Reader, size int64, contentType string) error
+	RemoveObject(ctx context.Context, objectName string) error
+}
+
+// ErrObjectNotFound is returned by StatObject when nothing is stored under
+// the name, e.g. because a client never used its presigned upload.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrObjectChanged is returned when an object no longer has the ETag it was
+// read or copied under, because it was overwritten meanwhile.
+var ErrObjectChanged = errors.New("object changed")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ETag        string
+}
+
+// DirectUploader presigns uploads so clients send files straight to the
+// bucket instead of through the API, inspects what arrived and moves it to
+// where it is kept. Reads and copies take the ETag StatObject reported, so
+// an object overwritten after it was inspected fails with ErrObjectChanged
+// instead of being moved unchecked.
+type DirectUploader interface {
+	PresignedPostObject(ctx context.Context, objectName, contentType string, maxBytes int64, expiry time.Duration) (string, map[string]string, error)
+	StatObject(ctx context.Context, objectName string) (ObjectInfo, error)
+	ReadObjectHead(ctx context.Context, objectName string, n int64, matchETag string) ([]byte, error)
+	CopyObject(ctx context.Context, srcName, dstName, matchETag string) error
+	RemoveObject(ctx context.Context, objectName string) error
+}
+
+// multipartPartSize is the part size used for streamed uploads; minio-go
+// uploads anything larger in parallel parts of this size.
+const multipartPartSize = 16 << 20
+
 func NewClientFromEnv() (*Client, error) {
 	endpoint := config.MINIOEndpoint()
 	accessKey := config.MINIOAccessKey()
@@ -78,6 +123,76 @@ func (c *Client) PresignedGetObject(ctx context.Context, objectName string, expi
 	return u.String(), nil
 }
 
+// PresignedPostObject returns the URL and form fields of a browser POST
+// upload to objectName, limited to contentType and at most maxBytes.
+func (c *Client) PresignedPostObject(ctx context.Context, objectName, contentType string, maxBytes int64, expiry time.Duration) (string, map[string]string, error) {
+	policy := minio.NewPostPolicy()
+	for _, err := range []error{
+		policy.SetBucket(c.Bucket),
+		policy.SetKey(objectName),
+		policy.SetExpires(time.Now().UTC().Add(expiry)),
+		policy.SetContentType(contentType),
+		policy.SetContentLengthRange(1, maxBytes),
+	} {
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	u, fields, err := c.Client.PresignedPostPolicy(ctx, policy)
+	if err != nil {
+		return "", nil, err
+	}
+	return u.String(), fields, nil
+}
+
+// StatObject returns objectName's size and stored content type.
+func (c *Client) StatObject(ctx context.Context, objectName string) (ObjectInfo, error) {
+	info, err := c.Client.StatObject(ctx, c.Bucket, objectName, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return ObjectInfo{}, ErrObjectNotFound
+		}
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Size: info.Size, ContentType: info.ContentType, ETag: info.ETag}, nil
+}
+
+// ReadObjectHead returns the first n bytes of objectName, enough to sniff
+// its content type without downloading it, provided it still has matchETag.
+func (c *Client) ReadObjectHead(ctx context.Context, objectName string, n int64, matchETag string) ([]byte, error) {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(0, n-1); err != nil {
+		return nil, err
+	}
+	if err := opts.SetMatchETag(matchETag); err != nil {
+		return nil, err
+	}
+	obj, err := c.Client.GetObject(ctx, c.Bucket, objectName, opts)
+	if err != nil {
+		return nil, preconditionError(err)
+	}
+	defer obj.Close()
+	b, err := io.ReadAll(io.LimitReader(obj, n))
+	return b, preconditionError(err)
+}
+
+// CopyObject copies srcName to dstName inside the bucket without
+// downloading it, provided srcName still has matchETag.
+func (c *Client) CopyObject(ctx context.Context, srcName, dstName, matchETag string) error {
+	_, err := c.Client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: c.Bucket, Object: dstName},
+		minio.CopySrcOptions{Bucket: c.Bucket, Object: srcName, MatchETag: matchETag})
+	return preconditionError(err)
+}
+
+// preconditionError reports a failed ETag match as ErrObjectChanged.
+func preconditionError(err error) error {
+	if err != nil && minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+		return ErrObjectChanged
+	}
+	return err
+}
+
 // UploadBytes uploads bytes to bucket with objectName and returns public URL.
 // folder: "users", "classes", "reports"
 // filename: "user_42_123456.png"
@@ -104,7 +219,23 @@ func (c *Client) UploadBytes(ctx context.Context, folder, filename string, b []b
 	return objectName, nil
 }
 
-// DecodeBase64Image handles data: URI or plain base64
+// PutObject streams size bytes from r to objectName.
+func (c *Client) PutObject(ctx context.Context, objectName string, r io.Reader, size int64, contentType string) error {
+	_, err := c.Client.PutObject(ctx, c.Bucket, objectName, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    multipartPartSize,
+	})
+	return err
+}
+
+// RemoveObject deletes objectName; removing a missing object is not an error.
+func (c *Client) RemoveObject(ctx context.Context, objectName string) error {
+	return c.Client.RemoveObject(ctx, c.Bucket, objectName, minio.RemoveObjectOptions{})
+}
+
+// DecodeBase64Image handles data: URI or plain base64.
+// Base64 pictures in JSON bodies are kept for existing clients; new clients
+// should upload directly through a DirectUploader (see /uploads).
 func DecodeBase64Image(s string) ([]byte, error) {
 	if s == "" {
 		return nil, nil
@@ -135,6 +266,24 @@ func GenerateFilename(contentType string) string {
 		ext = ".gif"
 	case "image/webp":
 		ext = ".webp"
+	case "video/mp4":
+		ext = ".mp4"
+	case "video/webm":
+		ext = ".webm"
+	case "audio/mpeg":
+		ext = ".mp3"
+	case "audio/wave":
+		ext = ".wav"
+	case "audio/aiff":
+		ext = ".aiff"
+	case "application/ogg":
+		ext = ".ogg"
+	case "text/plain":
+		ext = ".txt"
+	case "application/pdf":
+		ext = ".pdf"
+	case "application/zip":
+		ext = ".zip"
 	}
 	return fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
 }